@@ -38,7 +38,17 @@ func main() {
 		fatal(err)
 	}
 
-	// 4. Compilar Launcher
+	// 4. Compilar fvtool (ao lado do servidor, que guarda os mundos em servidor/saves)
+	if err := buildComponent("FVTOOL (CGO + Static)", "cmd/fvtool", "servidor/fvtool.exe", true, "-extldflags=-static -s -w"); err != nil {
+		fatal(err)
+	}
+
+	// 5. Compilar fvbot (cliente sem janela para testar o protocolo e a carga do servidor)
+	if err := buildComponent("FVBOT (CGO + Static)", "cmd/fvbot", "cliente/fvbot.exe", true, "-extldflags=-static -s -w"); err != nil {
+		fatal(err)
+	}
+
+	// 6. Compilar Launcher
 	if err := buildComponent("LAUNCHER (Pure Go)", "launcher", "FortressVision.exe", false, "-s -w"); err != nil {
 		fatal(err)
 	}
@@ -51,7 +61,7 @@ func main() {
 }
 
 func setupEnvironment() {
-	fmt.Println(ColorYellow + "\n[0/3] Configurando ambiente de compilação..." + ColorReset)
+	fmt.Println(ColorYellow + "\n[0/4] Configurando ambiente de compilação..." + ColorReset)
 
 	// Adicionar MSYS2 ao PATH se estiver no Windows
 	if runtime.GOOS == "windows" {
@@ -3,13 +3,16 @@ package app
 import (
 	"log"
 	"runtime"
+	"sync"
+	"sync/atomic"
 
 	"FortressVision/cliente/internal/camera"
-	"FortressVision/cliente/internal/client"
 	"FortressVision/cliente/internal/meshing"
 	"FortressVision/cliente/internal/render"
 	"FortressVision/shared/config"
 	"FortressVision/shared/mapdata"
+	"FortressVision/shared/pkg/fvclient"
+	"FortressVision/shared/proto/fvnet"
 	"FortressVision/shared/util"
 
 	rl "github.com/gen2brain/raylib-go/raylib"
@@ -20,7 +23,7 @@ type AppState int
 
 const (
 	StateLoading    AppState = iota // Carregando assets
-	StateMenu                       // Menu principal
+	StateMenu                       // Menu principal (seleção de mundo salvo)
 	StateConnecting                 // Conectando ao DFHack
 	StateViewing                    // Visualizando o mapa
 	StatePaused                     // Pausado
@@ -43,7 +46,7 @@ type App struct {
 
 	// Dados do mapa e comunicação
 	mapCenter   util.DFCoord
-	netClient   *client.NetworkClient
+	netClient   *fvclient.NetworkClient
 	mapStore    *mapdata.MapDataStore
 	matStore    *mapdata.MaterialStore
 	mesher      *meshing.BlockMesher
@@ -60,32 +63,45 @@ type App struct {
 	Loading                bool
 	LoadingStatus          string
 	LoadingProgress        float32
-	LoadingTotalBlocks     int  // Total de blocos esperados na carga inicial
-	LoadingProcessedBlocks int  // Total já processados e enviados para GPU
-	FullScanActive         bool // Flag para download total do mundo
+	LoadingTotalBlocks     int          // Total de blocos esperados na carga inicial
+	LoadingProcessedBlocks int          // Total já recebidos do servidor (inclui chunks de ar)
+	loadingChunksReceived  atomic.Int32 // Contador alimentado pela goroutine de rede
+	FullScanActive         bool         // Flag para download total do mundo
+	ScanRate               float32      // Blocos/s da varredura total (servidor)
+	ScanETA                float32      // Segundos restantes estimados da varredura total
+	ServerState            fvnet.ServerStatus_State
+	worldResetPending      atomic.Bool // Servidor trocou de mundo; reset feito na thread principal
+
+	// Mundos salvos no servidor (menu principal)
+	worldsMu         sync.Mutex
+	Worlds           []*fvnet.WorldInfo
+	WorldsSelectable bool        // false quando o DFHack define o mundo
+	menuRequested    atomic.Bool // Lista chegou no arranque; abre o menu na thread principal
+	menuShown        bool
+
+	// Linha do tempo (histórico de chunks do servidor, ver app_timeline.go)
+	timeline timelineState
 
 	// Estado do Mundo (DFHack)
-	WorldName        string
-	WorldYear        int32
-	WorldSeason      string
-	WorldDay         int32
-	WorldMonth       string
-	WorldPopulation  int
-	ZOffset          int32 // Diferença entre coordenada interna e Elevation do HUD
-	lastWorldUpdate  float64
-	LoadingStartTime float64 // Timestamp de quando a sincronização inicial começou
+	WorldName       string
+	WorldYear       int32
+	WorldSeason     string
+	WorldDay        int32
+	WorldMonth      string
+	WorldPopulation int
+	ZOffset         int32 // Diferença entre coordenada interna e Elevation do HUD
+	lastWorldUpdate float64
 }
 
 // New cria uma nova instância da aplicação.
 func New(cfg *config.Config) *App {
 	app := &App{
-		Config:           cfg,
-		State:            StateLoading,
-		mapCenter:        util.NewDFCoord(0, 0, 10), // Força início no nível 10
-		Loading:          true,
-		LoadingStatus:    "Conectando ao DFHack...",
-		LoadingProgress:  0.1,
-		LoadingStartTime: rl.GetTime(),
+		Config:          cfg,
+		State:           StateLoading,
+		mapCenter:       util.NewDFCoord(0, 0, 10), // Força início no nível 10
+		Loading:         true,
+		LoadingStatus:   "Conectando ao DFHack...",
+		LoadingProgress: 0.1,
 	}
 	return app
 }
@@ -124,6 +140,7 @@ func (a *App) Run() {
 
 	// Inicializar sistemas novos
 	a.mapStore = mapdata.NewMapDataStore()
+	a.mapStore.MemoryBudget = a.Config.ChunkCacheMB << 20
 	a.matStore = mapdata.NewMaterialStore()
 	a.resultStore = meshing.NewResultStore()
 
@@ -156,19 +173,32 @@ func (a *App) update() {
 
 	switch a.State {
 	case StateViewing:
+		if a.worldResetPending.Swap(false) {
+			a.resetWorld()
+		}
+		if a.menuRequested.Swap(false) && !a.menuShown {
+			a.menuShown = true
+			a.State = StateMenu
+		}
 		a.renderer.ProcessPurge() // Limpeza incremental da GPU
-		// RAM Streaming: Executa a purga apenas a cada 120 frames (2s a 60fps)
+		// RAM Streaming: confere o orçamento do cache a cada 120 frames (2s a 60fps).
+		// A região pedida ao servidor fica fixada (ver updateMap), o resto sai por LRU.
 		if a.frameCount%120 == 0 {
-			// Purga desativada para Unlimited Vision
-			// a.mapStore.Purge(a.mapCenter, 256.0)
+			go a.mapStore.EnforceBudget()
 		}
 		a.handleAutoSave() // Salvamento periódico (SQLite)
+		a.updateTimeline() // Antes da câmera: arrastar a barra não gira a visão
 		a.updateCamera()
 		a.updateInput()
 		a.updateMap(false)
 		a.processMesherResults()
 	case StatePaused:
 		a.updateInput() // Permite detectar ESC para despausar
+	case StateMenu:
+		if a.worldResetPending.Swap(false) {
+			a.resetWorld()
+		}
+		a.updateInput() // ESC fecha o menu
 	}
 }
 
@@ -177,7 +207,11 @@ func (a *App) shutdown() {
 	log.Println("[App] Finalizando aplicação...")
 
 	// Salvar progresso automaticamente ao fechar
-	// A persistência agora é responsabilidade do servidor.
+	// O mundo é persistido pelo servidor; aqui só o cache local do cliente
+	if a.netClient != nil {
+		a.saveDiskCache(a.viewRegion().Center)
+		a.netClient.Close()
+	}
 
 	a.mapStore.Close() // Fecha SQLite
 
@@ -0,0 +1,57 @@
+package app
+
+import (
+	"log"
+
+	"FortressVision/shared/pkg/fvclient"
+	"FortressVision/shared/util"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// openDiskCache liga o cache local de chunks (disk_cache na configuração). O
+// mundo pedido com -world, ou o último visto neste servidor, aparece na hora,
+// antes da conexão; se o servidor servir outro mundo, o cliente troca de cache
+// sozinho e o mapa é recarregado como numa troca de mundo.
+func (a *App) openDiskCache() {
+	if !a.Config.DiskCache {
+		return
+	}
+	world := a.Config.World
+	if world == "" {
+		world = fvclient.LastCachedWorld(a.Config.ServerURL)
+	}
+	known, err := a.netClient.EnableCache(world)
+	if err != nil {
+		log.Printf("[Cache] Cache local indisponível: %v", err)
+		return
+	}
+	if known == 0 {
+		return
+	}
+
+	center, ok := a.netClient.CachedView()
+	if !ok {
+		return
+	}
+	a.WorldName = world
+	a.mapCenter.Z = center.Z
+	a.Cam.SetTarget(rl.Vector3(util.DFToWorldPos(center)))
+	if shown := a.netClient.LoadCachedRegion(a.viewAround(center)); shown > 0 {
+		a.Loading = false
+		log.Printf("[Cache] %s aberto do cache local: %d chunks na tela antes da conexão", world, shown)
+	}
+}
+
+// saveDiskCache grava no cache local os chunks recebidos e a posição view da câmera.
+func (a *App) saveDiskCache(view util.DFCoord) {
+	if a.netClient == nil {
+		return
+	}
+	count, err := a.netClient.SaveCache(view)
+	if err != nil {
+		log.Printf("[Cache] ERRO ao gravar o cache local: %v", err)
+	} else if count > 0 {
+		log.Printf("[Cache] %d chunks gravados no cache local", count)
+	}
+}
@@ -0,0 +1,93 @@
+package app
+
+import (
+	"fmt"
+
+	"FortressVision/shared/mapdata"
+	"FortressVision/shared/util"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// diffColors dá a cor de cada tipo de mudança na sobreposição, em ordem de prioridade
+// (um tile escavado e inundado aparece como escavado).
+var diffColors = []struct {
+	kind  mapdata.DiffKind
+	label string
+	color rl.Color
+}{
+	{mapdata.DiffConstructed, "Construído", rl.NewColor(70, 140, 255, 110)},
+	{mapdata.DiffDug, "Escavado", rl.NewColor(255, 150, 40, 110)},
+	{mapdata.DiffFlooded, "Inundado", rl.NewColor(40, 220, 220, 110)},
+	{mapdata.DiffRevealed, "Revelado", rl.NewColor(120, 230, 90, 70)},
+}
+
+// toggleDiffOverlay liga ou desliga a sobreposição de diferenças da linha do tempo:
+// os tiles que mudaram entre o instante da barra e o estado atual ficam coloridos.
+func (a *App) toggleDiffOverlay() {
+	t := &a.timeline
+	if !t.Active {
+		return
+	}
+	t.showDiff = !t.showDiff
+	t.diff.Store(nil)
+	if t.showDiff {
+		t.dirty = true // Pede a diferença junto com a próxima região
+	}
+}
+
+// diffColor escolhe a cor de um tile da sobreposição.
+func diffColor(kind mapdata.DiffKind) rl.Color {
+	for _, c := range diffColors {
+		if kind&c.kind != 0 {
+			return c.color
+		}
+	}
+	return rl.Blank
+}
+
+// drawDiffOverlay desenha os tiles alterados (chamado dentro do modo 3D).
+func (a *App) drawDiffOverlay() {
+	t := &a.timeline
+	if !t.Active || !t.showDiff {
+		return
+	}
+	diff := t.diff.Load()
+	if diff == nil {
+		return
+	}
+	for _, tile := range diff.Tiles {
+		pos := rl.Vector3(util.DFToWorldCenter(util.NewDFCoord(tile.X, tile.Y, tile.Z)))
+		pos.Y += 0.5
+		rl.DrawCube(pos, 1.02, 1.02, 1.02, diffColor(mapdata.DiffKind(tile.Kind)))
+	}
+}
+
+// drawDiffLegend desenha a legenda da sobreposição com a contagem de cada tipo.
+func (a *App) drawDiffLegend(x, y int32) {
+	t := &a.timeline
+	if !t.showDiff {
+		rl.DrawText("C: Mostrar mudanças até hoje", x, y, 12, rl.Gray)
+		return
+	}
+	diff := t.diff.Load()
+	if diff == nil {
+		rl.DrawText("Comparando com o estado atual...", x, y, 12, rl.LightGray)
+		return
+	}
+
+	var totals [4]int32
+	for _, level := range diff.Levels {
+		for i, n := range []int32{level.Constructed, level.Dug, level.Flooded, level.Revealed} {
+			totals[i] += n
+		}
+	}
+	for i, c := range diffColors {
+		text := fmt.Sprintf("%s: %d", c.label, totals[i])
+		color := c.color
+		color.A = 255
+		rl.DrawRectangle(x, y+1, 10, 10, color)
+		rl.DrawText(text, x+14, y, 12, rl.LightGray)
+		x += 14 + rl.MeasureText(text, 12) + 16
+	}
+}
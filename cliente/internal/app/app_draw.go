@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"log"
 	"runtime"
+	"time"
 
 	"FortressVision/shared/pkg/dfproto"
+	"FortressVision/shared/pkg/fvclient"
 	"FortressVision/shared/util"
 
 	rl "github.com/gen2brain/raylib-go/raylib"
@@ -16,11 +18,14 @@ func (a *App) draw() {
 	rl.BeginDrawing()
 	rl.ClearBackground(rl.NewColor(30, 30, 40, 255))
 
-	if a.Loading {
+	if a.State == StateMenu {
+		a.drawMainMenu()
+	} else if a.Loading {
 		a.drawLoadingScreen()
 	} else {
 		a.drawScene()
 		a.drawHUD()
+		a.drawTimeline()
 
 		if a.State == StatePaused {
 			a.drawPauseMenu()
@@ -49,6 +54,9 @@ func (a *App) drawScene() {
 		}
 	}
 
+	// Mudanças destacadas na linha do tempo (translúcidas, por último)
+	a.drawDiffOverlay()
+
 	rl.EndMode3D()
 }
 
@@ -60,7 +68,7 @@ func (a *App) drawHUD() {
 
 	// Fundo semi-transparente para o debug (Aumentado para Fase 9)
 	width := int32(340)
-	height := int32(240)
+	height := int32(295)
 	x := int32(rl.GetScreenWidth()) - width - 10
 	y := int32(10)
 
@@ -98,18 +106,16 @@ func (a *App) drawHUD() {
 	// Informações de Localização
 	rl.DrawText("LOCALIZAÇÃO", x+10, y+45, 12, rl.Gray)
 
-	dfCoord := util.WorldToDFCoord(a.Cam.CurrentLookAt)
+	dfCoord := util.WorldToDFCoord(util.Vector3(a.Cam.CurrentLookAt))
 	dfCoord.Z = a.mapCenter.Z
 
 	rl.DrawText(fmt.Sprintf("Coord DF: (%d, %d, %d)", dfCoord.X, dfCoord.Y, dfCoord.Z), x+10, y+60, 16, rl.White)
 
 	elevation := dfCoord.Z - a.ZOffset
-	syncStatus := "Offline"
-	if a.netClient != nil && a.netClient.IsConnected() {
-		syncStatus = "Conectado"
-	}
-
-	rl.DrawText(fmt.Sprintf("Elevação: %d (Offset:%d) [%s]", elevation, a.ZOffset, syncStatus), x+10, y+80, 14, rl.LightGray)
+	syncStatus, syncColor := a.connectionStatus()
+	elevationText := fmt.Sprintf("Elevação: %d (Offset:%d) ", elevation, a.ZOffset)
+	rl.DrawText(elevationText, x+10, y+80, 14, rl.LightGray)
+	rl.DrawText(fmt.Sprintf("[%s]", syncStatus), x+10+rl.MeasureText(elevationText, 14), y+80, 14, syncColor)
 
 	// Divisor
 	rl.DrawLine(x+10, y+100, x+width-10, y+100, rl.NewColor(100, 100, 100, 100))
@@ -138,6 +144,25 @@ func (a *App) drawHUD() {
 	}
 	rl.DrawText(fmt.Sprintf("F7: Clima | F11: Tela Cheia | F3: HUD%s", wireframeExtra), x+10, y+205, 14, rl.SkyBlue)
 
+	// Divisor
+	rl.DrawLine(x+10, y+225, x+width-10, y+225, rl.NewColor(100, 100, 100, 100))
+
+	// Qualidade da Conexão (PING/PONG)
+	rl.DrawText("REDE", x+10, y+235, 12, rl.Gray)
+	if a.netClient != nil && a.netClient.State() == fvclient.StateConnected {
+		st := a.netClient.Stats()
+		quality, qualityColor := connectionQuality(st)
+		rl.DrawText(quality, x+50, y+235, 12, qualityColor)
+		rl.DrawText(fmt.Sprintf("RTT: %v (méd %v, mín %v) Jitter: %v",
+			st.RTT.Round(time.Millisecond), st.AvgRTT.Round(time.Millisecond),
+			st.MinRTT.Round(time.Millisecond), st.Jitter.Round(time.Millisecond)), x+10, y+250, 14, rl.LightGray)
+		rl.DrawText(fmt.Sprintf("Pings: %d/%d | Descartadas: %d | Falhas: %d",
+			st.PongsReceived, st.PingsSent, st.Dropped, st.DecodeErrors), x+10, y+268, 14, rl.LightGray)
+	} else {
+		status, statusColor := a.connectionStatus()
+		rl.DrawText(status, x+50, y+235, 12, statusColor)
+	}
+
 	// Título no canto inferior direito
 	title := "FortressVision v0.1.0 - Alpha"
 	titleWidth := rl.MeasureText(title, 18)
@@ -160,7 +185,7 @@ func (a *App) drawSelectedTileInfo() {
 	width := int32(280)
 	height := int32(180)
 	x := int32(rl.GetScreenWidth()) - width - 10
-	y := int32(260) // Abaixo do HUD principal (240 + 10 margem + 10 respiro)
+	y := int32(315) // Abaixo do HUD principal (295 + 10 margem + 10 respiro)
 
 	// Fundo semi-transparente
 	rl.DrawRectangle(x, y, width, height, rl.NewColor(0, 0, 0, 200))
@@ -232,7 +257,7 @@ func (a *App) drawPauseMenu() {
 
 	// 2. Painel Central
 	panelWidth := int32(400)
-	panelHeight := int32(300)
+	panelHeight := int32(355)
 	panelX := (screenWidth - panelWidth) / 2
 	panelY := (screenHeight - panelHeight) / 2
 
@@ -259,8 +284,13 @@ func (a *App) drawPauseMenu() {
 		// Por enquanto exibe apenas info, mas poderia abrir submenu
 	}
 
+	// Botão: MUNDOS SALVOS
+	if a.drawButton(buttonX, panelY+200, buttonWidth, buttonHeight, "MUNDOS SALVOS", rl.SkyBlue) {
+		a.openWorldMenu()
+	}
+
 	// Botão: SAIR
-	if a.drawButton(buttonX, panelY+200, buttonWidth, buttonHeight, "SAIR DO JOGO", rl.Red) {
+	if a.drawButton(buttonX, panelY+255, buttonWidth, buttonHeight, "SAIR DO JOGO", rl.Red) {
 		// Para fechar via código no Raylib/Go, precisamos sinalizar o loop principal
 		// mas aqui podemos apenas chamar o cleanup e sair
 		a.shutdown()
@@ -318,6 +348,14 @@ func (a *App) drawLoadingScreen() {
 	rl.DrawRectangle(barX, barY, int32(float32(barWidth)*a.LoadingProgress), barHeight, rl.Orange)
 	rl.DrawRectangleLines(barX, barY, barWidth, barHeight, rl.White)
 
+	// Vazão e tempo restante da varredura total (informados pelo servidor)
+	if a.FullScanActive && a.ScanRate > 0 {
+		eta := int(a.ScanETA)
+		rate := fmt.Sprintf("%.0f blocos/s · ETA %02d:%02d:%02d", a.ScanRate, eta/3600, (eta/60)%60, eta%60)
+		rateWidth := rl.MeasureText(rate, 16)
+		rl.DrawText(rate, (screenWidth-rateWidth)/2, barY-24, 16, rl.SkyBlue)
+	}
+
 	// Status
 	statusWidth := rl.MeasureText(a.LoadingStatus, 18)
 	rl.DrawText(a.LoadingStatus, (screenWidth-statusWidth)/2, barY+45, 18, rl.LightGray)
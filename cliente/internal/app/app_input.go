@@ -15,7 +15,7 @@ func (a *App) updateCamera() {
 	dt := rl.GetFrameTime()
 
 	// Processa input (WASD, Mouse, Zoom)
-	if a.Cam.HandleInput(dt) {
+	if !a.timeline.dragging && a.Cam.HandleInput(dt) {
 		a.lastManualMove = int64(rl.GetTime() * 1000) // Converte segundos para ms
 	}
 
@@ -93,6 +93,11 @@ func (a *App) updateInput() {
 		}
 	}
 
+	// Linha do tempo com H (histórico de construção da fortaleza)
+	if rl.IsKeyPressed(rl.KeyH) {
+		a.toggleTimeline()
+	}
+
 	// Fullscreen toggle
 	if rl.IsKeyPressed(rl.KeyF11) {
 		rl.ToggleFullscreen()
@@ -115,7 +120,7 @@ func (a *App) updateInput() {
 		if a.State == StateViewing {
 			a.State = StatePaused
 			log.Println("[App] Jogo Pausado")
-		} else if a.State == StatePaused {
+		} else if a.State == StatePaused || a.State == StateMenu {
 			a.State = StateViewing
 			log.Println("[App] Retomando Jogo")
 		}
@@ -4,7 +4,7 @@ import (
 	"fmt"
 	"log"
 
-	"FortressVision/shared/util"
+	"FortressVision/shared/mapdata"
 
 	rl "github.com/gen2brain/raylib-go/raylib"
 )
@@ -16,13 +16,20 @@ func (a *App) handleAutoSave() {
 	if currentTime-a.lastAutoSaveTime >= 60.0 {
 		a.lastAutoSaveTime = currentTime
 
-		// O Auto-save do SQLite agora é responsabilidade do Servidor.
-		// O cliente pode salvar seu próprio cache se for necessário futuramente.
+		// O mundo é persistido pelo Servidor; o cliente grava só o seu cache local (ver app_cache.go)
+		go a.saveDiskCache(a.viewRegion().Center)
 	}
 }
 
 func (a *App) updateMap(force bool) {
-	if a.netClient == nil || !a.netClient.IsConnected() {
+	if a.netClient == nil {
+		return
+	}
+	if !a.netClient.IsConnected() {
+		// Sem conexão, a câmera ainda navega pelo que está no cache local
+		if a.frameCount%60 == 0 {
+			a.netClient.LoadCachedRegion(a.viewRegion())
+		}
 		return
 	}
 
@@ -35,21 +42,20 @@ func (a *App) updateMap(force bool) {
 		return
 	}
 
-	// Determina a posição central atual baseada na câmera
-	center := util.WorldToDFCoord(a.Cam.CurrentLookAt)
-	center.Z = a.mapCenter.Z
-
-	// Solicita região ao servidor
-	radius := int32(64) // Raio de cobertura
+	view := a.viewRegion()
 
 	// Inicializa o total esperado para a tela de carregamento (apenas na primeira vez)
 	if a.Loading && a.LoadingTotalBlocks == 0 {
-		blocksPerSide := (radius * 2) / 16
-		a.LoadingTotalBlocks = int(blocksPerSide * blocksPerSide)
+		a.LoadingTotalBlocks = len(view.Chunks())
 		log.Printf("[App] Esperando %d blocos para concluir sincronização inicial", a.LoadingTotalBlocks)
 	}
 
-	a.netClient.RequestRegion(center, radius)
+	if a.timeline.Active {
+		a.requestTimelineRegion(view.Center, view.Radius)
+	} else {
+		a.netClient.RequestView(view)
+	}
+	a.mapStore.PinRegion(a, mapdata.RegionAround(view.Center, view.Radius, view.Center.Z-view.MinZ, view.MaxZ-view.Center.Z))
 }
 
 // processMesherResults consome resultados da fila e envia para a GPU.
@@ -72,44 +78,62 @@ func (a *App) processMesherResults() {
 
 		select {
 		case res := <-a.mesher.Results():
+			// Resultados pendentes de um mundo anterior são descartados
+			if _, ok := a.mapStore.GetChunk(res.Origin); !ok {
+				continue
+			}
 			if len(res.Terreno.Vertices) > 0 || len(res.Liquidos.Vertices) > 0 || len(res.MaterialGeometries) > 0 {
 				log.Printf("[Renderer] Upload de Geometria: %s (Terreno: %d, Água: %d, Texturas: %d tipos)",
 					res.Origin.String(), len(res.Terreno.Vertices)/3, len(res.Liquidos.Vertices)/3, len(res.MaterialGeometries))
 			}
 			a.renderer.UploadResult(res)
-
-			// Lógica de progresso do Loading baseada em novos blocos
-			if a.Loading && !a.FullScanActive {
-				a.LoadingProcessedBlocks++
-				// Calcula progresso visual genérico
-				if a.LoadingTotalBlocks > 0 {
-					a.LoadingProgress = float32(a.LoadingProcessedBlocks) / float32(a.LoadingTotalBlocks)
-					a.LoadingStatus = fmt.Sprintf("Construindo terreno: %d/%d (%.1f%%)",
-						a.LoadingProcessedBlocks, a.LoadingTotalBlocks, a.LoadingProgress*100)
-				}
-			}
 		default:
-			// Se não há mais resultados, mas estamos no loading, verificamos o FailSafe temporal aqui
 			if a.Loading {
-				timeSinceSync := rl.GetTime() - a.LoadingStartTime
-				loadThreshold := float32(0.35) // Ajustado para 35%
-
-				// Condições de término: (Progresso OK OR Tempo limite atingido) AND Não estar em varredura total
-				reachedThreshold := a.LoadingTotalBlocks > 0 && float32(a.LoadingProcessedBlocks)/float32(a.LoadingTotalBlocks) >= loadThreshold
-				failedTimeout := timeSinceSync > 20.0 // Aumentado para 20s de margem de segurança
-
-				// Só saímos do loading se não houver varredura total ativa
-				if !a.FullScanActive && (reachedThreshold || failedTimeout) {
-					a.Loading = false
-					a.LoadingProgress = 1.0
-					log.Printf("[App] Loading concluído! (%d blocos processados em %.1fs). FailSafe: %v",
-						a.LoadingProcessedBlocks, timeSinceSync, failedTimeout)
-				}
+				a.updateLoadingProgress()
 			}
 			return
 		}
 	}
 }
 
+// updateLoadingProgress avança a tela de loading pelos chunks já respondidos pelo servidor.
+// Sai do loading ao atingir o limiar, desde que não haja varredura total em andamento.
+func (a *App) updateLoadingProgress() {
+	a.LoadingProcessedBlocks = int(a.loadingChunksReceived.Load())
+	if a.FullScanActive || a.LoadingTotalBlocks == 0 {
+		return
+	}
+
+	loadThreshold := float32(0.35) // Ajustado para 35%
+	a.LoadingProgress = min(float32(a.LoadingProcessedBlocks)/float32(a.LoadingTotalBlocks), 1.0)
+	a.LoadingStatus = fmt.Sprintf("Construindo terreno: %d/%d (%.1f%%)",
+		a.LoadingProcessedBlocks, a.LoadingTotalBlocks, a.LoadingProgress*100)
+
+	if a.LoadingProgress >= loadThreshold {
+		a.Loading = false
+		a.LoadingProgress = 1.0
+		log.Printf("[App] Loading concluído! (%d de %d blocos recebidos)", a.LoadingProcessedBlocks, a.LoadingTotalBlocks)
+	}
+}
+
+// resetWorld descarta toda a geometria e caches do mundo anterior e volta à tela de loading.
+// Chamado na thread principal, pois libera recursos de GPU.
+func (a *App) resetWorld() {
+	log.Println("[App] Servidor trocou de mundo. Descartando caches locais...")
+	a.renderer.Reset()
+	a.resultStore.Clear() // mapStore já foi limpo pela goroutine de rede, na ordem das mensagens
+
+	a.Loading = true
+	a.LoadingProgress = 0
+	a.LoadingStatus = "Carregando novo mundo..."
+	a.LoadingTotalBlocks = 0
+	a.LoadingProcessedBlocks = 0
+	a.loadingChunksReceived.Store(0)
+	a.initialZSyncDone = false
+	a.SelectedCoord = nil
+
+	a.updateMap(true)
+}
+
 // updateWorldStatus agora pode ser alimentado pelo servidor futuramente.
 func (a *App) updateWorldStatus() {}
@@ -0,0 +1,100 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"FortressVision/shared/proto/fvnet"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// openWorldMenu abre o menu principal e pede a lista atualizada de mundos ao servidor.
+func (a *App) openWorldMenu() {
+	if a.netClient != nil {
+		a.netClient.RequestWorldList()
+	}
+	a.menuShown = true
+	a.State = StateMenu
+}
+
+// drawMainMenu desenha o menu principal com a lista de mundos salvos no servidor.
+func (a *App) drawMainMenu() {
+	screenWidth := int32(rl.GetScreenWidth())
+	screenHeight := int32(rl.GetScreenHeight())
+
+	rl.DrawRectangle(0, 0, screenWidth, screenHeight, rl.NewColor(20, 20, 25, 255))
+
+	title := "FORTRESSVISION"
+	titleWidth := rl.MeasureText(title, 40)
+	rl.DrawText(title, (screenWidth-titleWidth)/2, 40, 40, rl.Gold)
+
+	a.worldsMu.Lock()
+	worlds := a.Worlds
+	selectable := a.WorldsSelectable
+	a.worldsMu.Unlock()
+
+	subtitle := "Escolha um mundo salvo"
+	if !selectable {
+		subtitle = "O servidor não permite trocar de mundo"
+	}
+	subWidth := rl.MeasureText(subtitle, 18)
+	rl.DrawText(subtitle, (screenWidth-subWidth)/2, 95, 18, rl.LightGray)
+
+	listWidth := int32(640)
+	listX := (screenWidth - listWidth) / 2
+	rowHeight := int32(56)
+	y := int32(140)
+
+	if len(worlds) == 0 {
+		msg := "Nenhum mundo salvo encontrado em saves/"
+		msgWidth := rl.MeasureText(msg, 18)
+		rl.DrawText(msg, (screenWidth-msgWidth)/2, y+20, 18, rl.Gray)
+		y += rowHeight
+	}
+
+	for _, w := range worlds {
+		// Não ultrapassa o espaço reservado ao botão de continuar
+		if y+rowHeight > screenHeight-90 {
+			break
+		}
+
+		color := rl.Gray
+		if w.Active {
+			color = rl.Gold
+		} else if selectable {
+			color = rl.SkyBlue
+		}
+
+		label := w.Name
+		if w.Live {
+			label += " (ao vivo)"
+		}
+		if w.Active {
+			label += " (atual)"
+		}
+		if a.drawButton(listX, y, listWidth, rowHeight-26, label, color) {
+			a.selectWorld(w, selectable)
+		}
+
+		details := fmt.Sprintf("%dx%dx%d blocos · %d chunks · %s · formato v%d",
+			w.SizeX, w.SizeY, w.SizeZ, w.ChunkCount,
+			time.Unix(w.UpdatedAt, 0).Format("02/01/2006 15:04"), w.FormatVersion)
+		rl.DrawText(details, listX+8, y+rowHeight-22, 14, rl.Gray)
+		y += rowHeight
+	}
+
+	if a.drawButton((screenWidth-300)/2, screenHeight-70, 300, 40, "CONTINUAR (ESC)", rl.Green) {
+		a.State = StateViewing
+	}
+}
+
+// selectWorld pede a troca de mundo ao servidor; a troca efetiva chega via WORLD_CHANGED.
+func (a *App) selectWorld(w *fvnet.WorldInfo, selectable bool) {
+	if !w.Active && selectable && a.netClient != nil {
+		log.Printf("[App] Mundo selecionado no menu: %s", w.Name)
+		a.netClient.SelectWorld(w.Name)
+	}
+	a.State = StateViewing
+}
@@ -3,11 +3,12 @@ package app
 import (
 	"fmt"
 	"log"
+	"time"
 
-	"FortressVision/cliente/internal/client"
 	"FortressVision/cliente/internal/liquid"
 	"FortressVision/cliente/internal/meshing"
 	"FortressVision/shared/pkg/dfproto"
+	"FortressVision/shared/pkg/fvclient"
 	"FortressVision/shared/proto/fvnet"
 	"FortressVision/shared/util"
 
@@ -22,38 +23,47 @@ func (a *App) connectServer() {
 		}
 	}()
 
-	a.netClient = client.NewNetworkClient(a.Config.ServerURL, a.mapStore)
+	a.netClient = fvclient.NewNetworkClient(fvclient.WorldURL(a.Config.ServerURL, a.Config.World), a.mapStore)
 
 	// Callbacks
-	a.netClient.OnStatus = func(msg string, dfConnected bool) {
-		// Detecta progresso de varredura total
-		if len(msg) > 10 && msg[:10] == "FULL_SCAN:" {
-			// Resetamos o timer de failsafe do loading sempre que houver progresso
-			a.LoadingStartTime = rl.GetTime()
-
-			status := msg[10:]
-			if status == "DONE" {
-				a.FullScanActive = false
-				a.LoadingStatus = "Mundo sincronizado!"
-			} else {
-				a.FullScanActive = true
-				var current, total int
-				fmt.Sscanf(status, "%d/%d", &current, &total)
-				if total > 0 {
-					a.LoadingProgress = float32(current) / float32(total)
-					a.LoadingStatus = fmt.Sprintf("Primeiro arranque do mundo. Isso pode levar alguns minutos...\nVarredura: Nível Z %d de %d (%.1f%%)", current, total, a.LoadingProgress*100)
-				}
+	a.netClient.OnStatus = func(status *fvnet.ServerStatus) {
+		a.ServerState = status.State
+		log.Printf("[Server] Status: %v - %s (DF: %v)", status.State, status.Message, status.DfConnected)
+	}
+
+	a.netClient.OnScanProgress = func(p *fvnet.ScanProgress) {
+		a.ScanRate = p.BlocksPerSec
+		a.ScanETA = p.EtaSeconds
+
+		switch p.Phase {
+		case fvnet.ScanProgress_SCANNING:
+			a.FullScanActive = true
+			if p.Total > 0 {
+				current := util.Min(p.Current+1, p.Total)
+				a.LoadingProgress = float32(p.Current) / float32(p.Total)
+				a.LoadingStatus = fmt.Sprintf("Primeiro arranque do mundo. Isso pode levar alguns minutos...\nVarredura: Nível Z %d de %d (%.1f%%)", current, p.Total, a.LoadingProgress*100)
 			}
-			return
+		case fvnet.ScanProgress_PAUSED:
+			// DFHack caiu: o que já está no cache continua navegável
+			a.FullScanActive = false
+			a.LoadingStatus = "Varredura pausada - DFHack desconectado"
+		case fvnet.ScanProgress_DONE:
+			a.FullScanActive = false
+			a.LoadingStatus = "Mundo sincronizado!"
+		default:
+			a.FullScanActive = false
 		}
-		log.Printf("[Server] Status: %s (DF: %v)", msg, dfConnected)
 	}
 
 	a.netClient.OnMapChunk = func(origin util.DFCoord) {
-		a.mapStore.Mu.RLock()
-		chunk, exists := a.mapStore.Chunks[origin]
-		a.mapStore.Mu.RUnlock()
+		// Todo chunk pedido recebe resposta (inclusive os de ar), então contamos aqui o progresso do loading
+		a.loadingChunksReceived.Add(1)
 
+		chunk, exists := a.mapStore.GetChunk(origin)
+
+		if exists {
+			liquid.TraceChunk(chunk)
+		}
 		if exists && a.mesher != nil {
 			liquid.TraceEnqueue(origin.X, origin.Y, origin.Z, chunk.MTime)
 			a.mesher.Enqueue(meshing.Request{
@@ -87,7 +97,7 @@ func (a *App) connectServer() {
 
 				// Atualiza posição alvo da câmera
 				targetCoord := util.NewDFCoord(status.ViewX, status.ViewY, status.ViewZ)
-				newTarget := util.DFToWorldPos(targetCoord)
+				newTarget := rl.Vector3(util.DFToWorldPos(targetCoord))
 
 				// Se for a primeira vez ou estiver muito longe, move a câmera
 				dist := rl.Vector3Distance(a.Cam.TargetLookAt, newTarget)
@@ -99,6 +109,31 @@ func (a *App) connectServer() {
 		}
 	}
 
+	a.netClient.OnWorldChanged = func(msg *fvnet.WorldChanged) {
+		a.worldResetPending.Store(true)
+	}
+
+	a.netClient.OnWorldList = func(list *fvnet.WorldList) {
+		a.worldsMu.Lock()
+		a.Worlds = list.Worlds
+		a.WorldsSelectable = list.Selectable
+		a.worldsMu.Unlock()
+
+		// No modo offline com mais de um save, o usuário escolhe o mundo ao iniciar
+		// (a não ser que já tenha pedido um mundo com -world)
+		if list.Selectable && len(list.Worlds) > 1 && a.Config.World == "" &&
+			a.ServerState == fvnet.ServerStatus_OFFLINE_CACHE {
+			a.menuRequested.Store(true)
+		}
+	}
+
+	a.netClient.OnHistoryInfo = func(info *fvnet.HistoryInfo) {
+		a.timeline.info.Store(info)
+	}
+	a.netClient.OnDiff = func(diff *fvnet.DiffResult) {
+		a.timeline.diff.Store(diff)
+	}
+
 	a.netClient.OnTiletypes = func(list *dfproto.TiletypeList) {
 		a.mapStore.Mu.Lock()
 		for _, tt := range list.TiletypeList {
@@ -116,12 +151,49 @@ func (a *App) connectServer() {
 		}()
 	}
 
+	// Terreno do cache local na tela enquanto a conexão não sai
+	a.openDiskCache()
+
 	if err := a.netClient.Connect(); err != nil {
 		log.Printf("[Server] Erro ao conectar: %v", err)
-		a.LoadingStatus = "Erro ao conectar ao Servidor. Verifique se o servidor está rodando."
+		a.LoadingStatus = "Erro ao conectar ao Servidor. Verifique se o servidor está rodando.\nTentando de novo em segundo plano..."
+		a.netClient.Reconnect()
 		return
 	}
 
 	log.Println("[Network] Conectado ao Servidor FortressVision!")
 	a.LoadingStatus = "Sincronizando com o mundo..."
 }
+
+// connectionStatus descreve o estado da conexão com o servidor para o HUD.
+func (a *App) connectionStatus() (string, rl.Color) {
+	if a.netClient == nil {
+		return "Offline", rl.Gray
+	}
+	switch state := a.netClient.State(); state {
+	case fvclient.StateConnected:
+		return state.String(), rl.Green
+	case fvclient.StateReconnecting:
+		attempt, retryIn := a.netClient.ReconnectStatus()
+		return fmt.Sprintf("Reconectando #%d (%.0fs)", attempt, retryIn.Seconds()), rl.Orange
+	default:
+		return state.String(), rl.Gray
+	}
+}
+
+// connectionQuality classifica a conexão pelo RTT médio e pelo silêncio desde o
+// último PONG, para o HUD.
+func connectionQuality(st fvclient.ConnStats) (string, rl.Color) {
+	switch {
+	case st.SinceLastPong == 0 || st.SinceLastPong > 5*time.Second:
+		return "Sem resposta", rl.Red
+	case st.PongsReceived == 0:
+		return "Sem RTT (servidor antigo)", rl.Gray
+	case st.AvgRTT < 80*time.Millisecond && st.Jitter < 20*time.Millisecond:
+		return "Boa", rl.Green
+	case st.AvgRTT < 250*time.Millisecond:
+		return "Regular", rl.Yellow
+	default:
+		return "Ruim", rl.Red
+	}
+}
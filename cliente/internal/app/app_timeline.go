@@ -0,0 +1,207 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"FortressVision/shared/mapdata"
+	"FortressVision/shared/proto/fvnet"
+	"FortressVision/shared/util"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// timelapseSeconds é quanto dura a reprodução do histórico inteiro (do início ao fim).
+const timelapseSeconds = 60.0
+
+// timelineState guarda o estado da linha do tempo (histórico de chunks do servidor).
+// Enquanto ela está aberta, o mapa ao vivo fica congelado e a região da câmera
+// é pedida como estava no instante escolhido na barra.
+type timelineState struct {
+	Active   bool
+	Playing  bool
+	Pos      float32 // 0 = primeira versão gravada, 1 = última
+	dragging bool
+	dirty    bool // Pos mudou desde o último pedido ao servidor
+
+	// info chega pela goroutine de rede (ver OnHistoryInfo)
+	info     atomic.Pointer[fvnet.HistoryInfo]
+	lastInfo *fvnet.HistoryInfo
+
+	// Sobreposição de diferenças (ver app_diff.go); diff chega pela goroutine de rede
+	showDiff bool
+	diff     atomic.Pointer[fvnet.DiffResult]
+}
+
+// toggleTimeline abre ou fecha a linha do tempo.
+func (a *App) toggleTimeline() {
+	if a.netClient == nil || !a.netClient.IsConnected() {
+		return
+	}
+	t := &a.timeline
+	if !t.Active {
+		t.Active, t.Playing, t.Pos = true, false, 1
+		a.netClient.SetTimeline(true)
+		a.netClient.RequestHistoryInfo() // O primeiro pedido sai quando o intervalo chegar
+		log.Println("[Timeline] Linha do tempo aberta")
+		return
+	}
+
+	t.Active, t.Playing, t.dragging, t.showDiff = false, false, false, false
+	t.diff.Store(nil)
+	a.netClient.SetTimeline(false)
+	a.updateMap(true) // Volta ao mapa ao vivo
+	log.Println("[Timeline] Linha do tempo fechada")
+}
+
+// timelinePoint converte a posição da barra num instante do histórico.
+// Usa ticks do DF quando o servidor os registrou; senão, o horário das gravações.
+func (a *App) timelinePoint() (mapdata.HistoryPoint, bool) {
+	info := a.timeline.info.Load()
+	if info == nil || !info.Enabled {
+		return mapdata.HistoryPoint{}, false
+	}
+	pos := float64(a.timeline.Pos)
+	if info.FirstTick > 0 && info.LastTick > info.FirstTick {
+		return mapdata.HistoryPoint{Tick: info.FirstTick + int64(pos*float64(info.LastTick-info.FirstTick))}, true
+	}
+	ms := info.FirstTime + int64(pos*float64(info.LastTime-info.FirstTime))
+	return mapdata.HistoryPoint{Time: time.UnixMilli(ms)}, true
+}
+
+// timelineBar é a área da barra de rolagem na tela.
+func timelineBar() rl.Rectangle {
+	w := float32(rl.GetScreenWidth())
+	h := float32(rl.GetScreenHeight())
+	return rl.Rectangle{X: 60, Y: h - 64, Width: w - 120, Height: 14}
+}
+
+// updateTimeline trata a entrada da linha do tempo e pede a região quando a posição muda.
+func (a *App) updateTimeline() {
+	t := &a.timeline
+	if !t.Active {
+		return
+	}
+	if info := t.info.Load(); info != t.lastInfo {
+		t.lastInfo = info
+		t.dirty = true
+	}
+
+	prev := t.Pos
+	if rl.IsKeyPressed(rl.KeySpace) {
+		t.Playing = !t.Playing
+		if t.Playing && t.Pos >= 1 {
+			t.Pos = 0 // Reproduz do início
+		}
+	}
+	if rl.IsKeyPressed(rl.KeyLeft) {
+		t.Pos, t.Playing = max(t.Pos-0.01, 0), false
+	}
+	if rl.IsKeyPressed(rl.KeyRight) {
+		t.Pos, t.Playing = min(t.Pos+0.01, 1), false
+	}
+	if rl.IsKeyPressed(rl.KeyC) {
+		a.toggleDiffOverlay()
+	}
+
+	bar := timelineBar()
+	mouse := rl.GetMousePosition()
+	grab := rl.Rectangle{X: bar.X, Y: bar.Y - 8, Width: bar.Width, Height: bar.Height + 16}
+	if rl.IsMouseButtonPressed(rl.MouseLeftButton) && rl.CheckCollisionPointRec(mouse, grab) {
+		t.dragging, t.Playing = true, false
+	}
+	if t.dragging {
+		t.Pos = min(max((mouse.X-bar.X)/bar.Width, 0), 1)
+		if rl.IsMouseButtonReleased(rl.MouseLeftButton) {
+			t.dragging = false
+		}
+	}
+
+	if t.Playing {
+		t.Pos += rl.GetFrameTime() / timelapseSeconds
+		if t.Pos >= 1 {
+			t.Pos, t.Playing = 1, false
+		}
+	}
+
+	if t.Pos != prev {
+		t.dirty = true
+	}
+	// Pedidos limitados a 4 por segundo (60fps) enquanto a barra se move
+	if t.dirty && a.frameCount%15 == 0 {
+		t.dirty = false
+		a.updateMap(true)
+	}
+}
+
+// requestTimelineRegion pede a região no instante da barra (ver updateMap).
+func (a *App) requestTimelineRegion(center util.DFCoord, radius int32) {
+	at, ok := a.timelinePoint()
+	if !ok {
+		return
+	}
+	a.netClient.RequestHistory(center, radius, at)
+	if a.timeline.showDiff {
+		a.netClient.RequestDiff(center, radius, at)
+	}
+}
+
+// drawTimeline desenha a barra da linha do tempo no rodapé.
+func (a *App) drawTimeline() {
+	t := &a.timeline
+	if !t.Active {
+		return
+	}
+	bar := timelineBar()
+	panel := rl.Rectangle{X: bar.X - 20, Y: bar.Y - 40, Width: bar.Width + 40, Height: 96}
+	rl.DrawRectangleRec(panel, rl.NewColor(0, 0, 0, 180))
+	rl.DrawRectangleLinesEx(panel, 1, rl.NewColor(50, 50, 50, 255))
+
+	info := t.info.Load()
+	x, y := int32(bar.X), int32(panel.Y)+10
+	switch {
+	case info == nil:
+		rl.DrawText("LINHA DO TEMPO - consultando o servidor...", x, y, 16, rl.LightGray)
+		return
+	case !info.Enabled:
+		rl.DrawText("LINHA DO TEMPO - este mundo não tem histórico (ligue FV_HISTORY_INTERVAL no servidor)  [H: fechar]", x, y, 16, rl.Orange)
+		return
+	}
+
+	state := "Pausado"
+	if t.Playing {
+		state = "Reproduzindo"
+	}
+	at, _ := a.timelinePoint()
+	rl.DrawText(fmt.Sprintf("LINHA DO TEMPO - %s [%s]", historyLabel(at), state), x, y, 16, rl.Gold)
+	hint := "Espaço: Play/Pausa | Setas: Passo | H: Voltar ao vivo"
+	rl.DrawText(hint, int32(bar.X+bar.Width)-rl.MeasureText(hint, 14), y+2, 14, rl.LightGray)
+
+	rl.DrawRectangleRec(bar, rl.NewColor(60, 60, 60, 255))
+	filled := bar
+	filled.Width *= t.Pos
+	rl.DrawRectangleRec(filled, rl.NewColor(200, 160, 40, 255))
+	rl.DrawCircle(int32(bar.X+bar.Width*t.Pos), int32(bar.Y+bar.Height/2), 9, rl.White)
+
+	first := mapdata.HistoryPoint{Tick: info.FirstTick, Time: time.UnixMilli(info.FirstTime)}
+	last := mapdata.HistoryPoint{Tick: info.LastTick, Time: time.UnixMilli(info.LastTime)}
+	if first.Tick == 0 || last.Tick <= first.Tick {
+		first.Tick, last.Tick = 0, 0
+	}
+	rl.DrawText(historyLabel(first), x, int32(bar.Y+bar.Height)+8, 12, rl.Gray)
+	end := fmt.Sprintf("%s (%d versões)", historyLabel(last), info.Snapshots)
+	rl.DrawText(end, int32(bar.X+bar.Width)-rl.MeasureText(end, 12), int32(bar.Y+bar.Height)+8, 12, rl.Gray)
+
+	a.drawDiffLegend(x, int32(bar.Y+bar.Height)+24)
+}
+
+// historyLabel formata um instante do histórico: data do DF quando há tick, senão o horário local.
+func historyLabel(p mapdata.HistoryPoint) string {
+	if p.Tick > 0 {
+		yearTick := p.Tick % mapdata.TicksPerYear
+		return fmt.Sprintf("Ano %d, mês %d, dia %d", p.Tick/mapdata.TicksPerYear, yearTick/33600+1, yearTick%33600/1200+1)
+	}
+	return p.Time.Format("02/01/2006 15:04:05")
+}
@@ -0,0 +1,89 @@
+package app
+
+import (
+	"math"
+
+	"FortressVision/shared/pkg/fvclient"
+	"FortressVision/shared/util"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// footprintPadding afasta os vértices do polígono da câmera (em tiles), para
+// cobrir paredes e telhados que aparecem na borda da tela vindos de fora do chão visto.
+const footprintPadding = 16
+
+// viewRegion retorna a região pedida ao servidor: o ponto da câmera no nível Z
+// atual, os níveis de draw_range_down/up e o chão visto pela câmera.
+func (a *App) viewRegion() fvclient.View {
+	center := util.WorldToDFCoord(util.Vector3(a.Cam.CurrentLookAt))
+	center.Z = a.mapCenter.Z
+	view := a.viewAround(center)
+	view.Frustum = a.cameraFootprint(view)
+	return view
+}
+
+// viewAround monta a região da configuração (draw_range_side/down/up) em volta de center.
+func (a *App) viewAround(center util.DFCoord) fvclient.View {
+	return fvclient.View{
+		Center: center,
+		Radius: max(a.Config.DrawRangeSide, 1) * util.BlockSize,
+		MinZ:   center.Z - max(a.Config.DrawRangeDown, 0),
+		MaxZ:   center.Z + max(a.Config.DrawRangeUp, 0),
+	}
+}
+
+// cameraFootprint projeta os cantos da tela no nível mais baixo da região (onde a
+// área vista é maior) e devolve o polígono resultante, em tiles. Raios que não
+// chegam ao chão (horizonte à vista) param a duas vezes o raio da câmera. Retorna
+// nil se a janela não tiver área (minimizada).
+func (a *App) cameraFootprint(view fvclient.View) []util.DFCoord {
+	w, h := float32(rl.GetScreenWidth()), float32(rl.GetScreenHeight())
+	if w <= 0 || h <= 0 {
+		return nil
+	}
+	planeY := float32(view.MinZ) * util.GameScale
+	far := float32(view.Radius*2) * util.GameScale
+	target := a.Cam.CurrentLookAt
+
+	var points []rl.Vector3
+	for _, corner := range []rl.Vector2{{X: 0, Y: 0}, {X: w, Y: 0}, {X: w, Y: h}, {X: 0, Y: h}} {
+		ray := rl.GetMouseRay(corner, a.Cam.RLCamera)
+		dir := rl.Vector2{X: ray.Direction.X, Y: ray.Direction.Z}
+		if t := (planeY - ray.Position.Y) / ray.Direction.Y; ray.Direction.Y < 0 && t > 0 {
+			hit := rl.Vector3Add(ray.Position, rl.Vector3Scale(ray.Direction, t))
+			if rl.Vector2Length(rl.Vector2{X: hit.X - target.X, Y: hit.Z - target.Z}) <= far {
+				points = append(points, hit)
+				continue
+			}
+		}
+		if rl.Vector2Length(dir) == 0 {
+			return nil
+		}
+		dir = rl.Vector2Scale(rl.Vector2Normalize(dir), far)
+		points = append(points, rl.Vector3{X: target.X + dir.X, Y: planeY, Z: target.Z + dir.Y})
+	}
+
+	var centroid rl.Vector3
+	for _, p := range points {
+		centroid = rl.Vector3Add(centroid, p)
+	}
+	centroid = rl.Vector3Scale(centroid, 1/float32(len(points)))
+
+	polygon := make([]util.DFCoord, len(points))
+	area := float32(0)
+	for i, p := range points {
+		next := points[(i+1)%len(points)]
+		area += p.X*next.Z - next.X*p.Z
+		out := rl.Vector3Subtract(p, centroid)
+		out.Y = 0
+		if rl.Vector3Length(out) > 0 {
+			p = rl.Vector3Add(p, rl.Vector3Scale(rl.Vector3Normalize(out), footprintPadding*util.GameScale))
+		}
+		polygon[i] = util.WorldToDFCoord(util.Vector3(p))
+	}
+	if math.Abs(float64(area)) < 1 {
+		return nil
+	}
+	return polygon
+}
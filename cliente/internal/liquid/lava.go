@@ -121,9 +121,8 @@ func addLavaPlane(tile *mapdata.Tile, level int32, color [4]uint8, buffer Geomet
 	// Função de ajuda local para buscar o nível de magma em qualquer offset
 	getFluidLevel := func(dx, dy int32) float32 {
 		neighborPos := tile.Position.Add(util.DFCoord{X: dx, Y: dy, Z: 0})
-		if neighbor := tile.GetStore().GetTile(neighborPos); neighbor != nil {
-			l := neighbor.MagmaLevel
-			return float32(l) / 7.0
+		if _, magma, ok := tile.GetStore().TileLiquid(neighborPos); ok {
+			return float32(magma) / 7.0
 		}
 		return float32(level) / 7.0
 	}
@@ -135,7 +134,7 @@ func addLavaPlane(tile *mapdata.Tile, level int32, color [4]uint8, buffer Geomet
 	se := (getFluidLevel(0, 0) + getFluidLevel(0, 1) + getFluidLevel(1, 0) + getFluidLevel(1, 1)) / 4.0
 
 	// Se o bloco de cima tiver magma, as quinas cravam no 1.0
-	if upTile := tile.Up(); upTile != nil && upTile.MagmaLevel > 0 {
+	if _, magma, ok := tile.GetStore().TileLiquid(tile.Position.Up()); ok && magma > 0 {
 		nw, ne, sw, se = 1.0, 1.0, 1.0, 1.0
 	}
 
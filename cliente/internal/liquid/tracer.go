@@ -1,6 +1,7 @@
 package liquid
 
 import (
+	"FortressVision/shared/mapdata"
 	"fmt"
 	"log"
 	"os"
@@ -70,19 +71,48 @@ func Trace(etapa int, tag string, format string, args ...interface{}) {
 }
 
 // TraceNetwork registra quando um chunk com água chega pela rede.
-// Chamar em: network.go → processChunk()
+// Chamar em: TraceChunk
 func TraceNetwork(chunkX, chunkY, chunkZ int32, totalTilesComAgua int, totalTilesComMagma int) {
 	Trace(1, "REDE", "Chunk (%d,%d,%d) recebido. Tiles com água: %d, com magma: %d",
 		chunkX, chunkY, chunkZ, totalTilesComAgua, totalTilesComMagma)
 }
 
 // TraceStore registra quando um tile com líquido é inserido no MapDataStore.
-// Chamar em: network.go → processChunk() após inserir no store
+// Chamar em: TraceChunk
 func TraceStore(x, y, z, waterLevel, magmaLevel int32, hidden bool) {
 	Trace(2, "STORE", "Tile (%d,%d,%d) no MapDataStore. WaterLevel=%d, MagmaLevel=%d, Hidden=%v",
 		x, y, z, waterLevel, magmaLevel, hidden)
 }
 
+// TraceChunk registra as etapas 1 e 2 de um chunk recém-chegado ao store:
+// cada tile com líquido e o total de água e magma do chunk.
+// Chamar em: app_network.go → OnMapChunk callback
+func TraceChunk(chunk *mapdata.Chunk) {
+	if !traceEnabled || traceLogger == nil {
+		return
+	}
+	origin := chunk.Origin
+	waterCount, magmaCount := 0, 0
+	for x := int32(0); x < 16; x++ {
+		for y := int32(0); y < 16; y++ {
+			t := chunk.Tile(x, y)
+			if t == nil || (t.WaterLevel == 0 && t.MagmaLevel == 0) {
+				continue
+			}
+			if t.WaterLevel > 0 {
+				waterCount++
+			}
+			if t.MagmaLevel > 0 {
+				magmaCount++
+			}
+			TraceStore(origin.X+x, origin.Y+y, origin.Z, t.WaterLevel, t.MagmaLevel, t.Hidden)
+		}
+	}
+	if waterCount > 0 || magmaCount > 0 {
+		TraceNetwork(origin.X, origin.Y, origin.Z, waterCount, magmaCount)
+	}
+}
+
 // TraceEnqueue registra quando um chunk é enfileirado para meshing.
 // Chamar em: app_network.go → OnMapChunk callback
 func TraceEnqueue(originX, originY, originZ int32, mtime int64) {
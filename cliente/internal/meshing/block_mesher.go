@@ -170,6 +170,17 @@ func (m *BlockMesher) runGreedyMesher2D(req Request, getBuffer func(string) *Mes
 		util.DirWest, util.DirEast,
 	}
 
+	// Os tiles do chunk são lidos uma vez (ReadTile, sem alocar um Tile por
+	// chamada) e servem às 6 faces
+	block := make([]mapdata.Tile, 16*16)
+	present := make([]bool, 16*16)
+	for yy := int32(0); yy < 16; yy++ {
+		for xx := int32(0); xx < 16; xx++ {
+			worldCoord := util.NewDFCoord(req.Origin.X+xx, req.Origin.Y+yy, currentZ)
+			present[yy*16+xx] = req.Data.ReadTile(worldCoord, &block[yy*16+xx])
+		}
+	}
+
 	// Para cada uma das 6 faces, executamos a otimização
 	for _, faceDir := range coreDirs {
 		// 1. Gerar Máscara 16x16 para esta face
@@ -183,12 +194,11 @@ func (m *BlockMesher) runGreedyMesher2D(req Request, getBuffer func(string) *Mes
 				if xx == 0 {
 					liquid.TraceHeartbeat(req.Origin.X, req.Origin.Y, req.Origin.Z, int32(faceDir))
 				}
-				worldCoord := util.NewDFCoord(req.Origin.X+xx, req.Origin.Y+yy, currentZ)
-				tile := req.Data.GetTile(worldCoord)
-
-				if tile == nil {
+				if !present[yy*16+xx] {
 					continue
 				}
+				worldCoord := util.NewDFCoord(req.Origin.X+xx, req.Origin.Y+yy, currentZ)
+				tile := &block[yy*16+xx]
 
 				// MOVE PARA CIMA DO CHECK DE tile.Hidden: Água deve ser visível mesmo se o solo estiver oculto!
 				if faceDir == util.DirUp {
@@ -25,13 +25,9 @@ func addLiquidPlane(tile *mapdata.Tile, level int32, color [4]uint8, buffer *Mes
 	// Função de ajuda local para buscar o nível de fluido em qualquer offset
 	getFluidLevel := func(dx, dy int32) float32 {
 		neighborPos := tile.Position.Add(util.DFCoord{X: dx, Y: dy, Z: 0})
-		if neighbor := tile.GetStore().GetTile(neighborPos); neighbor != nil {
+		if water, magma, ok := tile.GetStore().TileLiquid(neighborPos); ok {
 			// Usa o máximo entre água e magma para simplificar a malha caso encostem
-			l := neighbor.WaterLevel
-			if neighbor.MagmaLevel > l {
-				l = neighbor.MagmaLevel
-			}
-			return float32(l) / 7.0
+			return float32(max(water, magma)) / 7.0
 		}
 		// Se não há bloco vizinho carregado, assume a altura original do nosso bloco para não deformar a borda do chunk
 		return float32(level) / 7.0
@@ -52,7 +48,7 @@ func addLiquidPlane(tile *mapdata.Tile, level int32, color [4]uint8, buffer *Mes
 	se := (getFluidLevel(0, 0) + getFluidLevel(0, 1) + getFluidLevel(1, 0) + getFluidLevel(1, 1)) / 4.0
 
 	// Se for o nível máximo 7 e o bloco de "cima/teto" tiver água, as quinas cravam no 1.0 para ligar cachoeiras
-	if upTile := tile.Up(); upTile != nil && (upTile.WaterLevel > 0 || upTile.MagmaLevel > 0) {
+	if water, magma, ok := tile.GetStore().TileLiquid(tile.Position.Up()); ok && (water > 0 || magma > 0) {
 		// Simplificação pra líquidos transbordantes e colunas de água
 		nw, ne, sw, se = 1.0, 1.0, 1.0, 1.0
 	}
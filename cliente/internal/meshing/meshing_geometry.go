@@ -10,7 +10,7 @@ import (
 
 func (m *BlockMesher) emitGreedyQuad(req Request, x, y, w, h int32, face util.Directions, tile *mapdata.Tile, getBuffer func(string) *MeshBuffer) {
 	// Converte coordenadas locais (0-15) + dimensões (W, H) para mundo real
-	pos := util.DFToWorldPos(util.DFCoord{X: req.Origin.X + x, Y: req.Origin.Y + y, Z: req.Origin.Z})
+	pos := rl.Vector3(util.DFToWorldPos(util.DFCoord{X: req.Origin.X + x, Y: req.Origin.Y + y, Z: req.Origin.Z}))
 
 	// Espessura e formato baseados no shape
 	shape := tile.Shape()
@@ -99,17 +99,19 @@ func (m *BlockMesher) getQuadCornerColors(coord util.DFCoord, face util.Directio
 }
 
 func (m *BlockMesher) shouldDrawFace(tile *mapdata.Tile, dir util.Directions) bool {
-	neighbor := tile.GetNeighbor(dir)
-	if neighbor == nil {
+	data := tile.GetStore()
+	neighborPos := tile.Position.Add(util.DirOffsets[dir])
+	hidden, ok := data.TileHidden(neighborPos)
+	if !ok {
 		return true // Borda do mapa: sempre desenha
 	}
 
 	// Vizinho Hidden → sempre desenha (Hidden = vazio preto, precisa ver a parede)
-	if neighbor.Hidden {
+	if hidden {
 		return true
 	}
 
-	neighborShape := neighbor.Shape()
+	neighborShape, _ := data.TileShape(neighborPos)
 
 	// Vizinho é vazio/ar → sempre desenha
 	if neighborShape == dfproto.ShapeNoShape {
@@ -131,37 +133,27 @@ func (m *BlockMesher) shouldDrawFace(tile *mapdata.Tile, dir util.Directions) bo
 }
 
 func (m *BlockMesher) isSolidAO(coord util.DFCoord, dir util.Directions, data *mapdata.MapDataStore) bool {
-	neighborPos := coord.AddDir(dir)
-	tile := data.GetTile(neighborPos)
-	if tile == nil {
-		return false
-	}
-	shape := tile.Shape()
+	shape, _ := data.TileShape(coord.AddDir(dir))
 	return shape == dfproto.ShapeWall || shape == dfproto.ShapeFortification
 }
 
 func (m *BlockMesher) isSolid(coord util.DFCoord, dir util.Directions, data *mapdata.MapDataStore) bool {
-	neighborCoord := coord.Add(util.DirOffsets[dir])
-	tile := data.GetTile(neighborCoord)
-	if tile == nil {
-		return false
-	}
-	shape := tile.Shape()
+	shape, _ := data.TileShape(coord.Add(util.DirOffsets[dir]))
 	return shape == dfproto.ShapeWall || shape == dfproto.ShapeRamp
 }
 
 func (m *BlockMesher) calculateAwayFromWallRotation(tile *mapdata.Tile) float32 {
 	var vx, vz float32
-	if n := tile.GetNeighbor(util.DirNorth); n != nil && (n.Shape() == dfproto.ShapeWall || n.Shape() == dfproto.ShapeFortification) {
+	if m.isSolidAO(tile.Position, util.DirNorth, tile.GetStore()) {
 		vz -= 1.0
 	}
-	if n := tile.GetNeighbor(util.DirSouth); n != nil && (n.Shape() == dfproto.ShapeWall || n.Shape() == dfproto.ShapeFortification) {
+	if m.isSolidAO(tile.Position, util.DirSouth, tile.GetStore()) {
 		vz += 1.0
 	}
-	if n := tile.GetNeighbor(util.DirWest); n != nil && (n.Shape() == dfproto.ShapeWall || n.Shape() == dfproto.ShapeFortification) {
+	if m.isSolidAO(tile.Position, util.DirWest, tile.GetStore()) {
 		vx += 1.0
 	}
-	if n := tile.GetNeighbor(util.DirEast); n != nil && (n.Shape() == dfproto.ShapeWall || n.Shape() == dfproto.ShapeFortification) {
+	if m.isSolidAO(tile.Position, util.DirEast, tile.GetStore()) {
 		vx -= 1.0
 	}
 
@@ -434,7 +434,7 @@ func (r *Renderer) Draw(camera3d rl.Camera3D, focusZ int32) {
 
 		// Culling de distância
 		pos := rl.Vector3{X: posComp.X, Y: posComp.Y, Z: posComp.Z}
-		if util.DistSq(camPos, pos) > instViewRadiusSq {
+		if rl.Vector3DistanceSqr(camPos, pos) > instViewRadiusSq {
 			continue
 		}
 
@@ -567,6 +567,28 @@ func (r *Renderer) Unload() {
 	r.Models = make(map[util.DFCoord]*BlockModel)
 }
 
+// Reset descarta todos os modelos e entidades de chunks (troca de mundo).
+// Shaders, texturas e modelos 3D de assets são mantidos.
+func (r *Renderer) Reset() {
+	r.Unload()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.purgeQueue = r.purgeQueue[:0]
+
+	filter := ecs.NewFilter1[comp.ChunkInfo](&r.World)
+	query := filter.Query()
+	var toRemove []ecs.Entity
+	for query.Next() {
+		toRemove = append(toRemove, query.Entity())
+	}
+	query.Close()
+
+	for _, e := range toRemove {
+		r.World.RemoveEntity(e)
+	}
+}
+
 // GetRayCollision verifica qual bloco do terreno foi atingido pelo raio do mouse.
 func (r *Renderer) GetRayCollision(ray rl.Ray) (util.DFCoord, bool) {
 	r.mu.RLock()
@@ -646,7 +668,7 @@ func (r *Renderer) GetRayCollision(ray rl.Ray) (util.DFCoord, bool) {
 		hitPos.Y += dir.Y * 0.01
 		hitPos.Z += dir.Z * 0.01
 
-		return util.WorldToDFCoord(hitPos), true
+		return util.WorldToDFCoord(util.Vector3(hitPos)), true
 	}
 
 	return util.DFCoord{}, false
@@ -654,7 +676,7 @@ func (r *Renderer) GetRayCollision(ray rl.Ray) (util.DFCoord, bool) {
 
 // DrawSelection desenha um cubo de destaque no bloco selecionado.
 func (r *Renderer) DrawSelection(coord util.DFCoord) {
-	pos := util.DFToWorldCenter(coord)
+	pos := rl.Vector3(util.DFToWorldCenter(coord))
 	// Ajustamos para o centro vertical do bloco (DF Z + 0.5)
 	pos.Y += 0.5
 	rl.DrawCubeWires(pos, 1.01, 1.01, 1.01, rl.Yellow)
@@ -17,6 +17,7 @@ func main() {
 
 	// Flags de linha de comando
 	serverURL := flag.String("server", "", "URL do Servidor FortressVision (padrão: ws://localhost:8080/ws)")
+	world := flag.String("world", "", "Mundo salvo a visualizar (padrão: mundo ao vivo do servidor)")
 	fullscreen := flag.Bool("fullscreen", false, "Iniciar em tela cheia")
 	debug := flag.Bool("debug", false, "Mostrar informações de debug")
 	width := flag.Int("width", 0, "Largura da janela")
@@ -52,6 +53,9 @@ func main() {
 	if *serverURL != "" {
 		cfg.ServerURL = *serverURL
 	}
+	if *world != "" {
+		cfg.World = *world
+	}
 	if *fullscreen {
 		cfg.Fullscreen = true
 	}
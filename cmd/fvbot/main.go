@@ -0,0 +1,137 @@
+// fvbot é um cliente sem janela do protocolo do FortressVision: conecta ao
+// servidor, percorre um caminho de câmera roteirizado pedindo regiões e valida
+// cada chunk recebido decodificando-o num MapDataStore. Com -sessions N abre N
+// sessões simultâneas para testar a carga do Hub.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"FortressVision/shared/pkg/fvclient"
+	"FortressVision/shared/util"
+)
+
+// defaultPath dá a volta no mundo de demonstração (servidor --demo), da
+// fortaleza até a margem do rio, descendo em direção às cavernas.
+const defaultPath = "30,24,38 100,24,38 100,100,30 30,100,20 30,24,38"
+
+// botOptions são as opções de uma sessão, iguais para todas.
+type botOptions struct {
+	url     string
+	radius  int32
+	path    []util.DFCoord
+	loops   int
+	pause   time.Duration
+	timeout time.Duration
+}
+
+func main() {
+	server := flag.String("server", "ws://localhost:8080/ws", "URL do Servidor FortressVision")
+	world := flag.String("world", "", "Mundo salvo a pedir no handshake (padrão: mundo ao vivo)")
+	sessions := flag.Int("sessions", 1, "Sessões simultâneas")
+	ramp := flag.Duration("ramp", 100*time.Millisecond, "Intervalo entre o início de cada sessão")
+	pathSpec := flag.String("path", defaultPath, "Pontos do caminho da câmera: \"x,y,z x,y,z ...\"")
+	step := flag.Int("step", 16, "Distância em tiles entre dois pedidos ao longo do caminho")
+	radius := flag.Int("radius", 64, "Raio das regiões pedidas (o cliente usa 64)")
+	loops := flag.Int("loops", 1, "Voltas no caminho por sessão")
+	pause := flag.Duration("pause", 0, "Espera entre uma região completa e o próximo pedido")
+	timeout := flag.Duration("timeout", 10*time.Second, "Tempo máximo para uma região chegar inteira")
+	verbose := flag.Bool("v", false, "Mostrar o log do NetworkClient e o resumo de cada sessão")
+	flag.Parse()
+
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+	waypoints, err := parsePath(*pathSpec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fvbot: -path: %v\n", err)
+		os.Exit(2)
+	}
+	if *sessions < 1 || *step < 1 || *radius < 1 || *loops < 1 {
+		fmt.Fprintln(os.Stderr, "fvbot: -sessions, -step, -radius e -loops precisam ser positivos")
+		os.Exit(2)
+	}
+
+	opts := botOptions{
+		url:     fvclient.WorldURL(*server, *world),
+		radius:  int32(*radius),
+		path:    walkPath(waypoints, int32(*step)),
+		loops:   *loops,
+		pause:   *pause,
+		timeout: *timeout,
+	}
+	fmt.Printf("fvbot: %d sessão(ões) em %s, %d regiões por volta (raio %d)\n",
+		*sessions, opts.url, len(opts.path), opts.radius)
+
+	start := time.Now()
+	results := make([]*sessionStats, *sessions)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			results[id] = runSession(id+1, opts)
+		}(i)
+		if i < len(results)-1 {
+			time.Sleep(*ramp)
+		}
+	}
+	wg.Wait()
+
+	if !report(os.Stdout, results, time.Since(start), *verbose) {
+		os.Exit(1)
+	}
+}
+
+// parsePath lê os pontos "x,y,z" separados por espaço ou ';'.
+func parsePath(spec string) ([]util.DFCoord, error) {
+	var points []util.DFCoord
+	for _, field := range strings.FieldsFunc(spec, func(r rune) bool { return r == ' ' || r == ';' }) {
+		parts := strings.Split(field, ",")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("ponto %q não está no formato x,y,z", field)
+		}
+		var v [3]int32
+		for i, p := range parts {
+			n, err := strconv.ParseInt(strings.TrimSpace(p), 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("ponto %q: %v", field, err)
+			}
+			v[i] = int32(n)
+		}
+		points = append(points, util.NewDFCoord(v[0], v[1], v[2]))
+	}
+	if len(points) == 0 {
+		return nil, fmt.Errorf("caminho vazio")
+	}
+	return points, nil
+}
+
+// walkPath interpola os pontos do caminho a cada step tiles no plano, com o Z
+// acompanhando proporcionalmente: são as posições em que a câmera pede região.
+func walkPath(points []util.DFCoord, step int32) []util.DFCoord {
+	out := []util.DFCoord{points[0]}
+	for i := 1; i < len(points); i++ {
+		a, b := points[i-1], points[i]
+		dx, dy := float64(b.X-a.X), float64(b.Y-a.Y)
+		n := max(1, int(math.Ceil(max(math.Abs(dx), math.Abs(dy))/float64(step))))
+		for s := 1; s <= n; s++ {
+			t := float64(s) / float64(n)
+			out = append(out, util.NewDFCoord(
+				a.X+int32(dx*t),
+				a.Y+int32(dy*t),
+				a.Z+int32(float64(b.Z-a.Z)*t),
+			))
+		}
+	}
+	return out
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// report imprime o resumo das sessões e retorna false se alguma falhou
+// (conexão, timeout ou chunk inválido).
+func report(out io.Writer, results []*sessionStats, wall time.Duration, perSession bool) bool {
+	var total sessionStats
+	ok := true
+	connected := 0
+	for _, s := range results {
+		if perSession {
+			printSession(out, s)
+		}
+		if s.err != nil {
+			ok = false
+			if !perSession {
+				fmt.Fprintf(out, "sessão %d: %v\n", s.id, s.err)
+			}
+		}
+		if !perSession {
+			for _, p := range s.problems {
+				fmt.Fprintf(out, "sessão %d: %s\n", s.id, p)
+			}
+		}
+		if s.regions > 0 {
+			connected++
+		}
+		total.regions += s.regions
+		total.timeouts += s.timeouts
+		total.chunks += s.chunks
+		total.empty += s.empty
+		total.invalid += s.invalid
+		total.decodeErrors += s.decodeErrors
+		total.extra += s.extra
+		total.messages += s.messages
+		total.bytes += s.bytes
+		total.dropped += s.dropped
+		total.pings += s.pings
+		total.pongs += s.pongs
+		total.rtt = append(total.rtt, s.rtt...)
+		total.firstChunk = append(total.firstChunk, s.firstChunk...)
+		total.complete = append(total.complete, s.complete...)
+	}
+	if total.timeouts > 0 || total.invalid > 0 || total.decodeErrors > 0 {
+		ok = false
+	}
+
+	fmt.Fprintf(out, "\n== Total: %d/%d sessões conectadas, %v ==\n", connected, len(results), wall.Round(time.Millisecond))
+	printCounts(out, &total)
+	printThroughput(out, &total, wall)
+	if ok {
+		fmt.Fprintln(out, "OK")
+	} else {
+		fmt.Fprintln(out, "FALHOU")
+	}
+	return ok
+}
+
+func printSession(out io.Writer, s *sessionStats) {
+	fmt.Fprintf(out, "\n-- Sessão %d --\n", s.id)
+	if s.err != nil {
+		fmt.Fprintf(out, "erro: %v\n", s.err)
+	}
+	if s.regions == 0 {
+		return
+	}
+	printCounts(out, s)
+	printThroughput(out, s, s.elapsed)
+	for _, p := range s.problems {
+		fmt.Fprintf(out, "  ! %s\n", p)
+	}
+}
+
+func printCounts(out io.Writer, s *sessionStats) {
+	fmt.Fprintf(out, "regiões:   %d pedidas, %d completas, %d timeouts\n", s.regions, len(s.complete), s.timeouts)
+	fmt.Fprintf(out, "chunks:    %d com dados, %d ar, %d inválidos, %d sem decodificar, %d fora de pedido\n",
+		s.chunks, s.empty, s.invalid, s.decodeErrors, s.extra)
+	fmt.Fprintf(out, "latência:  1º chunk %s | região inteira %s\n", percentiles(s.firstChunk), percentiles(s.complete))
+	fmt.Fprintf(out, "rede:      RTT médio %s | %d pings, %d pongs, %d mensagens descartadas\n",
+		percentiles(s.rtt), s.pings, s.pongs, s.dropped)
+}
+
+func printThroughput(out io.Writer, s *sessionStats, elapsed time.Duration) {
+	secs := elapsed.Seconds()
+	if secs <= 0 {
+		return
+	}
+	fmt.Fprintf(out, "vazão:     %.1f chunks/s, %.1f msgs/s, %.2f MB/s (%.1f MB recebidos)\n",
+		float64(s.chunks+s.empty)/secs, float64(s.messages)/secs,
+		float64(s.bytes)/secs/(1<<20), float64(s.bytes)/(1<<20))
+}
+
+// percentiles resume as durações como p50/p95/máx.
+func percentiles(d []time.Duration) string {
+	if len(d) == 0 {
+		return "-"
+	}
+	sorted := append([]time.Duration(nil), d...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	at := func(p float64) time.Duration {
+		return sorted[int(p*float64(len(sorted)-1))].Round(100 * time.Microsecond)
+	}
+	return fmt.Sprintf("p50 %v, p95 %v, máx %v", at(0.50), at(0.95), sorted[len(sorted)-1].Round(100*time.Microsecond))
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"FortressVision/shared/mapdata"
+	"FortressVision/shared/pkg/dfproto"
+	"FortressVision/shared/pkg/fvclient"
+	"FortressVision/shared/util"
+)
+
+// sessionStats é o resultado de uma sessão do bot.
+type sessionStats struct {
+	id      int
+	err     error // Falha de conexão; os demais campos ficam zerados
+	elapsed time.Duration
+
+	regions  int // Regiões pedidas
+	timeouts int // Regiões que não chegaram inteiras dentro de -timeout
+
+	chunks       int // Chunks com dados, decodificados e válidos
+	empty        int // Chunks de ar (sem VoxelData)
+	invalid      int // Decodificaram, mas com conteúdo incoerente
+	decodeErrors int // Não decodificaram
+	extra        int // Chegaram fora de qualquer pedido pendente (atualizações ao vivo, atrasados)
+
+	messages int64
+	bytes    int64
+	dropped  int64 // Mensagens descartadas pelo cliente
+
+	rtt   []time.Duration // RTT médio da sessão medido por PING/PONG (um por sessão)
+	pings int64
+	pongs int64
+
+	firstChunk []time.Duration // Do pedido ao primeiro chunk da região
+	complete   []time.Duration // Do pedido ao último chunk da região
+
+	problems []string // Primeiras falhas de validação, para o relatório
+}
+
+// maxProblems limita quantas falhas de validação cada sessão guarda em detalhe.
+const maxProblems = 5
+
+// regionWait acompanha os chunks que ainda faltam do pedido em andamento.
+type regionWait struct {
+	mu      sync.Mutex
+	pending map[util.DFCoord]bool
+	first   time.Time
+	done    chan struct{}
+}
+
+// expect arma a espera para a região que o servidor vai mandar: todo chunk de
+// 16x16 entre center-radius e center+radius no nível Z recebe uma resposta.
+func (w *regionWait) expect(center util.DFCoord, radius int32) {
+	regionMin := util.NewDFCoord(center.X-radius, center.Y-radius, center.Z).BlockCoord()
+	regionMax := util.NewDFCoord(center.X+radius, center.Y+radius, center.Z).BlockCoord()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending = make(map[util.DFCoord]bool)
+	for x := regionMin.X; x <= regionMax.X; x += 16 {
+		for y := regionMin.Y; y <= regionMax.Y; y += 16 {
+			w.pending[util.NewDFCoord(x, y, center.Z)] = true
+		}
+	}
+	w.first = time.Time{}
+	w.done = make(chan struct{})
+}
+
+// arrive dá baixa num chunk; retorna false se ele não era esperado.
+func (w *regionWait) arrive(origin util.DFCoord) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.pending[origin] {
+		return false
+	}
+	delete(w.pending, origin)
+	if w.first.IsZero() {
+		w.first = time.Now()
+	}
+	if len(w.pending) == 0 {
+		close(w.done)
+	}
+	return true
+}
+
+// runSession conecta ao servidor e percorre o caminho opts.loops vezes,
+// esperando cada região chegar inteira antes de pedir a próxima.
+func runSession(id int, opts botOptions) *sessionStats {
+	stats := &sessionStats{id: id}
+	store := mapdata.NewMapDataStore()
+	nc := fvclient.NewNetworkClient(opts.url, store)
+	wait := &regionWait{}
+
+	var mu sync.Mutex // Protege stats contra o readLoop do NetworkClient
+	note := func(format string, args ...interface{}) {
+		if len(stats.problems) < maxProblems {
+			stats.problems = append(stats.problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	nc.OnTiletypes = func(list *dfproto.TiletypeList) { store.UpdateTiletypes(list) }
+	nc.OnMapChunk = func(origin util.DFCoord) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !wait.arrive(origin) {
+			stats.extra++
+			return
+		}
+		chunk, ok := store.GetChunk(origin)
+		if !ok || chunk.IsEmpty {
+			stats.empty++
+			return
+		}
+		if err := validateChunk(store, chunk, origin); err != nil {
+			stats.invalid++
+			note("chunk %v: %v", origin, err)
+			return
+		}
+		stats.chunks++
+	}
+	nc.OnChunkError = func(origin util.DFCoord, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !wait.arrive(origin) {
+			stats.extra++
+		}
+		stats.decodeErrors++
+		note("chunk %v não decodificou: %v", origin, err)
+	}
+
+	if err := nc.Connect(); err != nil {
+		stats.err = err
+		return stats
+	}
+	defer nc.Close()
+
+	start := time.Now()
+walk:
+	for loop := 0; loop < opts.loops; loop++ {
+		for _, center := range opts.path {
+			if !nc.IsConnected() {
+				stats.err = fmt.Errorf("conexão perdida após %d regiões", stats.regions)
+				break walk
+			}
+			wait.expect(center, opts.radius)
+			sent := time.Now()
+			nc.RequestRegion(center, opts.radius)
+			stats.regions++
+
+			select {
+			case <-wait.done:
+				mu.Lock()
+				stats.firstChunk = append(stats.firstChunk, wait.first.Sub(sent))
+				stats.complete = append(stats.complete, time.Since(sent))
+				mu.Unlock()
+			case <-time.After(opts.timeout):
+				mu.Lock()
+				stats.timeouts++
+				mu.Unlock()
+			}
+			if opts.pause > 0 {
+				time.Sleep(opts.pause)
+			}
+		}
+	}
+	stats.elapsed = time.Since(start)
+	st := nc.Stats()
+	stats.messages, stats.bytes, stats.dropped = st.Messages, st.Bytes, st.Dropped
+	stats.pings, stats.pongs = st.PingsSent, st.PongsReceived
+	if st.PongsReceived > 0 {
+		stats.rtt = []time.Duration{st.AvgRTT}
+	}
+	return stats
+}
+
+// validateChunk confere o que a decodificação sozinha não garante: que o chunk
+// é o pedido, que os tiletypes existem no dicionário recebido e que os níveis de
+// líquido estão na faixa do DF (0 a 7).
+func validateChunk(store *mapdata.MapDataStore, chunk *mapdata.Chunk, origin util.DFCoord) error {
+	if chunk.Origin != origin {
+		return fmt.Errorf("origem decodificada %v", chunk.Origin)
+	}
+	store.Mu.RLock()
+	defer store.Mu.RUnlock()
+
+	tiles := 0
+	for y := int32(0); y < 16; y++ {
+		for x := int32(0); x < 16; x++ {
+			t := chunk.Tile(x, y)
+			if t == nil {
+				continue
+			}
+			tiles++
+			if _, known := store.Tiletypes[t.TileType]; !known && len(store.Tiletypes) > 0 {
+				return fmt.Errorf("tile %v com tiletype desconhecido %d", t.Position, t.TileType)
+			}
+			if t.WaterLevel < 0 || t.WaterLevel > 7 || t.MagmaLevel < 0 || t.MagmaLevel > 7 {
+				return fmt.Errorf("tile %v com líquido fora da faixa (água %d, magma %d)", t.Position, t.WaterLevel, t.MagmaLevel)
+			}
+		}
+	}
+	if tiles == 0 {
+		return fmt.Errorf("chunk com dados mas sem tiles")
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"FortressVision/shared/mapdata"
+)
+
+func runExport(world string, args []string) error {
+	dest := userPath(world + mapdata.ArchiveExt)
+	if len(args) > 0 {
+		dest = userPath(args[0])
+	}
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("%s já existe", dest)
+	}
+
+	// Grava num temporário ao lado: um .fvz pela metade nunca fica com o nome final
+	tmp, err := os.CreateTemp(filepath.Dir(dest), filepath.Base(dest)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	manifest, err := mapdata.ExportWorld(world, tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), dest)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	stat, _ := os.Stat(dest)
+	fmt.Printf("%s: %d chunks, %s\n", dest, manifest.ChunkCount, formatBytes(stat.Size()))
+	warnMissingDictionaries(manifest)
+	return nil
+}
+
+func runImport(_ string, args []string) error {
+	src := userPath(args[0])
+	name := ""
+	if len(args) > 1 {
+		name = args[1]
+	}
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	manifest, err := mapdata.ImportWorld(f, stat.Size(), name)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Mundo %s criado em %s (%d chunks, exportado em %s)\n", manifest.WorldName,
+		mapdata.WorldPath(manifest.WorldName), manifest.ChunkCount, manifest.ExportedAt.Local().Format("02/01/2006 15:04"))
+	warnMissingDictionaries(manifest)
+	return nil
+}
+
+// warnMissingDictionaries avisa quando o arquivo não tem o que o modo offline precisa para desenhar o mapa.
+func warnMissingDictionaries(manifest *mapdata.ArchiveManifest) {
+	for _, key := range []string{"TiletypeList", "MaterialList"} {
+		if !slices.Contains(manifest.Dictionaries, key) {
+			fmt.Fprintf(os.Stderr, "Aviso: sem o dicionário %s, o modo offline não conseguirá desenhar o mapa\n", key)
+		}
+	}
+}
@@ -0,0 +1,452 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"FortressVision/shared/mapdata"
+	"FortressVision/shared/pkg/dfproto"
+	"FortressVision/shared/util"
+)
+
+// coord é uma DFCoord com nomes de campo em minúsculas no JSON.
+type coord struct {
+	X int32 `json:"x"`
+	Y int32 `json:"y"`
+	Z int32 `json:"z"`
+}
+
+func toCoord(c util.DFCoord) coord { return coord{c.X, c.Y, c.Z} }
+
+// matPair é um dfproto.MatPair com nomes de campo em minúsculas no JSON.
+type matPair struct {
+	Type  int32 `json:"mat_type"`
+	Index int32 `json:"mat_index"`
+}
+
+func toMatPair(m dfproto.MatPair) matPair { return matPair{m.MatType, m.MatIndex} }
+
+func writeJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// openWorld abre o mundo só para leitura. Bancos em formato antigo são recusados:
+// a leitura não migra, então os chunks poderiam não decodificar.
+func openWorld(world string) (*mapdata.MapDataStore, mapdata.WorldInfo, error) {
+	info, err := mapdata.ReadWorldInfo(world)
+	if err != nil {
+		return nil, info, err
+	}
+	if info.FormatVersion < mapdata.CurrentFormatVersion {
+		return nil, info, fmt.Errorf("mundo %q está no formato %d (atual: %d); rode fvtool migrate %s",
+			world, info.FormatVersion, mapdata.CurrentFormatVersion, world)
+	}
+	if info.FormatVersion > mapdata.CurrentFormatVersion {
+		return nil, info, fmt.Errorf("mundo %q está no formato %d, mais novo que este fvtool (%d)",
+			world, info.FormatVersion, mapdata.CurrentFormatVersion)
+	}
+	store := mapdata.NewMapDataStore()
+	if err := store.OpenReadOnly(world); err != nil {
+		return nil, info, err
+	}
+	return store, info, nil
+}
+
+// worldReport é a saída de info.
+type worldReport struct {
+	mapdata.WorldInfo
+	Path        string            `json:"path"`
+	FileSize    int64             `json:"file_size"`
+	WALSize     int64             `json:"wal_size,omitempty"`
+	EmptyChunks int64             `json:"empty_chunks"`
+	BoundsMin   *coord            `json:"bounds_min,omitempty"`
+	BoundsMax   *coord            `json:"bounds_max,omitempty"`
+	History     *historyReport    `json:"history,omitempty"`
+	Backups     int               `json:"backups"`
+	Dictionary  map[string]string `json:"dictionaries"`
+}
+
+type historyReport struct {
+	Snapshots int64     `json:"snapshots"`
+	FirstTick int64     `json:"first_tick,omitempty"`
+	FirstTime time.Time `json:"first_time"`
+	LastTick  int64     `json:"last_tick,omitempty"`
+	LastTime  time.Time `json:"last_time"`
+}
+
+func runInfo(world string, _ []string) error {
+	store, info, err := openWorld(world)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	report := worldReport{WorldInfo: info, Path: mapdata.WorldPath(world), Dictionary: make(map[string]string)}
+	if _, err := os.Stat(report.Path); err != nil {
+		report.Path = mapdata.LogWorldPath(world)
+	}
+	if stat, err := os.Stat(report.Path); err == nil {
+		report.FileSize = stat.Size()
+	}
+	if stat, err := os.Stat(report.Path + "-wal"); err == nil {
+		report.WALSize = stat.Size()
+	}
+
+	// Limites dos chunks com terreno (os vazios marcam só o ar escaneado)
+	headers, err := store.Repo.ChunkHeaders()
+	if err != nil {
+		return err
+	}
+	var lo, hi util.DFCoord
+	solid := 0
+	for _, h := range headers {
+		if h.IsEmpty {
+			report.EmptyChunks++
+			continue
+		}
+		if solid == 0 {
+			lo, hi = h.Origin, h.Origin
+		}
+		lo = util.NewDFCoord(min(lo.X, h.Origin.X), min(lo.Y, h.Origin.Y), min(lo.Z, h.Origin.Z))
+		hi = util.NewDFCoord(max(hi.X, h.Origin.X), max(hi.Y, h.Origin.Y), max(hi.Z, h.Origin.Z))
+		solid++
+	}
+	if solid > 0 {
+		bmin, bmax := toCoord(lo), toCoord(hi.Add(util.NewDFCoord(15, 15, 0)))
+		report.BoundsMin, report.BoundsMax = &bmin, &bmax
+	}
+
+	if hist, err := store.HistoryRange(); err == nil && hist.Snapshots > 0 {
+		report.History = &historyReport{
+			Snapshots: hist.Snapshots,
+			FirstTick: hist.First.Tick, FirstTime: hist.First.Time,
+			LastTick: hist.Last.Tick, LastTime: hist.Last.Time,
+		}
+	}
+	if backups, err := mapdata.ListBackups(world); err == nil {
+		report.Backups = len(backups)
+	}
+	for _, key := range mapdata.WorldDictionaries {
+		if data, err := store.GetDictionary(key); err == nil && len(data) > 0 {
+			report.Dictionary[key] = formatBytes(int64(len(data)))
+		}
+	}
+
+	if jsonOutput {
+		return writeJSON(report)
+	}
+	fmt.Printf("Mundo:       %s\n", report.Name)
+	fmt.Printf("Arquivo:     %s (%s", report.Path, formatBytes(report.FileSize))
+	if report.WALSize > 0 {
+		fmt.Printf(" + %s no -wal", formatBytes(report.WALSize))
+	}
+	fmt.Println(")")
+	fmt.Printf("Formato:     %d\n", report.FormatVersion)
+	fmt.Printf("Atualizado:  %s\n", report.UpdatedAt.Format("02/01/2006 15:04:05"))
+	fmt.Printf("Mapa:        %dx%dx%d blocos\n", report.SizeX, report.SizeY, report.SizeZ)
+	fmt.Printf("Chunks:      %d (%d vazios)\n", report.ChunkCount, report.EmptyChunks)
+	if report.BoundsMin != nil {
+		fmt.Printf("Limites:     (%d, %d, %d) a (%d, %d, %d)\n",
+			report.BoundsMin.X, report.BoundsMin.Y, report.BoundsMin.Z,
+			report.BoundsMax.X, report.BoundsMax.Y, report.BoundsMax.Z)
+	} else {
+		fmt.Println("Limites:     (sem chunks com terreno)")
+	}
+	if h := report.History; h != nil {
+		fmt.Printf("Histórico:   %d versões, de %s a %s\n", h.Snapshots,
+			h.FirstTime.Format("02/01/2006 15:04"), h.LastTime.Format("02/01/2006 15:04"))
+	}
+	fmt.Printf("Cópias:      %d em %s\n", report.Backups, mapdata.BackupsDir)
+	keys := make([]string, 0, len(report.Dictionary))
+	for k := range report.Dictionary {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Printf("Dicionário:  %s (%s)\n", k, report.Dictionary[k])
+	}
+	return nil
+}
+
+// tileReport é um tile em dump-chunk.
+type tileReport struct {
+	X            int32   `json:"x"`
+	Y            int32   `json:"y"`
+	Z            int32   `json:"z"`
+	TileType     int32   `json:"tile_type"`
+	TileTypeName string  `json:"tile_type_name,omitempty"`
+	Material     matPair `json:"material"`
+	Base         matPair `json:"base_material"`
+	Layer        matPair `json:"layer_material"`
+	Vein         matPair `json:"vein_material"`
+	Construction matPair `json:"construction_item"`
+	Water        int32   `json:"water_level,omitempty"`
+	Magma        int32   `json:"magma_level,omitempty"`
+	Flow         *coord  `json:"flow,omitempty"`
+	RampType     int32   `json:"ramp_type,omitempty"`
+	Hidden       bool    `json:"hidden,omitempty"`
+	Light        bool    `json:"light,omitempty"`
+	Subterranean bool    `json:"subterranean,omitempty"`
+	Outside      bool    `json:"outside,omitempty"`
+	Aquifer      bool    `json:"aquifer,omitempty"`
+	Grass        int32   `json:"grass_percent,omitempty"`
+	Trunk        uint8   `json:"trunk_percent,omitempty"`
+	Dig          int32   `json:"dig_designation,omitempty"`
+}
+
+// chunkReport é a saída de dump-chunk.
+type chunkReport struct {
+	Origin            coord        `json:"origin"`
+	MTime             int64        `json:"mtime"`
+	IsEmpty           bool         `json:"is_empty"`
+	Plants            int          `json:"plants"`
+	Buildings         int          `json:"buildings"`
+	Items             int          `json:"items"`
+	ConstructionItems int          `json:"construction_items"`
+	Spatters          int          `json:"spatters"`
+	Engravings        int          `json:"engravings"`
+	Tiles             []tileReport `json:"tiles"`
+}
+
+func runDumpChunk(world string, args []string) error {
+	var pos [3]int32
+	for i, arg := range args {
+		v, err := strconv.ParseInt(arg, 10, 32)
+		if err != nil {
+			return fmt.Errorf("coordenada inválida %q", arg)
+		}
+		pos[i] = int32(v)
+	}
+
+	store, _, err := openWorld(world)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	origin := util.NewDFCoord(pos[0], pos[1], pos[2]).BlockCoord()
+	chunk, err := store.LoadChunk(origin)
+	if errors.Is(err, mapdata.ErrChunkNotFound) {
+		return fmt.Errorf("nenhum chunk gravado em %v", origin)
+	}
+	if err != nil {
+		return fmt.Errorf("chunk %v: %w", origin, err)
+	}
+	types, _ := store.StoredTiletypes()
+
+	report := chunkReport{
+		Origin:            toCoord(origin),
+		MTime:             chunk.MTime,
+		IsEmpty:           chunk.IsEmpty,
+		Plants:            len(chunk.Plants),
+		Buildings:         len(chunk.Buildings),
+		Items:             len(chunk.Items),
+		ConstructionItems: len(chunk.ConstructionItems),
+		Spatters:          len(chunk.SpatterPile),
+		Engravings:        len(chunk.Engravings),
+		Tiles:             []tileReport{},
+	}
+	for y := int32(0); y < 16; y++ {
+		for x := int32(0); x < 16; x++ {
+			t := chunk.Tile(x, y)
+			if t == nil {
+				continue
+			}
+			tile := tileReport{
+				X: t.Position.X, Y: t.Position.Y, Z: t.Position.Z,
+				TileType:     t.TileType,
+				Material:     toMatPair(t.Material),
+				Base:         toMatPair(t.BaseMaterial),
+				Layer:        toMatPair(t.LayerMaterial),
+				Vein:         toMatPair(t.VeinMaterial),
+				Construction: toMatPair(t.ConstructionItem),
+				Water:        t.WaterLevel,
+				Magma:        t.MagmaLevel,
+				RampType:     t.RampType,
+				Hidden:       t.Hidden,
+				Light:        t.Light,
+				Subterranean: t.Subterranean,
+				Outside:      t.Outside,
+				Aquifer:      t.Aquifer,
+				Grass:        t.GrassPercent,
+				Trunk:        t.TrunkPercent,
+				Dig:          int32(t.DigDesignation),
+			}
+			if tt := types[t.TileType]; tt != nil {
+				tile.TileTypeName = tt.Name
+			}
+			if t.FlowVector != (util.DFCoord{}) {
+				flow := toCoord(t.FlowVector)
+				tile.Flow = &flow
+			}
+			report.Tiles = append(report.Tiles, tile)
+		}
+	}
+	return writeJSON(report)
+}
+
+// censusEntry é uma linha do censo de stats.
+type censusEntry struct {
+	Key   string `json:"key"`
+	Name  string `json:"name,omitempty"`
+	Tiles int64  `json:"tiles"`
+}
+
+// statsReport é a saída de stats.
+type statsReport struct {
+	Chunks    int           `json:"chunks"`
+	Failed    int           `json:"failed"`
+	Tiles     int64         `json:"tiles"`
+	Hidden    int64         `json:"hidden"`
+	Tiletypes []censusEntry `json:"tiletypes"`
+	Materials []censusEntry `json:"materials"`
+}
+
+func runStats(world string, _ []string) error {
+	store, _, err := openWorld(world)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	types, _ := store.StoredTiletypes()
+	matNames, _ := store.StoredMaterialNames()
+
+	var report statsReport
+	tiletypes := make(map[int32]int64)
+	materials := make(map[dfproto.MatPair]int64)
+	report.Chunks, err = store.ScanStoredChunks(func(_ util.DFCoord, chunk *mapdata.Chunk, err error) {
+		if err != nil {
+			report.Failed++
+			return
+		}
+		for y := int32(0); y < 16; y++ {
+			for x := int32(0); x < 16; x++ {
+				t := chunk.Tile(x, y)
+				if t == nil {
+					continue
+				}
+				report.Tiles++
+				if t.Hidden {
+					report.Hidden++
+				}
+				tiletypes[t.TileType]++
+				materials[t.Material]++
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	for id, n := range tiletypes {
+		e := censusEntry{Key: fmt.Sprint(id), Tiles: n}
+		if tt := types[id]; tt != nil {
+			e.Name = tt.Name
+		}
+		report.Tiletypes = append(report.Tiletypes, e)
+	}
+	for mat, n := range materials {
+		report.Materials = append(report.Materials, censusEntry{
+			Key:   fmt.Sprintf("%d:%d", mat.MatType, mat.MatIndex),
+			Name:  matNames[mat],
+			Tiles: n,
+		})
+	}
+	sortCensus(report.Tiletypes)
+	sortCensus(report.Materials)
+
+	if jsonOutput {
+		return writeJSON(report)
+	}
+	fmt.Printf("Chunks: %d (%d ilegíveis)  Tiles: %d (%d ocultos)\n", report.Chunks, report.Failed, report.Tiles, report.Hidden)
+	printCensus("Tiletypes", report.Tiletypes, report.Tiles, statsTop)
+	printCensus("Materiais", report.Materials, report.Tiles, statsTop)
+	return nil
+}
+
+func sortCensus(entries []censusEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Tiles != entries[j].Tiles {
+			return entries[i].Tiles > entries[j].Tiles
+		}
+		return entries[i].Key < entries[j].Key
+	})
+}
+
+func printCensus(title string, entries []censusEntry, total int64, top int) {
+	fmt.Printf("\n%s (%d distintos)\n", title, len(entries))
+	for i, e := range entries {
+		if top > 0 && i == top {
+			fmt.Printf("  ... mais %d\n", len(entries)-top)
+			break
+		}
+		name := e.Name
+		if name == "" {
+			name = "?"
+		}
+		fmt.Printf("  %-9s %-40s %10d  %5.1f%%\n", e.Key, name, e.Tiles, 100*float64(e.Tiles)/float64(max(total, 1)))
+	}
+}
+
+// verifyFailure é um chunk que não decodificou.
+type verifyFailure struct {
+	Origin coord  `json:"origin"`
+	Error  string `json:"error"`
+}
+
+func runVerify(world string, _ []string) error {
+	store, _, err := openWorld(world)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	failures := []verifyFailure{}
+	start := time.Now()
+	visited, err := store.ScanStoredChunks(func(origin util.DFCoord, _ *mapdata.Chunk, err error) {
+		if err != nil {
+			failures = append(failures, verifyFailure{Origin: toCoord(origin), Error: err.Error()})
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		if err := writeJSON(struct {
+			Chunks   int             `json:"chunks"`
+			Failures []verifyFailure `json:"failures"`
+		}{visited, failures}); err != nil {
+			return err
+		}
+	} else {
+		for _, f := range failures {
+			fmt.Printf("FALHA (%d, %d, %d): %s\n", f.Origin.X, f.Origin.Y, f.Origin.Z, f.Error)
+		}
+		fmt.Printf("%d chunks verificados em %v, %d com falha\n", visited, time.Since(start).Round(time.Millisecond), len(failures))
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%d chunks não decodificaram", len(failures))
+	}
+	return nil
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
@@ -0,0 +1,145 @@
+// fvtool inspeciona e faz manutenção nos mundos salvos (saves/*.fv e *.fvlog) sem
+// abrir o SQLite à mão. Os comandos de leitura nunca alteram o banco; vacuum,
+// purge-empty e migrate precisam que o servidor não esteja usando o mundo.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"FortressVision/shared/mapdata"
+)
+
+// command é um subcomando do fvtool. Os argumentos depois do mundo são nargs
+// obrigatórios e até optional opcionais. Comandos com noWorld recebem todos os
+// argumentos em args (world vazio), já dentro da pasta de -dir.
+type command struct {
+	name     string
+	args     string
+	help     string
+	nargs    int
+	optional int
+	noWorld  bool
+	run      func(world string, args []string) error
+}
+
+var commands = []command{
+	{name: "info", args: "<mundo>", help: "Metadados, limites, total de chunks e versão do formato", run: runInfo},
+	{name: "dump-chunk", args: "<mundo> <x> <y> <z>", help: "Tiles decodificados do chunk que contém (x, y, z), em JSON", nargs: 3, run: runDumpChunk},
+	{name: "stats", args: "<mundo>", help: "Censo de tiletypes e materiais", run: runStats},
+	{name: "verify", args: "<mundo>", help: "Decodifica todos os chunks e lista as falhas", run: runVerify},
+	{name: "vacuum", args: "<mundo>", help: "Compacta o banco (VACUUM; no .fvlog, reescreve o arquivo)", run: runVacuum},
+	{name: "purge-empty", args: "<mundo>", help: "Apaga os chunks vazios (ar) do banco", run: runPurgeEmpty},
+	{name: "migrate", args: "<mundo>", help: "Aplica as migrações de formato pendentes", run: runMigrate},
+	{name: "export", args: "<mundo> [arquivo.fvz]", help: "Exporta o mundo como arquivo portátil .fvz", optional: 1, run: runExport},
+	{name: "import", args: "<arquivo.fvz> [nome]", help: "Cria um mundo em saves/ a partir de um .fvz", nargs: 1, optional: 1, noWorld: true, run: runImport},
+}
+
+var (
+	// workDir é a pasta de onde o fvtool foi chamado (caminhos de arquivo são relativos a ela).
+	workDir string
+	// jsonOutput troca a saída em texto de info, stats e verify por JSON.
+	jsonOutput bool
+	// statsTop limita as linhas de cada tabela de stats no modo texto (0 = todas).
+	statsTop int
+)
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintln(out, "Uso: fvtool [opções] <comando> <mundo> [argumentos]")
+	fmt.Fprintln(out, "\n<mundo> é o nome de um mundo em saves/ ou o caminho de um arquivo .fv/.fvlog.")
+	fmt.Fprintln(out, "\nComandos:")
+	for _, c := range commands {
+		fmt.Fprintf(out, "  %-12s %-24s %s\n", c.name, c.args, c.help)
+	}
+	fmt.Fprintln(out, "\nOpções:")
+	flag.PrintDefaults()
+}
+
+func main() {
+	dir := flag.String("dir", ".", "Pasta que contém saves/")
+	flag.BoolVar(&jsonOutput, "json", false, "Saída em JSON (info, stats e verify)")
+	flag.IntVar(&statsTop, "top", 20, "Linhas por tabela em stats (0 = todas)")
+	verbose := flag.Bool("v", false, "Mostrar o log interno do mapdata")
+	flag.Usage = usage
+	flag.Parse()
+
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+	if flag.NArg() < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	workDir, _ = os.Getwd()
+	name, args := flag.Arg(0), flag.Args()[1:]
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == name {
+			cmd = &commands[i]
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "fvtool: comando desconhecido %q\n\n", name)
+		usage()
+		os.Exit(2)
+	}
+	if !cmd.noWorld {
+		args = args[1:]
+	}
+	if len(args) < cmd.nargs || len(args) > cmd.nargs+cmd.optional {
+		fmt.Fprintf(os.Stderr, "Uso: fvtool %s %s\n", cmd.name, cmd.args)
+		os.Exit(2)
+	}
+
+	var world string
+	var err error
+	if cmd.noWorld {
+		err = os.Chdir(*dir)
+	} else {
+		world, err = resolveWorld(*dir, flag.Arg(1))
+	}
+	if err == nil {
+		err = cmd.run(world, args)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fvtool %s: %v\n", cmd.name, err)
+		os.Exit(1)
+	}
+}
+
+// userPath resolve um caminho dado na linha de comando, relativo a workDir.
+func userPath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(workDir, path)
+}
+
+// resolveWorld entra na pasta do mundo e devolve o nome dele. Aceita o nome de um
+// mundo em <dir>/saves ou o caminho de um arquivo saves/<mundo>.fv (ou .fvlog).
+func resolveWorld(dir, arg string) (string, error) {
+	ext := filepath.Ext(arg)
+	if ext == ".fv" || ext == ".fvlog" {
+		abs := userPath(arg)
+		savesDir := filepath.Dir(abs)
+		if filepath.Base(savesDir) != mapdata.SavesDir {
+			return "", fmt.Errorf("%s não está numa pasta %s/", arg, mapdata.SavesDir)
+		}
+		dir = filepath.Dir(savesDir)
+		arg = strings.TrimSuffix(filepath.Base(abs), ext)
+	}
+	if err := os.Chdir(dir); err != nil {
+		return "", err
+	}
+	if !mapdata.WorldExists(arg) {
+		return "", fmt.Errorf("mundo %q não encontrado em %s", arg, filepath.Join(dir, mapdata.SavesDir))
+	}
+	return arg, nil
+}
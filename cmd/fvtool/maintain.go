@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"FortressVision/shared/mapdata"
+)
+
+// maintenanceFile devolve o arquivo do mundo que a manutenção altera: o banco
+// SQLite (.fv) se existir, senão o log (.fvlog).
+func maintenanceFile(world string) (string, mapdata.StorageBackend, error) {
+	if path := mapdata.WorldPath(world); exists(path) {
+		return path, mapdata.BackendSQLite, nil
+	}
+	if path := mapdata.LogWorldPath(world); exists(path) {
+		return path, mapdata.BackendLog, nil
+	}
+	return "", "", fmt.Errorf("mundo %q não tem banco (.fv nem .fvlog)", world)
+}
+
+// openForMaintenance abre o banco do mundo para escrita. A abertura aplica as
+// migrações pendentes, como no servidor.
+func openForMaintenance(world string, backend mapdata.StorageBackend) (*mapdata.MapDataStore, error) {
+	store := mapdata.NewMapDataStore()
+	store.Backend = backend
+	if err := store.OpenInitialize(world); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func runVacuum(world string, _ []string) error {
+	path, backend, err := maintenanceFile(world)
+	if err != nil {
+		return err
+	}
+	before := dbSize(path)
+	store, err := openForMaintenance(world, backend)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if err := store.Vacuum(); err != nil {
+		return err
+	}
+	after := dbSize(path)
+	fmt.Printf("%s: %s -> %s\n", path, formatBytes(before), formatBytes(after))
+	return nil
+}
+
+func runPurgeEmpty(world string, _ []string) error {
+	path, backend, err := maintenanceFile(world)
+	if err != nil {
+		return err
+	}
+	store, err := openForMaintenance(world, backend)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	n, err := store.PurgeEmpty()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d chunks vazios apagados de %s\n", n, path)
+	if n > 0 && backend == mapdata.BackendSQLite { // O log já é reescrito sem eles
+		fmt.Println("Rode fvtool vacuum para devolver o espaço ao disco.")
+	}
+	return nil
+}
+
+func runMigrate(world string, _ []string) error {
+	from, to, err := mapdata.MigrateWorld(world)
+	if err != nil {
+		return err
+	}
+	if from == to {
+		fmt.Printf("%s já está no formato %d\n", mapdata.WorldPath(world), to)
+		return nil
+	}
+	fmt.Printf("%s migrado do formato %d para o %d\n", mapdata.WorldPath(world), from, to)
+	return nil
+}
+
+// dbSize soma o tamanho do banco e do seu -wal.
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func dbSize(path string) int64 {
+	var total int64
+	for _, p := range []string{path, path + "-wal"} {
+		if stat, err := os.Stat(p); err == nil {
+			total += stat.Size()
+		}
+	}
+	return total
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"FortressVision/servidor/internal/dfhack"
+	"FortressVision/shared/mapdata"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// registerAPI registra os endpoints REST de administração do servidor.
+// importLimit é o tamanho máximo (bytes) de um .fvz recebido em /api/worlds/import.
+func registerAPI(hub *Hub, dfClient *dfhack.Client, registry *WorldRegistry, scanner *ServerScanner, importLimit int64) {
+	// GET /api/scan → andamento da varredura total
+	http.HandleFunc("/api/scan", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, scanner.Progress())
+	})
+
+	// POST /api/scan/rescan?min_z=N&max_z=M → força nova varredura de um intervalo de níveis Z
+	http.HandleFunc("/api/scan/rescan", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "use POST", http.StatusMethodNotAllowed)
+			return
+		}
+		minZ, errMin := strconv.Atoi(r.URL.Query().Get("min_z"))
+		maxZ, errMax := strconv.Atoi(r.URL.Query().Get("max_z"))
+		if errMin != nil || errMax != nil {
+			http.Error(w, "parâmetros min_z e max_z são obrigatórios", http.StatusBadRequest)
+			return
+		}
+		if err := scanner.RequestRescan(int32(minZ), int32(maxZ)); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, scanner.Progress())
+	})
+
+	// GET /api/worlds → mundos salvos em saves/ com seus metadados
+	http.HandleFunc("/api/worlds", func(w http.ResponseWriter, r *http.Request) {
+		worlds, err := mapdata.ListWorlds()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"live":       registry.Live().CurrentWorld(),
+			"selectable": dfClient == nil,
+			"archived":   registry.Archived(),
+			"worlds":     worlds,
+		})
+	})
+
+	// POST /api/worlds/select?name=X → troca o mundo ao vivo (apenas modo offline).
+	// Sessões individuais escolhem mundos arquivados com /ws?world=X ou SELECT_WORLD.
+	http.HandleFunc("/api/worlds/select", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "use POST", http.StatusMethodNotAllowed)
+			return
+		}
+		name := r.URL.Query().Get("name")
+		if name == "" {
+			http.Error(w, "parâmetro name é obrigatório", http.StatusBadRequest)
+			return
+		}
+		if err := selectOfflineWorld(hub, dfClient, registry, name); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"live": registry.Live().CurrentWorld()})
+	})
+
+	// GET /api/worlds/export?name=X → baixa o mundo salvo como arquivo portátil .fvz
+	http.HandleFunc("/api/worlds/export", func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Query().Get("name")
+		if !mapdata.WorldExists(name) {
+			http.Error(w, fmt.Sprintf("mundo %q não encontrado", name), http.StatusNotFound)
+			return
+		}
+		// O mundo ao vivo pode ter chunks só na RAM
+		if live := registry.Live(); live.CurrentWorld() == name {
+			live.Save(name)
+		}
+
+		// O zip vai para um temporário: um erro no meio não pode chegar como download válido
+		tmp, err := os.CreateTemp("tmp", "export-*"+mapdata.ArchiveExt)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		manifest, err := mapdata.ExportWorld(name, tmp)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("[API] Mundo %s exportado (%d chunks)", name, manifest.ChunkCount)
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+mapdata.ArchiveExt))
+		http.ServeContent(w, r, name+mapdata.ArchiveExt, time.Now(), tmp)
+	})
+
+	// POST /api/worlds/import?name=X (corpo: arquivo .fvz) → cria o mundo em saves/.
+	// Sem name, usa o nome gravado no arquivo. Mundos existentes nunca são sobrescritos.
+	// Corpos maiores que importLimit são recusados (413).
+	http.HandleFunc("/api/worlds/import", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "use POST", http.StatusMethodNotAllowed)
+			return
+		}
+		if r.ContentLength > importLimit {
+			http.Error(w, fmt.Sprintf("arquivo maior que o limite de %d MB", importLimit>>20), http.StatusRequestEntityTooLarge)
+			return
+		}
+		tmp, size, err := receiveUpload(w, r, importLimit, "import-*"+mapdata.ArchiveExt)
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			http.Error(w, fmt.Sprintf("arquivo maior que o limite de %d MB", importLimit>>20), http.StatusRequestEntityTooLarge)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer removeTempFile(tmp)
+
+		manifest, err := mapdata.ImportWorld(tmp, size, r.URL.Query().Get("name"))
+		switch {
+		case errors.Is(err, mapdata.ErrWorldExists):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("[API] Mundo %s importado (%d chunks)", manifest.WorldName, manifest.ChunkCount)
+		writeJSON(w, http.StatusCreated, manifest)
+	})
+}
+
+// writeJSON serializa a resposta de um endpoint REST.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("[API] Erro ao serializar resposta: %v", err)
+	}
+}
+
+// receiveUpload copia o corpo da requisição, até limit bytes, para um arquivo
+// temporário em tmp/ e retorna o arquivo aberto e o tamanho. Em qualquer erro o
+// arquivo já foi apagado; senão o chamador o apaga com removeTempFile.
+func receiveUpload(w http.ResponseWriter, r *http.Request, limit int64, pattern string) (*os.File, int64, error) {
+	tmp, err := os.CreateTemp("tmp", pattern)
+	if err != nil {
+		return nil, 0, err
+	}
+	size, err := io.Copy(tmp, http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		removeTempFile(tmp)
+		return nil, 0, err
+	}
+	return tmp, size, nil
+}
+
+// removeTempFile fecha e apaga um arquivo temporário.
+func removeTempFile(f *os.File) {
+	f.Close()
+	if err := os.Remove(f.Name()); err != nil && !os.IsNotExist(err) {
+		log.Printf("[API] Aviso: não foi possível apagar %s: %v", f.Name(), err)
+	}
+}
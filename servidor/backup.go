@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"FortressVision/shared/mapdata"
+)
+
+// Política padrão das cópias de segurança (ver backupPolicyFromEnv).
+var defaultBackupPolicy = mapdata.BackupPolicy{Interval: 6 * time.Hour, Keep: 4, KeepDays: 7}
+
+// backupPolicyFromEnv lê a política das cópias de segurança do banco do mundo:
+//
+//	FV_BACKUP_INTERVAL  intervalo entre cópias (padrão 6h; 0 = desligado)
+//	FV_BACKUP_KEEP      quantas cópias recentes manter (padrão 4)
+//	FV_BACKUP_DAYS      por quantos dias manter a última cópia de cada dia (padrão 7)
+func backupPolicyFromEnv() (mapdata.BackupPolicy, error) {
+	policy := defaultBackupPolicy
+	if v := os.Getenv("FV_BACKUP_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if v == "0" {
+			d, err = 0, nil
+		}
+		if err != nil || d < 0 {
+			return policy, fmt.Errorf("FV_BACKUP_INTERVAL=%q (use 0 ou uma duração como 6h)", v)
+		}
+		policy.Interval = d
+	}
+	for _, opt := range []struct {
+		name string
+		dst  *int
+	}{{"FV_BACKUP_KEEP", &policy.Keep}, {"FV_BACKUP_DAYS", &policy.KeepDays}} {
+		if v := os.Getenv(opt.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return policy, fmt.Errorf("%s=%q (use um número >= 0)", opt.name, v)
+			}
+			*opt.dst = n
+		}
+	}
+	if policy.Keep == 0 && policy.KeepDays == 0 && policy.Interval > 0 {
+		return policy, errors.New("FV_BACKUP_KEEP e FV_BACKUP_DAYS não podem ser ambos 0 (use FV_BACKUP_INTERVAL=0 para desligar)")
+	}
+	return policy, nil
+}
+
+// runBackups copia o banco do mundo ao vivo a cada store.Backup.Interval.
+// Cópias sem mudanças no banco são puladas por BackupWorld.
+func runBackups(store *mapdata.MapDataStore) {
+	for {
+		time.Sleep(store.Backup.Interval)
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("[Backup-Loop] Recuperado de pânico: %v", r)
+				}
+			}()
+			start := time.Now()
+			path, err := store.BackupWorld()
+			switch {
+			case errors.Is(err, mapdata.ErrBackupUnsupported):
+				// Sem banco aberto ou backend sem cópias (memory/log)
+			case err != nil:
+				log.Printf("[Backup] ERRO: %v", err)
+			case path != "":
+				log.Printf("[Backup] Cópia de segurança gravada em %s (%v)", path, time.Since(start).Round(time.Millisecond))
+			}
+		}()
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"FortressVision/servidor/internal/demo"
+	"FortressVision/shared/mapdata"
+)
+
+// demoVersionKey marca em WorldMetadata um banco criado pelo gerador de demonstração.
+const demoVersionKey = "DemoVersion"
+
+// openDemoWorld abre o mundo de demonstração (--demo) no store, gerando-o se ainda
+// não existir ou se foi gerado por outra versão do gerador. Depois disso o servidor
+// segue como no modo offline: o mundo é servido a partir do banco.
+func openDemoWorld(store *mapdata.MapDataStore) error {
+	existed := mapdata.WorldExists(demo.WorldName)
+	if err := store.OpenInitialize(demo.WorldName); err != nil {
+		return err
+	}
+	version, _ := store.GetMetadata(demoVersionKey)
+	if version == strconv.Itoa(demo.Version) {
+		log.Printf("[Demo] Usando o mundo de demonstração já gerado em %s", mapdata.WorldPath(demo.WorldName))
+		return nil
+	}
+	if existed && version == "" && store.HasData() {
+		return fmt.Errorf("%s já existe e não foi criado por --demo; renomeie-o para gerar a demonstração",
+			mapdata.WorldPath(demo.WorldName))
+	}
+
+	start := time.Now()
+	opts := demo.DefaultOptions()
+	log.Printf("[Demo] Gerando mundo de demonstração (%dx%d blocos, %d níveis)...", opts.BlocksX, opts.BlocksY, opts.Levels)
+	world := demo.New(opts)
+	blocks := demo.Fill(store, world)
+
+	// Mesmo conteúdo que persistStaticData grava quando o DF está conectado
+	if err := store.SaveMapInfo(world.MapInfo()); err != nil {
+		return err
+	}
+	for key, marshal := range map[string]func() ([]byte, error){
+		"TiletypeList": demo.TiletypeList().Marshal,
+		"MaterialList": demo.MaterialList().Marshal,
+	} {
+		data, err := marshal()
+		if err != nil {
+			return err
+		}
+		if err := store.SaveDictionary(key, data); err != nil {
+			return err
+		}
+	}
+	saved, err := store.Save(demo.WorldName)
+	if err != nil {
+		return err
+	}
+	if err := store.SaveEntities(); err != nil {
+		return err
+	}
+	// Os MTimes recomeçam com o gerador: clientes com cache precisam reenviar tudo
+	if err := store.NewGeneration(); err != nil {
+		return err
+	}
+	if err := store.SaveMetadata(demoVersionKey, strconv.Itoa(demo.Version)); err != nil {
+		return err
+	}
+	log.Printf("[Demo] %d blocos gerados (%d chunks gravados) em %v. Fortaleza em %v.",
+		blocks, saved, time.Since(start).Round(time.Millisecond), world.Center())
+	return nil
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"FortressVision/shared/mapdata"
+	"FortressVision/shared/proto/fvnet"
+	"FortressVision/shared/util"
+
+	"github.com/gorilla/websocket"
+)
+
+// diffReport é a saída JSON de "servidor diff".
+type diffReport struct {
+	Before string `json:"before"`
+	After  string `json:"after"`
+	*mapdata.MapDiff
+}
+
+// runDiffCommand implementa o subcomando diff. Compara dois mundos salvos ou duas
+// versões do histórico de um mundo e escreve as mudanças em JSON na saída padrão:
+//
+//	servidor diff [-tiles] <mundoA> <mundoB>
+//	servidor diff [-tiles] -from <instante> [-to <instante>] <mundo>
+//
+// Um instante é um tick do DF (número), uma duração até agora (ex.: 24h) ou uma
+// data (2006-01-02T15:04:05Z07:00 ou "2006-01-02 15:04" no horário local).
+// Sem -to, a comparação é com o estado atual gravado.
+func runDiffCommand(args []string) int {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	from := fs.String("from", "", "Instante de base no histórico do mundo")
+	to := fs.String("to", "", "Instante final no histórico (padrão: estado atual)")
+	tiles := fs.Bool("tiles", false, "Incluir a lista de tiles alterados")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Uso: servidor diff [-tiles] <mundoA> <mundoB>")
+		fmt.Fprintln(fs.Output(), "     servidor diff [-tiles] -from <instante> [-to <instante>] <mundo>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if (*from == "" && fs.NArg() != 2) || (*from != "" && fs.NArg() != 1) {
+		fs.Usage()
+		return 2
+	}
+
+	report, err := diffWorlds(fs.Args(), *from, *to, *tiles)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "diff: %v\n", err)
+		return 1
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		fmt.Fprintf(os.Stderr, "diff: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// diffWorlds abre os mundos de worlds (somente leitura) e monta o relatório.
+func diffWorlds(worlds []string, from, to string, tiles bool) (*diffReport, error) {
+	stores := make([]*mapdata.MapDataStore, len(worlds))
+	for i, name := range worlds {
+		s := mapdata.NewMapDataStore()
+		s.MemoryBudget = defaultCacheMB << 20 // Os níveis já comparados podem sair da RAM
+		if err := s.OpenReadOnly(name); err != nil {
+			return nil, err
+		}
+		defer s.Close()
+		stores[i] = s
+	}
+
+	last := stores[len(stores)-1]
+	report := &diffReport{Before: worlds[0], After: last.WorldName}
+	var before, after mapdata.ChunkSource = stores[0].LoadRegion, last.LoadRegion
+	if from != "" {
+		at, err := parseHistoryPoint(from)
+		if err != nil {
+			return nil, err
+		}
+		before = stores[0].SourceAt(at)
+		report.Before = fmt.Sprintf("%s@%s", worlds[0], from)
+		if to != "" {
+			at, err := parseHistoryPoint(to)
+			if err != nil {
+				return nil, err
+			}
+			after = last.SourceAt(at)
+			report.After = fmt.Sprintf("%s@%s", last.WorldName, to)
+		}
+	}
+
+	// Caixa que cobre os chunks gravados dos dois lados (as coordenadas do DF
+	// são absolutas: o embarque raramente começa em (0, 0, 0))
+	var lo, hi util.DFCoord
+	found := false
+	for _, s := range stores {
+		bMin, bMax, ok, err := s.Repo.ChunkBounds()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		bMax = bMax.Add(util.NewDFCoord(15, 15, 0))
+		if !found {
+			lo, hi, found = bMin, bMax, true
+			continue
+		}
+		lo = util.NewDFCoord(min(lo.X, bMin.X), min(lo.Y, bMin.Y), min(lo.Z, bMin.Z))
+		hi = util.NewDFCoord(max(hi.X, bMax.X), max(hi.Y, bMax.Y), max(hi.Z, bMax.Z))
+	}
+	if !found {
+		return nil, errors.New("nenhum chunk gravado nos mundos comparados")
+	}
+
+	types, err := last.StoredTiletypes()
+	if err != nil {
+		log.Printf("[Diff] AVISO: sem TiletypeList em %s (%v); escavações e construções não serão detectadas", last.WorldName, err)
+	}
+	diff, err := mapdata.DiffRegion(before, after, lo, hi, mapdata.DiffOptions{Tiletypes: types, Tiles: tiles})
+	if err != nil {
+		return nil, err
+	}
+	report.MapDiff = diff
+	return report, nil
+}
+
+// parseHistoryPoint lê um instante da linha de comando (ver runDiffCommand).
+func parseHistoryPoint(s string) (mapdata.HistoryPoint, error) {
+	if tick, err := strconv.ParseInt(s, 10, 64); err == nil && tick > 0 {
+		return mapdata.HistoryPoint{Tick: tick}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return mapdata.HistoryPoint{Time: time.Now().Add(-d)}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return mapdata.HistoryPoint{Time: t}, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local); err == nil {
+		return mapdata.HistoryPoint{Time: t}, nil
+	}
+	return mapdata.HistoryPoint{}, fmt.Errorf("instante inválido %q (use um tick, uma duração como 24h ou uma data)", s)
+}
+
+// sendDiffToClient envia as mudanças da região pedida desde o instante pedido até
+// o estado atual do mundo da sessão (sobreposição de diferenças da linha do tempo).
+func sendDiffToClient(hub *Hub, conn *websocket.Conn, store *mapdata.MapDataStore, req *fvnet.HistoryRequest) {
+	z := req.CenterZ
+	regionMin := util.NewDFCoord(req.CenterX-req.Radius, req.CenterY-req.Radius, z)
+	regionMax := util.NewDFCoord(req.CenterX+req.Radius, req.CenterY+req.Radius, z)
+	at := mapdata.HistoryPoint{Tick: req.Tick, Time: time.UnixMilli(req.Time)}
+
+	types, err := store.StoredTiletypes()
+	if err != nil {
+		log.Printf("[Diff] AVISO: sem TiletypeList em %s: %v", store.WorldName, err)
+	}
+	diff, err := mapdata.DiffRegion(store.SourceAt(at), store.LoadRegion, regionMin, regionMax, mapdata.DiffOptions{Tiletypes: types, Tiles: true})
+	if err != nil {
+		log.Printf("[Diff] ERRO ao comparar região %v-%v desde %+v: %v", regionMin, regionMax, at, err)
+		return
+	}
+
+	msg := &fvnet.DiffResult{Time: req.Time, Tick: req.Tick}
+	for _, level := range diff.Levels {
+		msg.Levels = append(msg.Levels, &fvnet.DiffLevel{
+			Z:           level.Z,
+			Dug:         int32(level.Dug),
+			Constructed: int32(level.Constructed),
+			Flooded:     int32(level.Flooded),
+			Revealed:    int32(level.Revealed),
+		})
+	}
+	for _, tile := range diff.Tiles {
+		msg.Tiles = append(msg.Tiles, &fvnet.DiffTile{X: tile.X, Y: tile.Y, Z: tile.Z, Kind: uint32(tile.Kind)})
+	}
+	hub.SendProtoMessage(conn, fvnet.Envelope_DIFF_RESULT, msg)
+	log.Printf("[Diff] Região Z=%d desde %+v → %d tiles alterados", z, at, len(msg.Tiles))
+}
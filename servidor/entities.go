@@ -0,0 +1,62 @@
+package main
+
+import (
+	"FortressVision/shared/mapdata"
+	"FortressVision/shared/proto/fvnet"
+)
+
+// buildingSyncEvery é de quantas voltas do loop de unidades (1s cada) sai uma
+// nova leitura das construções do DF.
+const buildingSyncEvery = 30
+
+// buildingListMessage monta a BuildingList com as construções do store.
+// Ao vivo e offline saem do mesmo lugar: offline o store as carregou do banco.
+func buildingListMessage(store *mapdata.MapDataStore) *fvnet.BuildingList {
+	buildings := store.BuildingList()
+	msg := &fvnet.BuildingList{Buildings: make([]*fvnet.Building, 0, len(buildings))}
+	for _, b := range buildings {
+		building := &fvnet.Building{
+			Index:     b.Index,
+			MatType:   b.Material.MatType,
+			MatIndex:  b.Material.MatIndex,
+			MinX:      b.MinPos.X,
+			MinY:      b.MinPos.Y,
+			MinZ:      b.MinPos.Z,
+			MaxX:      b.MaxPos.X,
+			MaxY:      b.MaxPos.Y,
+			MaxZ:      b.MaxPos.Z,
+			Direction: int32(b.Direction),
+		}
+		if def := b.BuildingType; def != nil {
+			building.Type = def.BuildingType.BuildingType
+			building.Subtype = def.BuildingType.BuildingSubtype
+			building.Custom = def.BuildingType.BuildingCustom
+			building.TypeId = def.ID
+			building.Name = def.Name
+		}
+		msg.Buildings = append(msg.Buildings, building)
+	}
+	return msg
+}
+
+// creatureListMessage monta a CreatureList com as unidades do store (última posição conhecida).
+func creatureListMessage(store *mapdata.MapDataStore) *fvnet.CreatureList {
+	units := store.UnitList()
+	msg := &fvnet.CreatureList{Creatures: make([]*fvnet.Creature, 0, len(units))}
+	for _, u := range units {
+		msg.Creatures = append(msg.Creatures, &fvnet.Creature{
+			Id:        u.ID,
+			Name:      u.Name,
+			RaceType:  u.Race.MatType,
+			RaceIndex: u.Race.MatIndex,
+			X:         u.Pos.X,
+			Y:         u.Pos.Y,
+			Z:         u.Pos.Z,
+			Flags1:    u.Flags1,
+			Flags2:    u.Flags2,
+			Flags3:    u.Flags3,
+			Dead:      u.IsDead,
+		})
+	}
+	return msg
+}
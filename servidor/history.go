@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"FortressVision/shared/mapdata"
+	"FortressVision/shared/proto/fvnet"
+	"FortressVision/shared/util"
+
+	"github.com/gorilla/websocket"
+)
+
+// historyPolicyFromEnv lê a política do histórico de chunks:
+//
+//	FV_HISTORY_INTERVAL  vazio = histórico desligado; 0 = guarda toda mudança gravada;
+//	                     uma duração (ex.: 10m) = no máximo uma versão por chunk nesse intervalo
+//	FV_HISTORY_KEEP      por quanto tempo as versões são mantidas (ex.: 720h); vazio = para sempre
+func historyPolicyFromEnv() (mapdata.HistoryPolicy, error) {
+	var policy mapdata.HistoryPolicy
+	interval := os.Getenv("FV_HISTORY_INTERVAL")
+	if interval == "" {
+		return policy, nil
+	}
+	d, err := time.ParseDuration(interval)
+	if interval == "0" {
+		d, err = 0, nil
+	}
+	if err != nil || d < 0 {
+		return policy, fmt.Errorf("FV_HISTORY_INTERVAL=%q (use 0 ou uma duração como 10m)", interval)
+	}
+	policy.Enabled = true
+	policy.Interval = d
+
+	if keep := os.Getenv("FV_HISTORY_KEEP"); keep != "" {
+		d, err := time.ParseDuration(keep)
+		if err != nil || d <= 0 {
+			return policy, fmt.Errorf("FV_HISTORY_KEEP=%q (use uma duração como 720h)", keep)
+		}
+		policy.MaxAge = d
+	}
+	return policy, nil
+}
+
+// sendHistoryInfo informa ao cliente o intervalo da linha do tempo do mundo ligado.
+func (s *session) sendHistoryInfo() {
+	info := &fvnet.HistoryInfo{}
+	hr, err := s.store.HistoryRange()
+	if err != nil {
+		log.Printf("[History] Histórico indisponível em %s: %v", s.store.WorldName, err)
+	} else if hr.Snapshots > 0 {
+		info.Enabled = true
+		info.FirstTime = hr.First.Time.UnixMilli()
+		info.LastTime = hr.Last.Time.UnixMilli()
+		info.FirstTick = hr.First.Tick
+		info.LastTick = hr.Last.Tick
+		info.Snapshots = hr.Snapshots
+	}
+	s.hub.SendProtoMessage(s.conn, fvnet.Envelope_HISTORY_INFO, info)
+}
+
+// streamHistoryToClient envia a região pedida como ela estava no instante pedido.
+// Assim como no streaming ao vivo, toda coordenada recebe resposta: chunks que
+// ainda não existiam naquele instante vão como "Ar".
+func streamHistoryToClient(hub *Hub, conn *websocket.Conn, store *mapdata.MapDataStore, req *fvnet.HistoryRequest) {
+	z := req.CenterZ
+	regionMin := util.NewDFCoord(req.CenterX-req.Radius, req.CenterY-req.Radius, z).BlockCoord()
+	regionMax := util.NewDFCoord(req.CenterX+req.Radius, req.CenterY+req.Radius, z).BlockCoord()
+	at := mapdata.HistoryPoint{Tick: req.Tick, Time: time.UnixMilli(req.Time)}
+
+	chunks, err := store.RegionAt(regionMin, regionMax, at)
+	if err != nil {
+		log.Printf("[History] ERRO ao montar região %v-%v em %+v: %v", regionMin, regionMax, at, err)
+		return
+	}
+
+	sent := 0
+	for x := regionMin.X; x <= regionMax.X; x += 16 {
+		for y := regionMin.Y; y <= regionMax.Y; y += 16 {
+			origin := util.NewDFCoord(x, y, z)
+			msg := &fvnet.MapChunkMessage{ChunkX: origin.X, ChunkY: origin.Y, ChunkZ: origin.Z}
+			if chunk, ok := chunks[origin]; ok && !chunk.IsEmpty {
+				voxelData, err := mapdata.EncodeChunk(chunk, mapdata.LayersAll)
+				if err != nil {
+					log.Printf("[History] Erro ao codificar chunk %v: %v", origin, err)
+					continue
+				}
+				msg.VoxelData = voxelData
+				sent++
+			}
+			hub.SendProtoMessage(conn, fvnet.Envelope_HISTORY_CHUNK, msg)
+		}
+	}
+	log.Printf("[History] Linha do tempo → %d chunks de %d enviados (Z=%d)", sent, len(chunks), z)
+}
@@ -0,0 +1,99 @@
+package demo
+
+import (
+	"FortressVision/shared/mapdata"
+	"FortressVision/shared/pkg/dfproto"
+	"FortressVision/shared/util"
+)
+
+// Block monta o MapBlock de 16x16 tiles com origem (x, y, z), como GetBlockList
+// devolveria. Retorna nil para um bloco só de ar.
+func (w *World) Block(x, y, z int32) *dfproto.MapBlock {
+	block := &dfproto.MapBlock{
+		MapX:           x,
+		MapY:           y,
+		MapZ:           z,
+		Tiles:          make([]int32, 256),
+		Materials:      make([]dfproto.MatPair, 256),
+		BaseMaterials:  make([]dfproto.MatPair, 256),
+		LayerMaterials: make([]dfproto.MatPair, 256),
+		VeinMaterials:  make([]dfproto.MatPair, 256),
+		Water:          make([]int32, 256),
+		Magma:          make([]int32, 256),
+		Hidden:         make([]bool, 256),
+		Light:          make([]bool, 256),
+		Subterranean:   make([]bool, 256),
+		Outside:        make([]bool, 256),
+		GrassPercent:   make([]int32, 256),
+	}
+	empty := true
+	for ly := int32(0); ly < 16; ly++ {
+		for lx := int32(0); lx < 16; lx++ {
+			i := lx + ly*16
+			tx, ty := x+lx, y+ly
+			c := w.cellAt(tx, ty, z)
+			outside := z >= w.skyLevel(tx, ty)
+
+			block.Tiles[i] = c.tiletype
+			block.Materials[i] = c.material
+			block.BaseMaterials[i] = c.base
+			block.LayerMaterials[i] = c.base
+			block.VeinMaterials[i] = c.vein
+			block.Water[i] = c.water
+			block.Magma[i] = c.magma
+			block.Light[i] = outside
+			block.Outside[i] = outside
+			block.Subterranean[i] = !outside
+			block.GrassPercent[i] = c.grass
+
+			if c.flow != (util.DFCoord{}) {
+				block.Flows = append(block.Flows, dfproto.FlowInfo{
+					Pos:  dfproto.Coord{X: tx, Y: ty, Z: z},
+					Dest: dfproto.Coord{X: tx + c.flow.X, Y: ty + c.flow.Y, Z: z + c.flow.Z},
+				})
+			}
+			if c.plant {
+				block.Plants = append(block.Plants, dfproto.PlantDetail{
+					Pos:      dfproto.Coord{X: lx, Y: ly},
+					Material: c.material,
+				})
+			}
+			if c.tiletype != ttOpenSpace || c.water > 0 || c.magma > 0 {
+				empty = false
+			}
+		}
+	}
+	for _, b := range w.buildings {
+		if b.PosZMin == z && b.PosXMin >= x && b.PosXMin < x+16 && b.PosYMin >= y && b.PosYMin < y+16 {
+			block.Buildings = append(block.Buildings, b)
+		}
+	}
+	if empty && len(block.Buildings) == 0 {
+		return nil
+	}
+	return block
+}
+
+// Fill grava o mundo inteiro no store pelo mesmo caminho dos blocos do DFHack
+// (StoreSingleBlock), junto com tiletypes, construções e unidades.
+// Retorna quantos blocos têm conteúdo; os demais são marcados como ar.
+func Fill(store *mapdata.MapDataStore, w *World) int {
+	store.UpdateTiletypes(TiletypeList())
+	blocks := 0
+	for z := int32(0); z < w.opts.Levels; z++ {
+		for y := int32(0); y < w.height; y += 16 {
+			for x := int32(0); x < w.width; x += 16 {
+				if block := w.Block(x, y, z); block != nil {
+					store.StoreSingleBlock(block)
+					blocks++
+				} else {
+					store.MarkAsEmpty(util.NewDFCoord(x, y, z))
+				}
+			}
+		}
+	}
+	store.SetBuildingDefs(BuildingDefs())
+	store.ReplaceBuildings(w.Buildings())
+	store.ReplaceUnits(w.Units())
+	return blocks
+}
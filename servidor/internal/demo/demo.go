@@ -0,0 +1,375 @@
+// Package demo gera um mundo procedural para rodar o FortressVision sem o Dwarf
+// Fortress (servidor --demo). O mundo é entregue como MapBlocks do DFHack, então
+// passa pelo mesmo caminho de store, persistência, streaming e meshing do jogo real.
+package demo
+
+import (
+	"math"
+
+	"FortressVision/shared/mapdata"
+	"FortressVision/shared/pkg/dfproto"
+	"FortressVision/shared/util"
+)
+
+// WorldName é o nome do mundo de demonstração em saves/.
+const WorldName = "Demo"
+
+// Version muda quando o gerador passa a produzir outro mundo para as mesmas opções;
+// o servidor regenera o banco salvo por uma versão anterior.
+const Version = 1
+
+// Options define o tamanho e a semente do mundo gerado.
+type Options struct {
+	Seed    int64
+	BlocksX int32 // Largura em blocos de 16x16
+	BlocksY int32
+	Levels  int32 // Níveis Z
+}
+
+// DefaultOptions é o mundo de 8x8 blocos (128x128 tiles) e 50 níveis.
+func DefaultOptions() Options {
+	return Options{Seed: 1, BlocksX: 8, BlocksY: 8, Levels: 50}
+}
+
+// Camadas verticais (Z absoluto). O relevo fica perto de surfaceBase.
+const (
+	magmaFloor   = 0  // Chão de obsidiana do mar de magma
+	magmaTop     = 4  // Último nível aberto do mar de magma
+	magmaShell   = 6  // Crosta de obsidiana acima do mar
+	cavernBottom = 14 // Faixa das cavernas
+	cavernTop    = 19
+	graniteTop   = 24 // Acima disto, rochas sedimentares
+	surfaceBase  = 38
+	riverZ       = surfaceBase - 1 // Margens do rio; a água fica abaixo
+	riverHalf    = 2.5             // Meia largura do leito
+	valleyHalf   = 7.0             // Meia largura do vale que desce até o rio
+)
+
+// cell é o conteúdo de um tile gerado.
+type cell struct {
+	tiletype int32
+	material dfproto.MatPair
+	base     dfproto.MatPair
+	vein     dfproto.MatPair
+	water    int32
+	magma    int32
+	grass    int32
+	flow     util.DFCoord // Direção da correnteza (rio)
+	plant    bool         // Entra em MapBlock.Plants (arbusto ou muda)
+}
+
+type rect struct{ x0, y0, x1, y1 int32 }
+
+func (r rect) contains(x, y int32) bool { return x >= r.x0 && x <= r.x1 && y >= r.y0 && y <= r.y1 }
+
+// World é um mundo de demonstração já planejado: relevo, árvores e fortaleza.
+// Os tiles são calculados sob demanda por Block.
+type World struct {
+	opts          Options
+	width, height int32
+	surface       []int32 // Nível do chão por coluna (x + y*width)
+	features      map[util.DFCoord]cell
+	fort          rect
+	fortZ         int32
+	buildings     []dfproto.BuildingInstance
+	units         []mapdata.UnitInstance
+}
+
+// New planeja o mundo para as opções dadas. O resultado é determinístico.
+func New(opts Options) *World {
+	w := &World{
+		opts:     opts,
+		width:    opts.BlocksX * 16,
+		height:   opts.BlocksY * 16,
+		features: make(map[util.DFCoord]cell),
+	}
+	// Fortaleza num platô no quadrante noroeste, longe do rio
+	w.fort = rect{w.width/6 + 4, w.height/6 - 2, w.width/6 + 15, w.height/6 + 7}
+	w.fortZ = w.naturalHeight(w.fort.x0+6, w.fort.y0+5)
+
+	w.surface = make([]int32, w.width*w.height)
+	for y := int32(0); y < w.height; y++ {
+		for x := int32(0); x < w.width; x++ {
+			w.surface[x+y*w.width] = w.terrainHeight(x, y)
+		}
+	}
+	w.plantTrees()
+	w.buildFort()
+	return w
+}
+
+// MapInfo descreve o mapa como o DFHack faria.
+func (w *World) MapInfo() *dfproto.MapInfo {
+	return &dfproto.MapInfo{
+		BlockSizeX:  w.opts.BlocksX,
+		BlockSizeY:  w.opts.BlocksY,
+		BlockSizeZ:  w.opts.Levels,
+		WorldName:   WorldName,
+		WorldNameEn: "FortressVision Demo",
+		SaveName:    "demo",
+	}
+}
+
+// Center é o tile no centro da fortaleza, bom ponto inicial para a câmera.
+func (w *World) Center() util.DFCoord {
+	return util.NewDFCoord((w.fort.x0+w.fort.x1)/2, (w.fort.y0+w.fort.y1)/2, w.fortZ)
+}
+
+// riverY é a linha central do rio, que cruza o mapa de oeste para leste.
+func (w *World) riverY(x int32) float64 {
+	phase := rand01(w.opts.Seed, 0, 0, 99) * 2 * math.Pi
+	return float64(w.height)*0.6 + 12*math.Sin(float64(x)/18+phase)
+}
+
+// riverDist é a distância (em tiles) do tile até o centro do rio.
+func (w *World) riverDist(x, y int32) float64 {
+	return math.Abs(float64(y) - w.riverY(x))
+}
+
+// naturalHeight é o relevo antes do vale do rio e da fortaleza.
+func (w *World) naturalHeight(x, y int32) int32 {
+	n := fbm2(w.opts.Seed, float64(x)/28, float64(y)/28, 3)
+	return surfaceBase + int32(math.Round((n-0.5)*10))
+}
+
+func (w *World) terrainHeight(x, y int32) int32 {
+	if w.fort.contains(x, y) || (rect{w.fort.x0 - 1, w.fort.y0 - 1, w.fort.x1 + 1, w.fort.y1 + 1}).contains(x, y) {
+		return w.fortZ
+	}
+	h := w.naturalHeight(x, y)
+	// O vale desce suavemente até as margens do rio
+	if d := w.riverDist(x, y); d < valleyHalf {
+		t := math.Max(0, (d-riverHalf)/(valleyHalf-riverHalf))
+		h = int32(math.Round(lerp(riverZ, float64(h), t)))
+		if h < riverZ {
+			h = riverZ
+		}
+	}
+	return h
+}
+
+// surfaceAt é o nível do chão na coluna (limitado às bordas do mapa).
+func (w *World) surfaceAt(x, y int32) int32 {
+	x = max(0, min(x, w.width-1))
+	y = max(0, min(y, w.height-1))
+	return w.surface[x+y*w.width]
+}
+
+// skyLevel é o nível mais baixo a céu aberto na coluna: o chão, ou o leito no rio.
+func (w *World) skyLevel(x, y int32) int32 {
+	if w.riverDist(x, y) <= riverHalf {
+		return riverZ - 3
+	}
+	return w.surfaceAt(x, y)
+}
+
+// caveOpen diz se o tile faz parte das cavernas.
+func (w *World) caveOpen(x, y, z int32) bool {
+	if z < cavernBottom || z > cavernTop {
+		return false
+	}
+	// Mais aberto no meio da faixa, fechando perto do teto e do chão
+	mid := float64(cavernBottom+cavernTop) / 2
+	edge := math.Abs(float64(z)-mid) / (float64(cavernTop-cavernBottom) / 2)
+	n := noise3(w.opts.Seed+11, float64(x)/11, float64(y)/11, float64(z)/4)
+	return n > 0.52+0.25*edge*edge
+}
+
+// stoneAt é a rocha da camada no nível z (com veios de minério).
+func (w *World) stoneAt(x, y, z int32) (tiletype int32, mat, vein dfproto.MatPair) {
+	layer := matGranite
+	if z >= graniteTop+int32(noise2(w.opts.Seed+5, float64(x)/20, float64(y)/20)*3) {
+		layer = matLimestone
+		if (z/3)%2 == 0 {
+			layer = matSandstone
+		}
+	}
+	v := noise3(w.opts.Seed+23, float64(x)/6, float64(y)/6, float64(z)/2)
+	switch {
+	case layer == matGranite && v > 0.93:
+		return ttMineralWall, matNativeGold, matNativeGold
+	case layer != matGranite && v > 0.88:
+		return ttMineralWall, matHematite, matHematite
+	}
+	return ttStoneWall, layer, dfproto.MatPair{}
+}
+
+// cellAt calcula o tile (x, y, z).
+func (w *World) cellAt(x, y, z int32) cell {
+	if c, ok := w.features[util.NewDFCoord(x, y, z)]; ok {
+		return c
+	}
+	open := cell{tiletype: ttOpenSpace}
+
+	// Mar de magma no fundo do mapa
+	switch {
+	case z == magmaFloor:
+		return cell{tiletype: ttLavaFloor, material: matObsidian, base: matObsidian}
+	case z <= magmaTop:
+		switch z {
+		case magmaFloor + 1, magmaFloor + 2:
+			open.magma = 7
+		case magmaFloor + 3:
+			open.magma = 4
+		}
+		return open
+	case z <= magmaShell:
+		return cell{tiletype: ttLavaWall, material: matObsidian, base: matObsidian}
+	}
+
+	if w.caveOpen(x, y, z) {
+		if w.caveOpen(x, y, z-1) {
+			return open
+		}
+		floor := cell{tiletype: ttStoneFloor, material: matGranite, base: matGranite}
+		if rand01(w.opts.Seed+31, x, y, z) < 0.04 {
+			floor.tiletype, floor.material, floor.plant = ttShrub, matPlumpHelm, true
+		}
+		return floor
+	}
+
+	h := w.surfaceAt(x, y)
+	if w.riverDist(x, y) <= riverHalf {
+		return w.riverCell(x, y, z)
+	}
+	switch {
+	case z > h:
+		return open
+	case z < h:
+		return w.groundCell(x, y, z, h)
+	}
+	return w.surfaceCell(x, y, h)
+}
+
+// riverCell é o leito (areia) com dois níveis de água correndo para leste.
+func (w *World) riverCell(x, y, z int32) cell {
+	bed := int32(riverZ - 3)
+	switch {
+	case z < bed:
+		return w.groundCell(x, y, z, riverZ)
+	case z == bed:
+		return cell{tiletype: ttRiverFloor, material: matSand, base: matSand}
+	case z <= bed+2:
+		dy := int32(0)
+		if delta := w.riverY(x+1) - w.riverY(x); delta > 0.5 {
+			dy = 1
+		} else if delta < -0.5 {
+			dy = -1
+		}
+		return cell{tiletype: ttOpenSpace, water: 7, flow: util.NewDFCoord(1, dy, 0)}
+	}
+	return cell{tiletype: ttOpenSpace}
+}
+
+// groundCell é o subsolo: solo nos primeiros níveis e rocha abaixo.
+func (w *World) groundCell(x, y, z, h int32) cell {
+	depth := h - z
+	soilDepth := 2 + int32(noise2(w.opts.Seed+3, float64(x)/9, float64(y)/9)*3)
+	if depth <= soilDepth {
+		mat := matLoam
+		if depth > 1 {
+			mat = matClay
+		}
+		if w.riverDist(x, y) < valleyHalf {
+			mat = matSand
+		}
+		return cell{tiletype: ttSoilWall, material: mat, base: mat}
+	}
+	tiletype, mat, vein := w.stoneAt(x, y, z)
+	return cell{tiletype: tiletype, material: mat, base: mat, vein: vein}
+}
+
+// surfaceCell é o chão (grama ou areia perto do rio), com rampas nos degraus do relevo.
+func (w *World) surfaceCell(x, y, h int32) cell {
+	nearRiver := w.riverDist(x, y) < valleyHalf
+	ramp := false
+	for _, d := range [4][2]int32{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+		if w.surfaceAt(x+d[0], y+d[1]) == h+1 {
+			ramp = true
+		}
+	}
+
+	c := cell{material: matGrass, base: matLoam}
+	switch {
+	case nearRiver && ramp:
+		c.tiletype, c.material, c.base = ttSoilRamp, matSand, matSand
+	case nearRiver:
+		c.tiletype, c.material, c.base = ttSoilFloor, matSand, matSand
+	case ramp:
+		c.tiletype = ttGrassRamp
+	default:
+		c.tiletype = ttGrassFloor
+		c.grass = 40 + int32(rand01(w.opts.Seed+41, x, y, h)*60)
+		switch r := rand01(w.opts.Seed+43, x, y, h); {
+		case r < 0.02:
+			c.tiletype, c.material, c.plant = ttShrub, matStrawberry, true
+		case r < 0.025:
+			c.tiletype, c.material, c.plant = ttSapling, matOakLeaves, true
+		}
+	}
+	return c
+}
+
+// plantTrees espalha carvalhos pela superfície, no máximo um por célula de 8x8 tiles.
+func (w *World) plantTrees() {
+	for cy := int32(0); cy < w.height/8; cy++ {
+		for cx := int32(0); cx < w.width/8; cx++ {
+			if rand01(w.opts.Seed+51, cx, cy, 0) > 0.55 {
+				continue
+			}
+			jitter := hash(w.opts.Seed+53, cx, cy, 0)
+			x := cx*8 + 2 + int32(jitter%4)
+			y := cy*8 + 2 + int32((jitter>>8)%4)
+			h := w.surfaceAt(x, y)
+			if w.riverDist(x, y) < valleyHalf+1 || !w.flatAround(x, y, h) ||
+				(rect{w.fort.x0 - 3, w.fort.y0 - 3, w.fort.x1 + 3, w.fort.y1 + 3}).contains(x, y) {
+				continue
+			}
+			w.addTree(x, y, h, 4+int32((jitter>>16)%2))
+		}
+	}
+}
+
+// flatAround diz se o quadrado 5x5 em volta de (x, y) está todo no nível h.
+func (w *World) flatAround(x, y, h int32) bool {
+	for dy := int32(-2); dy <= 2; dy++ {
+		for dx := int32(-2); dx <= 2; dx++ {
+			if w.surfaceAt(x+dx, y+dy) != h {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// addTree monta tronco, galhos e folhas de uma árvore com base no nível h.
+func (w *World) addTree(x, y, h, trunk int32) {
+	top := h + trunk - 1
+	for z := h; z <= top; z++ {
+		w.features[util.NewDFCoord(x, y, z)] = cell{tiletype: ttTreeTrunk, material: matOakWood, base: matOakWood}
+	}
+	for z := top - 1; z <= top+1; z++ {
+		for dy := int32(-2); dy <= 2; dy++ {
+			for dx := int32(-2); dx <= 2; dx++ {
+				dist := max(abs(dx), abs(dy))
+				if dist == 0 && z <= top {
+					continue // Tronco
+				}
+				pos := util.NewDFCoord(x+dx, y+dy, z)
+				switch {
+				case z <= top && dist == 1:
+					w.features[pos] = cell{tiletype: ttTreeBranches, material: matOakWood, base: matOakWood}
+				case (z <= top && dist == 2 && abs(dx)+abs(dy) <= 3) || (z == top+1 && dist <= 1):
+					w.features[pos] = cell{tiletype: ttTreeTwigs, material: matOakLeaves, base: matOakWood}
+				}
+			}
+		}
+	}
+}
+
+func abs(v int32) int32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
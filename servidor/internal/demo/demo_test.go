@@ -0,0 +1,120 @@
+package demo
+
+import (
+	"bytes"
+	"testing"
+
+	"FortressVision/shared/mapdata"
+	"FortressVision/shared/util"
+)
+
+// testOptions é um mundo menor que o padrão, com rio, cavernas e mar de magma.
+func testOptions(seed int64) Options {
+	return Options{Seed: seed, BlocksX: 4, BlocksY: 4, Levels: 50}
+}
+
+// fillChunks gera o mundo num store em RAM e devolve cada chunk codificado pelo
+// codec do banco e da rede, por origem.
+func fillChunks(t *testing.T, opts Options) map[util.DFCoord][]byte {
+	t.Helper()
+	w := New(opts)
+	store := mapdata.NewMapDataStore()
+	if blocks := Fill(store, w); blocks == 0 {
+		t.Fatal("Fill não gerou nenhum bloco")
+	}
+
+	encoded := make(map[util.DFCoord][]byte)
+	for z := int32(0); z < opts.Levels; z++ {
+		for y := int32(0); y < w.height; y += 16 {
+			for x := int32(0); x < w.width; x += 16 {
+				origin := util.NewDFCoord(x, y, z)
+				chunk, ok := store.GetChunk(origin)
+				if !ok {
+					t.Fatalf("chunk %v ausente após Fill", origin)
+				}
+				if chunk.IsEmpty {
+					continue
+				}
+				data, err := mapdata.EncodeChunk(chunk, mapdata.LayersAll)
+				if err != nil {
+					t.Fatalf("EncodeChunk %v: %v", origin, err)
+				}
+				encoded[origin] = data
+			}
+		}
+	}
+	return encoded
+}
+
+func TestFillRoundTripsThroughCodec(t *testing.T) {
+	var water, flowing, magma, plants int
+	for origin, data := range fillChunks(t, testOptions(1)) {
+		chunk, err := mapdata.DecodeChunk(origin, data)
+		if err != nil {
+			t.Fatalf("DecodeChunk %v: %v", origin, err)
+		}
+		again, err := mapdata.EncodeChunk(chunk, mapdata.LayersAll)
+		if err != nil || !bytes.Equal(again, data) {
+			t.Fatalf("chunk %v mudou ao passar pelo codec (err %v)", origin, err)
+		}
+
+		for y := int32(0); y < 16; y++ {
+			for x := int32(0); x < 16; x++ {
+				tile := chunk.Tile(x, y)
+				if tile == nil {
+					continue
+				}
+				if tile.WaterLevel > 0 {
+					water++
+					if tile.FlowVector != (util.DFCoord{}) {
+						flowing++
+					}
+				}
+				if tile.MagmaLevel > 0 {
+					magma++
+				}
+			}
+		}
+		for _, p := range chunk.Plants {
+			tile := chunk.Tile(p.Pos.X, p.Pos.Y)
+			if tile == nil || (tile.TileType != ttShrub && tile.TileType != ttSapling) {
+				t.Fatalf("planta em %v+(%d,%d) fora de arbusto/muda", origin, p.Pos.X, p.Pos.Y)
+			}
+			plants++
+		}
+	}
+
+	if water == 0 || flowing == 0 {
+		t.Errorf("rio sem água corrente: %d tiles com água, %d com correnteza", water, flowing)
+	}
+	if magma == 0 {
+		t.Error("mar de magma ausente")
+	}
+	if plants == 0 {
+		t.Error("nenhuma planta gerada")
+	}
+}
+
+func TestFillIsDeterministic(t *testing.T) {
+	first, second := fillChunks(t, testOptions(7)), fillChunks(t, testOptions(7))
+	if len(first) != len(second) {
+		t.Fatalf("mesma semente, %d e %d chunks com conteúdo", len(first), len(second))
+	}
+	for origin, data := range first {
+		if !bytes.Equal(data, second[origin]) {
+			t.Fatalf("mesma semente, chunk %v diferente", origin)
+		}
+	}
+
+	other := fillChunks(t, testOptions(8))
+	same := len(other) == len(first)
+	for origin, data := range first {
+		if !same {
+			break
+		}
+		same = bytes.Equal(data, other[origin])
+	}
+	if same {
+		t.Error("sementes diferentes geraram o mesmo mundo")
+	}
+}
@@ -0,0 +1,130 @@
+package demo
+
+import "FortressVision/shared/pkg/dfproto"
+
+// Tiletypes do mundo de demonstração. Os IDs são próprios (não os do DF): o
+// cliente só enxerga shape, material e Dir, que seguem os enums do RemoteFortressReader.
+const (
+	ttVoid int32 = iota
+	ttOpenSpace
+	ttSoilWall
+	ttSoilFloor
+	ttSoilRamp
+	ttGrassFloor
+	ttGrassRamp
+	ttStoneWall
+	ttStoneFloor
+	ttStoneRamp
+	ttMineralWall
+	ttLavaWall
+	ttLavaFloor
+	ttRiverFloor
+	ttTreeTrunk
+	ttTreeBranches
+	ttTreeTwigs
+	ttShrub
+	ttSapling
+	ttConstructedWall
+	ttConstructedFloor
+	ttStairUp
+	ttStairDown
+	ttStairUpDown
+)
+
+var tiletypeDefs = []dfproto.Tiletype{
+	ttVoid:             {Name: "Void", Shape: dfproto.ShapeNoShape, Material: dfproto.TilematNoMaterial},
+	ttOpenSpace:        {Name: "OpenSpace", Shape: dfproto.ShapeEmpty, Material: dfproto.TilematAir},
+	ttSoilWall:         {Name: "SoilWall", Shape: dfproto.ShapeWall, Material: dfproto.TilematSoil},
+	ttSoilFloor:        {Name: "SoilFloor1", Shape: dfproto.ShapeFloor, Material: dfproto.TilematSoil},
+	ttSoilRamp:         {Name: "SoilRamp", Shape: dfproto.ShapeRamp, Material: dfproto.TilematSoil},
+	ttGrassFloor:       {Name: "GrassLightFloor1", Shape: dfproto.ShapeFloor, Material: dfproto.TilematGrassLight},
+	ttGrassRamp:        {Name: "GrassLightRamp", Shape: dfproto.ShapeRamp, Material: dfproto.TilematGrassLight},
+	ttStoneWall:        {Name: "StoneWall", Shape: dfproto.ShapeWall, Material: dfproto.TilematStone},
+	ttStoneFloor:       {Name: "StoneFloor1", Shape: dfproto.ShapeFloor, Material: dfproto.TilematStone},
+	ttStoneRamp:        {Name: "StoneRamp", Shape: dfproto.ShapeRamp, Material: dfproto.TilematStone},
+	ttMineralWall:      {Name: "MineralWall", Shape: dfproto.ShapeWall, Material: dfproto.TilematMineral},
+	ttLavaWall:         {Name: "LavaWall", Shape: dfproto.ShapeWall, Material: dfproto.TilematLavaStone},
+	ttLavaFloor:        {Name: "LavaFloor1", Shape: dfproto.ShapeFloor, Material: dfproto.TilematLavaStone},
+	ttRiverFloor:       {Name: "RiverFloor", Shape: dfproto.ShapeFloor, Material: dfproto.TilematRiver},
+	ttTreeTrunk:        {Name: "TreeTrunkPillar", Shape: dfproto.ShapeTrunkBranch, Material: dfproto.TilematTreeMaterial},
+	ttTreeBranches:     {Name: "TreeBranches", Shape: dfproto.ShapeBranch, Material: dfproto.TilematTreeMaterial, Dir: "--------"},
+	ttTreeTwigs:        {Name: "TreeTwigs", Shape: dfproto.ShapeTwig, Material: dfproto.TilematTreeMaterial},
+	ttShrub:            {Name: "Shrub", Shape: dfproto.ShapeShrub, Material: dfproto.TilematPlant},
+	ttSapling:          {Name: "Sapling", Shape: dfproto.ShapeSapling, Material: dfproto.TilematPlant},
+	ttConstructedWall:  {Name: "ConstructedWallLRUD", Shape: dfproto.ShapeWall, Material: dfproto.TilematConstruction},
+	ttConstructedFloor: {Name: "ConstructedFloor", Shape: dfproto.ShapeFloor, Material: dfproto.TilematConstruction},
+	ttStairUp:          {Name: "ConstructedStairU", Shape: dfproto.ShapeStairUp, Material: dfproto.TilematConstruction},
+	ttStairDown:        {Name: "ConstructedStairD", Shape: dfproto.ShapeStairDown, Material: dfproto.TilematConstruction},
+	ttStairUpDown:      {Name: "ConstructedStairUD", Shape: dfproto.ShapeStairUpDown, Material: dfproto.TilematConstruction},
+}
+
+// TiletypeList monta o dicionário de tiletypes do mundo de demonstração.
+func TiletypeList() *dfproto.TiletypeList {
+	list := &dfproto.TiletypeList{TiletypeList: make([]dfproto.Tiletype, len(tiletypeDefs))}
+	for i, tt := range tiletypeDefs {
+		tt.ID = int32(i)
+		list.TiletypeList[i] = tt
+	}
+	return list
+}
+
+// Materiais: inorgânicos em MatType 0 e plantas em 419 (estrutural) / 420 (madeira),
+// como no DF. O índice é o da lista abaixo.
+var (
+	matLoam       = dfproto.MatPair{MatType: 0, MatIndex: 0}
+	matClay       = dfproto.MatPair{MatType: 0, MatIndex: 1}
+	matSand       = dfproto.MatPair{MatType: 0, MatIndex: 2}
+	matSandstone  = dfproto.MatPair{MatType: 0, MatIndex: 3}
+	matLimestone  = dfproto.MatPair{MatType: 0, MatIndex: 4}
+	matGranite    = dfproto.MatPair{MatType: 0, MatIndex: 5}
+	matObsidian   = dfproto.MatPair{MatType: 0, MatIndex: 6}
+	matHematite   = dfproto.MatPair{MatType: 0, MatIndex: 7}
+	matNativeGold = dfproto.MatPair{MatType: 0, MatIndex: 8}
+	matGrass      = dfproto.MatPair{MatType: 419, MatIndex: 0}
+	matOakLeaves  = dfproto.MatPair{MatType: 419, MatIndex: 1}
+	matStrawberry = dfproto.MatPair{MatType: 419, MatIndex: 2}
+	matPlumpHelm  = dfproto.MatPair{MatType: 419, MatIndex: 3}
+	matOakWood    = dfproto.MatPair{MatType: 420, MatIndex: 1}
+)
+
+var materialDefs = []dfproto.MaterialDefinition{
+	{MatPair: matLoam, ID: "INORGANIC:LOAM", Name: "loam", StateColor: dfproto.ColorDefinition{Red: 121, Green: 85, Blue: 58}},
+	{MatPair: matClay, ID: "INORGANIC:CLAY", Name: "clay", StateColor: dfproto.ColorDefinition{Red: 160, Green: 95, Blue: 65}},
+	{MatPair: matSand, ID: "INORGANIC:SAND_YELLOW", Name: "yellow sand", StateColor: dfproto.ColorDefinition{Red: 214, Green: 192, Blue: 128}},
+	{MatPair: matSandstone, ID: "INORGANIC:SANDSTONE", Name: "sandstone", StateColor: dfproto.ColorDefinition{Red: 196, Green: 166, Blue: 112}},
+	{MatPair: matLimestone, ID: "INORGANIC:LIMESTONE", Name: "limestone", StateColor: dfproto.ColorDefinition{Red: 204, Green: 200, Blue: 178}},
+	{MatPair: matGranite, ID: "INORGANIC:GRANITE", Name: "granite", StateColor: dfproto.ColorDefinition{Red: 150, Green: 122, Blue: 120}},
+	{MatPair: matObsidian, ID: "INORGANIC:OBSIDIAN", Name: "obsidian", StateColor: dfproto.ColorDefinition{Red: 42, Green: 32, Blue: 52}},
+	{MatPair: matHematite, ID: "INORGANIC:HEMATITE", Name: "hematite", StateColor: dfproto.ColorDefinition{Red: 142, Green: 44, Blue: 32}},
+	{MatPair: matNativeGold, ID: "INORGANIC:NATIVE_GOLD", Name: "native gold", StateColor: dfproto.ColorDefinition{Red: 232, Green: 190, Blue: 42}},
+	{MatPair: matGrass, ID: "PLANT:GRASS_TEMPERATE:STRUCTURAL", Name: "meadow-grass", StateColor: dfproto.ColorDefinition{Red: 84, Green: 150, Blue: 62}},
+	{MatPair: matOakLeaves, ID: "PLANT:OAK:LEAF", Name: "oak leaf", StateColor: dfproto.ColorDefinition{Red: 58, Green: 118, Blue: 42}},
+	{MatPair: matStrawberry, ID: "PLANT:BERRIES_STRAW:STRUCTURAL", Name: "strawberry", StateColor: dfproto.ColorDefinition{Red: 72, Green: 140, Blue: 70}},
+	{MatPair: matPlumpHelm, ID: "PLANT:MUSHROOM_HELMET_PLUMP:STRUCTURAL", Name: "plump helmet", StateColor: dfproto.ColorDefinition{Red: 150, Green: 82, Blue: 160}},
+	{MatPair: matOakWood, ID: "PLANT:OAK:WOOD", Name: "oak", StateColor: dfproto.ColorDefinition{Red: 128, Green: 88, Blue: 52}},
+}
+
+// MaterialList monta o dicionário de materiais do mundo de demonstração.
+func MaterialList() *dfproto.MaterialList {
+	return &dfproto.MaterialList{MaterialList: append([]dfproto.MaterialDefinition(nil), materialDefs...)}
+}
+
+// Tipos de construção usados na fortaleza de demonstração (df::building_type).
+var (
+	bldChair    = dfproto.BuildingType{BuildingType: 0, BuildingSubtype: -1, BuildingCustom: -1}
+	bldBed      = dfproto.BuildingType{BuildingType: 1, BuildingSubtype: -1, BuildingCustom: -1}
+	bldTable    = dfproto.BuildingType{BuildingType: 2, BuildingSubtype: -1, BuildingCustom: -1}
+	bldDoor     = dfproto.BuildingType{BuildingType: 8, BuildingSubtype: -1, BuildingCustom: -1}
+	bldWorkshop = dfproto.BuildingType{BuildingType: 13, BuildingSubtype: 0, BuildingCustom: -1} // Carpenter's
+)
+
+// BuildingDefs monta as definições de construção (equivalente a GetBuildingDefList).
+func BuildingDefs() []dfproto.BuildingDefinition {
+	return []dfproto.BuildingDefinition{
+		{BuildingType: bldChair, ID: "Chair", Name: "Chair"},
+		{BuildingType: bldBed, ID: "Bed", Name: "Bed"},
+		{BuildingType: bldTable, ID: "Table", Name: "Table"},
+		{BuildingType: bldDoor, ID: "Door", Name: "Door"},
+		{BuildingType: bldWorkshop, ID: "Workshop/Carpenters", Name: "Carpenter's Workshop"},
+	}
+}
@@ -0,0 +1,107 @@
+package demo
+
+import (
+	"FortressVision/shared/mapdata"
+	"FortressVision/shared/pkg/dfproto"
+	"FortressVision/shared/util"
+)
+
+// buildFort ergue um pátio murado de granito com mobília, uma oficina, uma escada
+// até as cavernas e alguns anões.
+func (w *World) buildFort() {
+	f, z := w.fort, w.fortZ
+	wall := cell{tiletype: ttConstructedWall, material: matGranite, base: matLoam}
+	floor := cell{tiletype: ttConstructedFloor, material: matGranite, base: matLoam}
+	doorX := (f.x0 + f.x1) / 2
+
+	for y := f.y0; y <= f.y1; y++ {
+		for x := f.x0; x <= f.x1; x++ {
+			pos := util.NewDFCoord(x, y, z)
+			border := x == f.x0 || x == f.x1 || y == f.y0 || y == f.y1
+			switch {
+			case border && !(y == f.y1 && x == doorX):
+				w.features[pos] = wall
+				w.features[util.NewDFCoord(x, y, z+1)] = wall
+			default:
+				w.features[pos] = floor
+			}
+		}
+	}
+	w.features[util.NewDFCoord(doorX, f.y1, z+1)] = wall // Verga da porta
+
+	// Escada do pátio até o teto das cavernas
+	stairX, stairY := f.x0+2, f.y0+2
+	for sz := int32(cavernTop); sz <= z; sz++ {
+		tiletype := ttStairUpDown
+		switch sz {
+		case z:
+			tiletype = ttStairDown
+		case cavernTop:
+			tiletype = ttStairUp
+		}
+		w.features[util.NewDFCoord(stairX, stairY, sz)] = cell{tiletype: tiletype, material: matGranite, base: matGranite}
+	}
+
+	place := func(t dfproto.BuildingType, mat dfproto.MatPair, x0, y0, x1, y1 int32) {
+		w.buildings = append(w.buildings, dfproto.BuildingInstance{
+			Index:        int32(len(w.buildings) + 1),
+			PosXMin:      x0,
+			PosYMin:      y0,
+			PosZMin:      z,
+			PosXMax:      x1,
+			PosYMax:      y1,
+			PosZMax:      z,
+			BuildingType: t,
+			Material:     mat,
+		})
+	}
+	place(bldDoor, matOakWood, doorX, f.y1, doorX, f.y1)
+	place(bldTable, matOakWood, f.x0+5, f.y0+3, f.x0+5, f.y0+3)
+	place(bldChair, matOakWood, f.x0+4, f.y0+3, f.x0+4, f.y0+3)
+	place(bldChair, matOakWood, f.x0+6, f.y0+3, f.x0+6, f.y0+3)
+	place(bldBed, matOakWood, f.x1-2, f.y0+2, f.x1-2, f.y0+2)
+	place(bldBed, matOakWood, f.x1-2, f.y0+4, f.x1-2, f.y0+4)
+	place(bldWorkshop, matOakWood, f.x0+4, f.y1-4, f.x0+6, f.y1-2)
+
+	names := []string{"Urist Lorbamzulban", "Doren Ustuthinal", "Kadol Oltarnish", "Zuglar Rigothkogan", "Ingiz Zasitlimul"}
+	for i, name := range names {
+		w.units = append(w.units, mapdata.UnitInstance{
+			ID:   int32(100 + i),
+			Name: name,
+			Race: dfproto.MatPair{MatType: 572, MatIndex: int32(i % 2)}, // DWARF, casta fêmea/macho
+			Pos:  util.NewDFCoord(f.x0+2+int32(i)*2, f.y0+6, z),
+		})
+	}
+}
+
+// Buildings retorna as construções da fortaleza no formato do store, com o tipo resolvido.
+func (w *World) Buildings() []*mapdata.BuildingInstance {
+	defs := BuildingDefs()
+	byType := make(map[dfproto.BuildingType]*dfproto.BuildingDefinition, len(defs))
+	for i := range defs {
+		byType[defs[i].BuildingType] = &defs[i]
+	}
+	list := make([]*mapdata.BuildingInstance, 0, len(w.buildings))
+	for _, b := range w.buildings {
+		list = append(list, &mapdata.BuildingInstance{
+			Index:        b.Index,
+			BuildingType: byType[b.BuildingType],
+			Material:     b.Material,
+			MinPos:       util.DFCoord{X: b.PosXMin, Y: b.PosYMin, Z: b.PosZMin},
+			MaxPos:       util.DFCoord{X: b.PosXMax, Y: b.PosYMax, Z: b.PosZMax},
+			Center:       util.DFCoord{X: (b.PosXMin + b.PosXMax) / 2, Y: (b.PosYMin + b.PosYMax) / 2, Z: b.PosZMin},
+			Direction:    b.Direction,
+		})
+	}
+	return list
+}
+
+// Units retorna os anões da fortaleza.
+func (w *World) Units() []*mapdata.UnitInstance {
+	list := make([]*mapdata.UnitInstance, len(w.units))
+	for i := range w.units {
+		u := w.units[i]
+		list[i] = &u
+	}
+	return list
+}
@@ -0,0 +1,58 @@
+package demo
+
+import "math"
+
+// hash mistura coordenadas e semente (splitmix64); a mesma entrada dá sempre o mesmo valor.
+func hash(seed int64, x, y, z int32) uint64 {
+	h := uint64(seed)*0x9E3779B97F4A7C15 ^ uint64(uint32(x))*0xBF58476D1CE4E5B9 ^
+		uint64(uint32(y))*0x94D049BB133111EB ^ uint64(uint32(z))*0xD6E8FEB86659FD93
+	h ^= h >> 30
+	h *= 0xBF58476D1CE4E5B9
+	h ^= h >> 27
+	h *= 0x94D049BB133111EB
+	h ^= h >> 31
+	return h
+}
+
+// rand01 é um valor pseudoaleatório em [0, 1) fixo para (seed, x, y, z).
+func rand01(seed int64, x, y, z int32) float64 {
+	return float64(hash(seed, x, y, z)>>11) / (1 << 53)
+}
+
+func smooth(t float64) float64 { return t * t * (3 - 2*t) }
+
+func lerp(a, b, t float64) float64 { return a + (b-a)*t }
+
+// noise2 é ruído de valor 2D em [0, 1), interpolado entre pontos inteiros.
+func noise2(seed int64, x, y float64) float64 {
+	x0, y0 := math.Floor(x), math.Floor(y)
+	tx, ty := smooth(x-x0), smooth(y-y0)
+	ix, iy := int32(x0), int32(y0)
+	top := lerp(rand01(seed, ix, iy, 0), rand01(seed, ix+1, iy, 0), tx)
+	bottom := lerp(rand01(seed, ix, iy+1, 0), rand01(seed, ix+1, iy+1, 0), tx)
+	return lerp(top, bottom, ty)
+}
+
+// noise3 é ruído de valor 3D em [0, 1).
+func noise3(seed int64, x, y, z float64) float64 {
+	x0, y0, z0 := math.Floor(x), math.Floor(y), math.Floor(z)
+	tx, ty, tz := smooth(x-x0), smooth(y-y0), smooth(z-z0)
+	ix, iy, iz := int32(x0), int32(y0), int32(z0)
+	plane := func(iz int32) float64 {
+		top := lerp(rand01(seed, ix, iy, iz), rand01(seed, ix+1, iy, iz), tx)
+		bottom := lerp(rand01(seed, ix, iy+1, iz), rand01(seed, ix+1, iy+1, iz), tx)
+		return lerp(top, bottom, ty)
+	}
+	return lerp(plane(iz), plane(iz+1), tz)
+}
+
+// fbm2 soma oitavas de noise2 (relevo com detalhe em várias escalas), em [0, 1).
+func fbm2(seed int64, x, y float64, octaves int) float64 {
+	var sum, amp, norm float64 = 0, 1, 0
+	for i := 0; i < octaves; i++ {
+		sum += noise2(seed+int64(i), x, y) * amp
+		norm += amp
+		x, y, amp = x*2, y*2, amp/2
+	}
+	return sum / norm
+}
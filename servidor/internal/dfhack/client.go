@@ -27,6 +27,11 @@ type Client struct {
 	MaterialList *dfproto.MaterialList
 	PlantRawList *dfproto.PlantRawList
 	MapInfo      *dfproto.MapInfo
+	BuildingDefs []dfproto.BuildingDefinition
+
+	// Raws serializadas, guardadas no banco para o modo offline e os arquivos .fvz
+	CreatureRaws []byte
+	PlantRaws    []byte
 
 	address string
 
@@ -123,6 +128,7 @@ func (c *Client) FetchStaticData() error {
 	// Novos dados baseados no Armok Vision
 	buildings, err := c.Service.GetBuildingDefList()
 	if err == nil {
+		c.BuildingDefs = buildings.BuildingList
 		fmt.Printf("  → %d definições de prédios carregadas\n", len(buildings.BuildingList))
 	}
 
@@ -142,9 +148,25 @@ func (c *Client) FetchStaticData() error {
 	if err != nil {
 		fmt.Printf(" [!] Erro ao carregar PlantRaws: %v\n", err)
 	}
+
+	c.CreatureRaws, err = c.Service.GetCreatureRaws()
+	if err != nil {
+		fmt.Printf(" [!] Erro ao carregar CreatureRaws: %v\n", err)
+	}
+	c.PlantRaws, err = c.Service.GetPlantRaws()
+	if err != nil {
+		fmt.Printf(" [!] Erro ao carregar raws de plantas: %v\n", err)
+	}
 	return nil
 }
 
+// QueryMapInfo consulta o MapInfo atual do DF sem alterar o cache.
+// Usado para detectar quando o jogador carrega outro save ou fortaleza.
+// Não reconecta em caso de erro: sem mapa carregado o DFHack responde com falha.
+func (c *Client) QueryMapInfo() (*dfproto.MapInfo, error) {
+	return c.Service.GetMapInfo()
+}
+
 // --- Wrappers delegados para o dfclient ---
 
 func (c *Client) GetViewInfo() (*dfproto.ViewInfo, error) {
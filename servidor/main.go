@@ -1,8 +1,8 @@
 package main
 
 import (
-	"bytes"
-	"encoding/gob"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
@@ -10,9 +10,11 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"sync"
 	"time"
 
+	"FortressVision/servidor/internal/demo"
 	"FortressVision/servidor/internal/dfhack"
 	"FortressVision/shared/mapdata"
 	"FortressVision/shared/pkg/dfproto"
@@ -23,15 +25,29 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
+// defaultCacheMB é o orçamento de RAM dos chunks quando FV_CACHE_MB não é informado.
+const defaultCacheMB = 2048
+
+// defaultImportMaxMB é o tamanho máximo de um .fvz enviado a /api/worlds/import
+// quando FV_IMPORT_MAX_MB não é informado.
+const defaultImportMaxMB = 4096
+
 var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool {
 		return true
 	},
 }
 
-// Hub gerencia as conexões WebSocket ativas
+// hubClient guarda o lock de escrita de uma conexão e o mundo ao qual ela está ligada.
+type hubClient struct {
+	mu    sync.Mutex
+	world string // liveWorld ou o nome de um mundo arquivado
+}
+
+// Hub gerencia as conexões WebSocket ativas.
+// Broadcasts são do mundo ao vivo e só chegam às conexões ligadas a ele.
 type Hub struct {
-	clients    map[*websocket.Conn]*sync.Mutex
+	clients    map[*websocket.Conn]*hubClient
 	broadcast  chan []byte
 	register   chan *websocket.Conn
 	unregister chan *websocket.Conn
@@ -40,7 +56,7 @@ type Hub struct {
 
 func newHub() *Hub {
 	return &Hub{
-		clients:    make(map[*websocket.Conn]*sync.Mutex),
+		clients:    make(map[*websocket.Conn]*hubClient),
 		broadcast:  make(chan []byte, 4096), // Bufferizado para evitar deadlocks e bloqueios
 		register:   make(chan *websocket.Conn),
 		unregister: make(chan *websocket.Conn),
@@ -62,7 +78,10 @@ func (h *Hub) run() {
 				return
 			}
 			h.mu.Lock()
-			h.clients[client] = &sync.Mutex{}
+			// Bind pode ter criado a entrada antes do registro ser processado
+			if _, exists := h.clients[client]; !exists {
+				h.clients[client] = &hubClient{world: liveWorld}
+			}
 			h.mu.Unlock()
 			log.Printf("Cliente registrado: %s", client.RemoteAddr())
 		case client, ok := <-h.unregister:
@@ -70,11 +89,11 @@ func (h *Hub) run() {
 				return
 			}
 			h.mu.Lock()
-			if lock, ok := h.clients[client]; ok {
-				lock.Lock()
+			if hc, ok := h.clients[client]; ok {
+				hc.mu.Lock()
 				delete(h.clients, client)
 				client.Close()
-				lock.Unlock()
+				hc.mu.Unlock()
 				log.Printf("Cliente desregistrado: %s", client.RemoteAddr())
 			}
 			h.mu.Unlock()
@@ -89,8 +108,11 @@ func (h *Hub) run() {
 				lock *sync.Mutex
 			}
 			var targets []clientEntry
-			for c, l := range h.clients {
-				targets = append(targets, clientEntry{c, l})
+			for c, hc := range h.clients {
+				if hc.world != liveWorld {
+					continue // Sessões de mundos arquivados não recebem dados ao vivo
+				}
+				targets = append(targets, clientEntry{c, &hc.mu})
 			}
 			h.mu.Unlock()
 
@@ -113,7 +135,7 @@ func (h *Hub) run() {
 // WriteSafe garante que apenas uma goroutine escreva no WebSocket por vez
 func (h *Hub) WriteSafe(conn *websocket.Conn, messageType int, data []byte) error {
 	h.mu.Lock()
-	lock, ok := h.clients[conn]
+	hc, ok := h.clients[conn]
 	h.mu.Unlock()
 
 	if !ok {
@@ -121,11 +143,23 @@ func (h *Hub) WriteSafe(conn *websocket.Conn, messageType int, data []byte) erro
 		return fmt.Errorf("cliente não encontrado no hub")
 	}
 
-	lock.Lock()
-	defer lock.Unlock()
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
 	return conn.WriteMessage(messageType, data)
 }
 
+// Bind liga a conexão a um mundo; broadcasts ao vivo só chegam às ligadas a liveWorld.
+func (h *Hub) Bind(conn *websocket.Conn, world string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hc, ok := h.clients[conn]
+	if !ok {
+		hc = &hubClient{}
+		h.clients[conn] = hc
+	}
+	hc.world = world
+}
+
 // safeSend envia para o canal de broadcast protegendo contra pânicos de canal fechado
 func (h *Hub) safeSend(data []byte) {
 	defer func() {
@@ -188,9 +222,10 @@ func (h *Hub) BroadcastVegetation(chunkX, chunkY, chunkZ int32, plants []dfproto
 }
 
 // BroadcastServerStatus envia uma mensagem de status/notificação para todos os clientes
-func (h *Hub) BroadcastServerStatus(message string, dfConnected bool) {
+func (h *Hub) BroadcastServerStatus(state fvnet.ServerStatus_State, dfConnected bool) {
 	msg := &fvnet.ServerStatus{
-		Message:     message,
+		State:       state,
+		Message:     serverStateMessage(state),
 		DfConnected: dfConnected,
 	}
 	payload, _ := proto.Marshal(msg)
@@ -202,6 +237,21 @@ func (h *Hub) BroadcastServerStatus(message string, dfConnected bool) {
 	h.broadcast <- data
 }
 
+// BroadcastScanProgress envia o andamento estruturado da varredura total para todos os clientes
+func (h *Hub) BroadcastScanProgress(progress *fvnet.ScanProgress) {
+	payload, err := proto.Marshal(progress)
+	if err != nil {
+		log.Printf("[Hub] Erro ao serializar progresso: %v", err)
+		return
+	}
+	envelope := &fvnet.Envelope{
+		Type:    fvnet.Envelope_SCAN_PROGRESS,
+		Payload: payload,
+	}
+	data, _ := proto.Marshal(envelope)
+	h.safeSend(data)
+}
+
 func main() {
 	// Garante que o working directory é o mesmo diretório do executável,
 	// para que caminhos relativos (saves/, tmp/) funcionem corretamente.
@@ -210,6 +260,14 @@ func main() {
 		os.Chdir(exeDir)
 	}
 
+	// Subcomandos de linha de comando (não sobem o servidor)
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		os.Exit(runDiffCommand(os.Args[2:]))
+	}
+
+	demoMode := flag.Bool("demo", false, "Servir um mundo gerado proceduralmente, sem o Dwarf Fortress")
+	flag.Parse()
+
 	log.SetFlags(log.Ltime | log.Lshortfile)
 
 	// Configurar Log em Arquivo para depuração de crash
@@ -228,8 +286,36 @@ func main() {
 	hub := newHub()
 	go hub.run()
 
-	// Inicializar Store (SQLite)
+	// Inicializar Store (SQLite por padrão; FV_STORAGE=memory|log troca o backend)
 	store := mapdata.NewMapDataStore()
+	if backend, err := mapdata.ParseStorageBackend(os.Getenv("FV_STORAGE")); err != nil {
+		log.Fatalf("Configuração inválida: %v", err)
+	} else {
+		store.Backend = backend
+		log.Printf("Armazenamento do mundo: %s", backend)
+	}
+	// Orçamento de RAM dos chunks (FV_CACHE_MB, padrão 2048; 0 = sem limite)
+	store.MemoryBudget = defaultCacheMB << 20
+	if v := os.Getenv("FV_CACHE_MB"); v != "" {
+		mb, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || mb < 0 {
+			log.Fatalf("Configuração inválida: FV_CACHE_MB=%q", v)
+		}
+		store.MemoryBudget = mb << 20
+	}
+	// Histórico de chunks para a linha do tempo (FV_HISTORY_INTERVAL liga; ver historyPolicyFromEnv)
+	if policy, err := historyPolicyFromEnv(); err != nil {
+		log.Fatalf("Configuração inválida: %v", err)
+	} else if policy.Enabled {
+		store.History = policy
+		log.Printf("Histórico de chunks: uma versão a cada %v por chunk (retenção: %v)", policy.Interval, policy.MaxAge)
+	}
+	// Cópias de segurança periódicas do banco (FV_BACKUP_*, ver backupPolicyFromEnv)
+	if policy, err := backupPolicyFromEnv(); err != nil {
+		log.Fatalf("Configuração inválida: %v", err)
+	} else {
+		store.Backup = policy
+	}
 
 	// Conectar ao DFHack
 	dfHost := "127.0.0.1:5000"
@@ -237,13 +323,18 @@ func main() {
 		dfHost = h
 	}
 
-	log.Printf("Conectando ao DFHack em %s...", dfHost)
-	dfClient, err := dfhack.NewClient(dfHost)
-	if err != nil {
-		log.Printf("Aviso: Não foi possível conectar ao DFHack (%v). O servidor continuará em MODO OFFLINE.", err)
-		dfClient = nil
+	var dfClient *dfhack.Client
+	if *demoMode {
+		log.Println("[Demo] Modo demonstração: o DFHack não será usado.")
 	} else {
-		defer dfClient.Close()
+		log.Printf("Conectando ao DFHack em %s...", dfHost)
+		client, err := dfhack.NewClient(dfHost)
+		if err != nil {
+			log.Printf("Aviso: Não foi possível conectar ao DFHack (%v). O servidor continuará em MODO OFFLINE.", err)
+		} else {
+			dfClient = client
+			defer dfClient.Close()
+		}
 	}
 
 	var worldName string
@@ -251,17 +342,17 @@ func main() {
 		if err := dfClient.FetchStaticData(); err != nil {
 			log.Printf("Aviso: Falha ao carregar dados estáticos iniciais: %v", err)
 		} else {
-			worldName = dfClient.MapInfo.WorldNameEn
-			if worldName == "" {
-				worldName = dfClient.MapInfo.WorldName
-			}
+			worldName = worldFileOf(dfClient.MapInfo)
 			if worldName != "" {
 				log.Printf("[Startup] Dimensões do Mapa: %dx%dx%d blocos (DF-Blocks)",
 					dfClient.MapInfo.BlockSizeX, dfClient.MapInfo.BlockSizeY, dfClient.MapInfo.BlockSizeZ)
-				// Persistir dimensões para uso offline futuro
-				store.SaveMapInfo(dfClient.MapInfo)
 			}
 		}
+	} else if *demoMode {
+		if err := openDemoWorld(store); err != nil {
+			log.Fatalf("[Demo] Erro ao preparar o mundo de demonstração: %v", err)
+		}
+		worldName = demo.WorldName
 	} else {
 		// Modo Offline: Tentar encontrar o último mundo salvo
 		worldName = findLatestSave()
@@ -281,47 +372,22 @@ func main() {
 		}
 	}
 
-	if worldName != "" {
+	// O modo offline e o --demo já abriram o banco acima
+	if worldName != "" && store.Repo == nil {
 		log.Printf("Inicializando banco de dados para o mundo: %s", worldName)
 		if err := store.OpenInitialize(worldName); err != nil {
+			if errors.Is(err, mapdata.ErrFormatTooNew) {
+				log.Fatalf("Erro ao abrir SQLite: %v. Atualize o FortressVision para usar este mundo.", err)
+			}
 			log.Printf("Erro ao abrir SQLite: %v", err)
 		}
 
-		// Gravar dicionários críticos no banco para futuro modo offline
-		if dfClient != nil && dfClient.IsConnected() {
-			if dfClient.TiletypeList != nil {
-				data, _ := dfClient.TiletypeList.Marshal()
-				store.SaveDictionary("TiletypeList", data)
-			}
-			if dfClient.MaterialList != nil {
-				data, _ := dfClient.MaterialList.Marshal()
-				store.SaveDictionary("MaterialList", data)
-			}
-		}
+		// Gravar dimensões e dicionários críticos no banco para futuro modo offline
+		persistStaticData(dfClient, store)
 
 		// Carregar Construções Iniciais (Fase 6) - Assíncrono para retorno rápido
 		if dfClient != nil {
-			go func() {
-				defer func() {
-					if r := recover(); r != nil {
-						log.Printf("[Startup-Buildings] Recuperado de pânico: %v", r)
-					}
-				}()
-				log.Println("[Startup] Sincronizando construções do mundo...")
-				bList, err := dfClient.GetBuildingList()
-				if err == nil && bList != nil {
-					for _, b := range bList.BuildingList {
-						instance := &mapdata.BuildingInstance{
-							Index:     b.Index,
-							MinPos:    util.DFCoord{X: b.PosXMin, Y: b.PosYMin, Z: b.PosZMin},
-							MaxPos:    util.DFCoord{X: b.PosXMax, Y: b.PosYMax, Z: b.PosZMax},
-							Direction: b.Direction,
-						}
-						store.AddBuilding(instance)
-					}
-					log.Printf("  → %d construções indexadas", len(bList.BuildingList))
-				}
-			}()
+			go syncBuildings(dfClient, store, hub)
 		}
 	}
 
@@ -333,7 +399,7 @@ func main() {
 	// Sincronização Dinâmica de Unidades (Fase 6)
 	// ---------------------------------------------------------
 	go func() {
-		for {
+		for tick := 1; ; tick++ {
 			func() {
 				defer func() {
 					if r := recover(); r != nil {
@@ -343,6 +409,7 @@ func main() {
 				if dfClient != nil && dfClient.IsConnected() {
 					units, err := dfClient.GetUnitList()
 					if err == nil && units != nil {
+						list := make([]*mapdata.UnitInstance, 0, len(units.CreatureList))
 						for _, u := range units.CreatureList {
 							// Converter para nossa estrutura interna
 							instance := &mapdata.UnitInstance{
@@ -356,9 +423,16 @@ func main() {
 								Flags3: u.Flags3,
 								IsDead: !u.IsValid,
 							}
-							store.UpdateUnit(instance)
+							list = append(list, instance)
+						}
+						if store.ReplaceUnits(list) {
+							hub.BroadcastProtoMessage(fvnet.Envelope_CREATURE_UPDATE, creatureListMessage(store))
 						}
 					}
+					// Construções mudam bem menos: confere a cada buildingSyncEvery voltas
+					if tick%buildingSyncEvery == 0 {
+						syncBuildings(dfClient, store, hub)
+					}
 				}
 			}()
 			time.Sleep(1 * time.Second) // Unidades pedem atualização mais frequente
@@ -366,7 +440,7 @@ func main() {
 	}()
 
 	// ---------------------------------------------------------
-	// Auto-Save Periodico e Limpeza de Memória (Purge)
+	// Auto-Save Periodico e Limpeza de Memória (Cache LRU)
 	// ---------------------------------------------------------
 	go func() {
 		for {
@@ -377,36 +451,34 @@ func main() {
 					}
 				}()
 				if dfClient != nil && dfClient.IsConnected() && dfClient.MapInfo != nil {
-					worldName := dfClient.MapInfo.WorldNameEn
-					if worldName == "" {
-						worldName = dfClient.MapInfo.WorldName
-					}
-
 					// Salva chunks sujos
-					store.Save(worldName) //nolint:errcheck — background save, log de erro já está no persistence
-
-					// Purga chunks distantes do foco atual (Raio de 256 tiles)
-					viewZ := dfClient.GetInterestZ()
-					view, err := dfClient.GetViewInfo()
-					if err == nil && view != nil {
-						centerX := view.ViewPosX + view.ViewSizeX/2
-						centerY := view.ViewPosY + view.ViewSizeY/2
-						center := util.DFCoord{X: centerX, Y: centerY, Z: viewZ}
-						store.Purge(center, 512.0) // Raio de 512 tiles (~32 blocos)
-					}
+					store.Save(worldFileOf(dfClient.MapInfo)) //nolint:errcheck — background save, log de erro já está no persistence
+
+					// Despeja os chunks menos usados se a RAM passou do orçamento
+					// (as regiões pedidas pelos clientes ficam fixadas, ver session.pinRegion)
+					store.EnforceBudget()
 				}
 			}()
 			time.Sleep(30 * time.Second)
 		}
 	}()
 
+	if store.Backup.Interval > 0 {
+		log.Printf("Cópias de segurança: a cada %v em %s (mantém %d recentes e %d dias)",
+			store.Backup.Interval, mapdata.BackupsDir, store.Backup.Keep, store.Backup.KeepDays)
+		go runBackups(store)
+	}
+
 	// ---------------------------------------------------------
-	// Heurística de Mundo Novo (Smart Full-Scan)
+	// Varredura Total Retomável (Full-Scan com cursor persistido)
 	// ---------------------------------------------------------
+	scanner.OnProgress = func(p ScanProgress) {
+		hub.BroadcastScanProgress(p.ToProto())
+	}
 	go func() {
 		defer func() {
 			if r := recover(); r != nil {
-				log.Printf("[Startup-Heuristic] Recuperado de pânico: %v", r)
+				log.Printf("[Startup-FullScan] Recuperado de pânico: %v", r)
 			}
 		}()
 		// Damos um tempo menor (2 segs) para o servidor conectar e carregar MapInfo
@@ -415,26 +487,43 @@ func main() {
 			return
 		}
 
-		count, err := store.GetChunkCount()
-		if err == nil {
+		if count, err := store.GetChunkCount(); err == nil {
 			log.Printf("[Startup] Inspeção de Banco de Dados: %d chunks persistidos.", count)
-			if count < 5000 {
-				log.Println("[Startup] Banco incompleto. Agendando Varredura Total...")
-				// Notifica o cliente IMEDIATAMENTE para evitar timeout da splash screen
-				hub.BroadcastServerStatus("FULL_SCAN:0/1", dfClient.IsConnected())
-				scanner.StartFullScan()
-			} else {
-				log.Println("[Startup] Banco ok. Scan direcional ativo.")
-			}
+		}
+		if scanner.NeedsFullScan() {
+			log.Println("[Startup] Varredura total pendente. Agendando/retomando...")
+			scanner.StartFullScan()
+		} else {
+			log.Println("[Startup] Varredura total já concluída. Scan direcional ativo.")
 		}
 	}()
 
+	// Detecta troca de save/fortaleza no DF e troca o banco SQLite (hot-swap)
+	if dfClient != nil {
+		go NewWorldWatcher(dfClient, store, scanner, hub).Run()
+	}
+
 	// Iniciar Broadcast de Status do Mundo
-	go broadcastWorldStatus(hub, dfClient, store)
+	go broadcastWorldStatus(hub, dfClient, store, scanner)
+
+	// Mundos servidos: o ao vivo (store) e arquivados abertos sob demanda via /ws?world=
+	registry := NewWorldRegistry(store)
+	defer registry.Close()
+	go registry.RunIdleCloser()
 
 	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
-		serveWs(hub, w, r, dfClient, store, scanner)
+		serveWs(hub, w, r, registry, dfClient, scanner)
 	})
+	// Tamanho máximo dos .fvz recebidos pela API (FV_IMPORT_MAX_MB)
+	importLimit := int64(defaultImportMaxMB) << 20
+	if v := os.Getenv("FV_IMPORT_MAX_MB"); v != "" {
+		mb, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || mb <= 0 {
+			log.Fatalf("Configuração inválida: FV_IMPORT_MAX_MB=%q", v)
+		}
+		importLimit = mb << 20
+	}
+	registerAPI(hub, dfClient, registry, scanner, importLimit)
 
 	port := "8080"
 	if p := os.Getenv("PORT"); p != "" {
@@ -461,60 +550,29 @@ func main() {
 }
 
 // serveWs maneja requisições websocket do peer.
-func serveWs(hub *Hub, w http.ResponseWriter, r *http.Request, dfClient *dfhack.Client, store *mapdata.MapDataStore, scanner *ServerScanner) {
+// O parâmetro ?world=nome liga a sessão a um mundo arquivado; sem ele, ao mundo ao vivo.
+func serveWs(hub *Hub, w http.ResponseWriter, r *http.Request, registry *WorldRegistry, dfClient *dfhack.Client, scanner *ServerScanner) {
+	world, store, err := registry.Resolve(r.URL.Query().Get("world"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("Erro no upgrade do WebSocket: %v", err)
+		registry.Release(world)
 		return
 	}
 	hub.register <- conn
 
-	// Enviar status inicial
-	status := &fvnet.ServerStatus{
-		DfConnected: dfClient != nil && dfClient.IsConnected(),
-		Message:     "Servidor FortressVision Ativo",
-	}
-	if dfClient != nil && dfClient.IsConnected() {
-		status.Message = "Conectado ao DFHack - Sincronização em tempo real"
-	} else {
-		status.Message = "Modo Offline - Lendo dados do Cache SQLite"
-	}
-	hub.SendProtoMessage(conn, fvnet.Envelope_SERVER_STATUS, status)
-
-	// Enviar Dicionários de Tipos (Essencial para o Cliente renderizar blocos sólidos)
-	if dfClient != nil && dfClient.IsConnected() {
-		if dfClient.TiletypeList != nil {
-			hub.SendProtoMessage(conn, fvnet.Envelope_TILETYPE_LIST, dfClient.TiletypeList)
-		}
-		if dfClient.MaterialList != nil {
-			hub.SendProtoMessage(conn, fvnet.Envelope_MATERIAL_LIST, dfClient.MaterialList)
-		}
-	} else {
-		// MODO OFFLINE: Buscar do SQLite e enviar empacotado cru
-		tileData, errT := store.GetDictionary("TiletypeList")
-		matData, errM := store.GetDictionary("MaterialList")
-
-		if errT == nil && len(tileData) > 0 {
-			log.Println("[Offline] Servindo dicionário de Tiletypes a partir do Cache.")
-			env := &fvnet.Envelope{Type: fvnet.Envelope_TILETYPE_LIST, Payload: tileData}
-			b, _ := proto.Marshal(env)
-			conn.WriteMessage(websocket.BinaryMessage, b)
-		} else {
-			log.Println("[Offline] AVISO: TiletypeList não encontrado no banco de dados!")
-		}
-
-		if errM == nil && len(matData) > 0 {
-			log.Println("[Offline] Servindo dicionário de Materials a partir do Cache.")
-			env := &fvnet.Envelope{Type: fvnet.Envelope_MATERIAL_LIST, Payload: matData}
-			b, _ := proto.Marshal(env)
-			conn.WriteMessage(websocket.BinaryMessage, b)
-		} else {
-			log.Println("[Offline] AVISO: MaterialList não encontrado no banco de dados!")
-		}
-	}
+	sess := newSession(hub, conn, registry, dfClient, scanner)
+	sess.bind(world, store)
+	sess.sendWelcome()
 
 	go func() {
 		defer func() {
+			sess.close()
 			hub.unregister <- conn
 		}()
 
@@ -532,158 +590,204 @@ func serveWs(hub *Hub, w http.ResponseWriter, r *http.Request, dfClient *dfhack.
 				continue
 			}
 
-			handleClientMessage(hub, conn, dfClient, store, &envelope, scanner)
+			handleClientMessage(sess, &envelope)
 		}
 	}()
 }
 
-func handleClientMessage(hub *Hub, conn *websocket.Conn, dfClient *dfhack.Client, store *mapdata.MapDataStore, env *fvnet.Envelope, scanner *ServerScanner) {
+func handleClientMessage(sess *session, env *fvnet.Envelope) {
+	hub, conn, dfClient, store := sess.hub, sess.conn, sess.dfClient, sess.store
 	switch env.Type {
 	case fvnet.Envelope_PING:
-		hub.SendProtoMessage(conn, fvnet.Envelope_PONG, nil)
+		// Ecoa o payload (seq e horário do cliente) para ele medir o RTT
+		hub.SendProtoMessage(conn, fvnet.Envelope_PONG, rawPayload(env.Payload))
 	case fvnet.Envelope_CLIENT_REQUEST_REGION:
 		var req fvnet.ClientRequestRegion
 		if err := proto.Unmarshal(env.Payload, &req); err != nil {
 			log.Printf("Erro ao ler RequestRegion: %v", err)
 			return
 		}
-		log.Printf("[Network] Região Center(%d,%d,%d) R:%d", req.CenterX, req.CenterY, req.CenterZ, req.Radius)
+		minZ, maxZ := req.Levels()
+		log.Printf("[Network] Região Center(%d,%d,%d) R:%d Z:%d..%d", req.CenterX, req.CenterY, req.CenterZ, req.Radius, minZ, maxZ)
 		if dfClient != nil {
 			dfClient.SetInterestZ(req.CenterZ)
 		}
-		go streamRegionToClient(hub, conn, dfClient, store, &req, scanner)
+		sess.pinRegion(&req)
+		go streamRegionToClient(hub, conn, dfClient, store, &req, sess.scanner)
+	case fvnet.Envelope_HISTORY_INFO:
+		sess.sendHistoryInfo()
+	case fvnet.Envelope_CLIENT_REQUEST_HISTORY:
+		var req fvnet.HistoryRequest
+		if err := proto.Unmarshal(env.Payload, &req); err != nil {
+			log.Printf("Erro ao ler HistoryRequest: %v", err)
+			return
+		}
+		go streamHistoryToClient(hub, conn, store, &req)
+	case fvnet.Envelope_CLIENT_REQUEST_DIFF:
+		var req fvnet.HistoryRequest
+		if err := proto.Unmarshal(env.Payload, &req); err != nil {
+			log.Printf("Erro ao ler pedido de diff: %v", err)
+			return
+		}
+		go sendDiffToClient(hub, conn, store, &req)
+	case fvnet.Envelope_WORLD_LIST:
+		sess.sendWorldList()
+	case fvnet.Envelope_SELECT_WORLD:
+		var req fvnet.SelectWorld
+		if err := proto.Unmarshal(env.Payload, &req); err != nil {
+			log.Printf("Erro ao ler SelectWorld: %v", err)
+			return
+		}
+		if err := sess.switchWorld(req.Name); err != nil {
+			log.Printf("[Worlds] Seleção de mundo recusada (%s): %v", req.Name, err)
+			// Reenvia a lista para o cliente refletir o mundo que continua ativo
+			sess.sendWorldList()
+		}
 	}
 }
 
 func streamRegionToClient(hub *Hub, conn *websocket.Conn, dfClient *dfhack.Client, store *mapdata.MapDataStore, req *fvnet.ClientRequestRegion, scanner *ServerScanner) {
 	// Streaming agora é permitido mesmo durante o Full Scan para uma experiência fluida (Fase 8)
 
-	// Definir limites
-	minX := req.CenterX - req.Radius
-	maxX := req.CenterX + req.Radius
-	minY := req.CenterY - req.Radius
-	maxY := req.CenterY + req.Radius
-	z := req.CenterZ
+	region := requestedRegion(req)
+	regionMin, regionMax := region.Min.BlockCoord(), region.Max.BlockCoord()
 
-	chunksSent := 0
-	chunksEmpty := 0
-	// Ajustar limites para alinhar com a grade de 16-tiles
-	startX := (minX / 16) * 16
-	startY := (minY / 16) * 16
-
-	// Iterar em blocos de 16
-	for x := startX; x <= maxX; x += 16 {
-		for y := startY; y <= maxY; y += 16 {
-			origin := util.DFCoord{X: x, Y: y, Z: z}.BlockCoord()
+	// Uma única consulta traz do banco todos os chunks da região (todos os níveis) que não estão em RAM
+	chunks, err := store.LoadRegion(regionMin, regionMax)
+	if err != nil {
+		log.Printf("[WS] ERRO ao carregar região %v-%v do banco: %v", regionMin, regionMax, err)
+	}
 
-			chunk, exists := store.GetChunk(origin)
+	// Versões que o cliente já tem (ressincronização ou cache local): chunks inalterados não são reenviados.
+	// MTime só identifica o conteúdo dentro de uma geração do banco: de outra, reenvia tudo.
+	known := make(map[util.DFCoord]int64, len(req.Known))
+	if req.Generation == store.Generation() {
+		for _, v := range req.Known {
+			known[util.NewDFCoord(v.X, v.Y, v.Z)] = v.Mtime
+		}
+	} else if len(req.Known) > 0 {
+		log.Printf("[WS] Versões do cliente são de outra geração do banco (%q): reenviando a região inteira", req.Generation)
+	}
 
-			if !exists {
-				var err error
-				chunk, err = store.LoadChunk(origin)
-				if err != nil {
-					// Fallback On-Demand: Tenta buscar os blocos faltantes diretamente na memória do DFHack
-					if dfClient != nil && dfClient.IsConnected() && dfClient.MapInfo != nil {
-						info := dfClient.MapInfo
-						// Verifica se a coordenada está dentro dos limites reais da fortaleza no mundo (Absoluto)
-						if origin.X < info.BlockPosX*16 || origin.X >= (info.BlockPosX+info.BlockSizeX)*16 ||
-							origin.Y < info.BlockPosY*16 || origin.Y >= (info.BlockPosY+info.BlockSizeY)*16 ||
-							origin.Z < info.BlockPosZ || origin.Z >= info.BlockPosZ+info.BlockSizeZ {
-							// Fora dos limites do mapa gerado, é vazio.
-							store.MarkAsEmpty(origin)
-							continue
-						}
+	chunksSent := 0
+	chunksEmpty := 0
+	chunksUnchanged := 0
+	// Nível do foco primeiro, depois os vizinhos; em cada nível, do centro para fora
+	for _, origin := range mapdata.ChunksByPriority(region, req.Center(), req.FrustumPolygon()) {
+		chunk, exists := chunks[origin]
+
+		if !exists {
+			// Fallback On-Demand: Tenta buscar os blocos faltantes diretamente na memória do DFHack
+			if dfClient != nil && dfClient.IsConnected() && dfClient.MapInfo != nil {
+				info := dfClient.MapInfo
+				// Verifica se a coordenada está dentro dos limites reais da fortaleza no mundo (Absoluto)
+				if origin.X < info.BlockPosX*16 || origin.X >= (info.BlockPosX+info.BlockSizeX)*16 ||
+					origin.Y < info.BlockPosY*16 || origin.Y >= (info.BlockPosY+info.BlockSizeY)*16 ||
+					origin.Z < info.BlockPosZ || origin.Z >= info.BlockPosZ+info.BlockSizeZ {
+					// Fora dos limites do mapa gerado, é vazio.
+					store.MarkAsEmpty(origin)
+					chunksEmpty++
+					sendEmptyChunk(hub, conn, origin, 0)
+					continue
+				}
 
-						log.Printf("[WS-Fallback] Chunk %v não encontrado. Requisitando ao DFHack...", origin)
-						// Converter Tile Coord (Origin) de volta para Block Index para a RPC
-						bx, by := origin.X/16, origin.Y/16
-						list, rpcErr := dfClient.GetBlockList(bx, by, origin.Z, bx, by, origin.Z, 1)
-						if rpcErr == nil && list != nil && len(list.MapBlocks) > 0 {
-							for _, block := range list.MapBlocks {
-								store.StoreSingleBlock(&block)
-							}
-							chunk, exists = store.GetChunk(origin)
-							if exists {
-								log.Printf("[WS-Fallback] Chunk %v recuperado com sucesso via DFHack! (Enviando ao cliente)", origin)
-							}
-						} else {
-							// Se rpcErr for nil mas list vazio, logar também
-							if rpcErr == nil {
-								log.Printf("[WS-Fallback] Chunk %v retornou VAZIO do DFHack (Céu/Ar). Memorizando...", origin)
-								store.MarkAsEmpty(origin)
-							} else {
-								log.Printf("[WS-Fallback] ERRO ao recuperar %v: %v", origin, rpcErr)
-							}
-						}
+				log.Printf("[WS-Fallback] Chunk %v não encontrado. Requisitando ao DFHack...", origin)
+				// Converter Tile Coord (Origin) de volta para Block Index para a RPC
+				bx, by := origin.X/16, origin.Y/16
+				list, rpcErr := dfClient.GetBlockList(bx, by, origin.Z, bx, by, origin.Z, 1)
+				if rpcErr == nil && list != nil && len(list.MapBlocks) > 0 {
+					for _, block := range list.MapBlocks {
+						store.StoreSingleBlock(&block)
 					}
-					if !exists {
-						chunksEmpty++
-						// Notifica o cliente que o chunk é "Ar" (vazio) para progresso de loading
-						msg := &fvnet.MapChunkMessage{
-							ChunkX:    origin.X,
-							ChunkY:    origin.Y,
-							ChunkZ:    origin.Z,
-							VoxelData: nil, // VoxelData nil = Chunk Vazio/Ar
-						}
-						hub.SendProtoMessage(conn, fvnet.Envelope_MAP_CHUNK, msg)
-						continue
+					chunk, exists = store.GetChunk(origin)
+					if exists {
+						log.Printf("[WS-Fallback] Chunk %v recuperado com sucesso via DFHack! (Enviando ao cliente)", origin)
 					}
 				} else {
-					// Blocos carregados do SQLite devem voltar pro Cache ativo para evitar hit de disco repetido.
-					store.Mu.Lock()
-					if store.Chunks == nil {
-						store.Chunks = make(map[util.DFCoord]*mapdata.Chunk)
+					// Se rpcErr for nil mas list vazio, logar também
+					if rpcErr == nil {
+						log.Printf("[WS-Fallback] Chunk %v retornou VAZIO do DFHack (Céu/Ar). Memorizando...", origin)
+						store.MarkAsEmpty(origin)
+					} else {
+						log.Printf("[WS-Fallback] ERRO ao recuperar %v: %v", origin, rpcErr)
 					}
-					store.Chunks[origin] = chunk
-					store.Mu.Unlock()
 				}
 			}
+			if !exists {
+				chunksEmpty++
+				// Notifica o cliente que o chunk é "Ar" (vazio) para progresso de loading
+				sendEmptyChunk(hub, conn, origin, 0)
+				continue
+			}
+		}
 
-			if chunk != nil {
-				// Se for um bloco conhecido como vazio (Ar), enviamos sem VoxelData
-				if chunk.IsEmpty {
-					chunksEmpty++
-					msg := &fvnet.MapChunkMessage{
-						ChunkX:    origin.X,
-						ChunkY:    origin.Y,
-						ChunkZ:    origin.Z,
-						VoxelData: nil,
-					}
-					hub.SendProtoMessage(conn, fvnet.Envelope_MAP_CHUNK, msg)
-					continue
-				}
+		if chunk != nil {
+			if v, ok := known[origin]; ok && v != 0 && v == chunk.MTime {
+				chunksUnchanged++
+				continue
+			}
+			// Se for um bloco conhecido como vazio (Ar), enviamos sem VoxelData
+			if chunk.IsEmpty {
+				chunksEmpty++
+				sendEmptyChunk(hub, conn, origin, chunk.MTime)
+				continue
+			}
 
-				var buf bytes.Buffer
-				enc := gob.NewEncoder(&buf)
-				if err := enc.Encode(chunk.Tiles); err != nil {
-					log.Printf("[WS] Erro ao codificar tiles para chunk (%d,%d,%d): %v", origin.X, origin.Y, origin.Z, err)
-					continue
-				}
+			// Mesmo codec do banco: tiles, plantas, construções, itens, manchas e gravuras
+			voxelData, err := mapdata.EncodeChunk(chunk, mapdata.LayersAll)
+			if err != nil {
+				log.Printf("[WS] Erro ao codificar chunk (%d,%d,%d): %v", origin.X, origin.Y, origin.Z, err)
+				continue
+			}
 
-				msg := &fvnet.MapChunkMessage{
-					ChunkX:    origin.X,
-					ChunkY:    origin.Y,
-					ChunkZ:    origin.Z,
-					VoxelData: buf.Bytes(),
-				}
-				hub.SendProtoMessage(conn, fvnet.Envelope_MAP_CHUNK, msg)
-				chunksSent++
+			msg := &fvnet.MapChunkMessage{
+				ChunkX:    origin.X,
+				ChunkY:    origin.Y,
+				ChunkZ:    origin.Z,
+				VoxelData: voxelData,
+				Mtime:     chunk.MTime,
 			}
+			hub.SendProtoMessage(conn, fvnet.Envelope_MAP_CHUNK, msg)
+			chunksSent++
 		}
 	}
 	if chunksSent > 0 {
-		log.Printf("[WS] Streaming → %d chunks enviados, %d ar/céu (Z=%d)", chunksSent, chunksEmpty, z)
+		log.Printf("[WS] Streaming → %d chunks enviados, %d ar/céu (Z=%d..%d)", chunksSent, chunksEmpty, region.Min.Z, region.Max.Z)
+	}
+	if chunksUnchanged > 0 {
+		log.Printf("[WS] Versões conhecidas → %d chunks inalterados não reenviados (Z=%d..%d)", chunksUnchanged, region.Min.Z, region.Max.Z)
 	}
 }
 
-func broadcastWorldStatus(hub *Hub, dfClient *dfhack.Client, store *mapdata.MapDataStore) {
+// requestedRegion devolve a caixa de tiles (todos os níveis pedidos) de um pedido de região.
+func requestedRegion(req *fvnet.ClientRequestRegion) mapdata.Region {
+	regionMin, regionMax := req.Bounds()
+	return mapdata.Region{Min: regionMin, Max: regionMax}
+}
+
+// sendEmptyChunk avisa o cliente que o chunk é "Ar" (VoxelData nil).
+// Toda coordenada pedida recebe uma resposta (menos as que o cliente já tem na
+// mesma versão), o que permite ao cliente medir o progresso do carregamento sem
+// depender de timeout. mtime é 0 quando o chunk nem existe no store.
+func sendEmptyChunk(hub *Hub, conn *websocket.Conn, origin util.DFCoord, mtime int64) {
+	msg := &fvnet.MapChunkMessage{
+		ChunkX:    origin.X,
+		ChunkY:    origin.Y,
+		ChunkZ:    origin.Z,
+		VoxelData: nil,
+		Mtime:     mtime,
+	}
+	hub.SendProtoMessage(conn, fvnet.Envelope_MAP_CHUNK, msg)
+}
+
+func broadcastWorldStatus(hub *Hub, dfClient *dfhack.Client, store *mapdata.MapDataStore, scanner *ServerScanner) {
 	defer func() {
 		if r := recover(); r != nil {
 			log.Printf("[WorldStatus] Recuperado de pânico: %v", r)
 			// Reinicia após uma pausa
 			go func() {
 				time.Sleep(5 * time.Second)
-				broadcastWorldStatus(hub, dfClient, store)
+				broadcastWorldStatus(hub, dfClient, store, scanner)
 			}()
 		}
 	}()
@@ -691,7 +795,16 @@ func broadcastWorldStatus(hub *Hub, dfClient *dfhack.Client, store *mapdata.MapD
 	months := []string{"Granito", "Slate", "Felsite", "Hematita", "Malaquita", "Galena", "Calcário", "Arenito", "Madeira", "Moonstone", "Opal", "Obsidiana"}
 	seasons := []string{"Primavera", "Verão", "Outono", "Inverno"}
 
+	lastState := currentServerState(dfClient, scanner)
+
 	for {
+		// Notifica os clientes sempre que o estado operacional muda
+		if state := currentServerState(dfClient, scanner); state != lastState {
+			log.Printf("[Status] Estado do servidor: %v -> %v", lastState, state)
+			hub.BroadcastServerStatus(state, dfClient != nil && dfClient.IsConnected())
+			lastState = state
+		}
+
 		if dfClient == nil || !dfClient.IsConnected() || dfClient.MapInfo == nil {
 			// No modo offline
 			if dfClient == nil {
@@ -722,6 +835,7 @@ func broadcastWorldStatus(hub *Hub, dfClient *dfhack.Client, store *mapdata.MapD
 			status.WorldName = world.NameEn
 			status.Year = world.CurYear
 			tick := world.CurYearTick
+			store.SetGameTick(mapdata.GameTick(world.CurYear, tick)) // Marca as próximas versões do histórico
 			monthIdx := tick / 33600
 			status.Day = (tick%33600)/1200 + 1
 
@@ -768,6 +882,29 @@ func broadcastWorldStatus(hub *Hub, dfClient *dfhack.Client, store *mapdata.MapD
 }
 
 func (h *Hub) SendProtoMessage(conn *websocket.Conn, msgType fvnet.Envelope_Type, msg interface{}) {
+	data, err := encodeEnvelope(msgType, msg)
+	if err != nil {
+		log.Printf("Erro ao serializar mensagem: %v", err)
+		return
+	}
+
+	if err := h.WriteSafe(conn, websocket.BinaryMessage, data); err != nil {
+		log.Printf("Erro ao enviar mensagem: %v", err)
+	}
+}
+
+// BroadcastProtoMessage envia a mensagem embrulhada em Envelope para todos os clientes.
+func (h *Hub) BroadcastProtoMessage(msgType fvnet.Envelope_Type, msg interface{}) {
+	data, err := encodeEnvelope(msgType, msg)
+	if err != nil {
+		log.Printf("[Hub] Erro ao serializar broadcast: %v", err)
+		return
+	}
+	h.safeSend(data)
+}
+
+// encodeEnvelope serializa o payload (protobuf gerado ou Marshal manual) dentro de um Envelope.
+func encodeEnvelope(msgType fvnet.Envelope_Type, msg interface{}) ([]byte, error) {
 	var payload []byte
 	var err error
 	if msg != nil {
@@ -777,8 +914,7 @@ func (h *Hub) SendProtoMessage(conn *websocket.Conn, msgType fvnet.Envelope_Type
 			payload, err = proto.Marshal(pm)
 		}
 		if err != nil {
-			log.Printf("Erro ao serializar payload: %v", err)
-			return
+			return nil, err
 		}
 	}
 
@@ -786,43 +922,42 @@ func (h *Hub) SendProtoMessage(conn *websocket.Conn, msgType fvnet.Envelope_Type
 		Type:    msgType,
 		Payload: payload,
 	}
+	return proto.Marshal(envelope)
+}
 
-	data, err := proto.Marshal(envelope)
-	if err != nil {
-		log.Printf("Erro ao serializar envelope: %v", err)
-		return
+// currentServerState deriva o estado operacional reportado aos clientes.
+func currentServerState(dfClient *dfhack.Client, scanner *ServerScanner) fvnet.ServerStatus_State {
+	switch {
+	case dfClient == nil:
+		return fvnet.ServerStatus_OFFLINE_CACHE
+	case !dfClient.IsConnected():
+		return fvnet.ServerStatus_DF_DISCONNECTED
+	case scanner.IsFullScanning():
+		return fvnet.ServerStatus_SCANNING
+	default:
+		return fvnet.ServerStatus_ONLINE
 	}
+}
 
-	if err := h.WriteSafe(conn, websocket.BinaryMessage, data); err != nil {
-		log.Printf("Erro ao enviar mensagem: %v", err)
+// serverStateMessage retorna a descrição legível de cada estado.
+func serverStateMessage(state fvnet.ServerStatus_State) string {
+	switch state {
+	case fvnet.ServerStatus_OFFLINE_CACHE:
+		return "Modo Offline - Lendo dados do Cache SQLite"
+	case fvnet.ServerStatus_SCANNING:
+		return "Varredura total do mapa em andamento"
+	case fvnet.ServerStatus_DF_DISCONNECTED:
+		return "DFHack desconectado - Aguardando reconexão"
+	default:
+		return "Conectado ao DFHack - Sincronização em tempo real"
 	}
 }
 
-// findLatestSave busca o arquivo .fv mais recente na pasta saves
+// findLatestSave busca o mundo salvo mais recente na pasta saves
 func findLatestSave() string {
-	files, err := os.ReadDir("saves")
-	if err != nil {
+	worlds, err := mapdata.ListWorlds()
+	if err != nil || len(worlds) == 0 {
 		return ""
 	}
-
-	var latestFile string
-	var latestTime time.Time
-
-	for _, f := range files {
-		if !f.IsDir() && filepath.Ext(f.Name()) == ".fv" {
-			info, err := f.Info()
-			if err == nil {
-				if latestFile == "" || info.ModTime().After(latestTime) {
-					latestTime = info.ModTime()
-					latestFile = f.Name()
-				}
-			}
-		}
-	}
-
-	if latestFile != "" {
-		// Retorna o nome sem a extensão .fv
-		return latestFile[:len(latestFile)-3]
-	}
-	return ""
+	return worlds[0].Name
 }
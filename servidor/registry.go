@@ -0,0 +1,183 @@
+package main
+
+import (
+	"FortressVision/shared/mapdata"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// liveWorld identifica, no Hub e nas sessões, o mundo servido ao vivo
+// (o save aberto no DFHack ou, no modo offline, o banco padrão do servidor).
+const liveWorld = ""
+
+// archivedIdleTimeout é quanto um mundo arquivado sem sessões fica aberto antes
+// de o banco ser fechado (reabrir um banco em formato antigo migra uma cópia).
+const archivedIdleTimeout = 5 * time.Minute
+
+// WorldRegistry mantém os mundos servidos pelo processo: o mundo ao vivo e
+// os mundos arquivados, abertos sob demanda em modo somente leitura e fechados
+// depois de archivedIdleTimeout sem nenhuma sessão ligada (ver CloseIdle).
+type WorldRegistry struct {
+	live *mapdata.MapDataStore
+
+	mu       sync.Mutex
+	archived map[string]*archivedWorld
+	opening  map[string]*openingWorld
+}
+
+// openingWorld é um mundo arquivado sendo aberto fora de mu; quem pede o mesmo
+// mundo nesse meio tempo espera done em vez de abrir o banco de novo.
+type openingWorld struct {
+	done chan struct{}
+	err  error
+}
+
+// archivedWorld é um mundo arquivado aberto e quantas sessões o usam.
+type archivedWorld struct {
+	store     *mapdata.MapDataStore
+	refs      int
+	idleSince time.Time // Quando refs chegou a zero
+}
+
+func NewWorldRegistry(live *mapdata.MapDataStore) *WorldRegistry {
+	return &WorldRegistry{
+		live:     live,
+		archived: make(map[string]*archivedWorld),
+		opening:  make(map[string]*openingWorld),
+	}
+}
+
+// Live retorna o store do mundo ao vivo.
+func (r *WorldRegistry) Live() *mapdata.MapDataStore {
+	return r.live
+}
+
+// Resolve retorna o store do mundo pedido. Nome vazio ou igual ao mundo ao vivo
+// resolve para liveWorld; qualquer outro save é aberto uma única vez e compartilhado.
+// Cada Resolve bem-sucedido deve ser seguido de um Release do mundo retornado
+// quando a sessão deixar de usá-lo.
+//
+// A abertura (que pode migrar uma cópia do banco ou reler um .fvlog inteiro)
+// roda fora de mu: os outros mundos continuam resolvendo e sendo liberados, e
+// quem pede o mesmo mundo espera a abertura em andamento.
+func (r *WorldRegistry) Resolve(name string) (string, *mapdata.MapDataStore, error) {
+	if name == liveWorld || name == r.live.CurrentWorld() {
+		return liveWorld, r.live, nil
+	}
+
+	for {
+		r.mu.Lock()
+		if world, ok := r.archived[name]; ok {
+			world.refs++
+			r.mu.Unlock()
+			return name, world.store, nil
+		}
+		op, waiting := r.opening[name]
+		if !waiting {
+			op = &openingWorld{done: make(chan struct{})}
+			r.opening[name] = op
+		}
+		r.mu.Unlock()
+
+		if waiting {
+			<-op.done
+			if op.err != nil {
+				return "", nil, op.err
+			}
+			continue // Publicado por quem abriu: pega a referência acima
+		}
+		return r.open(name, op)
+	}
+}
+
+// open abre o mundo arquivado name fora de mu e o publica com uma referência.
+func (r *WorldRegistry) open(name string, op *openingWorld) (string, *mapdata.MapDataStore, error) {
+	store := mapdata.NewMapDataStore()
+	store.MemoryBudget = r.live.MemoryBudget // Mesma política de cache do mundo ao vivo
+	err := store.OpenReadOnly(name)
+
+	r.mu.Lock()
+	delete(r.opening, name)
+	op.err = err
+	if err == nil {
+		r.archived[name] = &archivedWorld{store: store, refs: 1}
+	}
+	r.mu.Unlock()
+	close(op.done)
+
+	if err != nil {
+		return "", nil, err
+	}
+	log.Printf("[Registry] Mundo arquivado aberto (somente leitura): %s", name)
+	return name, store, nil
+}
+
+// Release devolve a referência obtida com Resolve. O mundo ao vivo não é contado.
+func (r *WorldRegistry) Release(name string) {
+	if name == liveWorld {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	world, ok := r.archived[name]
+	if !ok || world.refs == 0 {
+		return
+	}
+	if world.refs--; world.refs == 0 {
+		world.idleSince = time.Now()
+	}
+}
+
+// CloseIdle fecha os mundos arquivados sem sessões há pelo menos maxIdle e
+// retorna quantos fechou.
+func (r *WorldRegistry) CloseIdle(maxIdle time.Duration) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	closed := 0
+	for name, world := range r.archived {
+		if world.refs > 0 || time.Since(world.idleSince) < maxIdle {
+			continue
+		}
+		world.store.Close()
+		delete(r.archived, name)
+		log.Printf("[Registry] Mundo arquivado %s fechado (sem sessões há %v)", name, maxIdle)
+		closed++
+	}
+	return closed
+}
+
+// RunIdleCloser chama CloseIdle(archivedIdleTimeout) periodicamente. Não retorna.
+func (r *WorldRegistry) RunIdleCloser() {
+	ticker := time.NewTicker(archivedIdleTimeout / 5)
+	defer ticker.Stop()
+	for range ticker.C {
+		r.CloseIdle(archivedIdleTimeout)
+	}
+}
+
+// Archived lista os mundos arquivados abertos no momento.
+func (r *WorldRegistry) Archived() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.archived))
+	for name := range r.archived {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Close fecha os bancos dos mundos arquivados.
+func (r *WorldRegistry) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for name, world := range r.archived {
+		world.store.Close()
+		delete(r.archived, name)
+	}
+}
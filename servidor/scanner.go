@@ -4,13 +4,59 @@ import (
 	"FortressVision/servidor/internal/dfhack"
 	"FortressVision/shared/mapdata"
 	"FortressVision/shared/pkg/dfproto"
+	"FortressVision/shared/proto/fvnet"
 	"FortressVision/shared/util"
+	"errors"
 	"fmt"
 	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// fullScanBatch é o tamanho (em blocos) de cada lote X/Y pedido ao DFHack na varredura total.
+const fullScanBatch = int32(3)
+
+// Fases da varredura total reportadas em ScanProgress.
+const (
+	ScanPhaseIdle     = "idle"
+	ScanPhaseScanning = "scanning"
+	ScanPhasePaused   = "paused" // DFHack caiu no meio da varredura; retoma na reconexão
+	ScanPhaseDone     = "done"
+)
+
+// ScanProgress descreve o andamento da varredura total de forma estruturada.
+type ScanProgress struct {
+	Phase         string  `json:"phase"`
+	CurrentZ      int32   `json:"current_z"`
+	LayersDone    int32   `json:"layers_done"`
+	LayersTotal   int32   `json:"layers_total"`
+	BlocksScanned int64   `json:"blocks_scanned"`
+	BlocksPerSec  float64 `json:"blocks_per_sec"`
+	ETASeconds    float64 `json:"eta_seconds"`
+}
+
+// ToProto converte o progresso para a mensagem fvnet.ScanProgress enviada aos clientes.
+func (p ScanProgress) ToProto() *fvnet.ScanProgress {
+	phase := fvnet.ScanProgress_IDLE
+	switch p.Phase {
+	case ScanPhaseScanning:
+		phase = fvnet.ScanProgress_SCANNING
+	case ScanPhasePaused:
+		phase = fvnet.ScanProgress_PAUSED
+	case ScanPhaseDone:
+		phase = fvnet.ScanProgress_DONE
+	}
+	return &fvnet.ScanProgress{
+		Phase:        phase,
+		Current:      p.LayersDone,
+		Total:        p.LayersTotal,
+		CurrentZ:     p.CurrentZ,
+		BlocksPerSec: float32(p.BlocksPerSec),
+		EtaSeconds:   float32(p.ETASeconds),
+	}
+}
+
 type ServerScanner struct {
 	dfClient *dfhack.Client
 	store    *mapdata.MapDataStore
@@ -22,6 +68,17 @@ type ServerScanner struct {
 	// Flag para controlar a suspensão de rotinas menores durante scans intensos
 	isFullScanning bool
 	fsMutex        sync.RWMutex
+
+	// abortFullScan interrompe a varredura total sem salvar cursor (troca de mundo)
+	abortFullScan atomic.Bool
+
+	// Progresso persistente da varredura total (cursor + camadas concluídas)
+	stateMu   sync.Mutex
+	scanState *mapdata.ScanState
+	progress  ScanProgress
+
+	// OnProgress é chamado a cada checkpoint da varredura total
+	OnProgress func(p ScanProgress)
 }
 
 func NewServerScanner(df *dfhack.Client, s *mapdata.MapDataStore, h *Hub) *ServerScanner {
@@ -30,6 +87,7 @@ func NewServerScanner(df *dfhack.Client, s *mapdata.MapDataStore, h *Hub) *Serve
 		store:          s,
 		hub:            h,
 		isFullScanning: false,
+		progress:       ScanProgress{Phase: ScanPhaseIdle},
 	}
 }
 
@@ -51,10 +109,20 @@ func (s *ServerScanner) scanLoop() {
 			// O scanner direcional agora pode rodar em paralelo ao Full Scan (Fase 8)
 			// Isso garante que o nível Z onde o jogador está olhando seja priorizado/atualizado.
 
-			if s.dfClient == nil || !s.dfClient.IsConnected() {
+			if s.dfClient == nil {
+				time.Sleep(2 * time.Second)
+				return
+			}
+			if !s.dfClient.IsConnected() {
+				// Tenta restabelecer o socket; a varredura total retoma do cursor salvo
+				s.dfClient.Reconnect(fmt.Errorf("conexão com DFHack perdida"))
 				time.Sleep(2 * time.Second)
 				return
 			}
+			if !s.IsFullScanning() && s.NeedsFullScan() && s.Progress().Phase == ScanPhasePaused {
+				log.Println("[Scanner] DFHack reconectado. Retomando varredura total...")
+				s.StartFullScan()
+			}
 
 			interestZ := s.dfClient.GetInterestZ()
 			radius := int32(192) // Expandido para 384x384 (24x24 blocos)
@@ -142,129 +210,361 @@ func (s *ServerScanner) scanLoop() {
 	}
 }
 
+// StartFullScan inicia (ou retoma) a varredura total do mapa em background.
+// O progresso é persistido no banco, então a varredura continua de onde parou
+// após reiniciar o servidor ou reconectar ao DFHack.
 func (s *ServerScanner) StartFullScan() {
-	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				log.Printf("[Scanner-FullScan] Recuperado de pânico fatal: %v", r)
-				s.fsMutex.Lock()
-				s.isFullScanning = false
-				s.fsMutex.Unlock()
-			}
-		}()
-		log.Printf("[Scanner] Iniciando download TOTAL do mapa no servidor...")
-		if s.dfClient == nil || !s.dfClient.IsConnected() {
-			return // Modo offline não faz full scan de DFHack
-		}
+	s.fsMutex.Lock()
+	if s.isFullScanning {
+		s.fsMutex.Unlock()
+		return
+	}
+	s.isFullScanning = true
+	s.fsMutex.Unlock()
 
-		info := s.dfClient.MapInfo
-		if info == nil {
-			return
-		}
+	go s.runFullScan()
+}
+
+// IsFullScanning indica se a varredura total está em andamento.
+func (s *ServerScanner) IsFullScanning() bool {
+	s.fsMutex.RLock()
+	defer s.fsMutex.RUnlock()
+	return s.isFullScanning
+}
 
-		// X e Y começam em 0 (Locais), Z é absoluto
-		minX, minY, minZ := info.BlockPosX, info.BlockPosY, info.BlockPosZ
-		totalBlocksX, totalBlocksY, totalBlocksZ := info.BlockSizeX, info.BlockSizeY, info.BlockSizeZ
-		maxZ := minZ + totalBlocksZ
+// NeedsFullScan indica se ainda existem níveis Z pendentes de varredura para o mapa atual.
+func (s *ServerScanner) NeedsFullScan() bool {
+	if s.dfClient == nil || s.dfClient.MapInfo == nil {
+		return false
+	}
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	st, err := s.scanStateLocked(s.dfClient.MapInfo)
+	if err != nil {
+		log.Printf("[Scanner] ERRO ao ler o progresso da varredura total: %v", err)
+		return false
+	}
+	return !st.Complete()
+}
 
-		worldName := s.dfClient.MapInfo.WorldNameEn
-		if worldName == "" {
-			worldName = s.dfClient.MapInfo.WorldName
+// RequestRescan marca os níveis [minZ, maxZ] como pendentes e dispara a varredura total.
+func (s *ServerScanner) RequestRescan(minZ, maxZ int32) error {
+	if s.dfClient == nil || !s.dfClient.IsConnected() || s.dfClient.MapInfo == nil {
+		return fmt.Errorf("DFHack desconectado")
+	}
+	if minZ > maxZ {
+		minZ, maxZ = maxZ, minZ
+	}
+
+	s.stateMu.Lock()
+	st, err := s.scanStateLocked(s.dfClient.MapInfo)
+	if err == nil {
+		st.ResetLayers(minZ, maxZ)
+		err = s.store.SaveScanState(st)
+	}
+	s.stateMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[Scanner] Nova varredura solicitada para Z %d a %d.", minZ, maxZ)
+	s.StartFullScan()
+	return nil
+}
+
+// ResetWorld interrompe a varredura total em andamento e descarta o estado em cache,
+// para que o próximo mapa use o seu próprio progresso salvo.
+func (s *ServerScanner) ResetWorld() {
+	s.abortFullScan.Store(true)
+	for s.IsFullScanning() {
+		time.Sleep(100 * time.Millisecond)
+	}
+	s.abortFullScan.Store(false)
+
+	s.stateMu.Lock()
+	s.scanState = nil
+	s.progress = ScanProgress{Phase: ScanPhaseIdle}
+	s.stateMu.Unlock()
+}
+
+// Progress retorna uma cópia do andamento atual da varredura total.
+func (s *ServerScanner) Progress() ScanProgress {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	return s.progress
+}
+
+// scanStateLocked retorna o estado da varredura do mapa atual, carregando-o do banco
+// na primeira vez. Um progresso gravado ilegível recomeça do zero; falhas ao ler o
+// banco são repassadas sem trocar o estado. Deve ser chamado com stateMu travado.
+func (s *ServerScanner) scanStateLocked(info *dfproto.MapInfo) (*mapdata.ScanState, error) {
+	if s.scanState != nil && s.scanState.Matches(info) {
+		return s.scanState, nil
+	}
+
+	st, err := s.store.LoadScanState()
+	if errors.Is(err, mapdata.ErrInvalidScanState) {
+		log.Printf("[Scanner] Aviso: %v. Reiniciando progresso da varredura.", err)
+	} else if err != nil {
+		return nil, err
+	}
+	if st != nil && !st.Matches(info) {
+		log.Println("[Scanner] Geometria do mapa mudou. Progresso de varredura anterior descartado.")
+		st = nil
+	}
+	if st == nil {
+		st = mapdata.NewScanState(info)
+	}
+	s.scanState = st
+	return st, nil
+}
+
+// fullScanSession acumula as métricas de throughput de uma execução da varredura total.
+type fullScanSession struct {
+	started        time.Time
+	blocks         int64 // Blocos processados nesta sessão (terreno + céu)
+	blocksPerLayer int64
+	// incomplete marca os níveis com lotes que falharam nesta execução: ficam
+	// pendentes no ScanState e são pulados até a próxima varredura
+	incomplete map[int32]bool
+}
+
+// reportProgress atualiza o andamento e notifica OnProgress.
+func (s *ServerScanner) reportProgress(phase string, z int32, layerBlocks int64, sess *fullScanSession) {
+	s.stateMu.Lock()
+	p := ScanProgress{
+		Phase:         phase,
+		CurrentZ:      z,
+		BlocksScanned: sess.blocks,
+	}
+	if s.scanState != nil {
+		p.LayersDone = s.scanState.DoneCount()
+		p.LayersTotal = s.scanState.MapSize.Z
+	}
+	if elapsed := time.Since(sess.started).Seconds(); elapsed > 0 {
+		p.BlocksPerSec = float64(sess.blocks) / elapsed
+	}
+	if phase == ScanPhaseScanning && p.BlocksPerSec > 0 {
+		remaining := int64(p.LayersTotal-p.LayersDone)*sess.blocksPerLayer - layerBlocks
+		if remaining > 0 {
+			p.ETASeconds = float64(remaining) / p.BlocksPerSec
 		}
+	}
+	s.progress = p
+	onProgress := s.OnProgress
+	s.stateMu.Unlock()
+
+	if onProgress != nil {
+		onProgress(p)
+	}
+}
 
-		// Travar o scan ultra-rápido (evita competição inútil)
+func (s *ServerScanner) runFullScan() {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[Scanner-FullScan] Recuperado de pânico fatal: %v", r)
+		}
 		s.fsMutex.Lock()
-		s.isFullScanning = true
+		s.isFullScanning = false
 		s.fsMutex.Unlock()
+	}()
 
-		// Destravar no final de forma garantida
-		defer func() {
-			s.fsMutex.Lock()
-			s.isFullScanning = false
-			s.fsMutex.Unlock()
-			s.hub.BroadcastServerStatus("FULL_SCAN:DONE", s.dfClient.IsConnected())
-			log.Println("[Scanner] Download total concluído de forma otimizada!")
-		}()
+	if s.dfClient == nil || !s.dfClient.IsConnected() {
+		return // Modo offline não faz full scan de DFHack
+	}
+	info := s.dfClient.MapInfo
+	if info == nil {
+		return
+	}
 
-		log.Printf("[Scanner] Iniciando varredura TOTAL linear (Top-Down): %d níveis (Z: %d a %d)", totalBlocksZ, minZ, maxZ-1)
+	worldName := worldFileOf(info)
 
-		for z := maxZ - 1; z >= minZ; z-- {
-			currentLevel := maxZ - z
-			progressMsg := fmt.Sprintf("FULL_SCAN:%d/%d", currentLevel, totalBlocksZ)
-			s.hub.BroadcastServerStatus(progressMsg, s.dfClient.IsConnected())
+	s.stateMu.Lock()
+	st, err := s.scanStateLocked(info)
+	if err != nil {
+		s.stateMu.Unlock()
+		log.Printf("[Scanner] ERRO ao ler o progresso da varredura total: %v. Varredura não iniciada.", err)
+		return
+	}
+	done := st.DoneCount()
+	s.stateMu.Unlock()
+
+	if done > 0 || st.CursorValid {
+		log.Printf("[Scanner] Retomando varredura total: %d/%d níveis já concluídos.", done, info.BlockSizeZ)
+	} else {
+		log.Printf("[Scanner] Iniciando varredura TOTAL linear (Top-Down): %d níveis (Z: %d a %d)",
+			info.BlockSizeZ, st.MinZ(), st.MaxZ())
+	}
 
-			blocksInLayer := 0
-			emptyInLayer := 0
+	sess := &fullScanSession{
+		started:        time.Now(),
+		blocksPerLayer: int64(info.BlockSizeX) * int64(info.BlockSizeY),
+		incomplete:     make(map[int32]bool),
+	}
 
-			for x := int32(0); x < totalBlocksX; x += 3 {
-				for y := int32(0); y < totalBlocksY; y += 3 {
-					maxX, maxY := x+3, y+3
-					if maxX > totalBlocksX {
-						maxX = totalBlocksX
-					}
-					if maxY > totalBlocksY {
-						maxY = totalBlocksY
-					}
+	// O loop consulta o estado a cada camada, então pedidos de nova varredura
+	// feitos durante a execução (RequestRescan) também são atendidos.
+	for {
+		s.stateMu.Lock()
+		z, ok := st.NextLayer(sess.incomplete)
+		startX, startY := int32(0), int32(0)
+		if ok && st.CursorValid && st.CursorZ == z {
+			startX, startY = st.CursorX, st.CursorY
+		}
+		s.stateMu.Unlock()
 
-					// GetBlockList agora aceita coordenadas GLOBAIS e cuida da tradução interna
-					list, err := s.dfClient.GetBlockList(minX+x, minY+y, z, minX+maxX, minY+maxY, z+1, 10)
-					if err != nil {
-						continue
-					}
+		if !ok {
+			break
+		}
 
-					foundInBatch := make(map[util.DFCoord]bool)
-					if list != nil && len(list.MapBlocks) > 0 {
-						for _, block := range list.MapBlocks {
-							s.store.StoreSingleBlock(&block)
-							blocksInLayer++
-							foundInBatch[util.NewDFCoord(block.MapX*16, block.MapY*16, block.MapZ).BlockCoord()] = true
-						}
-					}
+		if !s.scanLayer(info, st, worldName, z, startX, startY, sess) {
+			if s.abortFullScan.Load() {
+				log.Printf("[Scanner] Varredura total interrompida em Z=%d (troca de mundo).", z)
+				return
+			}
+			log.Printf("[Scanner] Varredura total pausada em Z=%d (DFHack indisponível). Será retomada na reconexão.", z)
+			s.reportProgress(ScanPhasePaused, z, 0, sess)
+			return
+		}
+	}
 
-					// Marcar como vazio apenas os blocos globais que tentamos buscar
-					for bx := int32(0); bx < (maxX - x); bx++ {
-						for by := int32(0); by < (maxY - y); by++ {
-							absBX, absBY := minX+x+bx, minY+y+by
-							origin := util.DFCoord{X: absBX * 16, Y: absBY * 16, Z: z}
-							if !foundInBatch[origin] {
-								s.store.MarkAsEmpty(origin)
-								emptyInLayer++
-							}
-						}
-					}
-					// Acelera as pausas para injetar mais velocidade de rede (era 2ms)
-					// time.Sleep(1 * time.Millisecond) // Opcional
+	s.reportProgress(ScanPhaseDone, 0, 0, sess)
+	if len(sess.incomplete) > 0 {
+		log.Printf("[Scanner] Download total terminou com %d níveis incompletos (lotes com falha): ficam pendentes para a próxima varredura.",
+			len(sess.incomplete))
+		return
+	}
+	log.Printf("[Scanner] Download total concluído: %d blocos em %v.", sess.blocks, time.Since(sess.started).Round(time.Second))
+}
+
+// scanLayer varre um nível Z a partir do lote (startX, startY).
+// Retorna false se o DFHack caiu; nesse caso o cursor fica salvo no lote que falhou.
+// Também retorna false, sem salvar nada, quando a varredura é abortada por ResetWorld.
+func (s *ServerScanner) scanLayer(info *dfproto.MapInfo, st *mapdata.ScanState, worldName string, z, startX, startY int32, sess *fullScanSession) bool {
+	// X e Y são índices locais de bloco, convertidos para absolutos na RPC
+	minX, minY := info.BlockPosX, info.BlockPosY
+	totalBlocksX, totalBlocksY := info.BlockSizeX, info.BlockSizeY
+
+	blocksInLayer := 0
+	emptyInLayer := 0
+	skippedBatches := 0
+	layerBlocks := int64(startX) * int64(totalBlocksY)
+
+	s.reportProgress(ScanPhaseScanning, z, layerBlocks, sess)
+
+	for x := startX; x < totalBlocksX; x += fullScanBatch {
+		y0 := int32(0)
+		if x == startX {
+			y0 = startY
+		}
+		for y := y0; y < totalBlocksY; y += fullScanBatch {
+			if s.abortFullScan.Load() {
+				return false
+			}
+			maxX, maxY := util.Min(x+fullScanBatch, totalBlocksX), util.Min(y+fullScanBatch, totalBlocksY)
+
+			// GetBlockList aceita coordenadas GLOBAIS e cuida da tradução interna
+			list, err := s.fetchFullScanBatch(minX+x, minY+y, z, minX+maxX, minY+maxY)
+			if err != nil {
+				if !s.dfClient.IsConnected() {
+					// Persiste o que já veio e guarda o cursor neste lote
+					s.store.Save(worldName)
+					s.stateMu.Lock()
+					st.SetCursor(z, x, y)
+					s.store.SaveScanState(st)
+					s.stateMu.Unlock()
+					return false
 				}
+				log.Printf("[Scanner] Lote (%d,%d,%d) ignorado após falhas repetidas: %v", minX+x, minY+y, z, err)
+				skippedBatches++
+				continue
 			}
 
-			nDone := maxZ - z
-			if z%20 == 0 {
-				pct := float64(nDone) / float64(totalBlocksZ) * 100
-				log.Printf("[Scanner] ████ Progresso: %d/%d níveis (%.0f%%)", nDone, totalBlocksZ, pct)
+			foundInBatch := make(map[util.DFCoord]bool)
+			if list != nil {
+				for _, block := range list.MapBlocks {
+					s.store.StoreSingleBlock(&block)
+					blocksInLayer++
+					foundInBatch[util.NewDFCoord(block.MapX, block.MapY, block.MapZ).BlockCoord()] = true
+				}
 			}
 
-			// ---> OTIMIZAÇÃO DE MEMÓRIA CRÍTICA (Evitar leak de 18GB) <---
-			// Salva o andar que acabamos de receber e força a limpeza da RAM
-			savedCount, _ := s.store.Save(worldName)
-
-			// Fazer o purge apenas deste Z-Level que acabamos de carregar
-			s.store.Mu.Lock()
-			countPurged := 0
-			for origin := range s.store.Chunks {
-				if origin.Z == z {
-					delete(s.store.Chunks, origin)
-					countPurged++
+			// Marcar como vazio apenas os blocos globais que tentamos buscar
+			for bx := minX + x; bx < minX+maxX; bx++ {
+				for by := minY + y; by < minY+maxY; by++ {
+					origin := util.DFCoord{X: bx * 16, Y: by * 16, Z: z}
+					if !foundInBatch[origin] {
+						s.store.MarkAsEmpty(origin)
+						emptyInLayer++
+					}
 				}
 			}
-			s.store.Mu.Unlock()
 
-			// Log compacto: 1 linha com tudo
-			if savedCount > 0 || countPurged > 0 {
-				log.Printf("[Scanner] Z=%d ✓ %d terreno, %d céu | %d salvos, %d liberados (%d/%d)", z, blocksInLayer, emptyInLayer, savedCount, countPurged, nDone, totalBlocksZ)
-			}
+			batchBlocks := int64(maxX-x) * int64(maxY-y)
+			sess.blocks += batchBlocks
+			layerBlocks += batchBlocks
+		}
+
+		// Checkpoint por coluna: os chunks vão para o disco antes do cursor avançar,
+		// garantindo que uma retomada nunca pule dados não salvos.
+		s.store.Save(worldName)
+		s.stateMu.Lock()
+		st.SetCursor(z, x+fullScanBatch, 0)
+		s.store.SaveScanState(st)
+		s.stateMu.Unlock()
+		s.reportProgress(ScanPhaseScanning, z, layerBlocks, sess)
+	}
+
+	// ---> OTIMIZAÇÃO DE MEMÓRIA CRÍTICA (Evitar leak de 18GB) <---
+	// Salva o andar que acabamos de receber e força a limpeza da RAM
+	savedCount, _ := s.store.Save(worldName)
+
+	// Fazer o purge apenas deste Z-Level que acabamos de carregar
+	countPurged := s.store.DeleteChunksWhere(func(c *mapdata.Chunk) bool {
+		return c.Origin.Z == z
+	})
+
+	// Só com todos os lotes gravados o nível conta como concluído; senão volta a
+	// pendente (sem cursor) para ser varrido de novo por inteiro na próxima vez
+	s.stateMu.Lock()
+	if skippedBatches > 0 {
+		st.ResetLayers(z, z)
+		sess.incomplete[z] = true
+	} else {
+		st.MarkLayerDone(z)
+	}
+	s.store.SaveScanState(st)
+	nDone := st.DoneCount()
+	s.stateMu.Unlock()
+
+	if skippedBatches > 0 {
+		log.Printf("[Scanner] Z=%d incompleto: %d lotes falharam | %d terreno, %d céu, %d salvos (%d/%d)",
+			z, skippedBatches, blocksInLayer, emptyInLayer, savedCount, nDone, info.BlockSizeZ)
+		return true
+	}
+
+	// Log compacto: 1 linha com tudo
+	log.Printf("[Scanner] Z=%d ✓ %d terreno, %d céu | %d salvos, %d liberados (%d/%d)",
+		z, blocksInLayer, emptyInLayer, savedCount, countPurged, nDone, info.BlockSizeZ)
+	return true
+}
 
+// fetchFullScanBatch busca um lote da varredura total com algumas tentativas.
+// Desiste cedo se o DFHack cair, para que o cursor seja salvo.
+func (s *ServerScanner) fetchFullScanBatch(minX, minY, z, maxX, maxY int32) (*dfproto.BlockList, error) {
+	var lastErr error
+	for attempt := 0; attempt < 3; attempt++ {
+		list, err := s.dfClient.GetBlockList(minX, minY, z, maxX, maxY, z+1, 10)
+		if err == nil {
+			return list, nil
 		}
-	}()
+		lastErr = err
+		if !s.dfClient.IsConnected() {
+			break
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	return nil, lastErr
 }
 
 func (s *ServerScanner) ScanZLevelBackground(z int32) {
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"FortressVision/servidor/internal/dfhack"
+	"FortressVision/shared/mapdata"
+	"FortressVision/shared/proto/fvnet"
+	"log"
+
+	"github.com/gorilla/websocket"
+)
+
+// session liga uma conexão websocket a um mundo servido pelo registry.
+// Só é acessada pela goroutine de leitura da conexão.
+type session struct {
+	hub      *Hub
+	conn     *websocket.Conn
+	registry *WorldRegistry
+	scanner  *ServerScanner
+
+	liveClient *dfhack.Client // DFHack do mundo ao vivo (nil no modo offline)
+
+	world    string
+	store    *mapdata.MapDataStore
+	dfClient *dfhack.Client // liveClient no mundo ao vivo, nil em mundos arquivados
+}
+
+func newSession(hub *Hub, conn *websocket.Conn, registry *WorldRegistry, dfClient *dfhack.Client, scanner *ServerScanner) *session {
+	return &session{
+		hub:        hub,
+		conn:       conn,
+		registry:   registry,
+		scanner:    scanner,
+		liveClient: dfClient,
+	}
+}
+
+// isLive indica se a sessão está ligada ao mundo ao vivo.
+func (s *session) isLive() bool {
+	return s.world == liveWorld
+}
+
+// bind liga a sessão ao mundo, obtido com registry.Resolve; a referência ao mundo
+// anterior é devolvida. Mundos arquivados são servidos sem DFHack (somente cache).
+func (s *session) bind(world string, store *mapdata.MapDataStore) {
+	if s.store != nil && s.store != store {
+		s.store.UnpinRegion(s.conn)
+		s.registry.Release(s.world)
+	}
+	s.world = world
+	s.store = store
+	s.dfClient = nil
+	if s.isLive() {
+		s.dfClient = s.liveClient
+	}
+	s.hub.Bind(s.conn, world)
+}
+
+// pinRegion fixa no cache do mundo ligado a região que o cliente acabou de pedir,
+// em todos os níveis Z do pedido (min_z..max_z), para que os chunks que ele está
+// vendo não sejam despejados.
+func (s *session) pinRegion(req *fvnet.ClientRequestRegion) {
+	s.store.PinRegion(s.conn, requestedRegion(req))
+}
+
+// close libera o que a sessão segura no mundo ligado (chamado quando a conexão cai).
+func (s *session) close() {
+	if s.store != nil {
+		s.store.UnpinRegion(s.conn)
+		s.registry.Release(s.world)
+	}
+}
+
+// sendWelcome envia status, dicionários e lista de mundos do mundo ligado à sessão.
+func (s *session) sendWelcome() {
+	state := fvnet.ServerStatus_OFFLINE_CACHE
+	if s.isLive() {
+		state = currentServerState(s.dfClient, s.scanner)
+	}
+	status := &fvnet.ServerStatus{
+		State:       state,
+		DfConnected: s.dfClient != nil && s.dfClient.IsConnected(),
+		Message:     serverStateMessage(state),
+	}
+	s.hub.SendProtoMessage(s.conn, fvnet.Envelope_SERVER_STATUS, status)
+	if s.isLive() && s.scanner.IsFullScanning() {
+		s.hub.SendProtoMessage(s.conn, fvnet.Envelope_SCAN_PROGRESS, s.scanner.Progress().ToProto())
+	}
+
+	// Enviar Dicionários de Tipos (Essencial para o Cliente renderizar blocos sólidos)
+	if s.dfClient != nil && s.dfClient.IsConnected() {
+		if s.dfClient.TiletypeList != nil {
+			s.hub.SendProtoMessage(s.conn, fvnet.Envelope_TILETYPE_LIST, s.dfClient.TiletypeList)
+		}
+		if s.dfClient.MaterialList != nil {
+			s.hub.SendProtoMessage(s.conn, fvnet.Envelope_MATERIAL_LIST, s.dfClient.MaterialList)
+		}
+	} else {
+		// MODO OFFLINE / ARQUIVADO: Buscar do SQLite e enviar empacotado cru
+		forEachCachedDictionary(s.store, func(msgType fvnet.Envelope_Type, payload rawPayload) {
+			s.hub.SendProtoMessage(s.conn, msgType, payload)
+		})
+	}
+
+	// Construções e unidades vêm do store nos dois modos (offline, do banco)
+	s.hub.SendProtoMessage(s.conn, fvnet.Envelope_BUILDING_LIST, buildingListMessage(s.store))
+	s.hub.SendProtoMessage(s.conn, fvnet.Envelope_CREATURE_UPDATE, creatureListMessage(s.store))
+
+	// Lista de mundos salvos (menu principal do cliente)
+	s.sendWorldList()
+}
+
+func (s *session) sendWorldList() {
+	list, err := worldListMessage(s.registry, s.store)
+	if err != nil {
+		log.Printf("[Worlds] Erro ao listar mundos: %v", err)
+		return
+	}
+	s.hub.SendProtoMessage(s.conn, fvnet.Envelope_WORLD_LIST, list)
+}
+
+// switchWorld religa apenas esta sessão a outro mundo, sem afetar as demais.
+func (s *session) switchWorld(name string) error {
+	world, store, err := s.registry.Resolve(name)
+	if err != nil {
+		return err
+	}
+	if store == s.store {
+		s.registry.Release(world) // A sessão já segura uma referência
+		return nil
+	}
+
+	log.Printf("[Session] %s: %s -> %s", s.conn.RemoteAddr(), s.store.WorldName, store.WorldName)
+	s.bind(world, store)
+	s.hub.SendProtoMessage(s.conn, fvnet.Envelope_WORLD_CHANGED, &fvnet.WorldChanged{WorldName: store.WorldName})
+	s.sendWelcome()
+	return nil
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"FortressVision/servidor/internal/dfhack"
+	"FortressVision/shared/mapdata"
+	"FortressVision/shared/pkg/dfproto"
+	"FortressVision/shared/proto/fvnet"
+	"FortressVision/shared/util"
+	"fmt"
+	"log"
+	"time"
+)
+
+// worldWatchInterval é o intervalo entre consultas ao MapInfo do DF.
+const worldWatchInterval = 5 * time.Second
+
+// worldKey identifica o mapa carregado no DF: o mundo e a geometria da fortaleza.
+// Dois saves do mesmo mundo com embarques diferentes têm chaves diferentes.
+type worldKey struct {
+	Name   string
+	Origin util.DFCoord
+	Size   util.DFCoord
+}
+
+func worldKeyOf(info *dfproto.MapInfo) worldKey {
+	if info == nil {
+		return worldKey{}
+	}
+	return worldKey{
+		Name:   worldNameOf(info),
+		Origin: util.NewDFCoord(info.BlockPosX, info.BlockPosY, info.BlockPosZ),
+		Size:   util.NewDFCoord(info.BlockSizeX, info.BlockSizeY, info.BlockSizeZ),
+	}
+}
+
+// worldNameOf retorna o nome do mundo no DF.
+func worldNameOf(info *dfproto.MapInfo) string {
+	if info.WorldNameEn != "" {
+		return info.WorldNameEn
+	}
+	return info.WorldName
+}
+
+// fileName retorna o nome do banco (.fv) do mapa: cada embarque do mundo tem o seu.
+func (k worldKey) fileName() string {
+	return mapdata.EmbarkWorldName(k.Name, k.Origin, k.Size)
+}
+
+// worldFileOf retorna o nome do banco (.fv) do mapa carregado no DF.
+func worldFileOf(info *dfproto.MapInfo) string {
+	return worldKeyOf(info).fileName()
+}
+
+// WorldWatcher acompanha o MapInfo do DFHack e troca o banco SQLite quando o
+// jogador abandona a fortaleza e carrega outro save, evitando misturar mapas num mesmo .fv.
+type WorldWatcher struct {
+	dfClient *dfhack.Client
+	store    *mapdata.MapDataStore
+	scanner  *ServerScanner
+	hub      *Hub
+
+	current worldKey
+}
+
+func NewWorldWatcher(df *dfhack.Client, store *mapdata.MapDataStore, scanner *ServerScanner, hub *Hub) *WorldWatcher {
+	return &WorldWatcher{
+		dfClient: df,
+		store:    store,
+		scanner:  scanner,
+		hub:      hub,
+		current:  worldKeyOf(df.MapInfo),
+	}
+}
+
+func (w *WorldWatcher) Run() {
+	for {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("[World] Recuperado de pânico: %v", r)
+				}
+			}()
+			w.check()
+		}()
+		time.Sleep(worldWatchInterval)
+	}
+}
+
+// check compara o mapa carregado no DF com o que está aberto e dispara a troca se mudou.
+func (w *WorldWatcher) check() {
+	if !w.dfClient.IsConnected() {
+		return
+	}
+	info, err := w.dfClient.QueryMapInfo()
+	if err != nil || info == nil {
+		return // Sem mapa carregado (menu principal do DF): mantém o banco atual
+	}
+
+	key := worldKeyOf(info)
+	if key.Name == "" || key == w.current {
+		return
+	}
+
+	log.Printf("[World] Mudança de mapa detectada: %q %v -> %q %v",
+		w.current.Name, w.current.Origin, key.Name, key.Origin)
+	w.switchTo(key)
+}
+
+// switchTo grava e fecha o banco do mapa anterior, abre o do novo mapa,
+// reinicia o estado do scanner e avisa os clientes para descartarem seus caches.
+func (w *WorldWatcher) switchTo(key worldKey) {
+	// A varredura total do mapa anterior precisa parar antes de o banco trocar
+	w.scanner.ResetWorld()
+
+	// Outro embarque do mesmo mundo vai para um banco próprio (ver EmbarkWorldName)
+	name := key.fileName()
+	if err := w.store.SwitchWorld(name); err != nil {
+		log.Printf("[World] ERRO ao abrir banco do mundo %s: %v", name, err)
+		return
+	}
+
+	// Dicionários e MapInfo do novo save (materiais e tiletypes mudam entre mundos)
+	if err := w.dfClient.FetchStaticData(); err != nil {
+		log.Printf("[World] Aviso: falha ao recarregar dados estáticos: %v", err)
+	}
+	persistStaticData(w.dfClient, w.store)
+	w.current = key
+
+	w.hub.BroadcastProtoMessage(fvnet.Envelope_WORLD_CHANGED, &fvnet.WorldChanged{WorldName: name})
+	if w.dfClient.TiletypeList != nil {
+		w.hub.BroadcastProtoMessage(fvnet.Envelope_TILETYPE_LIST, w.dfClient.TiletypeList)
+	}
+	if w.dfClient.MaterialList != nil {
+		w.hub.BroadcastProtoMessage(fvnet.Envelope_MATERIAL_LIST, w.dfClient.MaterialList)
+	}
+
+	go syncBuildings(w.dfClient, w.store, w.hub)
+
+	if w.scanner.NeedsFullScan() {
+		log.Println("[World] Novo mapa com varredura total pendente. Iniciando...")
+		w.scanner.StartFullScan()
+	}
+	log.Printf("[World] Banco trocado para o mundo %s.", name)
+}
+
+// persistStaticData grava dimensões e dicionários críticos no banco para o modo offline.
+func persistStaticData(dfClient *dfhack.Client, store *mapdata.MapDataStore) {
+	if dfClient == nil || !dfClient.IsConnected() {
+		return
+	}
+	if dfClient.MapInfo != nil {
+		store.SaveMapInfo(dfClient.MapInfo)
+	}
+	if dfClient.TiletypeList != nil {
+		data, _ := dfClient.TiletypeList.Marshal()
+		store.SaveDictionary("TiletypeList", data)
+	}
+	if dfClient.MaterialList != nil {
+		data, _ := dfClient.MaterialList.Marshal()
+		store.SaveDictionary("MaterialList", data)
+	}
+	if len(dfClient.CreatureRaws) > 0 {
+		store.SaveDictionary("CreatureRawList", dfClient.CreatureRaws)
+	}
+	if len(dfClient.PlantRaws) > 0 {
+		store.SaveDictionary("PlantRawList", dfClient.PlantRaws)
+	}
+	if len(dfClient.BuildingDefs) > 0 {
+		store.SetBuildingDefs(dfClient.BuildingDefs)
+	}
+}
+
+// syncBuildings troca as construções do store pelas do mapa atual e, se algo mudou,
+// avisa os clientes. O store grava a lista no banco para o modo offline.
+func syncBuildings(dfClient *dfhack.Client, store *mapdata.MapDataStore, hub *Hub) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[Buildings] Recuperado de pânico: %v", r)
+		}
+	}()
+	bList, err := dfClient.GetBuildingList()
+	if err != nil || bList == nil {
+		return // Mantém a última lista conhecida
+	}
+
+	defs := make(map[dfproto.BuildingType]*dfproto.BuildingDefinition, len(dfClient.BuildingDefs))
+	for i := range dfClient.BuildingDefs {
+		defs[dfClient.BuildingDefs[i].BuildingType] = &dfClient.BuildingDefs[i]
+	}
+	list := make([]*mapdata.BuildingInstance, 0, len(bList.BuildingList))
+	for _, b := range bList.BuildingList {
+		list = append(list, &mapdata.BuildingInstance{
+			Index:        b.Index,
+			BuildingType: defs[b.BuildingType],
+			Material:     b.Material,
+			MinPos:       util.DFCoord{X: b.PosXMin, Y: b.PosYMin, Z: b.PosZMin},
+			MaxPos:       util.DFCoord{X: b.PosXMax, Y: b.PosYMax, Z: b.PosZMax},
+			Center:       util.DFCoord{X: (b.PosXMin + b.PosXMax) / 2, Y: (b.PosYMin + b.PosYMax) / 2, Z: b.PosZMin},
+			Direction:    b.Direction,
+			Items:        b.Items,
+		})
+	}
+	if store.ReplaceBuildings(list) {
+		log.Printf("[Buildings] %d construções indexadas", len(list))
+		hub.BroadcastProtoMessage(fvnet.Envelope_BUILDING_LIST, buildingListMessage(store))
+	}
+}
+
+// rawPayload é um payload já serializado (ex.: dicionário lido do cache SQLite).
+type rawPayload []byte
+
+func (p rawPayload) Marshal() ([]byte, error) { return p, nil }
+
+// forEachCachedDictionary entrega os dicionários salvos no banco (modo offline).
+func forEachCachedDictionary(store *mapdata.MapDataStore, send func(msgType fvnet.Envelope_Type, payload rawPayload)) {
+	tileData, errT := store.GetDictionary("TiletypeList")
+	matData, errM := store.GetDictionary("MaterialList")
+
+	if errT == nil && len(tileData) > 0 {
+		log.Println("[Offline] Servindo dicionário de Tiletypes a partir do Cache.")
+		send(fvnet.Envelope_TILETYPE_LIST, tileData)
+	} else {
+		log.Println("[Offline] AVISO: TiletypeList não encontrado no banco de dados!")
+	}
+
+	if errM == nil && len(matData) > 0 {
+		log.Println("[Offline] Servindo dicionário de Materials a partir do Cache.")
+		send(fvnet.Envelope_MATERIAL_LIST, matData)
+	} else {
+		log.Println("[Offline] AVISO: MaterialList não encontrado no banco de dados!")
+	}
+}
+
+// worldListMessage monta a lista de mundos salvos, marcando o servido à sessão (store) e o ao vivo.
+func worldListMessage(registry *WorldRegistry, store *mapdata.MapDataStore) (*fvnet.WorldList, error) {
+	worlds, err := mapdata.ListWorlds()
+	if err != nil {
+		return nil, err
+	}
+
+	// Cada sessão pode trocar de mundo sem afetar as demais (ver WorldRegistry)
+	list := &fvnet.WorldList{Selectable: true}
+	for _, w := range worlds {
+		if w.Name == store.WorldName {
+			w.Generation = store.Generation()
+		}
+		list.Worlds = append(list.Worlds, &fvnet.WorldInfo{
+			Name:          w.Name,
+			SizeX:         w.SizeX,
+			SizeY:         w.SizeY,
+			SizeZ:         w.SizeZ,
+			ChunkCount:    w.ChunkCount,
+			UpdatedAt:     w.UpdatedAt.Unix(),
+			FormatVersion: int32(w.FormatVersion),
+			Active:        w.Name == store.WorldName,
+			Live:          w.Name == registry.Live().CurrentWorld(),
+			Generation:    w.Generation,
+		})
+	}
+	return list, nil
+}
+
+// selectOfflineWorld troca o banco do mundo ao vivo no modo offline e avisa as sessões ligadas a ele.
+// Com o DFHack conectado o mundo ao vivo é definido pelo save aberto no DF (ver WorldWatcher).
+func selectOfflineWorld(hub *Hub, dfClient *dfhack.Client, registry *WorldRegistry, name string) error {
+	store := registry.Live()
+	if dfClient != nil {
+		return fmt.Errorf("DFHack conectado: o mundo é definido pelo save aberto no DF")
+	}
+	if name == store.WorldName {
+		return nil
+	}
+
+	// Só aceita nomes que existem em saves/ (evita caminhos arbitrários)
+	if !mapdata.WorldExists(name) {
+		return fmt.Errorf("mundo %q não encontrado em %s", name, mapdata.SavesDir)
+	}
+
+	log.Printf("[Offline] Trocando mundo servido: %s -> %s", store.WorldName, name)
+	if err := store.SwitchWorld(name); err != nil {
+		return err
+	}
+
+	hub.BroadcastProtoMessage(fvnet.Envelope_WORLD_CHANGED, &fvnet.WorldChanged{WorldName: name})
+	forEachCachedDictionary(store, func(msgType fvnet.Envelope_Type, payload rawPayload) {
+		hub.BroadcastProtoMessage(msgType, payload)
+	})
+	if list, err := worldListMessage(registry, store); err == nil {
+		hub.BroadcastProtoMessage(fvnet.Envelope_WORLD_LIST, list)
+	}
+	return nil
+}
@@ -21,6 +21,7 @@ type Config struct {
 
 	// FortressVision Server (Usado pelo Cliente)
 	ServerURL string `json:"server_url"`
+	World     string `json:"-"` // Mundo arquivado a abrir (flag -world); vazio = mundo ao vivo
 
 	// Renderização
 	DrawDistance  int32   `json:"draw_distance"`
@@ -31,6 +32,10 @@ type Config struct {
 	DrawRangeUp   int32   `json:"draw_range_up"`   // Níveis Z acima da câmera
 	DrawRangeSide int32   `json:"draw_range_side"` // Raio horizontal em blocos de 16 tiles
 
+	// Memória
+	ChunkCacheMB int64 `json:"chunk_cache_mb"` // Orçamento de RAM dos chunks (0 = sem limite)
+	DiskCache    bool  `json:"disk_cache"`     // Guarda os chunks recebidos em disco (saves/cache) entre sessões
+
 	// Câmera
 	CameraSpeed       float32 `json:"camera_speed"`
 	CameraSensitivity float32 `json:"camera_sensitivity"`
@@ -64,6 +69,9 @@ func DefaultConfig() *Config {
 		DrawRangeUp:   1,
 		DrawRangeSide: 4,
 
+		ChunkCacheMB: 1024,
+		DiskCache:    true,
+
 		CameraSpeed:       10.0,
 		CameraSensitivity: 0.3,
 		ZoomSpeed:         5.0,
@@ -0,0 +1,363 @@
+package mapdata
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"FortressVision/shared/util"
+)
+
+// ArchiveExt é a extensão dos arquivos portáteis de mundo.
+const ArchiveExt = ".fvz"
+
+// archiveVersion é a versão do layout do .fvz. A versão dos blobs de chunk
+// dentro dele é a CurrentFormatVersion de quem exportou (ver ArchiveManifest).
+const archiveVersion = 1
+
+// Entradas do .fvz (um zip):
+//
+//	manifest.json        ArchiveManifest
+//	dictionaries/<chave> um arquivo por dicionário (protobuf ou GOB, como no banco)
+//	chunks.fvlog         logMagic seguido de registros 'C' (ver logRepository)
+const (
+	archiveManifestEntry = "manifest.json"
+	archiveDictPrefix    = "dictionaries/"
+	archiveChunksEntry   = "chunks.fvlog"
+)
+
+// archiveBatchSize é quantos chunks a importação grava por transação.
+const archiveBatchSize = 512
+
+// Limites da importação, que recebe .fvz da rede: o manifesto e cada dicionário
+// (descompactados) e o blob de cada chunk. Blobs reais têm poucos KB; o log de
+// mundo aceita registros bem maiores (maxLogRecord), mas o .fvz não.
+const (
+	maxArchiveEntry = 64 << 20
+	maxArchiveChunk = 1 << 20
+)
+
+// WorldDictionaries lista os dicionários que o modo offline usa e que viajam no .fvz.
+var WorldDictionaries = []string{"TiletypeList", "MaterialList", "CreatureRawList", "PlantRawList",
+	buildingDefsKey, buildingsKey, unitsKey}
+
+// ErrWorldExists indica uma importação para um nome de mundo já usado em SavesDir.
+var ErrWorldExists = errors.New("já existe um mundo com esse nome")
+
+// ArchiveMapSize são as dimensões do mapa em blocos (MapInfo.BlockSize*).
+type ArchiveMapSize struct {
+	X int32 `json:"x"`
+	Y int32 `json:"y"`
+	Z int32 `json:"z"`
+}
+
+// ArchiveManifest descreve o conteúdo de um .fvz.
+type ArchiveManifest struct {
+	ArchiveVersion int            `json:"archive_version"`
+	WorldName      string         `json:"world_name"`
+	FormatVersion  int            `json:"format_version"`
+	MapSize        ArchiveMapSize `json:"map_size"`
+	ChunkCount     int64          `json:"chunk_count"`
+	Dictionaries   []string       `json:"dictionaries"`
+	ExportedAt     time.Time      `json:"exported_at"`
+}
+
+// ExportWorld grava o mundo salvo worldName (.fv ou .fvlog) como um .fvz em w.
+// O banco é lido apenas para leitura, então o mundo pode estar em uso pelo servidor;
+// chunks ainda não gravados pelo store ao vivo ficam de fora.
+func ExportWorld(worldName string, w io.Writer) (*ArchiveManifest, error) {
+	if !WorldExists(worldName) {
+		return nil, fmt.Errorf("mundo %q não encontrado em %s", worldName, SavesDir)
+	}
+	repo, err := openReadOnlyRepository(worldName)
+	if err != nil {
+		return nil, err
+	}
+	defer repo.Close()
+
+	value := func(key string) string {
+		v, _ := repo.GetMetadata(key)
+		return v
+	}
+	manifest := &ArchiveManifest{
+		ArchiveVersion: archiveVersion,
+		WorldName:      worldName,
+		MapSize:        ArchiveMapSize{parseInt32(value("MapSizeX")), parseInt32(value("MapSizeY")), parseInt32(value("MapSizeZ"))},
+		Dictionaries:   []string{},
+		ExportedAt:     time.Now().UTC(),
+	}
+	if manifest.FormatVersion, err = strconv.Atoi(value(formatVersionKey)); err != nil || manifest.FormatVersion != CurrentFormatVersion {
+		return nil, fmt.Errorf("mundo %q está no formato %q, esperado %d", worldName, value(formatVersionKey), CurrentFormatVersion)
+	}
+
+	zw := zip.NewWriter(w)
+	for _, key := range WorldDictionaries {
+		data, err := repo.GetDictionary(key)
+		if err != nil || len(data) == 0 {
+			continue // Dicionário nunca recebido do DF
+		}
+		if err := writeArchiveEntry(zw, archiveDictPrefix+key, data, manifest.ExportedAt); err != nil {
+			return nil, err
+		}
+		manifest.Dictionaries = append(manifest.Dictionaries, key)
+	}
+
+	chunks, err := createArchiveEntry(zw, archiveChunksEntry, manifest.ExportedAt)
+	if err != nil {
+		return nil, err
+	}
+	bw := bufio.NewWriter(chunks)
+	bw.Write(logMagic)
+	var record bytes.Buffer
+	_, err = forEachStoredModel(repo, func(origin util.DFCoord, model *ChunkModel) error {
+		if model == nil {
+			return fmt.Errorf("chunk %v listado mas não encontrado", origin)
+		}
+		record.Reset()
+		encodeChunkRecord(&record, model)
+		manifest.ChunkCount++
+		_, err := bw.Write(record.Bytes())
+		return err
+	})
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("exportando chunks de %s: %w", worldName, err)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeArchiveEntry(zw, archiveManifestEntry, data, manifest.ExportedAt); err != nil {
+		return nil, err
+	}
+	return manifest, zw.Close()
+}
+
+func createArchiveEntry(zw *zip.Writer, name string, modified time.Time) (io.Writer, error) {
+	return zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+}
+
+func writeArchiveEntry(zw *zip.Writer, name string, data []byte, modified time.Time) error {
+	f, err := createArchiveEntry(zw, name, modified)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+// ReadArchiveManifest lê o manifesto de um .fvz sem importar nada.
+func ReadArchiveManifest(r io.ReaderAt, size int64) (*ArchiveManifest, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("arquivo .fvz inválido: %w", err)
+	}
+	return readArchiveManifest(zr)
+}
+
+func readArchiveManifest(zr *zip.Reader) (*ArchiveManifest, error) {
+	data, err := readArchiveEntry(zr, archiveManifestEntry)
+	if err != nil {
+		return nil, err
+	}
+	var manifest ArchiveManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("%s inválido: %w", archiveManifestEntry, err)
+	}
+	if manifest.ArchiveVersion != archiveVersion {
+		return nil, fmt.Errorf("versão %d de .fvz não suportada (esperada %d)", manifest.ArchiveVersion, archiveVersion)
+	}
+	return &manifest, nil
+}
+
+// readArchiveEntry lê a entrada name inteira, recusando as que passam de
+// maxArchiveEntry descompactadas (pelo cabeçalho ou de fato).
+func readArchiveEntry(zr *zip.Reader, name string) ([]byte, error) {
+	for _, f := range zr.File {
+		if f.Name == name {
+			return readArchiveFile(f)
+		}
+	}
+	return nil, fmt.Errorf("entrada %s: %w", name, os.ErrNotExist)
+}
+
+func readArchiveFile(f *zip.File) ([]byte, error) {
+	if f.UncompressedSize64 > maxArchiveEntry {
+		return nil, fmt.Errorf("entrada %s com %d bytes (máximo %d)", f.Name, f.UncompressedSize64, maxArchiveEntry)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("entrada %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, maxArchiveEntry+1))
+	if err != nil {
+		return nil, fmt.Errorf("entrada %s: %w", f.Name, err)
+	}
+	if len(data) > maxArchiveEntry {
+		return nil, fmt.Errorf("entrada %s passa de %d bytes", f.Name, maxArchiveEntry)
+	}
+	return data, nil
+}
+
+// ImportWorld cria o mundo worldName em SavesDir (banco SQLite) a partir de um .fvz.
+// Com worldName vazio, usa o nome gravado no manifesto; o manifesto retornado traz
+// o nome com que o mundo foi criado. Nunca sobrescreve um mundo existente
+// (ErrWorldExists); se a importação falhar, o banco parcial é apagado.
+func ImportWorld(r io.ReaderAt, size int64, worldName string) (*ArchiveManifest, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("arquivo .fvz inválido: %w", err)
+	}
+	manifest, err := readArchiveManifest(zr)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.FormatVersion > CurrentFormatVersion {
+		return nil, fmt.Errorf("%w: o .fvz está na versão %d, este binário suporta até a %d",
+			ErrFormatTooNew, manifest.FormatVersion, CurrentFormatVersion)
+	}
+	if manifest.FormatVersion != CurrentFormatVersion {
+		return nil, fmt.Errorf("o .fvz está no formato %d; exporte-o de novo com esta versão (%d)",
+			manifest.FormatVersion, CurrentFormatVersion)
+	}
+
+	if worldName == "" {
+		worldName = manifest.WorldName
+	}
+	if worldName == "" || filepath.Base(worldName) != worldName || strings.HasPrefix(worldName, ".") {
+		return nil, fmt.Errorf("nome de mundo inválido: %q", worldName)
+	}
+	if WorldExists(worldName) {
+		return nil, fmt.Errorf("%w: %s", ErrWorldExists, worldName)
+	}
+
+	repo, err := openSQLiteRepository(worldName)
+	if err != nil {
+		return nil, err
+	}
+	err = importArchive(zr, manifest, repo, worldName)
+	if closeErr := repo.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		path := WorldPath(worldName)
+		for _, p := range []string{path, path + "-wal", path + "-shm"} {
+			os.Remove(p)
+		}
+		return nil, fmt.Errorf("importando %s: %w", worldName, err)
+	}
+	manifest.WorldName = worldName
+	return manifest, nil
+}
+
+// importArchive copia dicionários, metadados e chunks do .fvz para o repositório.
+func importArchive(zr *zip.Reader, manifest *ArchiveManifest, repo ChunkRepository, worldName string) error {
+	for _, f := range zr.File {
+		key, ok := strings.CutPrefix(f.Name, archiveDictPrefix)
+		if !ok || key == "" {
+			continue
+		}
+		data, err := readArchiveFile(f)
+		if err != nil {
+			return err
+		}
+		if err := repo.SaveDictionary(key, data); err != nil {
+			return err
+		}
+	}
+
+	meta := map[string]string{"WorldName": worldName, generationKey: newGeneration()}
+	if size := manifest.MapSize; size.X > 0 && size.Y > 0 && size.Z > 0 {
+		meta["MapSizeX"], meta["MapSizeY"], meta["MapSizeZ"] = fmt.Sprint(size.X), fmt.Sprint(size.Y), fmt.Sprint(size.Z)
+	}
+	for key, value := range meta {
+		if err := repo.SaveMetadata(key, value); err != nil {
+			return err
+		}
+	}
+
+	f, err := zr.Open(archiveChunksEntry)
+	if err != nil {
+		return fmt.Errorf("entrada %s: %w", archiveChunksEntry, err)
+	}
+	defer f.Close()
+	// O manifesto limita o tamanho descompactado: cada chunk ocupa no máximo o
+	// blob e um cabeçalho de poucos varints. Lê um byte além do limite para
+	// distinguir uma entrada maior de uma que termina nele.
+	chunks := min(max(manifest.ChunkCount, 0), math.MaxInt64/(2*(maxArchiveChunk+64)))
+	limited := &io.LimitedReader{R: f, N: int64(len(logMagic)) + chunks*(maxArchiveChunk+64) + 1}
+	br := bufio.NewReader(limited)
+	magic := make([]byte, len(logMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || !bytes.Equal(magic, logMagic) {
+		return fmt.Errorf("%s sem cabeçalho FVLOG", archiveChunksEntry)
+	}
+
+	var count int64
+	batch := make([]*ChunkModel, 0, archiveBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		_, err := repo.SaveChunks(batch)
+		batch = batch[:0]
+		return err
+	}
+	for {
+		kind, err := br.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if kind != logRecordChunk {
+			return fmt.Errorf("registro %q inesperado em %s", kind, archiveChunksEntry)
+		}
+		if count == manifest.ChunkCount {
+			return fmt.Errorf("%s tem mais chunks que os %d do manifesto", archiveChunksEntry, manifest.ChunkCount)
+		}
+		model, size, err := readChunkRecordHeader(br, maxArchiveChunk)
+		if err != nil {
+			return err
+		}
+		model.Data = make([]byte, size)
+		if _, err := io.ReadFull(br, model.Data); err != nil {
+			return unexpected(err)
+		}
+		model.Key = chunkKey(util.NewDFCoord(model.X, model.Y, model.Z))
+		batch = append(batch, model)
+		count++
+		if len(batch) == archiveBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	if limited.N == 0 {
+		return fmt.Errorf("%s maior que o permitido para %d chunks", archiveChunksEntry, manifest.ChunkCount)
+	}
+	if count != manifest.ChunkCount {
+		return fmt.Errorf("%s tem %d chunks, o manifesto diz %d", archiveChunksEntry, count, manifest.ChunkCount)
+	}
+	if err := repo.SaveMetadata(chunkCountKey, fmt.Sprint(count)); err != nil {
+		return err
+	}
+	return repo.SaveMetadata(updatedAtKey, time.Now().UTC().Format(time.RFC3339Nano))
+}
@@ -0,0 +1,164 @@
+package mapdata
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"FortressVision/shared/pkg/dfproto"
+	"FortressVision/shared/util"
+)
+
+func TestArchiveRoundTrip(t *testing.T) {
+	chdirTemp(t)
+	s := NewMapDataStore()
+	if err := s.OpenInitialize("Fortaleza"); err != nil {
+		t.Fatal(err)
+	}
+	origins := []util.DFCoord{util.NewDFCoord(0, 0, 3), util.NewDFCoord(16, 32, 3), util.NewDFCoord(48, 0, 9)}
+	for i, origin := range origins {
+		s.StoreSingleBlock(scanBlock(origin, int32(i+1)))
+	}
+	s.MarkAsEmpty(util.NewDFCoord(0, 0, 20))
+	if _, err := s.Save("Fortaleza"); err != nil {
+		t.Fatal(err)
+	}
+	s.SaveMapInfo(&dfproto.MapInfo{BlockSizeX: 12, BlockSizeY: 10, BlockSizeZ: 40})
+	s.SaveDictionary("TiletypeList", []byte{1, 2, 3})
+	s.SaveDictionary("PlantRawList", []byte{4, 5})
+	s.Close()
+
+	var buf bytes.Buffer
+	exported, err := ExportWorld("Fortaleza", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exported.ChunkCount != 4 || len(exported.Dictionaries) != 2 || exported.MapSize != (ArchiveMapSize{12, 10, 40}) {
+		t.Fatalf("manifesto exportado = %+v", exported)
+	}
+
+	archive := bytes.NewReader(buf.Bytes())
+	if _, err := ImportWorld(archive, archive.Size(), ""); !errors.Is(err, ErrWorldExists) {
+		t.Fatalf("importar por cima do original: err = %v, want ErrWorldExists", err)
+	}
+	imported, err := ImportWorld(archive, archive.Size(), "Copia")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported.WorldName != "Copia" || imported.ChunkCount != 4 {
+		t.Fatalf("manifesto importado = %+v", imported)
+	}
+
+	c := NewMapDataStore()
+	if err := c.OpenReadOnly("Copia"); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if x, y, z, err := c.GetMapInfo(); err != nil || x != 12 || y != 10 || z != 40 {
+		t.Fatalf("GetMapInfo = %d, %d, %d, %v", x, y, z, err)
+	}
+	if data, err := c.GetDictionary("PlantRawList"); err != nil || !bytes.Equal(data, []byte{4, 5}) {
+		t.Fatalf("PlantRawList = %v, %v", data, err)
+	}
+	for i, origin := range origins {
+		chunk, err := c.LoadChunk(origin)
+		if err != nil {
+			t.Fatalf("chunk %v: %v", origin, err)
+		}
+		if tile := chunk.Tile(5, 5); tile == nil || tile.TileType != int32(i+1) {
+			t.Fatalf("chunk %v: tile = %+v", origin, tile)
+		}
+	}
+	if chunk, err := c.LoadChunk(util.NewDFCoord(0, 0, 20)); err != nil || !chunk.IsEmpty {
+		t.Fatalf("chunk vazio = %+v, %v", chunk, err)
+	}
+}
+
+func TestImportRejectsDamagedArchive(t *testing.T) {
+	chdirTemp(t)
+	s := NewMapDataStore()
+	if err := s.OpenInitialize("Origem"); err != nil {
+		t.Fatal(err)
+	}
+	s.StoreSingleBlock(scanBlock(util.NewDFCoord(0, 0, 0), 1))
+	s.Save("Origem")
+	s.Close()
+
+	var buf bytes.Buffer
+	if _, err := ExportWorld("Origem", &buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	data = data[:len(data)/2] // Sem o diretório central do zip
+	if _, err := ImportWorld(bytes.NewReader(data), int64(len(data)), "Quebrado"); err == nil {
+		t.Fatal("ImportWorld aceitou um .fvz truncado")
+	}
+	if WorldExists("Quebrado") {
+		t.Fatal("importação com falha deixou o mundo em saves/")
+	}
+}
+
+// fvzWith monta um .fvz com o manifesto e as entradas dadas (nome -> conteúdo).
+func fvzWith(t *testing.T, chunkCount int64, entries map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	manifest, _ := json.Marshal(ArchiveManifest{ArchiveVersion: archiveVersion, WorldName: "Bomba",
+		FormatVersion: CurrentFormatVersion, ChunkCount: chunkCount})
+	entries[archiveManifestEntry] = manifest
+	for name, data := range entries {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// TestImportRejectsOversizedEntries cobre .fvz pequenos que pediriam gigabytes de
+// RAM: dicionário descompactado enorme, chunk com tamanho absurdo no cabeçalho e
+// mais chunks que o manifesto.
+func TestImportRejectsOversizedEntries(t *testing.T) {
+	chdirTemp(t)
+	record := func(size uint64) []byte {
+		var b bytes.Buffer
+		b.Write(logMagic)
+		encodeChunkRecord(&b, &ChunkModel{Data: make([]byte, 4)})
+		if size > 4 {
+			// Troca o tamanho dos dados no cabeçalho (último varint antes dos 4 bytes)
+			data := b.Bytes()[:b.Len()-5]
+			b = *bytes.NewBuffer(append([]byte(nil), data...))
+			b.Write(binary.AppendUvarint(nil, size))
+		}
+		return b.Bytes()
+	}
+	cases := map[string][]byte{
+		"dicionário": fvzWith(t, 1, map[string][]byte{
+			archiveDictPrefix + "MaterialList": make([]byte, maxArchiveEntry+1),
+			archiveChunksEntry:                 record(4),
+		}),
+		"chunk": fvzWith(t, 1, map[string][]byte{archiveChunksEntry: record(512 << 20)}),
+		"contagem": fvzWith(t, 0, map[string][]byte{archiveChunksEntry: record(4)}),
+	}
+	for name, data := range cases {
+		if _, err := ImportWorld(bytes.NewReader(data), int64(len(data)), "Bomba"); err == nil {
+			t.Errorf("%s: ImportWorld aceitou o .fvz (%d bytes)", name, len(data))
+		}
+		if WorldExists("Bomba") {
+			t.Fatalf("%s: importação com falha deixou o mundo em saves/", name)
+		}
+	}
+
+	// O mesmo registro dentro dos limites é importado
+	data := fvzWith(t, 1, map[string][]byte{archiveChunksEntry: record(4)})
+	if _, err := ImportWorld(bytes.NewReader(data), int64(len(data)), "Bomba"); err != nil {
+		t.Fatalf("ImportWorld válido: %v", err)
+	}
+}
@@ -0,0 +1,231 @@
+package mapdata
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BackupsDir é a pasta das cópias de segurança dos bancos (.fv).
+var BackupsDir = filepath.Join(SavesDir, "backups")
+
+// backupTimeLayout é o sufixo de data no nome das cópias: <mundo>_<data>.fv
+const backupTimeLayout = "20060102_150405"
+
+// ErrBackupUnsupported indica um repositório que não sabe fazer cópias online.
+var ErrBackupUnsupported = errors.New("repositório sem suporte a backup")
+
+// BackupPolicy configura as cópias de segurança periódicas do banco do mundo.
+//
+// Ficam as Keep cópias mais recentes e, nos últimos KeepDays dias, a última
+// cópia de cada dia; as demais são apagadas a cada nova cópia.
+type BackupPolicy struct {
+	Interval time.Duration // 0 = sem cópias periódicas
+	Keep     int
+	KeepDays int
+}
+
+// BackupInfo descreve uma cópia de segurança em BackupsDir.
+type BackupInfo struct {
+	Path string
+	Time time.Time
+}
+
+// BackupRepository é implementado pelos repositórios que copiam o banco
+// sem parar as escritas (hoje só o SQLite, via VACUUM INTO).
+type BackupRepository interface {
+	Backup(dest string) error
+}
+
+// BackupPath retorna o caminho da cópia de um mundo feita em t.
+func BackupPath(worldName string, t time.Time) string {
+	return filepath.Join(BackupsDir, fmt.Sprintf("%s_%s.fv", worldName, t.Format(backupTimeLayout)))
+}
+
+// ListBackups lista as cópias de um mundo, da mais recente para a mais antiga.
+func ListBackups(worldName string) ([]BackupInfo, error) {
+	files, err := os.ReadDir(BackupsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var backups []BackupInfo
+	prefix := worldName + "_"
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasPrefix(name, prefix) || filepath.Ext(name) != ".fv" {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".fv")
+		t, err := time.ParseInLocation(backupTimeLayout, stamp, time.Local)
+		if err != nil {
+			continue // Outro mundo com o mesmo prefixo (ex.: "A" e "A_B")
+		}
+		backups = append(backups, BackupInfo{Path: filepath.Join(BackupsDir, name), Time: t})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Time.After(backups[j].Time)
+	})
+	return backups, nil
+}
+
+// expiredBackups escolhe, entre as cópias (da mais recente para a mais antiga),
+// as que a política não mantém mais.
+func expiredBackups(backups []BackupInfo, policy BackupPolicy, now time.Time) []BackupInfo {
+	var expired []BackupInfo
+	days := make(map[string]bool)
+	y, m, d := now.Date()
+	oldestDay := time.Date(y, m, d-policy.KeepDays+1, 0, 0, 0, 0, now.Location())
+	for i, b := range backups {
+		day := b.Time.Format("20060102")
+		keep := i < policy.Keep
+		if !days[day] && policy.KeepDays > 0 && !b.Time.Before(oldestDay) {
+			keep = true // A última cópia do dia
+		}
+		days[day] = true
+		if !keep {
+			expired = append(expired, b)
+		}
+	}
+	return expired
+}
+
+// BackupWorld copia o banco do mundo aberto para BackupsDir e apaga as cópias que
+// s.Backup não mantém mais. Se o banco não mudou desde a última cópia, nada é
+// feito e o caminho retornado é vazio.
+func (s *MapDataStore) BackupWorld() (string, error) {
+	s.Mu.RLock()
+	repo, worldName := s.Repo, s.WorldName
+	s.Mu.RUnlock()
+	backupRepo, ok := repo.(BackupRepository)
+	if repo == nil || !ok {
+		return "", ErrBackupUnsupported
+	}
+
+	backups, err := ListBackups(worldName)
+	if err != nil {
+		return "", err
+	}
+	if len(backups) > 0 && !worldModifiedSince(WorldPath(worldName), backups[0].Path) {
+		return "", nil
+	}
+
+	if err := os.MkdirAll(BackupsDir, 0755); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	dest := BackupPath(worldName, now)
+	// VACUUM INTO recusa destino existente; o arquivo final só aparece completo
+	tmp := dest + ".tmp"
+	os.Remove(tmp)
+	if err := backupRepo.Backup(tmp); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("backup de %s: %w", worldName, err)
+	}
+	// A data da cópia é o início do VACUUM: escritas durante a cópia contam como mudanças
+	os.Chtimes(tmp, now, now)
+	if err := os.Rename(tmp, dest); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+
+	for _, b := range expiredBackups(append([]BackupInfo{{Path: dest, Time: now}}, backups...), s.Backup, now) {
+		if err := os.Remove(b.Path); err != nil {
+			log.Printf("[Backup] Aviso: não foi possível apagar %s: %v", b.Path, err)
+		}
+	}
+	return dest, nil
+}
+
+// worldModifiedSince indica se o banco (ou seu -wal) foi alterado depois da cópia
+// em backupPath (cuja data de modificação é o início da cópia, ver BackupWorld).
+func worldModifiedSince(dbPath, backupPath string) bool {
+	backup, err := os.Stat(backupPath)
+	if err != nil {
+		return true
+	}
+	for _, path := range []string{dbPath, dbPath + "-wal"} {
+		if stat, err := os.Stat(path); err == nil && stat.ModTime().After(backup.ModTime()) {
+			return true
+		}
+	}
+	return false
+}
+
+// restoreFromBackup troca um banco corrompido pela cópia íntegra mais recente do mundo.
+// O banco corrompido é mantido ao lado com o sufixo .corrupt_<data>.
+func restoreFromBackup(dbPath, worldName string) bool {
+	backups, err := ListBackups(worldName)
+	if err != nil {
+		log.Printf("[Backup] Não foi possível listar as cópias de %s: %v", worldName, err)
+		return false
+	}
+	for _, b := range backups {
+		if err := checkDatabaseFile(b.Path); err != nil {
+			log.Printf("[Backup] Cópia %s também está corrompida: %v", b.Path, err)
+			continue
+		}
+		if err := moveCorruptDatabase(dbPath); err != nil {
+			log.Printf("[Backup] %v", err)
+			return false
+		}
+		if err := copyFile(b.Path, dbPath); err != nil {
+			log.Printf("[Backup] ERRO ao restaurar %s: %v", b.Path, err)
+			return false
+		}
+		log.Printf("[Backup] Banco %s restaurado da cópia de %s", dbPath, b.Time.Format("02/01/2006 15:04:05"))
+		return true
+	}
+	return false
+}
+
+// checkDatabaseFile roda PRAGMA integrity_check num banco, sem alterá-lo.
+func checkDatabaseFile(path string) error {
+	db, err := openReadOnlyDB(path)
+	if err != nil {
+		return err
+	}
+	defer closeDB(db)
+	var integrity string
+	if err := db.Raw("PRAGMA integrity_check").Scan(&integrity).Error; err != nil {
+		return err
+	}
+	if integrity != "ok" {
+		return errors.New(integrity)
+	}
+	return nil
+}
+
+// copyFile copia src para dst passando por um arquivo temporário.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := dst + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
@@ -0,0 +1,119 @@
+package mapdata
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"FortressVision/shared/util"
+)
+
+func TestExpiredBackups(t *testing.T) {
+	now := time.Date(2026, 5, 10, 12, 0, 0, 0, time.Local)
+	var backups []BackupInfo
+	// Uma cópia a cada 6h nos últimos 5 dias, da mais recente para a mais antiga
+	for i := 0; i < 20; i++ {
+		backups = append(backups, BackupInfo{Path: string(rune('a' + i)), Time: now.Add(-time.Duration(i) * 6 * time.Hour)})
+	}
+
+	expired := expiredBackups(backups, BackupPolicy{Keep: 2, KeepDays: 2}, now)
+	kept := make(map[string]bool)
+	for _, b := range backups {
+		kept[b.Path] = true
+	}
+	for _, b := range expired {
+		delete(kept, b.Path)
+	}
+	// a e b: as 2 mais recentes; d: a última do dia 9 (a do dia 10 é a)
+	if len(kept) != 3 || !kept["a"] || !kept["b"] || !kept["d"] {
+		t.Fatalf("cópias mantidas = %v", kept)
+	}
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	chdirTemp(t)
+	s := NewMapDataStore()
+	if err := s.OpenInitialize("Copia"); err != nil {
+		t.Fatal(err)
+	}
+	s.Backup = BackupPolicy{Keep: 2}
+	origin := util.NewDFCoord(16, 16, 2)
+	s.StoreSingleBlock(scanBlock(origin, 5))
+	if _, err := s.Save("Copia"); err != nil {
+		t.Fatal(err)
+	}
+
+	generation := s.Generation()
+	if generation == "" {
+		t.Fatal("banco novo sem geração")
+	}
+
+	dest, err := s.BackupWorld()
+	if err != nil || dest == "" {
+		t.Fatalf("BackupWorld = %q, %v", dest, err)
+	}
+	// Sem mudanças no banco, a próxima cópia é pulada
+	if again, err := s.BackupWorld(); err != nil || again != "" {
+		t.Fatalf("BackupWorld sem mudanças = %q, %v", again, err)
+	}
+	if backups, _ := ListBackups("Copia"); len(backups) != 1 || backups[0].Path != dest {
+		t.Fatalf("ListBackups = %+v", backups)
+	}
+	s.Repo.Close()
+
+	// Corrompe o banco: a abertura restaura a cópia em vez de começar vazio
+	if err := os.WriteFile(WorldPath("Copia"), []byte("isto não é um banco SQLite"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	repo, err := openSQLiteRepository("Copia")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	if _, err := repo.LoadChunk(origin); err != nil {
+		t.Fatalf("chunk perdido após restaurar a cópia: %v", err)
+	}
+	// Os MTimes da cópia são velhos: clientes precisam ver outra geração
+	if restored, _ := repo.GetMetadata(generationKey); restored == "" || restored == generation {
+		t.Fatalf("geração após restaurar = %q, antes %q", restored, generation)
+	}
+}
+
+func TestGenerationSurvivesReopenAndChangesOnReset(t *testing.T) {
+	chdirTemp(t)
+	s := NewMapDataStore()
+	if err := s.OpenInitialize("Geracao"); err != nil {
+		t.Fatal(err)
+	}
+	generation := s.Generation()
+	if err := s.SwitchWorld("Geracao"); err != nil {
+		t.Fatal(err)
+	}
+	if again := s.Generation(); again != generation {
+		t.Fatalf("geração mudou ao reabrir: %q → %q", generation, again)
+	}
+	s.Repo.Close()
+
+	// Sem cópia de segurança, o banco corrompido é recriado com outra geração
+	if err := os.WriteFile(WorldPath("Geracao"), []byte("isto não é um banco SQLite"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.OpenInitialize("Geracao"); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Repo.Close()
+	if reset := s.Generation(); reset == "" || reset == generation {
+		t.Fatalf("geração após recriar = %q, antes %q", reset, generation)
+	}
+	if info, err := ReadWorldInfo("Geracao"); err != nil || info.Generation != s.Generation() {
+		t.Fatalf("ReadWorldInfo = %+v, %v", info, err)
+	}
+}
+
+func TestBackupUnsupported(t *testing.T) {
+	s := NewMapDataStore()
+	s.Repo = newMemoryRepository()
+	if _, err := s.BackupWorld(); err != ErrBackupUnsupported {
+		t.Fatalf("BackupWorld em memória: err = %v, want ErrBackupUnsupported", err)
+	}
+}
@@ -0,0 +1,255 @@
+package mapdata
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"FortressVision/shared/util"
+)
+
+// Region é uma caixa de tiles com limites inclusivos.
+type Region struct {
+	Min, Max util.DFCoord
+}
+
+// RegionAround monta a região de raio radius (em tiles) em volta de center,
+// com zDown níveis abaixo e zUp acima.
+func RegionAround(center util.DFCoord, radius, zDown, zUp int32) Region {
+	return Region{
+		Min: util.NewDFCoord(center.X-radius, center.Y-radius, center.Z-zDown),
+		Max: util.NewDFCoord(center.X+radius, center.Y+radius, center.Z+zUp),
+	}
+}
+
+// ContainsChunk indica se o chunk de origin tem algum tile dentro da região.
+func (r Region) ContainsChunk(origin util.DFCoord) bool {
+	return origin.X+15 >= r.Min.X && origin.X <= r.Max.X &&
+		origin.Y+15 >= r.Min.Y && origin.Y <= r.Max.Y &&
+		origin.Z >= r.Min.Z && origin.Z <= r.Max.Z
+}
+
+// chunkCache guarda o estado do cache LRU de um MapDataStore.
+//
+// A RAM dos chunks é limitada por MapDataStore.MemoryBudget: quando a estimativa
+// passa do orçamento, os chunks menos usados saem da RAM (os sujos são gravados
+// antes). Chunks dentro de uma região fixada (PinRegion) nunca saem. Um chunk
+// despejado volta sozinho do banco no próximo GetChunk/GetTile.
+type chunkCache struct {
+	pinMu sync.Mutex
+	pins  map[any]Region
+
+	// evicting impede duas passadas de despejo ao mesmo tempo
+	evicting atomic.Bool
+}
+
+// cacheLowWater é a fração do orçamento a que uma passada de despejo desce,
+// para que o cache não fique despejando um chunk a cada inserção.
+const cacheLowWater = 0.9
+
+// PinRegion fixa em RAM os chunks da região de owner (ex.: a conexão de um cliente),
+// substituindo a região fixada anteriormente pelo mesmo owner.
+func (s *MapDataStore) PinRegion(owner any, r Region) {
+	s.cache.pinMu.Lock()
+	defer s.cache.pinMu.Unlock()
+	if s.cache.pins == nil {
+		s.cache.pins = make(map[any]Region)
+	}
+	s.cache.pins[owner] = r
+}
+
+// UnpinRegion libera a região fixada por owner.
+func (s *MapDataStore) UnpinRegion(owner any) {
+	s.cache.pinMu.Lock()
+	defer s.cache.pinMu.Unlock()
+	delete(s.cache.pins, owner)
+}
+
+// pinnedRegions copia as regiões fixadas no momento.
+func (s *MapDataStore) pinnedRegions() []Region {
+	s.cache.pinMu.Lock()
+	defer s.cache.pinMu.Unlock()
+	regions := make([]Region, 0, len(s.cache.pins))
+	for _, r := range s.cache.pins {
+		regions = append(regions, r)
+	}
+	return regions
+}
+
+// MemoryUsage retorna a estimativa de RAM ocupada pelos chunks em memória.
+func (s *MapDataStore) MemoryUsage() int64 {
+	return s.chunks.bytes.Load()
+}
+
+// maybeEvict dispara uma passada de despejo em background se o orçamento estourou.
+func (s *MapDataStore) maybeEvict() {
+	if s.MemoryBudget <= 0 || s.chunks.bytes.Load() <= s.MemoryBudget {
+		return
+	}
+	if s.cache.evicting.Load() {
+		return
+	}
+	go s.EnforceBudget()
+}
+
+// EnforceBudget despeja os chunks menos usados até a RAM voltar abaixo do orçamento.
+// Chunks sujos são gravados antes de sair; se não há onde gravá-los (sem banco ou
+// mundo somente leitura) eles ficam em RAM. Retorna quantos chunks saíram.
+func (s *MapDataStore) EnforceBudget() int {
+	if s.MemoryBudget <= 0 || !s.cache.evicting.CompareAndSwap(false, true) {
+		return 0
+	}
+	defer s.cache.evicting.Store(false)
+
+	usage := s.chunks.bytes.Load()
+	if usage <= s.MemoryBudget {
+		return 0
+	}
+
+	s.Mu.RLock()
+	persistent := s.Repo != nil
+	canWrite := persistent && !s.ReadOnly
+	gen := s.worldGen
+	s.Mu.RUnlock()
+
+	// Candidatos: tudo fora das regiões fixadas, do acesso mais antigo ao mais novo
+	pins := s.pinnedRegions()
+	type candidate struct {
+		chunk   *Chunk
+		lastUse int64
+	}
+	var candidates []candidate
+	s.chunks.rangeChunks(func(c *Chunk) bool {
+		if c.IsDirty && !canWrite {
+			return true
+		}
+		for _, r := range pins {
+			if r.ContainsChunk(c.Origin) {
+				return true
+			}
+		}
+		candidates = append(candidates, candidate{c, c.lastUse.Load()})
+		return true
+	})
+	slices.SortFunc(candidates, func(a, b candidate) int {
+		return cmp.Compare(a.lastUse, b.lastUse)
+	})
+
+	target := int64(float64(s.MemoryBudget) * cacheLowWater)
+	var victims, dirty []*Chunk
+	for _, cand := range candidates {
+		if usage <= target {
+			break
+		}
+		victims = append(victims, cand.chunk)
+		if cand.chunk.IsDirty {
+			dirty = append(dirty, cand.chunk)
+		}
+		usage -= cand.chunk.size
+	}
+
+	// WRITE-BACK: os sujos vão para o banco antes de sair da RAM
+	if len(dirty) > 0 {
+		if err := s.writeBack(dirty, gen); errors.Is(err, errWorldChanged) {
+			return 0 // Os candidatos eram do mundo anterior, que já saiu da RAM
+		} else if err != nil {
+			log.Printf("[Cache] ERRO ao gravar chunks antes do despejo: %v", err)
+			return 0
+		}
+	}
+
+	evicted := 0
+	for _, c := range victims {
+		if c.IsDirty {
+			// Foi gravado acima; se mudou desde então, evict recusa e ele continua sujo em RAM
+			c = s.chunks.lastVersion(c)
+		}
+		if c != nil && s.chunks.evict(c, persistent) {
+			evicted++
+		}
+	}
+	if evicted > 0 {
+		log.Printf("[Cache] %d chunks despejados (RAM: %d KB / orçamento %d KB, %d fixados)",
+			evicted, s.chunks.bytes.Load()/1024, s.MemoryBudget/1024, len(pins))
+	}
+	return evicted
+}
+
+// errWorldChanged indica um lote de write-back coletado antes de uma troca de mundo.
+var errWorldChanged = errors.New("mundo trocado desde a coleta dos chunks")
+
+// writeBack grava um lote de chunks sujos e marca como limpas as versões gravadas.
+// gen é o worldGen de quando o lote foi coletado: se o mundo mudou desde então
+// (SwitchWorld roda entre a coleta e dbMu), o lote é descartado com errWorldChanged
+// em vez de cair no banco do mundo novo.
+func (s *MapDataStore) writeBack(chunks []*Chunk, gen uint64) error {
+	s.dbMu.Lock()
+	defer s.dbMu.Unlock()
+
+	s.Mu.RLock()
+	repo, current := s.Repo, s.worldGen
+	s.Mu.RUnlock()
+	if current != gen {
+		return errWorldChanged
+	}
+	if repo == nil {
+		return fmt.Errorf("banco de dados não inicializado")
+	}
+
+	models := make([]*ChunkModel, 0, len(chunks))
+	for _, c := range chunks {
+		model, err := chunkModelOf(c)
+		if err != nil {
+			return err
+		}
+		models = append(models, &model)
+	}
+	if _, err := repo.SaveChunks(models); err != nil {
+		return err
+	}
+	for _, c := range chunks {
+		s.markClean(c)
+	}
+	s.recordHistory(models)
+	return nil
+}
+
+// lastVersion devolve a versão publicada de c se ela for apenas c marcado como
+// limpo (ver markClean); qualquer outra mudança desde a escolha devolve nil.
+func (m *chunkMap) lastVersion(c *Chunk) *Chunk {
+	sh := m.shard(c.Origin)
+	sh.mu.RLock()
+	cur := sh.chunks[c.Origin]
+	sh.mu.RUnlock()
+	if cur == nil || cur.IsDirty || cur.MTime != c.MTime || cur.Tiles != c.Tiles {
+		return nil
+	}
+	return cur
+}
+
+// reload relê do banco um chunk despejado pelo cache e o publica de novo.
+func (s *MapDataStore) reload(origin util.DFCoord) (*Chunk, bool) {
+	chunk := s.chunks.update(origin, func(cur *Chunk) *Chunk {
+		if cur != nil {
+			return cur // Outro leitor (ou o scanner) chegou antes
+		}
+		loaded, err := s.LoadChunk(origin)
+		if err != nil {
+			if !errors.Is(err, ErrChunkNotFound) {
+				log.Printf("[Cache] ERRO ao reler chunk %v do banco: %v", origin, err)
+			}
+			s.chunks.forgetEvicted(origin)
+			return nil
+		}
+		return loaded
+	})
+	if chunk == nil {
+		return nil, false
+	}
+	s.maybeEvict()
+	return chunk, true
+}
@@ -0,0 +1,161 @@
+package mapdata
+
+import (
+	"errors"
+	"testing"
+
+	"FortressVision/shared/util"
+)
+
+// cacheTestStore monta um store em RAM com uma linha de n chunks sujos em z=0.
+// O orçamento só é ligado depois, para que nenhum despejo em background rode durante a carga.
+func cacheTestStore(t *testing.T, n int) (*MapDataStore, []util.DFCoord) {
+	t.Helper()
+	s := NewMapDataStore()
+	s.Repo = newMemoryRepository()
+	var origins []util.DFCoord
+	for i := 0; i < n; i++ {
+		origin := util.NewDFCoord(int32(i)*16, 0, 0)
+		s.StoreSingleBlock(scanBlock(origin, int32(i+1)))
+		origins = append(origins, origin)
+	}
+	return s, origins
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	s, origins := cacheTestStore(t, 10)
+	perChunk := s.MemoryUsage() / int64(len(origins))
+
+	// Os dois primeiros ficam fixados por um cliente; o último é o mais recente
+	s.PinRegion("cliente", Region{Min: origins[0], Max: origins[1]})
+	s.GetTile(origins[9])
+
+	s.MemoryBudget = perChunk * 5
+	if n := s.EnforceBudget(); n == 0 {
+		t.Fatal("EnforceBudget não despejou nada acima do orçamento")
+	}
+	if s.MemoryUsage() > s.MemoryBudget {
+		t.Fatalf("RAM %d continua acima do orçamento %d", s.MemoryUsage(), s.MemoryBudget)
+	}
+
+	for _, origin := range []util.DFCoord{origins[0], origins[1], origins[9]} {
+		if _, evicted := s.chunks.lookup(origin); evicted {
+			t.Errorf("chunk %v não deveria ter sido despejado", origin)
+		}
+	}
+	if _, evicted := s.chunks.lookup(origins[2]); !evicted {
+		t.Errorf("chunk menos usado %v deveria ter sido despejado", origins[2])
+	}
+
+	// Despejados sujos foram gravados antes de sair
+	if count, _ := s.Repo.ChunkCount(); count == 0 {
+		t.Fatal("nenhum chunk gravado no write-back")
+	}
+}
+
+// TestCachePinsEveryRequestedLevel cobre pedidos com vários níveis Z (draw_range_down/up):
+// todos os níveis da região fixada ficam em RAM, não só o do centro.
+func TestCachePinsEveryRequestedLevel(t *testing.T) {
+	s := NewMapDataStore()
+	s.Repo = newMemoryRepository()
+	for z := int32(0); z < 6; z++ {
+		s.StoreSingleBlock(scanBlock(util.NewDFCoord(0, 0, z), z+1))
+	}
+	center := util.NewDFCoord(8, 8, 3)
+	s.PinRegion("cliente", RegionAround(center, 8, 2, 1))
+
+	s.MemoryBudget = 1
+	s.EnforceBudget()
+	for z := int32(0); z < 6; z++ {
+		_, evicted := s.chunks.lookup(util.NewDFCoord(0, 0, z))
+		if pinned := z >= 1 && z <= 4; evicted == pinned {
+			t.Errorf("nível %d: despejado = %v, fixado = %v", z, evicted, pinned)
+		}
+	}
+}
+
+func TestCacheReloadsEvictedChunk(t *testing.T) {
+	s, origins := cacheTestStore(t, 4)
+	s.MemoryBudget = 1
+	s.EnforceBudget()
+	if s.LoadedChunkCount() != 0 {
+		t.Fatalf("%d chunks continuam em RAM", s.LoadedChunkCount())
+	}
+
+	// Acesso transparente: GetTile relê o chunk do banco
+	tile := s.GetTile(origins[2].Add(util.NewDFCoord(3, 4, 0)))
+	if tile == nil || tile.TileType != 3 {
+		t.Fatalf("GetTile após despejo = %+v, want TileType 3", tile)
+	}
+	chunk, ok := s.GetChunk(origins[2])
+	if !ok || chunk.IsDirty || len(chunk.Items) != 1 {
+		t.Fatalf("chunk relido = %+v, %v", chunk, ok)
+	}
+}
+
+func TestCacheKeepsDirtyChunksWithoutRepository(t *testing.T) {
+	s, origins := cacheTestStore(t, 3)
+	s.Repo = nil // Cliente sem banco: chunks sujos não têm para onde ir
+	s.MemoryBudget = 1
+	if n := s.EnforceBudget(); n != 0 {
+		t.Fatalf("EnforceBudget despejou %d chunks sujos sem banco", n)
+	}
+	if s.LoadedChunkCount() != len(origins) {
+		t.Fatalf("LoadedChunkCount = %d, want %d", s.LoadedChunkCount(), len(origins))
+	}
+}
+
+// TestWriteBackSkipsPreviousWorld simula uma troca de mundo entre a escolha dos
+// despejados e o write-back: o lote antigo não pode cair no banco novo.
+func TestWriteBackSkipsPreviousWorld(t *testing.T) {
+	s, origins := cacheTestStore(t, 3)
+	var dirty []*Chunk
+	for _, origin := range origins {
+		chunk, _ := s.GetChunk(origin)
+		dirty = append(dirty, chunk)
+	}
+	s.Mu.RLock()
+	gen := s.worldGen
+	s.Mu.RUnlock()
+
+	// Troca de mundo como SwitchWorld faz (banco novo, RAM zerada)
+	next := newMemoryRepository()
+	s.Mu.Lock()
+	s.Repo = next
+	s.Mu.Unlock()
+	s.ResetMemory()
+	if err := s.writeBack(dirty, gen); !errors.Is(err, errWorldChanged) {
+		t.Fatalf("writeBack do mundo anterior: err = %v, want errWorldChanged", err)
+	}
+	if count, _ := next.ChunkCount(); count != 0 {
+		t.Fatalf("%d chunks do mundo anterior gravados no banco novo", count)
+	}
+
+	// Sem banco (a reabertura falhou): erro, não panic
+	s.Mu.Lock()
+	s.Repo = nil
+	gen = s.worldGen
+	s.Mu.Unlock()
+	if err := s.writeBack(dirty, gen); err == nil {
+		t.Fatal("writeBack sem banco não falhou")
+	}
+}
+
+func TestRegionContainsChunk(t *testing.T) {
+	r := RegionAround(util.NewDFCoord(100, 100, 10), 20, 1, 0)
+	cases := []struct {
+		origin util.DFCoord
+		want   bool
+	}{
+		{util.NewDFCoord(96, 96, 10), true},
+		{util.NewDFCoord(112, 112, 10), true}, // 112..127 toca o limite 120
+		{util.NewDFCoord(64, 96, 10), false},  // 64..79 termina antes de 80
+		{util.NewDFCoord(96, 96, 9), true},
+		{util.NewDFCoord(96, 96, 11), false},
+	}
+	for _, c := range cases {
+		if got := r.ContainsChunk(c.origin); got != c.want {
+			t.Errorf("ContainsChunk(%v) = %v, want %v", c.origin, got, c.want)
+		}
+	}
+}
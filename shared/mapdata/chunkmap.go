@@ -0,0 +1,270 @@
+package mapdata
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"FortressVision/shared/pkg/dfproto"
+	"FortressVision/shared/util"
+)
+
+// chunkShardCount é o número de shards do mapa de chunks (potência de 2).
+const chunkShardCount = 64
+
+// chunkShard guarda uma fatia dos chunks em RAM.
+//
+// Os chunks publicados são imutáveis (copy-on-write): quem altera um chunk monta
+// uma cópia fora de qualquer lock de leitura e só troca o ponteiro no final.
+// Assim mu fica travado apenas durante a troca, e o scanner processando os 256
+// tiles de um bloco nunca bloqueia GetTile do mesher ou o streaming.
+type chunkShard struct {
+	// writeMu serializa os escritores do shard (evita perder uma atualização
+	// quando dois escritores partem da mesma versão de um chunk)
+	writeMu sync.Mutex
+	// mu protege apenas os mapas (leituras e trocas de ponteiro)
+	mu     sync.RWMutex
+	chunks map[util.DFCoord]*Chunk
+	// evicted marca os chunks que o cache tirou da RAM e que voltam do banco no próximo acesso
+	evicted map[util.DFCoord]struct{}
+}
+
+// chunkMap é o mapa de chunks em RAM, dividido em shards por origem.
+type chunkMap struct {
+	shards [chunkShardCount]chunkShard
+
+	// bytes é a estimativa de RAM ocupada pelos chunks publicados (ver Chunk.memSize)
+	bytes atomic.Int64
+	// epoch avança a cada publicação; é o relógio do LRU (ver touch)
+	epoch atomic.Int64
+}
+
+// shard escolhe o shard de um chunk. Os multiplicadores espalham vizinhos em
+// X, Y e Z por shards diferentes, para que o scanner (que anda em linha) e o
+// mesher (que lê vizinhos) raramente caiam no mesmo shard.
+func (m *chunkMap) shard(origin util.DFCoord) *chunkShard {
+	h := uint32(origin.X>>4)*73856093 ^ uint32(origin.Y>>4)*19349663 ^ uint32(origin.Z)*83492791
+	return &m.shards[h&(chunkShardCount-1)]
+}
+
+func (m *chunkMap) get(origin util.DFCoord) (*Chunk, bool) {
+	c, _ := m.lookup(origin)
+	return c, c != nil
+}
+
+// lookup busca um chunk e marca o acesso para o LRU. Se ele não está em RAM,
+// evicted indica que o cache o descartou (e que ele pode ser relido do banco).
+func (m *chunkMap) lookup(origin util.DFCoord) (c *Chunk, evicted bool) {
+	sh := m.shard(origin)
+	sh.mu.RLock()
+	c = sh.chunks[origin]
+	if c == nil {
+		_, evicted = sh.evicted[origin]
+	}
+	sh.mu.RUnlock()
+	if c != nil {
+		m.touch(c)
+	}
+	return c, evicted
+}
+
+// touch registra o acesso ao chunk. Só escreve quando o relógio andou,
+// para que leitores do mesmo chunk não fiquem disputando a linha de cache.
+func (m *chunkMap) touch(c *Chunk) {
+	if c.lastUse == nil {
+		return
+	}
+	if e := m.epoch.Load(); c.lastUse.Load() != e {
+		c.lastUse.Store(e)
+	}
+}
+
+// update publica a versão de origin devolvida por fn. fn recebe a versão atual
+// (nil se o chunk não está em RAM) e não deve alterá-la: para mudar algo, devolve
+// uma cópia (ver Chunk.clone). Devolver cur mantém o chunk como está; devolver nil
+// em um chunk inexistente não cria nada. Retorna a versão publicada.
+func (m *chunkMap) update(origin util.DFCoord, fn func(cur *Chunk) *Chunk) *Chunk {
+	sh := m.shard(origin)
+	sh.writeMu.Lock()
+	defer sh.writeMu.Unlock()
+
+	sh.mu.RLock()
+	cur := sh.chunks[origin]
+	sh.mu.RUnlock()
+
+	next := fn(cur)
+	if next == nil || next == cur {
+		return cur
+	}
+
+	// Prepara os campos do cache antes de publicar (depois disso o chunk é imutável)
+	if next.lastUse == nil {
+		if cur != nil && cur.lastUse != nil {
+			next.lastUse = cur.lastUse
+		} else {
+			next.lastUse = new(atomic.Int64)
+		}
+	}
+	next.lastUse.Store(m.epoch.Add(1))
+	next.size = next.memSize()
+
+	sh.mu.Lock()
+	if sh.chunks == nil {
+		sh.chunks = make(map[util.DFCoord]*Chunk)
+	}
+	sh.chunks[origin] = next
+	delete(sh.evicted, origin)
+	sh.mu.Unlock()
+
+	if cur != nil {
+		m.bytes.Add(next.size - cur.size)
+	} else {
+		m.bytes.Add(next.size)
+	}
+	return next
+}
+
+func (m *chunkMap) put(c *Chunk) {
+	m.update(c.Origin, func(*Chunk) *Chunk { return c })
+}
+
+func (m *chunkMap) delete(origin util.DFCoord) {
+	sh := m.shard(origin)
+	sh.writeMu.Lock()
+	sh.mu.Lock()
+	if c, ok := sh.chunks[origin]; ok {
+		delete(sh.chunks, origin)
+		m.bytes.Add(-c.size)
+	}
+	delete(sh.evicted, origin)
+	sh.mu.Unlock()
+	sh.writeMu.Unlock()
+}
+
+// evict tira c da RAM se ele ainda for a versão publicada; reloadable marca a
+// origem para ser relida do banco no próximo acesso. Retorna se o chunk saiu.
+func (m *chunkMap) evict(c *Chunk, reloadable bool) bool {
+	sh := m.shard(c.Origin)
+	sh.writeMu.Lock()
+	defer sh.writeMu.Unlock()
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	if sh.chunks[c.Origin] != c {
+		return false // mudou (ou saiu) depois de escolhido
+	}
+	delete(sh.chunks, c.Origin)
+	m.bytes.Add(-c.size)
+	if reloadable {
+		if sh.evicted == nil {
+			sh.evicted = make(map[util.DFCoord]struct{})
+		}
+		sh.evicted[c.Origin] = struct{}{}
+	}
+	return true
+}
+
+// forgetEvicted desiste de reler origin do banco.
+func (m *chunkMap) forgetEvicted(origin util.DFCoord) {
+	sh := m.shard(origin)
+	sh.mu.Lock()
+	delete(sh.evicted, origin)
+	sh.mu.Unlock()
+}
+
+// deleteWhere remove os chunks para os quais pred retorna true, um shard por vez.
+// Retorna os chunks removidos.
+func (m *chunkMap) deleteWhere(pred func(c *Chunk) bool) []*Chunk {
+	var removed []*Chunk
+	for i := range m.shards {
+		sh := &m.shards[i]
+		sh.writeMu.Lock()
+		sh.mu.Lock()
+		for origin, c := range sh.chunks {
+			if pred(c) {
+				delete(sh.chunks, origin)
+				m.bytes.Add(-c.size)
+				removed = append(removed, c)
+			}
+		}
+		sh.mu.Unlock()
+		sh.writeMu.Unlock()
+	}
+	return removed
+}
+
+// rangeChunks chama fn para cada chunk em RAM até fn retornar false.
+// Cada shard é copiado antes de fn rodar; fn pode chamar o store livremente.
+func (m *chunkMap) rangeChunks(fn func(c *Chunk) bool) {
+	var buf []*Chunk
+	for i := range m.shards {
+		sh := &m.shards[i]
+		sh.mu.RLock()
+		buf = buf[:0]
+		for _, c := range sh.chunks {
+			buf = append(buf, c)
+		}
+		sh.mu.RUnlock()
+		for _, c := range buf {
+			if !fn(c) {
+				return
+			}
+		}
+	}
+}
+
+func (m *chunkMap) len() int {
+	n := 0
+	for i := range m.shards {
+		sh := &m.shards[i]
+		sh.mu.RLock()
+		n += len(sh.chunks)
+		sh.mu.RUnlock()
+	}
+	return n
+}
+
+// reset descarta todos os chunks (e as marcas de despejo).
+func (m *chunkMap) reset() {
+	m.deleteWhere(func(*Chunk) bool { return true })
+	for i := range m.shards {
+		sh := &m.shards[i]
+		sh.mu.Lock()
+		sh.evicted = nil
+		sh.mu.Unlock()
+	}
+}
+
+// clone devolve uma cópia rasa do chunk para ser alterada e publicada no lugar dele.
+// Os tiles continuam compartilhados: quem for alterá-los deve chamar ownTiles antes.
+func (c *Chunk) clone() *Chunk {
+	next := *c
+	return &next
+}
+
+// ownTiles troca os tiles compartilhados de um clone por uma cópia própria.
+func (c *Chunk) ownTiles() {
+	if c.Tiles == nil {
+		return
+	}
+	b := *c.Tiles
+	b.Palette = slices.Clone(b.Palette)
+	c.Tiles = &b
+}
+
+// memSize estima a RAM ocupada pelo chunk (struct, tiles e camadas de entidades).
+// Não precisa ser exata: serve para comparar com o orçamento do cache.
+func (c *Chunk) memSize() int64 {
+	n := int64(unsafe.Sizeof(*c))
+	if c.Tiles != nil {
+		n += int64(unsafe.Sizeof(*c.Tiles)) + int64(cap(c.Tiles.Palette))*int64(unsafe.Sizeof(dfproto.MatPair{}))
+	}
+	n += int64(len(c.Plants)) * int64(unsafe.Sizeof(dfproto.PlantDetail{}))
+	n += int64(len(c.Buildings)) * int64(unsafe.Sizeof(dfproto.BuildingInstance{}))
+	n += int64(len(c.Items)) * int64(unsafe.Sizeof(dfproto.Item{}))
+	n += int64(len(c.ConstructionItems)) * int64(unsafe.Sizeof(dfproto.MatPair{}))
+	n += int64(len(c.SpatterPile)) * int64(unsafe.Sizeof(dfproto.SpatterPile{}))
+	n += int64(len(c.Engravings)) * int64(unsafe.Sizeof(dfproto.Engraving{}))
+	return n
+}
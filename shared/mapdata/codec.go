@@ -0,0 +1,112 @@
+package mapdata
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+
+	"FortressVision/shared/pkg/dfproto"
+	"FortressVision/shared/util"
+)
+
+// ChunkLayers seleciona quais camadas de um chunk entram na serialização.
+type ChunkLayers uint8
+
+const (
+	LayerTiles ChunkLayers = 1 << iota
+	LayerPlants
+	LayerBuildings
+	LayerItems
+	LayerConstructionItems
+	LayerSpatter
+	LayerEngravings
+
+	// LayersAll é usado pelo banco e pela rede: nenhum dado do chunk fica para trás.
+	LayersAll = LayerTiles | LayerPlants | LayerBuildings | LayerItems |
+		LayerConstructionItems | LayerSpatter | LayerEngravings
+)
+
+// chunkData é o container para serialização completa de um bloco.
+// É o formato gravado no banco (ChunkModel.Data) e enviado em MapChunkMessage.VoxelData;
+// camadas fora de ChunkLayers ficam zeradas e o GOB simplesmente não as grava.
+// Block é o layout compacto dos tiles (formato v6); Tiles é o layout antigo,
+// mantido apenas para ler blobs anteriores (ver migrations.go).
+type chunkData struct {
+	Block             *TileBlock
+	Tiles             *[16][16]*Tile
+	Plants            []dfproto.PlantDetail
+	Buildings         []dfproto.BuildingInstance
+	Items             []dfproto.Item
+	ConstructionItems []dfproto.MatPair
+	SpatterPile       []dfproto.SpatterPile
+	Engravings        []dfproto.Engraving
+}
+
+// EncodeChunk serializa as camadas pedidas do chunk.
+func EncodeChunk(chunk *Chunk, layers ChunkLayers) ([]byte, error) {
+	var cData chunkData
+	if layers&LayerTiles != 0 {
+		cData.Block = chunk.Tiles
+	}
+	if layers&LayerPlants != 0 {
+		cData.Plants = chunk.Plants
+	}
+	if layers&LayerBuildings != 0 {
+		cData.Buildings = chunk.Buildings
+	}
+	if layers&LayerItems != 0 {
+		cData.Items = chunk.Items
+	}
+	if layers&LayerConstructionItems != 0 {
+		cData.ConstructionItems = chunk.ConstructionItems
+	}
+	if layers&LayerSpatter != 0 {
+		cData.SpatterPile = chunk.SpatterPile
+	}
+	if layers&LayerEngravings != 0 {
+		cData.Engravings = chunk.Engravings
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(cData); err != nil {
+		return nil, fmt.Errorf("falha ao codificar chunk %v: %w", chunk.Origin, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeChunk reconstrói um chunk a partir de dados gerados por EncodeChunk.
+// O chunk volta sem store; quem chama deve ligá-lo (ver Chunk.Attach).
+func DecodeChunk(origin util.DFCoord, data []byte) (*Chunk, error) {
+	cData, err := decodeChunkData(data)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao decodificar dados do chunk %d_%d_%d: %v", origin.X, origin.Y, origin.Z, err)
+	}
+	chunk := &Chunk{
+		Origin:            origin,
+		Tiles:             cData.Block,
+		Plants:            cData.Plants,
+		Buildings:         cData.Buildings,
+		Items:             cData.Items,
+		ConstructionItems: cData.ConstructionItems,
+		SpatterPile:       cData.SpatterPile,
+		Engravings:        cData.Engravings,
+	}
+	if chunk.Tiles == nil && cData.Tiles != nil {
+		chunk.Tiles = tileBlockFromLegacy(cData.Tiles)
+	}
+	return chunk, nil
+}
+
+func decodeChunkData(data []byte) (*chunkData, error) {
+	var cData chunkData
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&cData); err != nil {
+		return nil, err
+	}
+	return &cData, nil
+}
+
+// Attach liga um chunk decodificado ao store que o recebe (as visões de Tile
+// usam o store para consultar tiletypes e vizinhos).
+func (c *Chunk) Attach(s *MapDataStore) {
+	c.store = s
+}
@@ -0,0 +1,138 @@
+package mapdata
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"FortressVision/shared/pkg/dfproto"
+	"FortressVision/shared/util"
+)
+
+// chunkMetaFields não passam pelo codec: vêm da coordenada, da linha do banco ou do estado em RAM.
+var chunkMetaFields = map[string]bool{"Origin": true, "MTime": true, "IsDirty": true, "IsEmpty": true,
+	"store": true, "lastUse": true, "size": true}
+
+// sampleChunk preenche todas as camadas do chunk com valores não nulos.
+func sampleChunk() *Chunk {
+	origin := util.NewDFCoord(32, 48, 7)
+	c := &Chunk{Origin: origin, MTime: 42}
+	for x := int32(0); x < 16; x++ {
+		for y := int32(0); y < 16; y++ {
+			c.SetTile(x, y, &Tile{
+				TileType:     x*16 + y,
+				Material:     dfproto.MatPair{MatType: 0, MatIndex: y},
+				WaterLevel:   x % 8,
+				Hidden:       x == y,
+				GrassPercent: y,
+			})
+		}
+	}
+	pos := dfproto.Coord{X: origin.X + 1, Y: origin.Y + 2, Z: origin.Z}
+	c.Plants = []dfproto.PlantDetail{{Pos: pos, Material: dfproto.MatPair{MatType: 419, MatIndex: 3}}}
+	c.Buildings = []dfproto.BuildingInstance{{Index: 5, PosXMin: pos.X, PosYMin: pos.Y, PosZMin: pos.Z, PosXMax: pos.X + 2, PosYMax: pos.Y + 2, PosZMax: pos.Z, IsRoom: true}}
+	c.Items = []dfproto.Item{{ID: 77, Pos: pos, StackSize: 3, Material: dfproto.MatPair{MatType: 0, MatIndex: 1}, SubposX: 0.5}}
+	c.ConstructionItems = []dfproto.MatPair{{MatType: 1, MatIndex: 2}}
+	c.SpatterPile = []dfproto.SpatterPile{{Spatters: []dfproto.Spatter{{Material: dfproto.MatPair{MatType: 3, MatIndex: 4}, Amount: 10}}}}
+	c.Engravings = []dfproto.Engraving{{Pos: pos, Quality: 2, IsFloor: true, North: true}}
+	return c
+}
+
+// assertSameLayers compara campo a campo as camadas serializáveis do chunk.
+func assertSameLayers(t *testing.T, want, got *Chunk) {
+	t.Helper()
+	wv, gv := reflect.ValueOf(want).Elem(), reflect.ValueOf(got).Elem()
+	for i := 0; i < wv.NumField(); i++ {
+		name := wv.Type().Field(i).Name
+		if chunkMetaFields[name] {
+			continue
+		}
+		if !reflect.DeepEqual(wv.Field(i).Interface(), gv.Field(i).Interface()) {
+			t.Errorf("campo %s perdido no round-trip", name)
+		}
+	}
+}
+
+// TestSampleChunkCoversAllFields garante que um campo novo em Chunk entre na amostra (e no codec).
+func TestSampleChunkCoversAllFields(t *testing.T) {
+	v := reflect.ValueOf(sampleChunk()).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Name
+		if !chunkMetaFields[name] && v.Field(i).IsZero() {
+			t.Errorf("sampleChunk não preenche Chunk.%s", name)
+		}
+	}
+}
+
+func TestChunkCodecRoundTrip(t *testing.T) {
+	want := sampleChunk()
+	data, err := EncodeChunk(want, LayersAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeChunk(want.Origin, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Origin != want.Origin {
+		t.Errorf("Origin = %v, want %v", got.Origin, want.Origin)
+	}
+	assertSameLayers(t, want, got)
+}
+
+func TestChunkCodecLayers(t *testing.T) {
+	src := sampleChunk()
+	data, err := EncodeChunk(src, LayerPlants|LayerItems)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeChunk(src.Origin, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &Chunk{Origin: src.Origin, Plants: src.Plants, Items: src.Items}
+	assertSameLayers(t, want, got)
+}
+
+// TestChunkPersistenceRoundTrip cobre os dois caminhos de gravação (SaveChunk e Save em lote).
+func TestChunkPersistenceRoundTrip(t *testing.T) {
+	wd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	s := NewMapDataStore()
+	if err := s.OpenInitialize("RoundTrip"); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	single := sampleChunk()
+	if err := s.SaveChunk(single); err != nil {
+		t.Fatal(err)
+	}
+
+	batch := sampleChunk()
+	batch.Origin = util.NewDFCoord(64, 48, 7)
+	batch.IsDirty = true
+	s.PutChunk(batch)
+	if n, err := s.Save("RoundTrip"); err != nil || n != 1 {
+		t.Fatalf("Save = %d, %v", n, err)
+	}
+
+	for _, want := range []*Chunk{single, batch} {
+		got, err := s.LoadChunk(want.Origin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.MTime != want.MTime {
+			t.Errorf("%v: MTime = %d, want %d", want.Origin, got.MTime, want.MTime)
+		}
+		if got.store != s {
+			t.Errorf("%v: chunk não religado ao store", want.Origin)
+		}
+		assertSameLayers(t, want, got)
+	}
+}
@@ -0,0 +1,225 @@
+package mapdata
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"FortressVision/shared/pkg/dfproto"
+	"FortressVision/shared/util"
+)
+
+// DiffKind classifica a mudança de um tile entre duas versões do mapa.
+// É um conjunto de bits: um tile escavado e inundado tem DiffDug|DiffFlooded.
+type DiffKind uint8
+
+const (
+	DiffDug         DiffKind = 1 << iota // Parede natural virou espaço aberto (piso, rampa, escada, vazio...)
+	DiffConstructed                      // Tile passou a ser uma construção (ou trocou de construção)
+	DiffFlooded                          // Tile seco passou a ter água ou magma
+	DiffRevealed                         // Tile escondido (ou nunca escaneado) ficou visível
+)
+
+// diffKindNames dá o nome de cada bit de DiffKind, na ordem dos bits.
+var diffKindNames = [...]string{"dug", "constructed", "flooded", "revealed"}
+
+// String lista os nomes dos bits ligados, separados por "|".
+func (k DiffKind) String() string {
+	var names []string
+	for i, name := range diffKindNames {
+		if k&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// MarshalJSON escreve a lista de nomes (ex.: ["dug","flooded"]).
+func (k DiffKind) MarshalJSON() ([]byte, error) {
+	names := []string{}
+	for i, name := range diffKindNames {
+		if k&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	return json.Marshal(names)
+}
+
+// DiffCounts conta os tiles de cada tipo de mudança.
+type DiffCounts struct {
+	Dug         int `json:"dug"`
+	Constructed int `json:"constructed"`
+	Flooded     int `json:"flooded"`
+	Revealed    int `json:"revealed"`
+}
+
+func (c *DiffCounts) add(k DiffKind) {
+	if k&DiffDug != 0 {
+		c.Dug++
+	}
+	if k&DiffConstructed != 0 {
+		c.Constructed++
+	}
+	if k&DiffFlooded != 0 {
+		c.Flooded++
+	}
+	if k&DiffRevealed != 0 {
+		c.Revealed++
+	}
+}
+
+// LevelDiff resume as mudanças de um nível Z.
+type LevelDiff struct {
+	Z int32 `json:"z"`
+	DiffCounts
+}
+
+// TileDiff é um tile alterado (coordenadas absolutas do DF).
+type TileDiff struct {
+	X    int32    `json:"x"`
+	Y    int32    `json:"y"`
+	Z    int32    `json:"z"`
+	Kind DiffKind `json:"kind"`
+}
+
+// MapDiff é o resultado de DiffRegion.
+type MapDiff struct {
+	Total  DiffCounts  `json:"total"`
+	Levels []LevelDiff `json:"levels"`          // Só os níveis com mudanças, em ordem crescente de Z
+	Tiles  []TileDiff  `json:"tiles,omitempty"` // Preenchido com DiffOptions.Tiles
+}
+
+// ChunkSource entrega os chunks de uma caixa (coordenadas de tile, limites inclusivos),
+// como MapDataStore.LoadRegion ou uma versão do histórico (ver SourceAt).
+// Chunks ausentes do mapa retornado são tratados como nunca escaneados.
+type ChunkSource func(min, max util.DFCoord) (map[util.DFCoord]*Chunk, error)
+
+// SourceAt entrega os chunks do mundo aberto como estavam em at (ver RegionAt).
+func (s *MapDataStore) SourceAt(at HistoryPoint) ChunkSource {
+	return func(min, max util.DFCoord) (map[util.DFCoord]*Chunk, error) {
+		return s.RegionAt(min, max, at)
+	}
+}
+
+// DiffOptions ajusta DiffRegion.
+type DiffOptions struct {
+	// Tiletypes classifica os tiles (forma e material). Sem ele só
+	// DiffFlooded e DiffRevealed são detectados. Ver StoredTiletypes.
+	Tiletypes map[int32]*dfproto.Tiletype
+	// Tiles inclui a lista de tiles alterados no resultado.
+	Tiles bool
+}
+
+// DiffRegion compara, tile a tile, duas versões da caixa [min, max] (coordenadas de tile).
+// Os níveis são lidos um de cada vez, então a memória usada é a de um nível das duas versões.
+func DiffRegion(before, after ChunkSource, min, max util.DFCoord, opts DiffOptions) (*MapDiff, error) {
+	min, max = min.BlockCoord(), max.BlockCoord()
+	diff := &MapDiff{Levels: []LevelDiff{}}
+	for z := min.Z; z <= max.Z; z++ {
+		lo, hi := util.NewDFCoord(min.X, min.Y, z), util.NewDFCoord(max.X+15, max.Y+15, z)
+		old, err := before(lo, hi)
+		if err != nil {
+			return nil, fmt.Errorf("nível %d (antes): %w", z, err)
+		}
+		cur, err := after(lo, hi)
+		if err != nil {
+			return nil, fmt.Errorf("nível %d (depois): %w", z, err)
+		}
+
+		level := LevelDiff{Z: z}
+		for x := min.X; x <= max.X; x += 16 {
+			for y := min.Y; y <= max.Y; y += 16 {
+				origin := util.NewDFCoord(x, y, z)
+				diffChunk(opts.Tiletypes, old[origin], cur[origin], func(i int, k DiffKind) {
+					level.add(k)
+					if opts.Tiles {
+						diff.Tiles = append(diff.Tiles, TileDiff{X: x + int32(i%16), Y: y + int32(i/16), Z: z, Kind: k})
+					}
+				})
+			}
+		}
+		if level.DiffCounts != (DiffCounts{}) {
+			diff.Levels = append(diff.Levels, level)
+			diff.Total.Dug += level.Dug
+			diff.Total.Constructed += level.Constructed
+			diff.Total.Flooded += level.Flooded
+			diff.Total.Revealed += level.Revealed
+		}
+	}
+	return diff, nil
+}
+
+// diffChunk chama emit para cada tile do chunk que mudou entre old e cur.
+// Chunks sem a versão nova (não escaneados) não geram mudanças.
+func diffChunk(types map[int32]*dfproto.Tiletype, old, cur *Chunk, emit func(i int, k DiffKind)) {
+	if cur == nil || cur.Tiles == nil {
+		return
+	}
+	var ob *TileBlock
+	if old != nil {
+		ob = old.Tiles
+	}
+	cb := cur.Tiles
+	for i := 0; i < 256; i++ {
+		if !cb.has(i) || cb.flag(flagHidden, i) {
+			continue // Ainda não dá para ver o que há no tile
+		}
+		if ob == nil || !ob.has(i) {
+			emit(i, DiffRevealed)
+			continue
+		}
+
+		var k DiffKind
+		if ob.flag(flagHidden, i) {
+			k |= DiffRevealed
+		}
+		if ob.Liquid[i] == 0 && cb.Liquid[i] != 0 {
+			k |= DiffFlooded
+		}
+		if ob.TileType[i] != cb.TileType[i] {
+			before, after := types[int32(ob.TileType[i])], types[int32(cb.TileType[i])]
+			if before != nil && after != nil {
+				if after.Material == dfproto.TilematConstruction {
+					k |= DiffConstructed
+				} else if isNaturalWall(before) && after.Shape != dfproto.ShapeWall {
+					k |= DiffDug
+				}
+			}
+		}
+		if k != 0 {
+			emit(i, k)
+		}
+	}
+}
+
+// isNaturalWall indica uma parede de rocha, solo ou minério (o que os anões escavam).
+func isNaturalWall(tt *dfproto.Tiletype) bool {
+	if tt.Shape != dfproto.ShapeWall {
+		return false
+	}
+	switch tt.Material {
+	case dfproto.TilematSoil, dfproto.TilematStone, dfproto.TilematFeature,
+		dfproto.TilematLavaStone, dfproto.TilematMineral, dfproto.TilematFrozenLiquid:
+		return true
+	default:
+		return false
+	}
+}
+
+// StoredTiletypes lê o dicionário de Tiletypes gravado no banco do mundo aberto.
+func (s *MapDataStore) StoredTiletypes() (map[int32]*dfproto.Tiletype, error) {
+	data, err := s.GetDictionary("TiletypeList")
+	if err != nil {
+		return nil, err
+	}
+	var list dfproto.TiletypeList
+	if err := list.Unmarshal(data); err != nil {
+		return nil, fmt.Errorf("TiletypeList inválido: %w", err)
+	}
+	types := make(map[int32]*dfproto.Tiletype, len(list.TiletypeList))
+	for i := range list.TiletypeList {
+		tt := &list.TiletypeList[i]
+		types[tt.ID] = tt
+	}
+	return types, nil
+}
@@ -0,0 +1,115 @@
+package mapdata
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"FortressVision/shared/pkg/dfproto"
+	"FortressVision/shared/util"
+)
+
+// Tiletypes mínimos para classificar as mudanças nos testes.
+const (
+	ttStoneWall int32 = iota + 1
+	ttStoneFloor
+	ttConstructedWall
+)
+
+var diffTestTypes = map[int32]*dfproto.Tiletype{
+	ttStoneWall:       {ID: ttStoneWall, Shape: dfproto.ShapeWall, Material: dfproto.TilematStone},
+	ttStoneFloor:      {ID: ttStoneFloor, Shape: dfproto.ShapeFloor, Material: dfproto.TilematStone},
+	ttConstructedWall: {ID: ttConstructedWall, Shape: dfproto.ShapeWall, Material: dfproto.TilematConstruction},
+}
+
+// staticSource serve sempre os mesmos chunks, como um mundo já carregado.
+func staticSource(chunks ...*Chunk) ChunkSource {
+	m := make(map[util.DFCoord]*Chunk)
+	for _, c := range chunks {
+		m[c.Origin] = c
+	}
+	return func(min, max util.DFCoord) (map[util.DFCoord]*Chunk, error) {
+		return m, nil
+	}
+}
+
+// diffTestChunk monta um chunk de parede de pedra, escondido se hidden.
+func diffTestChunk(origin util.DFCoord, hidden bool, edit func(c *Chunk)) *Chunk {
+	c := &Chunk{Origin: origin}
+	for x := int32(0); x < 16; x++ {
+		for y := int32(0); y < 16; y++ {
+			c.SetTile(x, y, &Tile{TileType: ttStoneWall, Hidden: hidden})
+		}
+	}
+	if edit != nil {
+		edit(c)
+	}
+	return c
+}
+
+func TestDiffRegion(t *testing.T) {
+	a, b := util.NewDFCoord(0, 0, 3), util.NewDFCoord(16, 0, 3)
+	before := staticSource(diffTestChunk(a, false, nil))
+	after := staticSource(
+		diffTestChunk(a, false, func(c *Chunk) {
+			c.SetTile(1, 0, &Tile{TileType: ttStoneFloor})
+			c.SetTile(2, 0, &Tile{TileType: ttConstructedWall})
+			c.SetTile(3, 0, &Tile{TileType: ttStoneFloor, WaterLevel: 7})
+			c.SetTile(4, 0, &Tile{TileType: ttStoneWall, MagmaLevel: 2})
+		}),
+		// Chunk nunca escaneado antes: só os tiles visíveis contam como revelados
+		diffTestChunk(b, true, func(c *Chunk) {
+			c.SetTile(5, 5, &Tile{TileType: ttStoneFloor})
+		}),
+	)
+
+	diff, err := DiffRegion(before, after, a, b, DiffOptions{Tiletypes: diffTestTypes, Tiles: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := DiffCounts{Dug: 2, Constructed: 1, Flooded: 2, Revealed: 1}
+	if diff.Total != want || len(diff.Levels) != 1 || diff.Levels[0].Z != 3 {
+		t.Fatalf("DiffRegion = %+v, want total %+v no nível 3", diff, want)
+	}
+
+	kinds := make(map[util.DFCoord]DiffKind)
+	for _, tile := range diff.Tiles {
+		kinds[util.NewDFCoord(tile.X, tile.Y, tile.Z)] = tile.Kind
+	}
+	cases := map[util.DFCoord]DiffKind{
+		util.NewDFCoord(1, 0, 3):  DiffDug,
+		util.NewDFCoord(2, 0, 3):  DiffConstructed,
+		util.NewDFCoord(3, 0, 3):  DiffDug | DiffFlooded,
+		util.NewDFCoord(4, 0, 3):  DiffFlooded,
+		util.NewDFCoord(21, 5, 3): DiffRevealed,
+	}
+	if len(kinds) != len(cases) {
+		t.Fatalf("%d tiles alterados, want %d: %v", len(kinds), len(cases), kinds)
+	}
+	for pos, k := range cases {
+		if kinds[pos] != k {
+			t.Errorf("tile %v = %v, want %v", pos, kinds[pos], k)
+		}
+	}
+
+	out, err := json.Marshal(diff.Tiles[0])
+	if err != nil || !strings.Contains(string(out), `"kind":["dug"]`) {
+		t.Fatalf("JSON do tile = %s, %v", out, err)
+	}
+}
+
+func TestDiffRegionWithoutTiletypes(t *testing.T) {
+	origin := util.NewDFCoord(0, 0, 0)
+	before := staticSource(diffTestChunk(origin, true, nil))
+	after := staticSource(diffTestChunk(origin, false, func(c *Chunk) {
+		c.SetTile(0, 0, &Tile{TileType: ttStoneFloor})
+	}))
+	diff, err := DiffRegion(before, after, origin, origin, DiffOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sem dicionário a escavação não é reconhecida, mas a revelação sim
+	if want := (DiffCounts{Revealed: 256}); diff.Total != want || diff.Tiles != nil {
+		t.Fatalf("DiffRegion = %+v, want %+v sem lista de tiles", diff, want)
+	}
+}
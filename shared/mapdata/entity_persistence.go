@@ -0,0 +1,223 @@
+package mapdata
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"log"
+	"reflect"
+	"slices"
+	"time"
+
+	"FortressVision/shared/pkg/dfproto"
+	"FortressVision/shared/util"
+)
+
+// Chaves de DictionaryModel onde ficam as entidades do mundo (GOB). Entram em
+// WorldDictionaries, então viajam também no .fvz.
+const (
+	buildingDefsKey = "BuildingDefList"
+	buildingsKey    = "Buildings"
+	unitsKey        = "Units"
+)
+
+// UnitSaveInterval é o intervalo mínimo entre gravações das unidades: elas andam o
+// tempo todo, e o modo offline só precisa da última posição conhecida.
+var UnitSaveInterval = 30 * time.Second
+
+// entityState acompanha o que mudou nas entidades desde a última gravação.
+type entityState struct {
+	defsDirty      bool
+	buildingsDirty bool
+	unitsDirty     bool
+	unitsSavedAt   time.Time
+}
+
+// SetBuildingDefs guarda as definições de construção (GetBuildingDefList) do mundo aberto.
+func (s *MapDataStore) SetBuildingDefs(defs []dfproto.BuildingDefinition) {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	if slices.Equal(s.BuildingDefs, defs) {
+		return
+	}
+	s.BuildingDefs = slices.Clone(defs)
+	s.entities.defsDirty = true
+}
+
+// ReplaceBuildings troca todas as construções pela lista dada e refaz o índice por tile.
+// Retorna false se nada mudou em relação ao que já estava no store.
+func (s *MapDataStore) ReplaceBuildings(list []*BuildingInstance) bool {
+	next := make(map[int32]*BuildingInstance, len(list))
+	for _, b := range list {
+		next[b.Index] = b
+	}
+
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	if reflect.DeepEqual(s.Buildings, next) {
+		return false
+	}
+	s.Buildings = next
+	s.BuildingLookup = make(map[util.DFCoord]int32)
+	for _, b := range list {
+		s.indexBuilding(b)
+	}
+	s.entities.buildingsDirty = true
+	return true
+}
+
+// ReplaceUnits troca todas as unidades pela lista dada (as que sumiram da lista são removidas).
+// Retorna false se nenhuma unidade mudou.
+func (s *MapDataStore) ReplaceUnits(list []*UnitInstance) bool {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	changed := len(list) != len(s.Units)
+	next := make(map[int32]*UnitInstance, len(list))
+	for _, u := range list {
+		if cur, ok := s.Units[u.ID]; !ok || *cur != *u {
+			changed = true
+		}
+		next[u.ID] = u
+	}
+	if !changed {
+		return false
+	}
+	s.Units = next
+	s.entities.unitsDirty = true
+	return true
+}
+
+// BuildingList retorna uma cópia das construções ordenada por índice.
+func (s *MapDataStore) BuildingList() []BuildingInstance {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	list := make([]BuildingInstance, 0, len(s.Buildings))
+	for _, b := range s.Buildings {
+		list = append(list, *b)
+	}
+	slices.SortFunc(list, func(a, b BuildingInstance) int { return int(a.Index) - int(b.Index) })
+	return list
+}
+
+// UnitList retorna uma cópia das unidades ordenada por ID.
+func (s *MapDataStore) UnitList() []UnitInstance {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	list := make([]UnitInstance, 0, len(s.Units))
+	for _, u := range s.Units {
+		list = append(list, *u)
+	}
+	slices.SortFunc(list, func(a, b UnitInstance) int { return int(a.ID) - int(b.ID) })
+	return list
+}
+
+// SaveEntities grava no banco as entidades alteradas, sem esperar UnitSaveInterval.
+func (s *MapDataStore) SaveEntities() error {
+	s.dbMu.Lock()
+	defer s.dbMu.Unlock()
+	return s.saveEntities(true)
+}
+
+// saveEntities grava definições, construções e unidades sujas. Sem force, as
+// unidades respeitam UnitSaveInterval. Exige dbMu travado.
+func (s *MapDataStore) saveEntities(force bool) error {
+	s.Mu.Lock()
+	repo := s.Repo
+	if repo == nil || s.ReadOnly {
+		s.Mu.Unlock()
+		return nil
+	}
+	blobs := make(map[string]any)
+	if s.entities.defsDirty {
+		blobs[buildingDefsKey] = s.BuildingDefs
+		s.entities.defsDirty = false
+	}
+	if s.entities.buildingsDirty {
+		list := make([]BuildingInstance, 0, len(s.Buildings))
+		for _, b := range s.Buildings {
+			list = append(list, *b)
+		}
+		blobs[buildingsKey] = list
+		s.entities.buildingsDirty = false
+	}
+	now := time.Now()
+	if s.entities.unitsDirty && (force || now.Sub(s.entities.unitsSavedAt) >= UnitSaveInterval) {
+		list := make([]UnitInstance, 0, len(s.Units))
+		for _, u := range s.Units {
+			list = append(list, *u)
+		}
+		blobs[unitsKey] = list
+		s.entities.unitsDirty = false
+		s.entities.unitsSavedAt = now
+	}
+	s.Mu.Unlock()
+
+	for key, value := range blobs {
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(value); err != nil {
+			s.markEntitiesDirty(key)
+			return fmt.Errorf("codificando %s: %w", key, err)
+		}
+		if err := repo.SaveDictionary(key, buf.Bytes()); err != nil {
+			log.Printf("[Persistence] ERRO ao gravar %s: %v", key, err)
+			s.markEntitiesDirty(key)
+			return err
+		}
+	}
+	return nil
+}
+
+// markEntitiesDirty devolve uma chave ao estado sujo depois de uma gravação com falha.
+func (s *MapDataStore) markEntitiesDirty(key string) {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	switch key {
+	case buildingDefsKey:
+		s.entities.defsDirty = true
+	case buildingsKey:
+		s.entities.buildingsDirty = true
+	case unitsKey:
+		s.entities.unitsDirty = true
+	}
+}
+
+// LoadEntities carrega do banco as definições, construções e unidades gravadas.
+// O que já estiver em RAM é mais novo que o banco e é mantido. Mundos gravados
+// antes das entidades serem persistidas simplesmente não têm as chaves.
+func (s *MapDataStore) LoadEntities() error {
+	if s.Repo == nil {
+		return fmt.Errorf("banco não inicializado")
+	}
+	var defs []dfproto.BuildingDefinition
+	var buildings []BuildingInstance
+	var units []UnitInstance
+	for key, target := range map[string]any{buildingDefsKey: &defs, buildingsKey: &buildings, unitsKey: &units} {
+		data, err := s.Repo.GetDictionary(key)
+		if err != nil || len(data) == 0 {
+			continue
+		}
+		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(target); err != nil {
+			return fmt.Errorf("decodificando %s: %w", key, err)
+		}
+	}
+
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	if len(s.BuildingDefs) == 0 {
+		s.BuildingDefs = defs
+	}
+	if len(s.Buildings) == 0 {
+		for i := range buildings {
+			b := &buildings[i]
+			s.Buildings[b.Index] = b
+			s.indexBuilding(b)
+		}
+	}
+	if len(s.Units) == 0 {
+		for i := range units {
+			s.Units[units[i].ID] = &units[i]
+		}
+	}
+	s.entities.unitsSavedAt = time.Now()
+	return nil
+}
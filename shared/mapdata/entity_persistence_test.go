@@ -0,0 +1,59 @@
+package mapdata
+
+import (
+	"testing"
+
+	"FortressVision/shared/pkg/dfproto"
+	"FortressVision/shared/util"
+)
+
+func TestEntitiesSurviveReopen(t *testing.T) {
+	chdirTemp(t)
+	s := NewMapDataStore()
+	if err := s.OpenInitialize("Entidades"); err != nil {
+		t.Fatal(err)
+	}
+
+	defs := []dfproto.BuildingDefinition{{BuildingType: dfproto.BuildingType{BuildingType: 3}, ID: "Chair", Name: "Cadeira"}}
+	s.SetBuildingDefs(defs)
+	chair := &BuildingInstance{Index: 7, BuildingType: &defs[0], MinPos: util.NewDFCoord(2, 2, 5), MaxPos: util.NewDFCoord(3, 2, 5)}
+	if !s.ReplaceBuildings([]*BuildingInstance{chair}) {
+		t.Fatal("ReplaceBuildings não detectou a construção nova")
+	}
+	urist := &UnitInstance{ID: 42, Name: "Urist", Race: dfproto.MatPair{MatType: 572}, Pos: util.NewDFCoord(10, 11, 5)}
+	if !s.ReplaceUnits([]*UnitInstance{urist}) {
+		t.Fatal("ReplaceUnits não detectou a unidade nova")
+	}
+	if s.ReplaceUnits([]*UnitInstance{{ID: 42, Name: "Urist", Race: dfproto.MatPair{MatType: 572}, Pos: util.NewDFCoord(10, 11, 5)}}) {
+		t.Fatal("ReplaceUnits acusou mudança numa unidade igual")
+	}
+
+	// Save grava construções na hora; unidades esperam UnitSaveInterval desde a abertura
+	if _, err := s.Save("Entidades"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := s.Repo.GetDictionary(unitsKey); len(data) != 0 {
+		t.Fatal("unidades gravadas antes de UnitSaveInterval")
+	}
+	if err := s.SaveEntities(); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	r := NewMapDataStore()
+	if err := r.OpenReadOnly("Entidades"); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if len(r.BuildingDefs) != 1 || r.BuildingDefs[0].ID != "Chair" {
+		t.Fatalf("BuildingDefs = %+v", r.BuildingDefs)
+	}
+	b := r.GetBuildingAt(util.NewDFCoord(3, 2, 5))
+	if b == nil || b.Index != 7 || b.BuildingType == nil || b.BuildingType.Name != "Cadeira" {
+		t.Fatalf("GetBuildingAt = %+v", b)
+	}
+	units := r.UnitList()
+	if len(units) != 1 || units[0] != *urist {
+		t.Fatalf("UnitList = %+v", units)
+	}
+}
@@ -0,0 +1,222 @@
+package mapdata
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"FortressVision/shared/util"
+)
+
+// ErrHistoryUnsupported indica um repositório que não guarda histórico de chunks.
+var ErrHistoryUnsupported = errors.New("repositório sem histórico de chunks")
+
+// TicksPerYear é a duração de um ano do DF em ticks (12 meses de 28 dias de 1200 ticks).
+const TicksPerYear = 12 * 28 * 1200
+
+// GameTick converte o calendário do DF (ano e tick dentro do ano) num tick absoluto.
+func GameTick(year, yearTick int32) int64 {
+	return int64(year)*TicksPerYear + int64(yearTick)
+}
+
+// HistoryPolicy configura o histórico de versões dos chunks.
+//
+// Cada vez que um chunk alterado é gravado no banco, uma cópia da versão gravada
+// entra no histórico. Se a última cópia daquele chunk tem menos de Interval, a
+// nova a substitui em vez de se somar a ela: o histórico fica com no máximo uma
+// cópia por chunk a cada Interval e a mais recente sempre bate com o mapa ao
+// vivo (Interval = 0 guarda toda mudança gravada). Cópias mais antigas que
+// MaxAge são descartadas, exceto a última de cada chunk antes do corte, que
+// continua descrevendo o mapa naquele instante.
+type HistoryPolicy struct {
+	Enabled  bool
+	Interval time.Duration
+	MaxAge   time.Duration // 0 = guarda para sempre
+}
+
+// HistoryPoint é um instante do histórico: um tick do DF ou, se Tick = 0, um horário.
+type HistoryPoint struct {
+	Tick int64
+	Time time.Time
+}
+
+// HistoryRange resume o histórico gravado de um mundo.
+type HistoryRange struct {
+	First, Last HistoryPoint
+	Snapshots   int64
+}
+
+// ChunkSnapshotModel é uma versão antiga de um chunk no banco.
+type ChunkSnapshotModel struct {
+	Key     int64 `gorm:"primaryKey;autoIncrement:false"` // Mesma chave de ChunkModel (ver chunkKey)
+	Time    int64 `gorm:"primaryKey;autoIncrement:false"` // Unix (nanossegundos) da gravação
+	Tick    int64 `gorm:"index"`                          // Tick do DF na gravação (0 = desconhecido)
+	X, Y, Z int32
+	Data    []byte // Mesmo formato de ChunkModel.Data
+	IsEmpty bool
+
+	// Replaces é o Time da versão do mesmo chunk que esta substitui (0 = nenhuma).
+	// Não é gravado: só diz a SaveSnapshots qual versão remover.
+	Replaces int64 `gorm:"-"`
+}
+
+// HistoryRepository é implementado pelos repositórios que guardam o histórico
+// de versões dos chunks (hoje SQLite e memória; o .fvlog não guarda).
+type HistoryRepository interface {
+	// SaveSnapshots grava as versões snaps, removendo na mesma transação as que
+	// elas substituem (ver ChunkSnapshotModel.Replaces).
+	SaveSnapshots(snaps []*ChunkSnapshotModel) error
+	// LoadRegionAt retorna, para cada chunk da caixa [min, max], a última versão
+	// gravada até at. Chunks sem versão até at ficam de fora.
+	LoadRegionAt(min, max util.DFCoord, at HistoryPoint) ([]*ChunkSnapshotModel, error)
+	HistoryRange() (HistoryRange, error)
+	// PruneHistory remove as versões anteriores a before que já foram substituídas
+	// por outra também anterior a before. Retorna quantas saíram.
+	PruneHistory(before time.Time) (int64, error)
+}
+
+// chunkHistory guarda o estado do histórico em um MapDataStore.
+type chunkHistory struct {
+	mu sync.Mutex
+	// last é a última cópia de cada chunk gravada nesta sessão
+	last map[util.DFCoord]historyWindow
+	// lastPrune limita a limpeza por MaxAge a uma vez por minuto
+	lastPrune time.Time
+}
+
+// historyWindow é a janela de Interval aberta pela última cópia de um chunk.
+type historyWindow struct {
+	start time.Time // Início da janela: uma cópia nova só se soma às anteriores depois de Interval
+	time  int64     // ChunkSnapshotModel.Time da cópia atual da janela
+}
+
+// reset esquece as cópias da sessão (o mundo aberto mudou).
+func (h *chunkHistory) reset() {
+	h.mu.Lock()
+	h.last = nil
+	h.mu.Unlock()
+}
+
+// SetGameTick registra o tick atual do DF, gravado junto com as próximas cópias do histórico.
+func (s *MapDataStore) SetGameTick(tick int64) {
+	s.gameTick.Store(tick)
+}
+
+// historyRepo devolve o repositório de histórico do mundo aberto.
+func (s *MapDataStore) historyRepo() (HistoryRepository, error) {
+	s.Mu.RLock()
+	repo := s.Repo
+	s.Mu.RUnlock()
+	if repo == nil {
+		return nil, ErrHistoryUnsupported
+	}
+	hist, ok := repo.(HistoryRepository)
+	if !ok {
+		return nil, ErrHistoryUnsupported
+	}
+	return hist, nil
+}
+
+// recordHistory copia para o histórico as versões recém-gravadas, conforme s.History.
+// Chamado logo depois que os modelos foram gravados no repositório.
+func (s *MapDataStore) recordHistory(models []*ChunkModel) {
+	if !s.History.Enabled || len(models) == 0 {
+		return
+	}
+	hist, err := s.historyRepo()
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	tick := s.gameTick.Load()
+	var snaps []*ChunkSnapshotModel
+
+	s.history.mu.Lock()
+	if s.history.last == nil {
+		s.history.last = make(map[util.DFCoord]historyWindow)
+	}
+	for _, m := range models {
+		origin := util.NewDFCoord(m.X, m.Y, m.Z)
+		snap := &ChunkSnapshotModel{
+			Key:     m.Key,
+			Time:    now.UnixNano(),
+			Tick:    tick,
+			X:       m.X,
+			Y:       m.Y,
+			Z:       m.Z,
+			Data:    m.Data,
+			IsEmpty: m.IsEmpty,
+		}
+		window, ok := s.history.last[origin]
+		if ok && now.Sub(window.start) < s.History.Interval {
+			// Dentro da janela: a cópia nova substitui a anterior, para a última
+			// mudança não se perder
+			snap.Replaces = window.time
+		} else {
+			window.start = now
+		}
+		window.time = snap.Time
+		s.history.last[origin] = window
+		snaps = append(snaps, snap)
+	}
+	prune := s.History.MaxAge > 0 && now.Sub(s.history.lastPrune) >= time.Minute
+	if prune {
+		s.history.lastPrune = now
+	}
+	s.history.mu.Unlock()
+
+	if len(snaps) > 0 {
+		if err := hist.SaveSnapshots(snaps); err != nil {
+			log.Printf("[History] ERRO ao gravar %d versões de chunks: %v", len(snaps), err)
+		}
+	}
+	if prune {
+		if n, err := hist.PruneHistory(now.Add(-s.History.MaxAge)); err != nil {
+			log.Printf("[History] ERRO ao limpar o histórico: %v", err)
+		} else if n > 0 {
+			log.Printf("[History] %d versões antigas removidas (mais velhas que %v)", n, s.History.MaxAge)
+		}
+	}
+}
+
+// RegionAt monta o mapa da caixa [min, max] (coordenadas de tile) como ele estava
+// em at. Os chunks retornados são cópias soltas: não entram no cache do store.
+// Chunks que ainda não existiam em at ficam fora do mapa retornado.
+func (s *MapDataStore) RegionAt(min, max util.DFCoord, at HistoryPoint) (map[util.DFCoord]*Chunk, error) {
+	hist, err := s.historyRepo()
+	if err != nil {
+		return nil, err
+	}
+	snaps, err := hist.LoadRegionAt(min.BlockCoord(), max.BlockCoord(), at)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := make(map[util.DFCoord]*Chunk, len(snaps))
+	for _, snap := range snaps {
+		origin := util.NewDFCoord(snap.X, snap.Y, snap.Z)
+		chunk := &Chunk{Origin: origin, IsEmpty: snap.IsEmpty, MTime: snap.Time}
+		if !snap.IsEmpty && len(snap.Data) > 0 {
+			decoded, err := DecodeChunk(origin, snap.Data)
+			if err != nil {
+				log.Printf("[History] ERRO ao decodificar versão de %v: %v", origin, err)
+				continue
+			}
+			chunk = decoded
+			chunk.MTime = snap.Time
+		}
+		chunks[origin] = chunk
+	}
+	return chunks, nil
+}
+
+// HistoryRange resume o histórico gravado do mundo aberto.
+func (s *MapDataStore) HistoryRange() (HistoryRange, error) {
+	hist, err := s.historyRepo()
+	if err != nil {
+		return HistoryRange{}, err
+	}
+	return hist.HistoryRange()
+}
@@ -0,0 +1,107 @@
+package mapdata
+
+import (
+	"testing"
+	"time"
+
+	"FortressVision/shared/util"
+)
+
+// testHistory grava três versões de um chunk e consulta o mapa em instantes diferentes.
+func testHistory(t *testing.T, repo ChunkRepository) {
+	t.Helper()
+	s := NewMapDataStore()
+	s.Repo = repo
+	s.History = HistoryPolicy{Enabled: true}
+	origin := util.NewDFCoord(32, 16, 4)
+
+	saveGen := func(gen int32, tick int64) {
+		t.Helper()
+		time.Sleep(time.Millisecond) // horários distintos entre as versões
+		s.SetGameTick(tick)
+		s.StoreSingleBlock(scanBlock(origin, gen))
+		if _, err := s.Save("Historia"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before := time.Now()
+	saveGen(1, 100)
+	mid := time.Now()
+	saveGen(2, 200)
+
+	tileAt := func(at HistoryPoint) int32 {
+		t.Helper()
+		chunks, err := s.RegionAt(origin, origin.Add(util.NewDFCoord(15, 15, 0)), at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunk, ok := chunks[origin]
+		if !ok {
+			return 0
+		}
+		return chunk.Tile(7, 7).TileType
+	}
+	cases := []struct {
+		at   HistoryPoint
+		want int32
+	}{
+		{HistoryPoint{Time: before}, 0},
+		{HistoryPoint{Time: mid}, 1},
+		{HistoryPoint{Time: time.Now()}, 2},
+		{HistoryPoint{Tick: 50}, 0},
+		{HistoryPoint{Tick: 150}, 1},
+		{HistoryPoint{Tick: 250}, 2},
+	}
+	for _, c := range cases {
+		if got := tileAt(c.at); got != c.want {
+			t.Errorf("RegionAt(%+v) = TileType %d, want %d", c.at, got, c.want)
+		}
+	}
+
+	// Dentro do intervalo mínimo cada nova versão substitui a última da janela:
+	// o histórico não cresce, mas termina no estado atual do mapa
+	s.History.Interval = time.Hour
+	saveGen(3, 300)
+	saveGen(4, 400)
+	hr, err := s.HistoryRange()
+	if err != nil || hr.Snapshots != 2 || hr.First.Tick != 100 || hr.Last.Tick != 400 {
+		t.Fatalf("HistoryRange = %+v, %v", hr, err)
+	}
+	if got := tileAt(HistoryPoint{Time: time.Now()}); got != 4 {
+		t.Fatalf("última mudança da janela perdida: TileType %d, want 4", got)
+	}
+	if got := tileAt(HistoryPoint{Tick: 450}); got != 4 {
+		t.Fatalf("RegionAt(tick 450) = TileType %d, want 4", got)
+	}
+
+	// A limpeza mantém a última versão antes do corte
+	hist := repo.(HistoryRepository)
+	if n, err := hist.PruneHistory(time.Now()); err != nil || n != 1 {
+		t.Fatalf("PruneHistory = %d, %v; want 1", n, err)
+	}
+	if got := tileAt(HistoryPoint{Time: time.Now()}); got != 4 {
+		t.Fatalf("versão mais recente perdida na limpeza: TileType %d", got)
+	}
+}
+
+func TestHistoryMemory(t *testing.T) {
+	testHistory(t, newMemoryRepository())
+}
+
+func TestHistorySQLite(t *testing.T) {
+	chdirTemp(t)
+	repo, err := openSQLiteRepository("Historia")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	testHistory(t, repo)
+}
+
+func TestHistoryUnsupported(t *testing.T) {
+	s := NewMapDataStore()
+	origin := util.NewDFCoord(0, 0, 0)
+	if _, err := s.RegionAt(origin, origin, HistoryPoint{Time: time.Now()}); err != ErrHistoryUnsupported {
+		t.Fatalf("RegionAt sem banco: err = %v, want ErrHistoryUnsupported", err)
+	}
+}
@@ -0,0 +1,191 @@
+package mapdata
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"FortressVision/shared/pkg/dfproto"
+	"FortressVision/shared/util"
+)
+
+// ErrMaintenanceUnsupported indica um repositório sem as operações de manutenção
+// (o repositório em RAM não as tem).
+var ErrMaintenanceUnsupported = errors.New("repositório sem suporte a manutenção")
+
+// MaintenanceRepository é implementado pelos repositórios que sabem se compactar.
+type MaintenanceRepository interface {
+	// Vacuum reconstrói o arquivo do banco, devolvendo ao disco o espaço livre.
+	Vacuum() error
+	// PurgeEmpty apaga os chunks marcados como vazios (ar). Retorna quantos saíram.
+	PurgeEmpty() (int64, error)
+}
+
+// maintenanceRepo devolve o repositório de manutenção do mundo aberto. Repo e
+// ReadOnly são lidos juntos sob Mu, já que SwitchWorld troca os dois.
+func (s *MapDataStore) maintenanceRepo() (MaintenanceRepository, error) {
+	s.Mu.RLock()
+	repo, readOnly := s.Repo, s.ReadOnly
+	s.Mu.RUnlock()
+	if readOnly {
+		return nil, ErrReadOnly
+	}
+	maint, ok := repo.(MaintenanceRepository)
+	if repo == nil || !ok {
+		return nil, ErrMaintenanceUnsupported
+	}
+	return maint, nil
+}
+
+// Vacuum compacta o banco do mundo aberto (ver MaintenanceRepository).
+func (s *MapDataStore) Vacuum() error {
+	maint, err := s.maintenanceRepo()
+	if err != nil {
+		return err
+	}
+	return maint.Vacuum()
+}
+
+// PurgeEmpty apaga do banco os chunks vazios e os esquece na RAM.
+func (s *MapDataStore) PurgeEmpty() (int64, error) {
+	maint, err := s.maintenanceRepo()
+	if err != nil {
+		return 0, err
+	}
+	n, err := maint.PurgeEmpty()
+	if err == nil {
+		s.DeleteChunksWhere(func(c *Chunk) bool { return c.IsEmpty })
+	}
+	if n > 0 {
+		s.dbMu.Lock()
+		if s.Repo != nil {
+			s.saveChunkCount() // A lista de mundos lê a contagem dos metadados
+		}
+		s.dbMu.Unlock()
+	}
+	return n, err
+}
+
+// ScanStoredChunks decodifica todos os chunks gravados no mundo aberto, um nível Z
+// de cada vez, e chama fn para cada um em ordem de (Z, X, Y). Chunks que não
+// puderam ser lidos chegam com chunk = nil e o erro. Os chunks decodificados não
+// entram no cache do store. Retorna quantos chunks foram visitados.
+func (s *MapDataStore) ScanStoredChunks(fn func(origin util.DFCoord, chunk *Chunk, err error)) (int, error) {
+	s.Mu.RLock()
+	repo := s.Repo
+	s.Mu.RUnlock()
+	if repo == nil {
+		return 0, fmt.Errorf("banco não inicializado")
+	}
+	return forEachStoredModel(repo, func(origin util.DFCoord, model *ChunkModel) error {
+		if model == nil {
+			fn(origin, nil, ErrChunkNotFound)
+			return nil
+		}
+		chunk, err := s.chunkFromModel(origin, model)
+		fn(origin, chunk, err)
+		return nil
+	})
+}
+
+// forEachStoredModel lê os chunks gravados no repositório com um LoadRegion por
+// nível Z e chama fn para cada um em ordem de (Z, X, Y), sem decodificar. Um chunk
+// listado em ChunkHeaders mas ausente na leitura chega com model = nil. Um erro
+// de fn interrompe a varredura. Retorna quantos chunks foram visitados.
+func forEachStoredModel(repo ChunkRepository, fn func(origin util.DFCoord, model *ChunkModel) error) (int, error) {
+	headers, err := repo.ChunkHeaders()
+	if err != nil {
+		return 0, err
+	}
+
+	levels := make(map[int32][]util.DFCoord)
+	for _, h := range headers {
+		levels[h.Origin.Z] = append(levels[h.Origin.Z], h.Origin)
+	}
+	zs := make([]int32, 0, len(levels))
+	for z := range levels {
+		zs = append(zs, z)
+	}
+	sort.Slice(zs, func(i, j int) bool { return zs[i] < zs[j] })
+
+	visited := 0
+	for _, z := range zs {
+		origins := levels[z]
+		sort.Slice(origins, func(i, j int) bool {
+			if origins[i].X != origins[j].X {
+				return origins[i].X < origins[j].X
+			}
+			return origins[i].Y < origins[j].Y
+		})
+		lo, hi := origins[0], origins[0]
+		for _, o := range origins {
+			lo = util.NewDFCoord(min(lo.X, o.X), min(lo.Y, o.Y), z)
+			hi = util.NewDFCoord(max(hi.X, o.X), max(hi.Y, o.Y), z)
+		}
+
+		models, err := repo.LoadRegion(lo, hi)
+		if err != nil {
+			return visited, fmt.Errorf("nível %d: %w", z, err)
+		}
+		byOrigin := make(map[util.DFCoord]*ChunkModel, len(models))
+		for _, m := range models {
+			byOrigin[util.NewDFCoord(m.X, m.Y, m.Z)] = m
+		}
+		for _, origin := range origins {
+			visited++
+			if err := fn(origin, byOrigin[origin]); err != nil {
+				return visited, err
+			}
+		}
+	}
+	return visited, nil
+}
+
+// StoredMaterialNames lê os nomes dos materiais do dicionário MaterialList gravado no banco.
+func (s *MapDataStore) StoredMaterialNames() (map[dfproto.MatPair]string, error) {
+	data, err := s.GetDictionary("MaterialList")
+	if err != nil {
+		return nil, err
+	}
+	var list dfproto.MaterialList
+	if err := list.Unmarshal(data); err != nil {
+		return nil, fmt.Errorf("MaterialList inválido: %w", err)
+	}
+	names := make(map[dfproto.MatPair]string, len(list.MaterialList))
+	for _, mat := range list.MaterialList {
+		name := mat.Name
+		if name == "" {
+			name = mat.ID
+		}
+		names[mat.MatPair] = name
+	}
+	return names, nil
+}
+
+// MigrateWorld aplica as migrações de formato pendentes ao banco (.fv) de um mundo,
+// sem abrir um MapDataStore. Retorna a versão encontrada e a versão final.
+func MigrateWorld(worldName string) (from, to int, err error) {
+	if !fileExists(WorldPath(worldName)) {
+		return 0, 0, fmt.Errorf("mundo %q não tem banco SQLite em %s", worldName, SavesDir)
+	}
+	db, err := openDB(WorldPath(worldName))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer closeDB(db)
+
+	from, err = storedFormatVersion(db)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := runMigrations(db, worldName); err != nil {
+		return from, from, err
+	}
+	if err := migrateSchema(db); err != nil {
+		return from, from, err
+	}
+	if err := db.Save(&WorldMetadata{Key: formatVersionKey, Value: fmt.Sprint(CurrentFormatVersion)}).Error; err != nil {
+		return from, from, err
+	}
+	return from, CurrentFormatVersion, nil
+}
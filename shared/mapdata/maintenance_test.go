@@ -0,0 +1,128 @@
+package mapdata
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"FortressVision/shared/util"
+)
+
+func TestScanAndPurgeEmpty(t *testing.T) {
+	chdirTemp(t)
+	s := NewMapDataStore()
+	if err := s.OpenInitialize("Manutencao"); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	solid := []util.DFCoord{util.NewDFCoord(16, 0, 1), util.NewDFCoord(0, 16, 0), util.NewDFCoord(0, 0, 1)}
+	for i, origin := range solid {
+		s.StoreSingleBlock(scanBlock(origin, int32(i+1)))
+	}
+	s.MarkAsEmpty(util.NewDFCoord(32, 32, 2))
+	if _, err := s.Save("Manutencao"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Um chunk com blob ilegível aparece como falha, sem interromper a varredura
+	bad := solid[0]
+	db := s.Repo.(*sqliteRepository).db
+	if err := db.Model(&ChunkModel{}).Where("key = ?", chunkKey(bad)).Update("data", []byte("lixo")).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var seen []util.DFCoord
+	var failed []util.DFCoord
+	n, err := s.ScanStoredChunks(func(origin util.DFCoord, chunk *Chunk, err error) {
+		seen = append(seen, origin)
+		if err != nil {
+			failed = append(failed, origin)
+		}
+	})
+	if err != nil || n != 4 {
+		t.Fatalf("ScanStoredChunks = %d, %v", n, err)
+	}
+	want := []util.DFCoord{util.NewDFCoord(0, 16, 0), util.NewDFCoord(0, 0, 1), util.NewDFCoord(16, 0, 1), util.NewDFCoord(32, 32, 2)}
+	for i := range want {
+		if seen[i] != want[i] {
+			t.Fatalf("ordem da varredura = %v, want %v", seen, want)
+		}
+	}
+	if len(failed) != 1 || failed[0] != bad {
+		t.Fatalf("falhas = %v, want [%v]", failed, bad)
+	}
+
+	purged, err := s.PurgeEmpty()
+	if err != nil || purged != 1 {
+		t.Fatalf("PurgeEmpty = %d, %v", purged, err)
+	}
+	if count, _ := s.Repo.ChunkCount(); count != 3 {
+		t.Fatalf("chunks após PurgeEmpty = %d, want 3", count)
+	}
+	if err := s.Vacuum(); err != nil {
+		t.Fatalf("Vacuum: %v", err)
+	}
+}
+
+func TestMigrateWorld(t *testing.T) {
+	chdirTemp(t)
+	if err := os.MkdirAll(SavesDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// Banco v6: chave textual "X_Y_Z"
+	db, err := openDB(WorldPath("Antigo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&legacyChunkModel{}, &WorldMetadata{}); err != nil {
+		t.Fatal(err)
+	}
+	db.Create(&legacyChunkModel{ID: "16_0_2", X: 16, Y: 0, Z: 2, IsEmpty: true, MTime: 1})
+	db.Save(&WorldMetadata{Key: formatVersionKey, Value: "6"})
+	closeDB(db)
+
+	from, to, err := MigrateWorld("Antigo")
+	if err != nil || from != 6 || to != CurrentFormatVersion {
+		t.Fatalf("MigrateWorld = %d, %d, %v", from, to, err)
+	}
+	info, err := ReadWorldInfo("Antigo")
+	if err != nil || info.FormatVersion != CurrentFormatVersion || info.ChunkCount != 1 {
+		t.Fatalf("ReadWorldInfo após migrar = %+v, %v", info, err)
+	}
+	// Já atualizado: nada a fazer
+	if from, to, err := MigrateWorld("Antigo"); err != nil || from != to {
+		t.Fatalf("MigrateWorld repetido = %d, %d, %v", from, to, err)
+	}
+	if _, _, err := MigrateWorld("Inexistente"); err == nil {
+		t.Fatal("MigrateWorld num mundo sem banco deveria falhar")
+	}
+}
+
+// TestMaintenanceOnReadOnlyWorld roda a manutenção enquanto outro goroutine abre
+// um mundo arquivado no mesmo store. Deve ser rodado com -race.
+func TestMaintenanceOnReadOnlyWorld(t *testing.T) {
+	chdirTemp(t)
+	archived := NewMapDataStore()
+	if err := archived.OpenInitialize("Arquivo"); err != nil {
+		t.Fatal(err)
+	}
+	archived.Close()
+
+	s := NewMapDataStore()
+	s.Repo = newMemoryRepository()
+	opened := make(chan error)
+	go func() { opened <- s.OpenReadOnly("Arquivo") }()
+	for i := 0; i < 100; i++ {
+		if err := s.Vacuum(); !errors.Is(err, ErrMaintenanceUnsupported) && !errors.Is(err, ErrReadOnly) {
+			t.Fatalf("Vacuum durante a abertura: %v", err)
+		}
+	}
+	if err := <-opened; err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, err := s.PurgeEmpty(); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("PurgeEmpty em mundo arquivado: err = %v, want ErrReadOnly", err)
+	}
+}
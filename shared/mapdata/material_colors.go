@@ -0,0 +1,53 @@
+package mapdata
+
+import (
+	"FortressVision/shared/pkg/dfproto"
+	"image/color"
+)
+
+// opaque monta uma cor RGB sem transparência.
+func opaque(r, g, b uint8) color.RGBA {
+	return color.RGBA{R: r, G: g, B: b, A: 255}
+}
+
+// GetTileColor retorna a cor para um tile específico.
+func (s *MaterialStore) GetTileColor(tile *Tile) color.RGBA {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// 1. Tentar cor do material específico (se houver no cache enviada pelo DFHack)
+	if c, ok := s.Colors[tile.Material]; ok {
+		return c
+	}
+
+	// 2. Fallback baseado no MaterialCategory e cores padrão do DF
+	// Isso usa a tabela DFColorList portada do Armok Vision.
+	var colorToken string
+	switch tile.MaterialCategory() {
+	case dfproto.TilematStone:
+		colorToken = "GRAY"
+	case dfproto.TilematSoil:
+		colorToken = "DARK_TAN"
+	case dfproto.TilematGrassLight, dfproto.TilematGrassDark, dfproto.TilematGrassDead, dfproto.TilematGrassDry:
+		colorToken = "GREEN"
+	case dfproto.TilematTreeMaterial, dfproto.TilematPlant, dfproto.TilematMushroom:
+		colorToken = "BROWN"
+	case dfproto.TilematMineral:
+		colorToken = "SILVER"
+	case dfproto.TilematLavaStone, dfproto.TilematMagma:
+		colorToken = "RED"
+	case dfproto.TilematFrozenLiquid:
+		colorToken = "PALE_BLUE"
+	case dfproto.TilematHFS, dfproto.TilematConstruction:
+		colorToken = "WHITE"
+	default:
+		colorToken = "GRAY"
+	}
+
+	r, g, b, ok := GetDFColor(colorToken)
+	if ok {
+		return opaque(r, g, b)
+	}
+
+	return opaque(150, 150, 150) // Fallback absoluto
+}
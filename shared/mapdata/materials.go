@@ -3,10 +3,10 @@ package mapdata
 import (
 	"FortressVision/shared/pkg/dfproto"
 	"fmt"
+	"image/color"
 	"log"
 	"sync"
 
-	rl "github.com/gen2brain/raylib-go/raylib"
 	"gorm.io/gorm"
 )
 
@@ -14,8 +14,9 @@ import (
 type MaterialStore struct {
 	mu sync.RWMutex
 
-	// Cache de cores por par de material (MatType, MatIndex)
-	Colors map[dfproto.MatPair]rl.Color
+	// Cache de cores por par de material (MatType, MatIndex). color.RGBA é o
+	// mesmo tipo que rl.Color, então o renderer usa as cores sem conversão.
+	Colors map[dfproto.MatPair]color.RGBA
 
 	// Cache de nomes legíveis (ex: "Granite", "Iron Ore")
 	Names map[dfproto.MatPair]string
@@ -32,7 +33,7 @@ type MaterialStore struct {
 
 func NewMaterialStore() *MaterialStore {
 	return &MaterialStore{
-		Colors:     make(map[dfproto.MatPair]rl.Color),
+		Colors:     make(map[dfproto.MatPair]color.RGBA),
 		Names:      make(map[dfproto.MatPair]string),
 		Tokens:     make(map[dfproto.MatPair]string),
 		TextureMap: make(map[dfproto.TiletypeMaterial]string),
@@ -64,48 +65,6 @@ func (s *MaterialStore) GetTextureName(mat dfproto.TiletypeMaterial) string {
 	return ""
 }
 
-// GetTileColor retorna a cor para um tile específico.
-func (s *MaterialStore) GetTileColor(tile *Tile) rl.Color {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	// 1. Tentar cor do material específico (se houver no cache enviada pelo DFHack)
-	if color, ok := s.Colors[tile.Material]; ok {
-		return color
-	}
-
-	// 2. Fallback baseado no MaterialCategory e cores padrão do DF
-	// Isso usa a tabela DFColorList portada do Armok Vision.
-	var colorToken string
-	switch tile.MaterialCategory() {
-	case dfproto.TilematStone:
-		colorToken = "GRAY"
-	case dfproto.TilematSoil:
-		colorToken = "DARK_TAN"
-	case dfproto.TilematGrassLight, dfproto.TilematGrassDark, dfproto.TilematGrassDead, dfproto.TilematGrassDry:
-		colorToken = "GREEN"
-	case dfproto.TilematTreeMaterial, dfproto.TilematPlant, dfproto.TilematMushroom:
-		colorToken = "BROWN"
-	case dfproto.TilematMineral:
-		colorToken = "SILVER"
-	case dfproto.TilematLavaStone, dfproto.TilematMagma:
-		colorToken = "RED"
-	case dfproto.TilematFrozenLiquid:
-		colorToken = "PALE_BLUE"
-	case dfproto.TilematHFS, dfproto.TilematConstruction:
-		colorToken = "WHITE"
-	default:
-		colorToken = "GRAY"
-	}
-
-	r, g, b, ok := GetDFColor(colorToken)
-	if ok {
-		return rl.NewColor(r, g, b, 255)
-	}
-
-	return rl.NewColor(150, 150, 150, 255) // Fallback absoluto
-}
-
 // LoadFromDB carrega todos os materiais salvos no SQLite para o cache.
 func (s *MaterialStore) LoadFromDB() error {
 	if s.DB == nil {
@@ -124,7 +83,7 @@ func (s *MaterialStore) LoadFromDB() error {
 			MatType:  m.MatType,
 			MatIndex: m.MatIndex,
 		}
-		s.Colors[pair] = rl.NewColor(m.R, m.G, m.B, 255)
+		s.Colors[pair] = opaque(m.R, m.G, m.B)
 	}
 	log.Printf("[MaterialStore] %d materiais carregados do SQLite.", len(models))
 	return nil
@@ -140,13 +99,8 @@ func (s *MaterialStore) UpdateMaterials(list *dfproto.MaterialList) {
 	var models []MaterialModel
 	for _, mat := range list.MaterialList {
 		pair := mat.MatPair
-		color := rl.NewColor(
-			uint8(mat.StateColor.Red),
-			uint8(mat.StateColor.Green),
-			uint8(mat.StateColor.Blue),
-			255,
-		)
-		s.Colors[pair] = color
+		c := opaque(uint8(mat.StateColor.Red), uint8(mat.StateColor.Green), uint8(mat.StateColor.Blue))
+		s.Colors[pair] = c
 		s.Names[pair] = mat.Name
 		s.Tokens[pair] = mat.ID
 
@@ -154,9 +108,9 @@ func (s *MaterialStore) UpdateMaterials(list *dfproto.MaterialList) {
 			models = append(models, MaterialModel{
 				MatType:  pair.MatType,
 				MatIndex: pair.MatIndex,
-				R:        color.R,
-				G:        color.G,
-				B:        color.B,
+				R:        c.R,
+				G:        c.G,
+				B:        c.B,
 			})
 		}
 	}
@@ -0,0 +1,368 @@
+package mapdata
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"FortressVision/shared/util"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrFormatTooNew indica um banco gravado por uma versão mais nova do FortressVision.
+// Esses bancos nunca são abertos nem resetados: o usuário precisa atualizar o binário.
+var ErrFormatTooNew = errors.New("formato do banco mais novo que o suportado")
+
+const (
+	// formatVersionKey é a chave em WorldMetadata com a versão do formato do banco.
+	formatVersionKey = "FormatVersion"
+	// migrationCursorKey guarda o último chunk (ID antigo) já migrado pelo passo em andamento.
+	migrationCursorKey = "MigrationCursor"
+	// migrationBatchSize é a quantidade de chunks regravados por transaction.
+	migrationBatchSize = 500
+)
+
+// migration é um passo de atualização do formato do banco, de To-1 para To.
+// Os passos precisam ser idempotentes: se o processo cair no meio, o passo roda
+// de novo na próxima abertura, continuando do cursor salvo em WorldMetadata.
+type migration struct {
+	To   int
+	Name string
+	Run  func(m *migrator) error
+}
+
+// migrations lista os passos em ordem. Bancos sem FormatVersion (anteriores ao
+// versionamento) começam da versão 0 e passam por todos.
+var migrations = []migration{
+	{To: 4, Name: "colunas m_time e is_empty dos chunks", Run: migrateChunkColumns},
+	{To: 5, Name: "recodificar chunks antigos (apenas tiles) no formato chunkData", Run: migrateLegacyChunkBlobs},
+	{To: 6, Name: "recodificar tiles no layout compacto (TileBlock)", Run: migrateCompactTiles},
+	{To: 7, Name: "chave inteira Morton na tabela de chunks", Run: migrateChunkKeys},
+}
+
+// legacyChunkModel é o esquema da tabela de chunks até a v6, com chave textual "X_Y_Z".
+// Os passos anteriores à v7 operam sobre ele.
+type legacyChunkModel struct {
+	ID        string `gorm:"primaryKey"`
+	X, Y, Z   int32  `gorm:"index:idx_pos"`
+	Data      []byte
+	MTime     int64
+	IsEmpty   bool
+	UpdatedAt time.Time
+}
+
+func (legacyChunkModel) TableName() string { return "chunk_models" }
+
+// legacyChunkTable guarda a tabela antiga enquanto a v7 copia os chunks para a nova.
+const legacyChunkTable = "chunk_models_v6"
+
+// migrator carrega o estado de uma migração em andamento.
+type migrator struct {
+	db        *gorm.DB
+	worldName string
+	lastLog   time.Time
+}
+
+// runMigrations lê a versão gravada no banco e aplica, em ordem, os passos pendentes.
+// Bancos novos (sem tabela de chunks) apenas recebem a versão atual.
+func runMigrations(db *gorm.DB, worldName string) error {
+	version, err := storedFormatVersion(db)
+	if err != nil {
+		return err
+	}
+	if version > CurrentFormatVersion {
+		return fmt.Errorf("%w: mundo %s está na versão %d, este binário suporta até a %d",
+			ErrFormatTooNew, worldName, version, CurrentFormatVersion)
+	}
+
+	if !db.Migrator().HasTable(&ChunkModel{}) {
+		return nil // Banco vazio: AutoMigrate cria o esquema já no formato atual
+	}
+	if err := db.AutoMigrate(&WorldMetadata{}); err != nil {
+		return err
+	}
+
+	m := &migrator{db: db, worldName: worldName}
+	for _, step := range migrations {
+		if step.To <= version {
+			continue
+		}
+		log.Printf("[Migration] %s: v%d -> v%d (%s)...", worldName, version, step.To, step.Name)
+		start := time.Now()
+		if err := step.Run(m); err != nil {
+			return fmt.Errorf("migração v%d (%s) falhou: %w", step.To, step.Name, err)
+		}
+
+		// A versão só avança depois que o passo inteiro terminou
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(&WorldMetadata{Key: formatVersionKey, Value: strconv.Itoa(step.To)}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&WorldMetadata{}, "key = ?", migrationCursorKey).Error
+		})
+		if err != nil {
+			return err
+		}
+		version = step.To
+		log.Printf("[Migration] %s: v%d concluída em %v", worldName, step.To, time.Since(start).Round(time.Millisecond))
+	}
+	return nil
+}
+
+// storedFormatVersion lê FormatVersion de WorldMetadata (0 se ausente).
+func storedFormatVersion(db *gorm.DB) (int, error) {
+	if !db.Migrator().HasTable(&WorldMetadata{}) {
+		return 0, nil
+	}
+	var meta WorldMetadata
+	err := db.Where("key = ?", formatVersionKey).Limit(1).Find(&meta).Error
+	if err != nil {
+		return 0, err
+	}
+	if meta.Value == "" {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(meta.Value)
+	if err != nil {
+		return 0, fmt.Errorf("FormatVersion inválido no banco: %q", meta.Value)
+	}
+	return version, nil
+}
+
+// cursor retorna o último ID processado pelo passo em andamento ("" se nenhum).
+func (m *migrator) cursor() string {
+	var meta WorldMetadata
+	m.db.Where("key = ?", migrationCursorKey).Limit(1).Find(&meta)
+	return meta.Value
+}
+
+// progress registra o andamento no log, no máximo uma vez por segundo.
+func (m *migrator) progress(done, total int64) {
+	if done < total && time.Since(m.lastLog) < time.Second {
+		return
+	}
+	m.lastLog = time.Now()
+	pct := 100.0
+	if total > 0 {
+		pct = float64(done) * 100 / float64(total)
+	}
+	log.Printf("[Migration] %s: %d/%d chunks (%.1f%%)", m.worldName, done, total, pct)
+}
+
+// migrateChunkColumns adiciona as colunas m_time e is_empty a bancos anteriores a elas.
+// is_empty é preenchida a partir do blob: chunks sem dados são céu/ar puro.
+func migrateChunkColumns(m *migrator) error {
+	mig := m.db.Migrator()
+	if !mig.HasColumn(&legacyChunkModel{}, "MTime") {
+		if err := mig.AddColumn(&legacyChunkModel{}, "MTime"); err != nil {
+			return err
+		}
+	}
+	if !mig.HasColumn(&legacyChunkModel{}, "IsEmpty") {
+		if err := mig.AddColumn(&legacyChunkModel{}, "IsEmpty"); err != nil {
+			return err
+		}
+		return m.db.Exec("UPDATE chunk_models SET is_empty = (data IS NULL OR length(data) = 0)").Error
+	}
+	return nil
+}
+
+// migrateLegacyChunkBlobs regrava no formato chunkData os chunks que ainda guardam
+// apenas a matriz de tiles.
+func migrateLegacyChunkBlobs(m *migrator) error {
+	return m.rewriteChunkBlobs(upgradeLegacyChunkBlob)
+}
+
+// migrateCompactTiles regrava os chunks que ainda usam [16][16]*Tile no layout TileBlock.
+func migrateCompactTiles(m *migrator) error {
+	return m.rewriteChunkBlobs(upgradeCompactTiles)
+}
+
+// rewriteChunkBlobs passa todos os chunks não vazios por upgrade, em lotes com cursor.
+// Chunks ilegíveis são removidos para serem buscados de novo no DF.
+// Opera sobre o esquema anterior à v7 (legacyChunkModel).
+func (m *migrator) rewriteChunkBlobs(upgrade func(data []byte) ([]byte, bool, error)) error {
+	var total int64
+	if err := m.db.Model(&legacyChunkModel{}).Where("is_empty = ?", false).Count(&total).Error; err != nil {
+		return err
+	}
+
+	cursor := m.cursor()
+	var done int64
+	if cursor != "" {
+		m.db.Model(&legacyChunkModel{}).Where("is_empty = ? AND id <= ?", false, cursor).Count(&done)
+		log.Printf("[Migration] %s: retomando a partir do chunk %s", m.worldName, cursor)
+	}
+
+	var converted, dropped int
+	for {
+		var batch []legacyChunkModel
+		err := m.db.Where("is_empty = ? AND id > ?", false, cursor).Order("id").Limit(migrationBatchSize).Find(&batch).Error
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		err = m.db.Transaction(func(tx *gorm.DB) error {
+			for _, model := range batch {
+				data, changed, err := upgrade(model.Data)
+				if err != nil {
+					log.Printf("[Migration] Chunk %s ilegível (%v). Removendo do cache.", model.ID, err)
+					if err := tx.Delete(&legacyChunkModel{}, "id = ?", model.ID).Error; err != nil {
+						return err
+					}
+					dropped++
+					continue
+				}
+				if !changed {
+					continue
+				}
+				if err := tx.Model(&legacyChunkModel{}).Where("id = ?", model.ID).Update("data", data).Error; err != nil {
+					return err
+				}
+				converted++
+			}
+			// O cursor é gravado na mesma transaction dos chunks: o passo retoma daqui
+			return tx.Save(&WorldMetadata{Key: migrationCursorKey, Value: batch[len(batch)-1].ID}).Error
+		})
+		if err != nil {
+			return err
+		}
+
+		cursor = batch[len(batch)-1].ID
+		done += int64(len(batch))
+		m.progress(done, total)
+	}
+
+	if converted > 0 || dropped > 0 {
+		log.Printf("[Migration] %s: %d chunks recodificados, %d removidos", m.worldName, converted, dropped)
+	}
+	return nil
+}
+
+// migrateChunkKeys troca a chave textual "X_Y_Z" pela chave inteira de chunkKey.
+// A tabela antiga é renomeada e a nova criada na mesma transaction (um banco nunca
+// fica sem tabela de chunks); depois os chunks são copiados em lotes com cursor e
+// a tabela antiga é removida no final.
+func migrateChunkKeys(m *migrator) error {
+	if !m.db.Migrator().HasTable(legacyChunkTable) {
+		err := m.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Migrator().RenameTable("chunk_models", legacyChunkTable); err != nil {
+				return err
+			}
+			return tx.Migrator().CreateTable(&ChunkModel{})
+		})
+		if err != nil {
+			return err
+		}
+	}
+	legacy := m.db.Table(legacyChunkTable).Session(&gorm.Session{})
+
+	var total int64
+	if err := legacy.Count(&total).Error; err != nil {
+		return err
+	}
+
+	cursor := m.cursor()
+	var done int64
+	if cursor != "" {
+		legacy.Where("id <= ?", cursor).Count(&done)
+		log.Printf("[Migration] %s: retomando a partir do chunk %s", m.worldName, cursor)
+	}
+
+	for {
+		var batch []legacyChunkModel
+		err := legacy.Where("id > ?", cursor).Order("id").Limit(migrationBatchSize).Find(&batch).Error
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		models := make([]ChunkModel, len(batch))
+		for i, old := range batch {
+			models[i] = ChunkModel{
+				Key:       chunkKey(util.NewDFCoord(old.X, old.Y, old.Z)),
+				X:         old.X,
+				Y:         old.Y,
+				Z:         old.Z,
+				Data:      old.Data,
+				MTime:     old.MTime,
+				IsEmpty:   old.IsEmpty,
+				UpdatedAt: old.UpdatedAt,
+			}
+		}
+		err = m.db.Transaction(func(tx *gorm.DB) error {
+			// Um lote repetido após uma queda sobrescreve as cópias anteriores
+			if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&models).Error; err != nil {
+				return err
+			}
+			return tx.Save(&WorldMetadata{Key: migrationCursorKey, Value: batch[len(batch)-1].ID}).Error
+		})
+		if err != nil {
+			return err
+		}
+
+		cursor = batch[len(batch)-1].ID
+		done += int64(len(batch))
+		m.progress(done, total)
+	}
+
+	return m.db.Migrator().DropTable(legacyChunkTable)
+}
+
+// upgradeLegacyChunkBlob converte um blob antigo (apenas tiles) para chunkData.
+// Blobs já no formato atual (ou vazios) voltam inalterados com changed=false.
+func upgradeLegacyChunkBlob(data []byte) (out []byte, changed bool, err error) {
+	if len(data) == 0 {
+		return data, false, nil
+	}
+
+	if _, err := DecodeChunk(util.DFCoord{}, data); err == nil {
+		return data, false, nil
+	}
+
+	var tiles [16][16]*Tile
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&tiles); err != nil {
+		return nil, false, err
+	}
+
+	out, err = EncodeChunk(&Chunk{Tiles: tileBlockFromLegacy(&tiles)}, LayersAll)
+	if err != nil {
+		return nil, false, err
+	}
+	return out, true, nil
+}
+
+// upgradeCompactTiles recodifica um blob chunkData cujos tiles ainda estão no layout antigo.
+func upgradeCompactTiles(data []byte) (out []byte, changed bool, err error) {
+	if len(data) == 0 {
+		return data, false, nil
+	}
+	cData, err := decodeChunkData(data)
+	if err != nil {
+		return nil, false, err
+	}
+	if cData.Block != nil || cData.Tiles == nil {
+		return data, false, nil
+	}
+
+	chunk, err := DecodeChunk(util.DFCoord{}, data)
+	if err != nil {
+		return nil, false, err
+	}
+	out, err = EncodeChunk(chunk, LayersAll)
+	if err != nil {
+		return nil, false, err
+	}
+	return out, true, nil
+}
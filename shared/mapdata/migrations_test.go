@@ -0,0 +1,167 @@
+package mapdata
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"testing"
+
+	"FortressVision/shared/util"
+)
+
+// writeLegacyWorld grava em saves/<worldName>.fv um banco no formato v6 (chave
+// textual), com n chunks vazios numa fileira ao longo de X.
+func writeLegacyWorld(t *testing.T, worldName string, n int) {
+	t.Helper()
+	if err := os.MkdirAll(SavesDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	db, err := openDB(WorldPath(worldName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeDB(db)
+	if err := db.AutoMigrate(&legacyChunkModel{}, &WorldMetadata{}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		x := int32(i) * 16
+		model := legacyChunkModel{ID: legacyID(x, 0, 0), X: x, MTime: int64(i + 1), IsEmpty: true}
+		if err := db.Create(&model).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Save(&WorldMetadata{Key: formatVersionKey, Value: "6"}).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// legacyID monta a chave textual dos chunks até a v6.
+func legacyID(x, y, z int32) string {
+	return fmt.Sprintf("%d_%d_%d", x, y, z)
+}
+
+func TestMigrationRefusesNewerFormat(t *testing.T) {
+	chdirTemp(t)
+	repo, err := openSQLiteRepository("Futuro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.SaveMetadata(formatVersionKey, strconv.Itoa(CurrentFormatVersion+1))
+	repo.Close()
+	before, _ := os.ReadFile(WorldPath("Futuro"))
+
+	if _, err := openSQLiteRepository("Futuro"); !errors.Is(err, ErrFormatTooNew) {
+		t.Errorf("openSQLiteRepository: err = %v, want ErrFormatTooNew", err)
+	}
+	if _, err := openSQLiteReadOnly("Futuro"); !errors.Is(err, ErrFormatTooNew) {
+		t.Errorf("openSQLiteReadOnly: err = %v, want ErrFormatTooNew", err)
+	}
+	if after, _ := os.ReadFile(WorldPath("Futuro")); !bytes.Equal(before, after) {
+		t.Errorf("banco mais novo foi alterado ao ser recusado")
+	}
+}
+
+func TestMigrationResumesFromCursor(t *testing.T) {
+	chdirTemp(t)
+	const n = 10
+	writeLegacyWorld(t, "Retomada", n)
+
+	// Simula uma queda no meio da v7: tabela nova criada, metade dos chunks copiada
+	// (com MTime marcado) e o cursor gravado no último deles
+	db, err := openDB(WorldPath("Retomada"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &migrator{db: db, worldName: "Retomada"}
+	if err := db.Migrator().RenameTable("chunk_models", legacyChunkTable); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Migrator().CreateTable(&ChunkModel{}); err != nil {
+		t.Fatal(err)
+	}
+	var legacy []legacyChunkModel
+	db.Table(legacyChunkTable).Order("id").Find(&legacy)
+	for _, old := range legacy[:n/2] {
+		origin := util.NewDFCoord(old.X, old.Y, old.Z)
+		db.Create(&ChunkModel{Key: chunkKey(origin), X: old.X, Y: old.Y, Z: old.Z, MTime: -1, IsEmpty: true})
+	}
+	db.Save(&WorldMetadata{Key: migrationCursorKey, Value: legacy[n/2-1].ID})
+	if got := m.cursor(); got != legacy[n/2-1].ID {
+		t.Fatalf("cursor = %q, want %q", got, legacy[n/2-1].ID)
+	}
+	closeDB(db)
+
+	repo, err := openSQLiteRepository("Retomada")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	if count, _ := repo.ChunkCount(); count != n {
+		t.Fatalf("%d chunks depois da migração, want %d", count, n)
+	}
+	for i, old := range legacy {
+		model, err := repo.LoadChunk(util.NewDFCoord(old.X, old.Y, old.Z))
+		if err != nil {
+			t.Fatalf("chunk %s perdido: %v", old.ID, err)
+		}
+		// Os lotes antes do cursor não são copiados de novo
+		want := old.MTime
+		if i < n/2 {
+			want = -1
+		}
+		if model.MTime != want {
+			t.Errorf("chunk %s com MTime %d, want %d", old.ID, model.MTime, want)
+		}
+	}
+	if repo.db.Migrator().HasTable(legacyChunkTable) {
+		t.Errorf("tabela %s não foi removida", legacyChunkTable)
+	}
+	if _, err := repo.GetMetadata(migrationCursorKey); err == nil {
+		t.Errorf("cursor da migração continua gravado")
+	}
+	if v, _ := repo.GetMetadata(formatVersionKey); v != strconv.Itoa(CurrentFormatVersion) {
+		t.Errorf("FormatVersion = %q, want %d", v, CurrentFormatVersion)
+	}
+}
+
+func TestReadOnlyOpenLeavesFileUntouched(t *testing.T) {
+	chdirTemp(t)
+	writeLegacyWorld(t, "Arquivo", 3)
+	path := WorldPath("Arquivo")
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewMapDataStore()
+	if err := s.OpenReadOnly("Arquivo"); err != nil {
+		t.Fatal(err)
+	}
+	// O formato antigo é lido pela cópia migrada
+	if _, err := s.LoadChunk(util.NewDFCoord(16, 0, 0)); err != nil {
+		t.Errorf("chunk do mundo arquivado ilegível: %v", err)
+	}
+	if _, err := ReadWorldInfo("Arquivo"); err != nil {
+		t.Errorf("ReadWorldInfo: %v", err)
+	}
+	tmpPath := s.Repo.(*sqliteRepository).tempPath
+	if tmpPath == "" {
+		t.Fatalf("banco v6 aberto direto, sem cópia migrada")
+	}
+	s.Close()
+
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Errorf("abrir só para leitura alterou %s", path)
+	}
+	if _, err := os.Stat(tmpPath); !os.IsNotExist(err) {
+		t.Errorf("cópia temporária %s não foi apagada (err %v)", tmpPath, err)
+	}
+}
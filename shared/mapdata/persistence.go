@@ -3,23 +3,20 @@ package mapdata
 import (
 	"FortressVision/shared/pkg/dfproto"
 	"FortressVision/shared/util"
-	"bytes"
-	"encoding/gob"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"log"
-	"os"
-	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
 	"time"
-
-	"gorm.io/driver/sqlite"
-	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
 )
 
 // ChunkModel representa o esquema do banco de dados para um chunk
 type ChunkModel struct {
-	ID        string    `gorm:"primaryKey"` // Coordenada formatada "X_Y_Z"
-	X, Y, Z   int32     `gorm:"index:idx_pos"`
+	Key       int64 `gorm:"primaryKey;autoIncrement:false"` // Código de Morton da origem (ver chunkKey)
+	X, Y, Z   int32
 	Data      []byte    // Dados do chunk serializados em GOB
 	MTime     int64     // Versão/Timestamp
 	IsEmpty   bool      // Indica se o chunk é céu/ar puro
@@ -45,118 +42,105 @@ type MaterialModel struct {
 	R, G, B  uint8
 }
 
-const CurrentFormatVersion = 4
-
-// chunkData é o container para serialização completa de um bloco
-type chunkData struct {
-	Tiles             [16][16]*Tile
-	Plants            []dfproto.PlantDetail
-	Buildings         []dfproto.BuildingInstance
-	Items             []dfproto.Item
-	ConstructionItems []dfproto.MatPair
-	SpatterPile       []dfproto.SpatterPile
-	Engravings        []dfproto.Engraving
-}
-
-// OpenInitialize abre (ou cria) o banco de dados SQLite para o mundo e roda migrações.
-func (s *MapDataStore) OpenInitialize(worldName string) error {
-	saveDir := "saves"
-	if err := os.MkdirAll(saveDir, 0755); err != nil {
-		return err
-	}
+// CurrentFormatVersion é a versão do formato de banco gravada por este binário.
+// Bancos mais antigos são atualizados pelos passos de migrations.go ao abrir.
+const CurrentFormatVersion = 7
+
+// generationKey guarda em WorldMetadata a geração do banco: um identificador
+// sorteado quando o banco é criado e trocado sempre que o conteúdo é substituído
+// (cópia de segurança restaurada, mundo importado, demonstração regerada). As
+// MTime dos chunks são contadores por chunk e só se comparam dentro da mesma geração.
+const generationKey = "Generation"
+
+// Metadados lidos por ListWorlds sem varrer o banco: a última gravação de chunks
+// (RFC 3339) e quantos chunks o banco tem (ver saveWorldStats).
+const (
+	updatedAtKey  = "UpdatedAt"
+	chunkCountKey = "ChunkCount"
+)
 
-	dbPath := filepath.Join(saveDir, fmt.Sprintf("%s.fv", worldName))
+// chunkCountInterval espaça as recontagens de ChunkCount feitas por Save, já que
+// contar os chunks percorre o banco inteiro.
+const chunkCountInterval = time.Minute
 
-	// Função interna para conectar com parâmetros otimizados
-	connect := func(path string) (*gorm.DB, error) {
-		// Parâmetros de conexão:
-		// _journal_mode=WAL: Permite leituras concorrentes durante escritas
-		// _busy_timeout=10000: Espera até 10s em vez de retornar "database is locked"
-		// _synchronous=NORMAL: Equilíbrio entre performance e segurança
-		dsn := fmt.Sprintf("%s?_journal_mode=WAL&_busy_timeout=10000&_synchronous=NORMAL", path)
-		return gorm.Open(sqlite.Open(dsn), &gorm.Config{
-			Logger: logger.Default.LogMode(logger.Silent),
-		})
-	}
+// newGeneration sorteia um identificador de geração.
+func newGeneration() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
 
-	db, err := connect(dbPath)
+// OpenInitialize abre (ou cria) o repositório do mundo no backend configurado em s.Backend.
+// No SQLite (padrão) isso inclui verificação de integridade e migrações de formato.
+func (s *MapDataStore) OpenInitialize(worldName string) error {
+	repo, err := openRepository(s.Backend, worldName)
 	if err != nil {
-		log.Printf("[Persistence] ERRO ao conectar no SQLite: %v. Tentando reset do banco...", err)
-		return s.resetCorruptDatabase(dbPath, worldName)
-	}
-
-	// Verificação de Integridade Rápida
-	var integrity string
-	if err := db.Raw("PRAGMA integrity_check").Scan(&integrity).Error; err != nil || integrity != "ok" {
-		log.Printf("[Persistence] Banco CORROMPIDO Detectado: %s (%v). Iniciando auto-reset...", integrity, err)
-		sqlDB, _ := db.DB()
-		if sqlDB != nil {
-			sqlDB.Close()
-		}
-		return s.resetCorruptDatabase(dbPath, worldName)
+		return err
 	}
-
-	// Migração	// Auto-Migrate do Esquema
-	if err := db.AutoMigrate(&ChunkModel{}, &WorldMetadata{}, &MaterialModel{}, &DictionaryModel{}); err != nil {
-		log.Printf("[Persistence] Aviso: Falha ao migrar esquema principal: %v", err)
+	repo.SaveMetadata("WorldName", worldName)
+	// Bancos novos (inclusive os recriados após corrupção) e os de antes da geração
+	if generation, _ := repo.GetMetadata(generationKey); generation == "" {
+		repo.SaveMetadata(generationKey, newGeneration())
 	}
 
 	s.Mu.Lock()
-	s.DB = db
+	s.Repo = repo
+	s.WorldName = worldName
 	s.Mu.Unlock()
 
-	// Salva metadados iniciais
-	db.Save(&WorldMetadata{Key: "FormatVersion", Value: fmt.Sprint(CurrentFormatVersion)})
-	db.Save(&WorldMetadata{Key: "WorldName", Value: worldName})
-
-	log.Printf("[Persistence] Banco de dados SQLite aberto e íntegro: %s", dbPath)
+	// Construções e unidades da última sessão: o modo offline as mostra como estavam
+	if err := s.LoadEntities(); err != nil {
+		log.Printf("[Persistence] Aviso: entidades de %s ignoradas: %v", worldName, err)
+	}
 	return nil
 }
 
-// SaveMapInfo persiste as dimensões do mapa no banco
+// SaveMapInfo persiste as dimensões e a origem do embarque no banco (ver EmbarkWorldName)
 func (s *MapDataStore) SaveMapInfo(info *dfproto.MapInfo) error {
-	if s.DB == nil {
+	if s.Repo == nil {
 		return fmt.Errorf("banco não inicializado")
 	}
-	s.DB.Save(&WorldMetadata{Key: "MapSizeX", Value: fmt.Sprint(info.BlockSizeX)})
-	s.DB.Save(&WorldMetadata{Key: "MapSizeY", Value: fmt.Sprint(info.BlockSizeY)})
-	s.DB.Save(&WorldMetadata{Key: "MapSizeZ", Value: fmt.Sprint(info.BlockSizeZ)})
+	s.Repo.SaveMetadata("MapPosX", fmt.Sprint(info.BlockPosX))
+	s.Repo.SaveMetadata("MapPosY", fmt.Sprint(info.BlockPosY))
+	s.Repo.SaveMetadata("MapPosZ", fmt.Sprint(info.BlockPosZ))
+	s.Repo.SaveMetadata("MapSizeX", fmt.Sprint(info.BlockSizeX))
+	s.Repo.SaveMetadata("MapSizeY", fmt.Sprint(info.BlockSizeY))
+	s.Repo.SaveMetadata("MapSizeZ", fmt.Sprint(info.BlockSizeZ))
 	return nil
 }
 
 // GetMapInfo carrega as dimensões do mapa do banco
 func (s *MapDataStore) GetMapInfo() (x, y, z int32, err error) {
-	if s.DB == nil {
+	if s.Repo == nil {
 		return 0, 0, 0, fmt.Errorf("banco não inicializado")
 	}
-	var metaX, metaY, metaZ WorldMetadata
-	s.DB.First(&metaX, "key = ?", "MapSizeX")
-	s.DB.First(&metaY, "key = ?", "MapSizeY")
-	s.DB.First(&metaZ, "key = ?", "MapSizeZ")
+	metaX, _ := s.Repo.GetMetadata("MapSizeX")
+	metaY, _ := s.Repo.GetMetadata("MapSizeY")
+	metaZ, _ := s.Repo.GetMetadata("MapSizeZ")
 
-	fmt.Sscan(metaX.Value, &x)
-	fmt.Sscan(metaY.Value, &y)
-	fmt.Sscan(metaZ.Value, &z)
+	fmt.Sscan(metaX, &x)
+	fmt.Sscan(metaY, &y)
+	fmt.Sscan(metaZ, &z)
 
 	if x == 0 || y == 0 || z == 0 {
 		// Heurística de Emergência: Tenta calcular a partir dos chunks salvos,
 		// IGNORES is_empty=1 (phantom chunks) to avoid extreme X/Y boundaries.
 		log.Println("[Persistence] MapInfo ausente. Calculando dimensões via heurística de chunks...")
-		var res struct {
-			MaxX int32
-			MaxY int32
-			MaxZ int32
-		}
-		s.DB.Model(&ChunkModel{}).Where("is_empty = ?", false).Select("MAX(x) as max_x, MAX(y) as max_y, MAX(z) as max_z").Scan(&res)
-		
-		if res.MaxX > 0 && res.MaxY > 0 {
+		_, bound, ok, _ := s.Repo.ChunkBounds()
+		if ok && bound.X > 0 && bound.Y > 0 {
 			// Nota: Isso é o ORIGIN do último bloco. Adicionamos 16 para fechar a borda.
-			x, y, z = res.MaxX+16, res.MaxY+16, res.MaxZ+1
-			
+			x, y, z = bound.X+16, bound.Y+16, bound.Z+1
+
 			// Sanity check: Se os valores forem astronômicos, clipamos para um padrão seguro (ex: 4096x4096x246)
-			if x > 4096 { x = 4096 }
-			if y > 4096 { y = 4096 }
-			if z > 1000 { z = 1000 }
+			if x > 4096 {
+				x = 4096
+			}
+			if y > 4096 {
+				y = 4096
+			}
+			if z > 1000 {
+				z = 1000
+			}
 
 			log.Printf("[Persistence] Heurística (Sólida): Mapa estimado em %dx%dx%d", x, y, z)
 			return x, y, z, nil
@@ -168,275 +152,362 @@ func (s *MapDataStore) GetMapInfo() (x, y, z int32, err error) {
 
 // SaveDictionary armazena um arquivo binário ou payload protobuf no banco com a chave dada
 func (s *MapDataStore) SaveDictionary(key string, data []byte) error {
-	if s.DB == nil {
+	if s.Repo == nil {
 		return fmt.Errorf("banco não inicializado")
 	}
 	// Salva ou atualiza
-	return s.DB.Save(&DictionaryModel{Key: key, Data: data}).Error
+	return s.Repo.SaveDictionary(key, data)
 }
 
 // GetDictionary recupera um arquivo binário do banco sob a chave especificada
 func (s *MapDataStore) GetDictionary(key string) ([]byte, error) {
-	if s.DB == nil {
+	if s.Repo == nil {
 		return nil, fmt.Errorf("banco não inicializado")
 	}
-	var model DictionaryModel
-	if err := s.DB.First(&model, "key = ?", key).Error; err != nil {
-		return nil, err
+	return s.Repo.GetDictionary(key)
+}
+
+// SaveMetadata grava (ou atualiza) um valor textual em WorldMetadata
+func (s *MapDataStore) SaveMetadata(key, value string) error {
+	if s.Repo == nil {
+		return fmt.Errorf("banco não inicializado")
 	}
-	return model.Data, nil
+	return s.Repo.SaveMetadata(key, value)
 }
 
-// resetCorruptDatabase renomeia o arquivo corrompido e tenta criar um novo
-func (s *MapDataStore) resetCorruptDatabase(dbPath, worldName string) error {
-	backupPath := dbPath + ".corrupt_" + time.Now().Format("20060102_150405")
-	log.Printf("[Persistence] Renomeando banco corrompido para: %s", backupPath)
+// Generation retorna a geração do banco aberto (ver generationKey), ou "" em
+// bancos antigos abertos só para leitura.
+func (s *MapDataStore) Generation() string {
+	generation, _ := s.GetMetadata(generationKey)
+	return generation
+}
 
-	// Fecha conexões ativas se existirem
-	if s.DB != nil {
-		sqlDB, _ := s.DB.DB()
-		if sqlDB != nil {
-			sqlDB.Close()
-		}
+// NewGeneration troca a geração do banco aberto. Chame ao substituir o conteúdo
+// do mundo sob o mesmo nome, para que clientes descartem as versões que guardaram.
+func (s *MapDataStore) NewGeneration() error {
+	return s.SaveMetadata(generationKey, newGeneration())
+}
+
+// GetMetadata lê um valor textual de WorldMetadata
+func (s *MapDataStore) GetMetadata(key string) (string, error) {
+	if s.Repo == nil {
+		return "", fmt.Errorf("banco não inicializado")
 	}
+	return s.Repo.GetMetadata(key)
+}
 
-	// Tenta renomear. Se falhar porque o arquivo está em uso, retornamos erro fatal.
-	if err := os.Rename(dbPath, backupPath); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("não foi possível mover banco corrompido (arquivo em uso?): %w", err)
+// SaveChunk salva um único chunk no banco de dados SQLite.
+func (s *MapDataStore) SaveChunk(chunk *Chunk) error {
+	s.Mu.RLock()
+	repo, readOnly := s.Repo, s.ReadOnly
+	s.Mu.RUnlock()
+	if repo == nil {
+		return fmt.Errorf("banco de dados não inicializado")
+	}
+	if readOnly {
+		return nil
 	}
 
-	// Tenta reconectar em modo limpo (isso criará um novo arquivo)
-	dsn := fmt.Sprintf("%s?_journal_mode=WAL&_busy_timeout=10000&_synchronous=NORMAL", dbPath)
-	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Silent),
-	})
+	model, err := chunkModelOf(chunk)
 	if err != nil {
-		return fmt.Errorf("falha crítica: não foi possível criar novo banco após reset: %w", err)
+		log.Printf("[Persistence] ERRO Crítico GOB: %v", err)
+		return err
 	}
 
-	if err := db.AutoMigrate(&ChunkModel{}, &WorldMetadata{}, &MaterialModel{}); err != nil {
-		return fmt.Errorf("falha na migração do banco novo: %w", err)
+	// Upsert (Cria ou Atualiza)
+	err = repo.SaveChunk(&model)
+	if err != nil {
+		log.Printf("[Persistence] ERRO ao salvar chunk %v: %v", chunk.Origin, err)
+	} else {
+		// log.Printf("[Persistence] Chunk %s salvo com sucesso", id)
+		s.markClean(chunk)
+		s.recordHistory([]*ChunkModel{&model})
 	}
-
-	s.Mu.Lock()
-	s.DB = db
-	s.Mu.Unlock()
-
-	log.Printf("[Persistence] Novo banco de dados criado com sucesso: %s", dbPath)
-	return nil
+	return err
 }
 
-// SaveChunk salva um único chunk no banco de dados SQLite.
-func (s *MapDataStore) SaveChunk(chunk *Chunk) error {
-	if s.DB == nil {
-		return fmt.Errorf("banco de dados não inicializado")
-	}
+// markClean marca como gravada a versão salva de um chunk. Se o chunk mudou
+// desde então (ou saiu da RAM), a versão em RAM continua suja.
+func (s *MapDataStore) markClean(saved *Chunk) {
+	s.chunks.update(saved.Origin, func(cur *Chunk) *Chunk {
+		if cur != saved || !cur.IsDirty {
+			return cur
+		}
+		clean := cur.clone()
+		clean.IsDirty = false
+		return clean
+	})
+}
 
-	// Chunks vazios (Ar/Céu) não possuem tiles — pular serialização GOB
-	// pois o GOB do Go não aceita nil dentro de arrays fixos ([16][16]*Tile).
+// chunkModelOf monta a linha do banco para um chunk, com todas as camadas serializadas.
+// Chunks vazios (Ar/Céu) não possuem tiles e são gravados sem dados.
+func chunkModelOf(chunk *Chunk) (ChunkModel, error) {
 	var data []byte
 	if !chunk.IsEmpty {
-		var buf bytes.Buffer
-		enc := gob.NewEncoder(&buf)
-
-		// Agrupa todos os dados para serialização única
-		cData := chunkData{
-			Tiles:             chunk.Tiles,
-			Plants:            chunk.Plants,
-			Buildings:         chunk.Buildings,
-			Items:             chunk.Items,
-			ConstructionItems: chunk.ConstructionItems,
-			SpatterPile:       chunk.SpatterPile,
-			Engravings:        chunk.Engravings,
+		var err error
+		if data, err = EncodeChunk(chunk, LayersAll); err != nil {
+			return ChunkModel{}, err
 		}
-
-		if err := enc.Encode(cData); err != nil {
-			log.Printf("[Persistence] ERRO Crítico GOB: %v", err)
-			return err
-		}
-		data = buf.Bytes()
 	}
-
-	id := fmt.Sprintf("%d_%d_%d", chunk.Origin.X, chunk.Origin.Y, chunk.Origin.Z)
-	model := ChunkModel{
-		ID:      id,
+	return ChunkModel{
+		Key:     chunkKey(chunk.Origin),
 		X:       chunk.Origin.X,
 		Y:       chunk.Origin.Y,
 		Z:       chunk.Origin.Z,
 		Data:    data,
 		MTime:   chunk.MTime,
 		IsEmpty: chunk.IsEmpty,
-	}
-
-	// Upsert (Cria ou Atualiza)
-	err := s.DB.Save(&model).Error
-	if err != nil {
-		log.Printf("[Persistence] ERRO ao salvar chunk %s: %v", id, err)
-	} else {
-		// log.Printf("[Persistence] Chunk %s salvo com sucesso", id)
-		chunk.IsDirty = false
-	}
-	return err
+	}, nil
 }
 
 // LoadChunk tenta carregar um chunk específico do banco de dados.
 func (s *MapDataStore) LoadChunk(origin util.DFCoord) (*Chunk, error) {
-	if s.DB == nil {
+	if s.Repo == nil {
 		return nil, fmt.Errorf("banco de dados não inicializado")
 	}
 
-	id := fmt.Sprintf("%d_%d_%d", origin.X, origin.Y, origin.Z)
-	var model ChunkModel
-	if err := s.DB.First(&model, "id = ?", id).Error; err != nil {
-		return nil, err // Retorna error se não encontrar
+	model, err := s.Repo.LoadChunk(origin)
+	if err != nil {
+		return nil, err // ErrChunkNotFound se não encontrar
 	}
+	return s.chunkFromModel(origin, model)
+}
 
-	var tiles [16][16]*Tile
-	var plants []dfproto.PlantDetail
-	var buildings []dfproto.BuildingInstance
-	var items []dfproto.Item
-	var constrItems []dfproto.MatPair
-	var spatter []dfproto.SpatterPile
-	var engravings []dfproto.Engraving
-
+// chunkFromModel decodifica uma linha do banco e conecta o chunk ao store.
+func (s *MapDataStore) chunkFromModel(origin util.DFCoord, model *ChunkModel) (*Chunk, error) {
+	chunk := &Chunk{Origin: origin}
 	if !model.IsEmpty && len(model.Data) > 0 {
-		dec := gob.NewDecoder(bytes.NewReader(model.Data))
-		// Tenta novo formato (chunkData)
-		var cData chunkData
-		if err := dec.Decode(&cData); err == nil {
-			tiles = cData.Tiles
-			plants = cData.Plants
-			buildings = cData.Buildings
-			items = cData.Items
-			constrItems = cData.ConstructionItems
-			spatter = cData.SpatterPile
-			engravings = cData.Engravings
-		} else {
-			// Fallback: Tenta formato antigo (apenas tiles)
-			decOld := gob.NewDecoder(bytes.NewReader(model.Data))
-			if err := decOld.Decode(&tiles); err != nil {
-				return nil, fmt.Errorf("falha ao decodificar dados do chunk %s: %v", id, err)
-			}
+		// Blobs antigos (apenas tiles) já foram recodificados na abertura (ver migrations.go)
+		decoded, err := DecodeChunk(origin, model.Data)
+		if err != nil {
+			return nil, err
 		}
+		chunk = decoded
 	}
+	// Re-conecta o chunk ao container (as visões de tile apontam para ele)
+	chunk.Attach(s)
+	chunk.MTime = model.MTime
+	chunk.IsEmpty = model.IsEmpty
+
+	return chunk, nil
+}
 
-	chunk := &Chunk{
-		Origin:            origin,
-		Tiles:             tiles,
-		Plants:            plants,
-		Buildings:         buildings,
-		Items:             items,
-		ConstructionItems: constrItems,
-		SpatterPile:       spatter,
-		Engravings:        engravings,
-		MTime:             model.MTime,
-		IsEmpty:           model.IsEmpty,
-	}
-
-	// Se for um bloco conhecido por estar vazio, abortamos links nos tiles, ele não deve possuir nenhum.
-	if !model.IsEmpty {
-		// Re-conecta os tiles ao container
-		for x := 0; x < 16; x++ {
-			for y := 0; y < 16; y++ {
-				if tile := chunk.Tiles[x][y]; tile != nil {
-					tile.container = s
+// LoadRegion reúne os chunks com origem dentro da caixa [min, max] (coordenadas de
+// tile, limites inclusivos). Os que estão em RAM vêm do cache; os demais são lidos
+// do banco numa única consulta e publicados no cache. Origens nunca gravadas ficam
+// fora do mapa retornado. Em erro de leitura do banco, os chunks em RAM ainda são retornados.
+func (s *MapDataStore) LoadRegion(min, max util.DFCoord) (map[util.DFCoord]*Chunk, error) {
+	min, max = min.BlockCoord(), max.BlockCoord()
+	chunks := make(map[util.DFCoord]*Chunk)
+	missing := make(map[util.DFCoord]bool) // origem -> despejado pelo cache
+	for z := min.Z; z <= max.Z; z++ {
+		for x := min.X; x <= max.X; x += 16 {
+			for y := min.Y; y <= max.Y; y += 16 {
+				origin := util.NewDFCoord(x, y, z)
+				if c, evicted := s.chunks.lookup(origin); c != nil {
+					chunks[origin] = c
+				} else {
+					missing[origin] = evicted
 				}
 			}
 		}
 	}
 
-	return chunk, nil
+	s.Mu.RLock()
+	repo := s.Repo
+	s.Mu.RUnlock()
+	if len(missing) == 0 || repo == nil {
+		return chunks, nil
+	}
+
+	models, err := repo.LoadRegion(min, max)
+	if err != nil {
+		return chunks, err
+	}
+	wanted := models[:0]
+	for _, model := range models {
+		origin := util.NewDFCoord(model.X, model.Y, model.Z)
+		if _, ok := missing[origin]; ok {
+			wanted = append(wanted, model) // Os demais já estavam em RAM (possivelmente mais novos)
+			delete(missing, origin)
+		}
+	}
+
+	// A decodificação domina o custo da região: com todos os blobs em mãos, roda em paralelo
+	loaded := make([]*Chunk, len(wanted))
+	workers := runtime.NumCPU()
+	if workers > len(wanted) {
+		workers = len(wanted)
+	}
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for ; workers > 0; workers-- {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := int(next.Add(1) - 1); i < len(wanted); i = int(next.Add(1) - 1) {
+				model := wanted[i]
+				origin := util.NewDFCoord(model.X, model.Y, model.Z)
+				chunk, err := s.chunkFromModel(origin, model)
+				if err != nil {
+					log.Printf("[Persistence] ERRO ao decodificar chunk %v da região: %v", origin, err)
+					continue
+				}
+				loaded[i] = chunk
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, chunk := range loaded {
+		if chunk == nil {
+			continue
+		}
+		// O scanner pode ter publicado uma versão mais nova enquanto o banco era lido
+		chunks[chunk.Origin] = s.chunks.update(chunk.Origin, func(cur *Chunk) *Chunk {
+			if cur != nil {
+				return cur
+			}
+			return chunk
+		})
+	}
+	for origin, evicted := range missing {
+		if evicted {
+			s.chunks.forgetEvicted(origin) // Despejado mas ausente do banco: nada a reler
+		}
+	}
+	s.maybeEvict()
+	return chunks, nil
 }
 
 // GetChunkCount retorna a quantidade total de chunks gravados no disco SQLite.
 // Usado na inicialização para decidir se compensa ligar um full-scan em background.
 func (s *MapDataStore) GetChunkCount() (int64, error) {
-	if s.DB == nil {
+	if s.Repo == nil {
 		return 0, fmt.Errorf("banco de dados não inicializado")
 	}
 
-	var count int64
-	err := s.DB.Model(&ChunkModel{}).Count(&count).Error
-	return count, err
+	return s.Repo.ChunkCount()
 }
 
 // Save (Legacy Override) agora é apenas um wrapper que salva todos os chunks em memória.
 func (s *MapDataStore) Save(worldName string) (int, error) {
-	s.Mu.Lock()
-	if s.DB == nil {
+	s.Mu.RLock()
+	hasDB, readOnly := s.Repo != nil, s.ReadOnly
+	s.Mu.RUnlock()
+	if readOnly {
+		return 0, nil
+	}
+	if !hasDB {
+		// OpenInitialize trava s.Mu internamente, por isso é chamado fora do lock
 		if err := s.OpenInitialize(worldName); err != nil {
-			s.Mu.Unlock()
 			return 0, err
 		}
 	}
 
-	// Coleta uma lista dos chunks sujos para salvar fora do lock
+	// Serializa o acesso ao banco — impede "database is locked".
+	// Os chunks sujos são coletados já com dbMu travado, assim uma troca de mundo
+	// (SwitchWorld) nunca acontece entre a coleta e a gravação. O banco é relido
+	// aqui: uma troca que rodou antes de dbMu e não conseguiu reabrir deixa Repo nil.
+	s.dbMu.Lock()
+	defer s.dbMu.Unlock()
+	s.Mu.RLock()
+	repo := s.Repo
+	s.Mu.RUnlock()
+	if repo == nil {
+		return 0, fmt.Errorf("banco de dados não inicializado")
+	}
+
+	// Entidades vão junto (unidades no máximo a cada UnitSaveInterval)
+	s.saveEntities(false)
+
+	// Coleta uma lista dos chunks sujos; as versões coletadas são imutáveis,
+	// então o IO abaixo não trava o jogo
 	var dirtyChunks []*Chunk
-	for _, chunk := range s.Chunks {
+	s.chunks.rangeChunks(func(chunk *Chunk) bool {
 		if chunk.IsDirty {
 			dirtyChunks = append(dirtyChunks, chunk)
 		}
-	}
-	s.Mu.Unlock() // Libera o lock para não travar o jogo durante o IO
+		return true
+	})
 
 	if len(dirtyChunks) == 0 {
 		return 0, nil
 	}
 
-	// Serializa o acesso ao banco — impede "database is locked"
-	s.dbMu.Lock()
-	defer s.dbMu.Unlock()
-
-	count := 0
-	// Usa uma transaction para agrupar todas as escritas em uma operação atômica.
-	// Isso é MUITO mais rápido e elimina "database is locked" entre goroutines.
-	err := s.DB.Transaction(func(tx *gorm.DB) error {
-		for _, chunk := range dirtyChunks {
-			// Serialização GOB inline (igual ao SaveChunk, mas usando tx)
-			var data []byte
-			if !chunk.IsEmpty {
-				var buf bytes.Buffer
-				enc := gob.NewEncoder(&buf)
-				cData := chunkData{
-					Tiles:             chunk.Tiles,
-					Plants:            chunk.Plants,
-					Buildings:         chunk.Buildings,
-					Items:             chunk.Items,
-					ConstructionItems: chunk.ConstructionItems,
-				}
-				if err := enc.Encode(cData); err != nil {
-					log.Printf("[Persistence] ERRO Crítico GOB: %v", err)
-					continue // Pula este chunk, não aborta a transaction
-				}
-				data = buf.Bytes()
-			}
+	models := make([]*ChunkModel, 0, len(dirtyChunks))
+	saved := make([]*Chunk, 0, len(dirtyChunks))
+	for _, chunk := range dirtyChunks {
+		model, err := chunkModelOf(chunk)
+		if err != nil {
+			log.Printf("[Persistence] ERRO Crítico GOB: %v", err)
+			continue // Pula este chunk, não aborta o lote
+		}
+		models = append(models, &model)
+		saved = append(saved, chunk)
+	}
 
-			id := fmt.Sprintf("%d_%d_%d", chunk.Origin.X, chunk.Origin.Y, chunk.Origin.Z)
-			model := ChunkModel{
-				ID:      id,
-				X:       chunk.Origin.X,
-				Y:       chunk.Origin.Y,
-				Z:       chunk.Origin.Z,
-				Data:    data,
-				MTime:   chunk.MTime,
-				IsEmpty: chunk.IsEmpty,
-			}
+	count, err := repo.SaveChunks(models)
+	if err != nil {
+		log.Printf("[Persistence] ERRO na gravação em lote: %v", err)
+		return count, err
+	}
+	for _, chunk := range saved {
+		s.markClean(chunk)
+	}
+	s.recordHistory(models)
+	s.saveWorldStats()
+	return count, nil
+}
 
-			if err := tx.Save(&model).Error; err != nil {
-				log.Printf("[Persistence] ERRO ao salvar chunk %s: %v", id, err)
-				continue
-			}
-			chunk.IsDirty = false
-			count++
-		}
-		return nil
-	})
+// saveWorldStats grava UpdatedAt depois de uma gravação de chunks e reconta
+// ChunkCount no máximo a cada chunkCountInterval. Deve ser chamado com dbMu travado.
+func (s *MapDataStore) saveWorldStats() {
+	s.Repo.SaveMetadata(updatedAtKey, time.Now().UTC().Format(time.RFC3339Nano))
+	s.countStale = true
+	if time.Since(s.countedAt) >= chunkCountInterval {
+		s.saveChunkCount()
+	}
+}
 
+// saveChunkCount reconta os chunks do banco e grava ChunkCount. Deve ser chamado com dbMu travado.
+func (s *MapDataStore) saveChunkCount() {
+	count, err := s.Repo.ChunkCount()
 	if err != nil {
-		log.Printf("[Persistence] ERRO na transaction: %v", err)
+		log.Printf("[Persistence] Aviso: falha ao contar os chunks de %s: %v", s.WorldName, err)
+		return
+	}
+	if err := s.Repo.SaveMetadata(chunkCountKey, fmt.Sprint(count)); err == nil {
+		s.countedAt, s.countStale = time.Now(), false
 	}
+}
 
-	return count, err
+// SwitchWorld grava os chunks pendentes no banco atual, fecha-o e abre (ou cria) o banco de outro mundo.
+// Tudo que está em RAM pertence ao mapa anterior e é descartado.
+func (s *MapDataStore) SwitchWorld(worldName string) error {
+	if s.Repo != nil {
+		if count, err := s.Save(s.WorldName); err != nil {
+			log.Printf("[Persistence] Aviso: falha ao gravar chunks pendentes de %s: %v", s.WorldName, err)
+		} else if count > 0 {
+			log.Printf("[Persistence] %d chunks pendentes gravados em %s antes da troca.", count, s.WorldName)
+		}
+		if err := s.SaveEntities(); err != nil {
+			log.Printf("[Persistence] Aviso: falha ao gravar entidades de %s: %v", s.WorldName, err)
+		}
+	}
+
+	// Segura dbMu durante toda a troca para que nenhuma gravação caia no banco errado
+	s.dbMu.Lock()
+	defer s.dbMu.Unlock()
+
+	s.Close()
+	s.Mu.Lock()
+	s.Repo = nil
+	s.WorldName = ""
+	s.Mu.Unlock()
+	s.ResetMemory()
+
+	return s.OpenInitialize(worldName)
 }
 
 // Load (Legacy Override) inicializa o banco e pré-carrega o que for necessário.
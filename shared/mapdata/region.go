@@ -0,0 +1,108 @@
+package mapdata
+
+import (
+	"cmp"
+	"slices"
+
+	"FortressVision/shared/util"
+)
+
+// ChunksByPriority lista as origens dos chunks da região r na ordem em que
+// devem ser enviados: primeiro o nível de focus, depois os mais próximos dele
+// (o de baixo antes do de cima, já que a câmera vê mais níveis para baixo); em
+// cada nível, do chunk mais perto de focus para o mais longe.
+//
+// Com polygon (x, y em tiles; Z ignorado), só entram os chunks que tocam o polígono.
+func ChunksByPriority(r Region, focus util.DFCoord, polygon []util.DFCoord) []util.DFCoord {
+	min, max := r.Min.BlockCoord(), r.Max.BlockCoord()
+
+	type ranked struct {
+		origin util.DFCoord
+		level  int32 // 0 = foco, 1 = um abaixo, 2 = um acima, 3 = dois abaixo...
+		dist   int64 // Distância² do centro do chunk ao foco
+	}
+	var chunks []ranked
+	for x := min.X; x <= max.X; x += 16 {
+		for y := min.Y; y <= max.Y; y += 16 {
+			if len(polygon) >= 3 && !polygonTouchesSquare(polygon, x, y, 16) {
+				continue
+			}
+			dx, dy := int64(x+8-focus.X), int64(y+8-focus.Y)
+			for z := min.Z; z <= max.Z; z++ {
+				level := 2 * (z - focus.Z)
+				if z < focus.Z {
+					level = 2*(focus.Z-z) - 1
+				}
+				chunks = append(chunks, ranked{util.NewDFCoord(x, y, z), level, dx*dx + dy*dy})
+			}
+		}
+	}
+	slices.SortFunc(chunks, func(a, b ranked) int {
+		if c := cmp.Compare(a.level, b.level); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.dist, b.dist)
+	})
+
+	origins := make([]util.DFCoord, len(chunks))
+	for i, c := range chunks {
+		origins[i] = c.origin
+	}
+	return origins
+}
+
+// polygonTouchesSquare indica se o polígono encosta no quadrado [x, x+size) × [y, y+size):
+// ou o centro do quadrado está dentro do polígono, ou alguma aresta passa pelo quadrado.
+func polygonTouchesSquare(polygon []util.DFCoord, x, y, size int32) bool {
+	x0, y0 := float64(x), float64(y)
+	x1, y1 := x0+float64(size), y0+float64(size)
+	if pointInPolygon(polygon, (x0+x1)/2, (y0+y1)/2) {
+		return true
+	}
+	for i := range polygon {
+		a, b := polygon[i], polygon[(i+1)%len(polygon)]
+		if segmentTouchesBox(float64(a.X), float64(a.Y), float64(b.X), float64(b.Y), x0, y0, x1, y1) {
+			return true
+		}
+	}
+	return false
+}
+
+// pointInPolygon testa o ponto (px, py) contra o polígono (regra par-ímpar).
+func pointInPolygon(polygon []util.DFCoord, px, py float64) bool {
+	inside := false
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		ax, ay := float64(polygon[i].X), float64(polygon[i].Y)
+		bx, by := float64(polygon[j].X), float64(polygon[j].Y)
+		if (ay > py) != (by > py) && px < (bx-ax)*(py-ay)/(by-ay)+ax {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// segmentTouchesBox recorta o segmento (ax, ay)-(bx, by) pela caixa (Liang-Barsky)
+// e diz se sobra algum pedaço dentro dela.
+func segmentTouchesBox(ax, ay, bx, by, x0, y0, x1, y1 float64) bool {
+	t0, t1 := 0.0, 1.0
+	dx, dy := bx-ax, by-ay
+	for _, edge := range [4][2]float64{{-dx, ax - x0}, {dx, x1 - ax}, {-dy, ay - y0}, {dy, y1 - ay}} {
+		p, q := edge[0], edge[1]
+		if p == 0 {
+			if q < 0 {
+				return false // Paralelo à borda e fora da caixa
+			}
+			continue
+		}
+		t := q / p
+		if p < 0 {
+			t0 = max(t0, t)
+		} else {
+			t1 = min(t1, t)
+		}
+		if t0 > t1 {
+			return false
+		}
+	}
+	return true
+}
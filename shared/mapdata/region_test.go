@@ -0,0 +1,277 @@
+package mapdata
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"FortressVision/shared/util"
+)
+
+func TestChunkKeyBoundsRegion(t *testing.T) {
+	min, max := util.NewDFCoord(-32, 16, -2), util.NewDFCoord(48, 80, 3)
+	lo, hi := chunkKey(min), chunkKey(max)
+	seen := make(map[int64]bool)
+	for z := min.Z; z <= max.Z; z++ {
+		for x := min.X; x <= max.X; x += 16 {
+			for y := min.Y; y <= max.Y; y += 16 {
+				k := chunkKey(util.NewDFCoord(x, y, z))
+				if k < lo || k > hi {
+					t.Fatalf("chave de (%d,%d,%d) = %d fora do intervalo [%d, %d]", x, y, z, k, lo, hi)
+				}
+				if seen[k] {
+					t.Fatalf("chave repetida para (%d,%d,%d)", x, y, z)
+				}
+				seen[k] = true
+			}
+		}
+	}
+}
+
+// chdirTemp roda o teste numa pasta temporária (SavesDir é relativo ao diretório atual).
+func chdirTemp(tb testing.TB) {
+	tb.Helper()
+	wd, _ := os.Getwd()
+	if err := os.Chdir(tb.TempDir()); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestStoreLoadRegion(t *testing.T) {
+	s, origins := cacheTestStore(t, 6)
+	if _, err := s.Save("Regiao"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 0 e 1 continuam em RAM, 2 e 3 foram despejados, 4 e 5 nunca foram carregados
+	s.DeleteChunk(origins[4])
+	s.DeleteChunk(origins[5])
+	for _, origin := range origins[2:4] {
+		c, _ := s.chunks.get(origin)
+		s.chunks.evict(c, true)
+	}
+	s.StoreSingleBlock(scanBlock(origins[0], 99)) // versão em RAM mais nova que a do banco
+
+	// A caixa vai além do último chunk gravado e corta o chunk 0 no meio
+	chunks, err := s.LoadRegion(util.NewDFCoord(8, 0, 0), util.NewDFCoord(7*16, 15, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != len(origins) {
+		t.Fatalf("LoadRegion = %d chunks, want %d", len(chunks), len(origins))
+	}
+	if tile := chunks[origins[0]].Tile(0, 0); tile.TileType != 99 {
+		t.Fatalf("chunk em RAM substituído pelo do banco: TileType %d", tile.TileType)
+	}
+	for i, origin := range origins[1:] {
+		if tile := chunks[origin].Tile(3, 3); tile == nil || tile.TileType != int32(i+2) {
+			t.Fatalf("chunk %v = %+v, want TileType %d", origin, tile, i+2)
+		}
+		if c, ok := s.chunks.get(origin); !ok || c != chunks[origin] {
+			t.Fatalf("chunk %v não foi publicado no cache", origin)
+		}
+	}
+}
+
+func TestMigrateChunkKeys(t *testing.T) {
+	chdirTemp(t)
+	if err := os.MkdirAll(SavesDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Banco v6: chave textual "X_Y_Z"
+	db, err := openDB(WorldPath("Antigo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&legacyChunkModel{}, &WorldMetadata{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []util.DFCoord{{X: 0, Y: 0, Z: 1}, {X: 16, Y: 32, Z: 1}, {X: 48, Y: 0, Z: 7}} {
+		db.Create(&legacyChunkModel{ID: fmt.Sprintf("%d_%d_%d", c.X, c.Y, c.Z), X: c.X, Y: c.Y, Z: c.Z, Data: []byte{byte(c.Z)}, MTime: 5})
+	}
+	db.Save(&WorldMetadata{Key: formatVersionKey, Value: "6"})
+	closeDB(db)
+
+	repo, err := openSQLiteRepository("Antigo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	if repo.db.Migrator().HasTable(legacyChunkTable) {
+		t.Fatal("tabela antiga continua no banco")
+	}
+	if count, _ := repo.ChunkCount(); count != 3 {
+		t.Fatalf("ChunkCount = %d, want 3", count)
+	}
+	got, err := repo.LoadChunk(util.NewDFCoord(48, 0, 7))
+	if err != nil || len(got.Data) != 1 || got.Data[0] != 7 || got.MTime != 5 {
+		t.Fatalf("LoadChunk migrado = %+v, %v", got, err)
+	}
+	region, err := repo.LoadRegion(util.NewDFCoord(0, 0, 1), util.NewDFCoord(16, 32, 1))
+	if err != nil || len(region) != 2 {
+		t.Fatalf("LoadRegion migrado = %d chunks, %v", len(region), err)
+	}
+	if v, _ := repo.GetMetadata(formatVersionKey); v != "7" {
+		t.Fatalf("FormatVersion = %q, want 7", v)
+	}
+}
+
+// Região de streaming usada no benchmark: 24x24 blocos em um nível, num banco
+// com três níveis gravados.
+const (
+	regionBenchBlocks = 24
+	regionBenchLevels = 3
+)
+
+// BenchmarkLoadRegion compara o caminho antigo do streaming (GetChunk/LoadChunk
+// chunk a chunk) com LoadRegion, sempre com o cache em RAM vazio. Os casos Repo*
+// medem só as consultas ao banco, sem a decodificação dos chunks.
+// Rodar com: go test -run ^$ -bench LoadRegion ./shared/mapdata
+func BenchmarkLoadRegion(b *testing.B) {
+	chdirTemp(b)
+	s := NewMapDataStore()
+	if err := s.OpenInitialize("Regiao"); err != nil {
+		b.Fatal(err)
+	}
+	defer s.Repo.Close()
+	for z := int32(0); z < regionBenchLevels; z++ {
+		for x := int32(0); x < regionBenchBlocks; x++ {
+			for y := int32(0); y < regionBenchBlocks; y++ {
+				s.StoreSingleBlock(scanBlock(util.NewDFCoord(x*16, y*16, z), x+y))
+			}
+		}
+	}
+	if _, err := s.Save("Regiao"); err != nil {
+		b.Fatal(err)
+	}
+
+	const z = 1
+	min := util.NewDFCoord(0, 0, z)
+	max := util.NewDFCoord((regionBenchBlocks-1)*16, (regionBenchBlocks-1)*16, z)
+	want := regionBenchBlocks * regionBenchBlocks
+
+	b.Run("RepoPerChunk", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for x := min.X; x <= max.X; x += 16 {
+				for y := min.Y; y <= max.Y; y += 16 {
+					if _, err := s.Repo.LoadChunk(util.NewDFCoord(x, y, z)); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		}
+	})
+	b.Run("RepoLoadRegion", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if models, err := s.Repo.LoadRegion(min, max); err != nil || len(models) != want {
+				b.Fatalf("LoadRegion = %d, %v", len(models), err)
+			}
+		}
+	})
+	b.Run("PerChunk", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			s.chunks.reset()
+			n := 0
+			for x := min.X; x <= max.X; x += 16 {
+				for y := min.Y; y <= max.Y; y += 16 {
+					origin := util.NewDFCoord(x, y, z)
+					if _, ok := s.GetChunk(origin); ok {
+						n++
+						continue
+					}
+					chunk, err := s.LoadChunk(origin)
+					if err != nil {
+						b.Fatal(err)
+					}
+					s.PutChunk(chunk)
+					n++
+				}
+			}
+			if n != want {
+				b.Fatalf("%d chunks, want %d", n, want)
+			}
+		}
+	})
+	b.Run("LoadRegion", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			s.chunks.reset()
+			chunks, err := s.LoadRegion(min, max)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(chunks) != want {
+				b.Fatalf("%d chunks, want %d", len(chunks), want)
+			}
+		}
+	})
+}
+
+func TestChunksByPriorityOrder(t *testing.T) {
+	focus := util.NewDFCoord(40, 40, 10)
+	r := RegionAround(focus, 32, 2, 1)
+	origins := ChunksByPriority(r, focus, nil)
+
+	// 5x5 chunks em x/y (de 0 a 64) vezes 4 níveis
+	if len(origins) != 5*5*4 {
+		t.Fatalf("%d chunks, want %d", len(origins), 5*5*4)
+	}
+	wantLevels := []int32{10, 9, 11, 8}
+	for i, z := range wantLevels {
+		for _, o := range origins[i*25 : (i+1)*25] {
+			if o.Z != z {
+				t.Fatalf("bloco %d tem chunk no nível %d, want %d", i, o.Z, z)
+			}
+		}
+	}
+	if first := origins[0]; first != util.NewDFCoord(32, 32, 10) {
+		t.Errorf("primeiro chunk = %v, want o do foco (32, 32, 10)", first)
+	}
+	dist := func(o util.DFCoord) int32 {
+		dx, dy := o.X+8-focus.X, o.Y+8-focus.Y
+		return dx*dx + dy*dy
+	}
+	for i := 1; i < 25; i++ {
+		if dist(origins[i]) < dist(origins[i-1]) {
+			t.Fatalf("chunk %v vem depois de %v, mas está mais perto do foco", origins[i], origins[i-1])
+		}
+	}
+}
+
+func TestChunksByPriorityPolygon(t *testing.T) {
+	focus := util.NewDFCoord(8, 8, 0)
+	r := RegionAround(focus, 64, 0, 0)
+	// Triângulo apontando para +x a partir do chunk do foco
+	polygon := []util.DFCoord{
+		util.NewDFCoord(8, 8, 0),
+		util.NewDFCoord(70, -10, 0),
+		util.NewDFCoord(70, 26, 0),
+	}
+	got := make(map[util.DFCoord]bool)
+	for _, o := range ChunksByPriority(r, focus, polygon) {
+		got[o] = true
+	}
+	for _, o := range []util.DFCoord{
+		util.NewDFCoord(0, 0, 0),    // Contém o vértice
+		util.NewDFCoord(32, 0, 0),   // Centro dentro do polígono
+		util.NewDFCoord(64, -16, 0), // Só uma aresta passa por ele
+	} {
+		if !got[o] {
+			t.Errorf("chunk %v deveria tocar o polígono", o)
+		}
+	}
+	for _, o := range []util.DFCoord{
+		util.NewDFCoord(-16, 0, 0),
+		util.NewDFCoord(32, 48, 0),
+		util.NewDFCoord(0, -48, 0),
+	} {
+		if got[o] {
+			t.Errorf("chunk %v não toca o polígono", o)
+		}
+	}
+	if len(got) >= 81 {
+		t.Errorf("polígono não filtrou nada (%d chunks)", len(got))
+	}
+}
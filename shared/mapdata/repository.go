@@ -0,0 +1,130 @@
+package mapdata
+
+import (
+	"errors"
+	"fmt"
+
+	"FortressVision/shared/util"
+)
+
+// ErrChunkNotFound indica que o chunk pedido nunca foi gravado no repositório.
+var ErrChunkNotFound = errors.New("chunk não encontrado")
+
+// ErrMetadataNotFound indica que a chave pedida nunca foi gravada em WorldMetadata.
+var ErrMetadataNotFound = errors.New("metadado não encontrado")
+
+// ErrReadOnly é retornado por escritas num repositório aberto apenas para leitura.
+var ErrReadOnly = errors.New("repositório aberto apenas para leitura")
+
+// ChunkHeader resume um chunk gravado sem carregar seus dados.
+type ChunkHeader struct {
+	Origin  util.DFCoord
+	MTime   int64
+	IsEmpty bool
+}
+
+// ChunkRepository é o armazenamento persistente de um mundo: chunks já serializados
+// (ver EncodeChunk), dicionários binários e metadados textuais.
+// O MapDataStore cuida do cache em RAM e do codec; o repositório só guarda bytes.
+type ChunkRepository interface {
+	// LoadChunk retorna ErrChunkNotFound se o chunk nunca foi gravado.
+	LoadChunk(origin util.DFCoord) (*ChunkModel, error)
+	// LoadRegion retorna, numa única leitura, os chunks gravados com origem dentro
+	// da caixa [min, max] (limites inclusivos, em origens de chunk). A ordem não é definida.
+	LoadRegion(min, max util.DFCoord) ([]*ChunkModel, error)
+	SaveChunk(model *ChunkModel) error
+	// SaveChunks grava um lote de forma atômica quando o backend permite.
+	SaveChunks(models []*ChunkModel) (int, error)
+	ChunkCount() (int64, error)
+	// ChunkHeaders lista origem e versão de todos os chunks gravados.
+	ChunkHeaders() ([]ChunkHeader, error)
+	// ChunkBounds retorna a menor e a maior origem entre os chunks não vazios,
+	// coordenada a coordenada (ok=false se não houver).
+	ChunkBounds() (min, max util.DFCoord, ok bool, err error)
+
+	SaveDictionary(key string, data []byte) error
+	GetDictionary(key string) ([]byte, error)
+	SaveMetadata(key, value string) error
+	// GetMetadata retorna ErrMetadataNotFound se a chave nunca foi gravada.
+	GetMetadata(key string) (string, error)
+
+	Close() error
+}
+
+// StorageBackend escolhe a implementação de ChunkRepository usada por OpenInitialize.
+type StorageBackend string
+
+const (
+	// BackendSQLite é o padrão: um banco .fv por mundo, com migrações de formato.
+	BackendSQLite StorageBackend = "sqlite"
+	// BackendMemory não grava nada em disco (testes e sessões descartáveis).
+	BackendMemory StorageBackend = "memory"
+	// BackendLog grava um arquivo .fvlog só de acréscimos, usado para arquivos de mundos.
+	BackendLog StorageBackend = "log"
+)
+
+// ParseStorageBackend valida o nome de um backend (vazio = SQLite).
+func ParseStorageBackend(name string) (StorageBackend, error) {
+	switch StorageBackend(name) {
+	case "", BackendSQLite:
+		return BackendSQLite, nil
+	case BackendMemory, BackendLog:
+		return StorageBackend(name), nil
+	}
+	return "", fmt.Errorf("backend de armazenamento desconhecido: %q (use sqlite, memory ou log)", name)
+}
+
+// mortonBias desloca as coordenadas para que origens negativas também caibam nos 21 bits.
+const mortonBias = 1 << 20
+
+// chunkKey é a chave inteira de um chunk no banco: o código de Morton (Z-order)
+// do índice do bloco (X/16, Y/16) e do nível Z, 21 bits cada. Chunks vizinhos no
+// mapa ficam próximos na ordem da chave, e toda origem dentro de uma caixa
+// [min, max] tem chave entre chunkKey(min) e chunkKey(max), o que deixa LoadRegion
+// percorrer um único intervalo da chave primária.
+func chunkKey(origin util.DFCoord) int64 {
+	bx := uint64(origin.X>>4+mortonBias) & 0x1fffff
+	by := uint64(origin.Y>>4+mortonBias) & 0x1fffff
+	bz := uint64(origin.Z+mortonBias) & 0x1fffff
+	return int64(spreadBits3(bx) | spreadBits3(by)<<1 | spreadBits3(bz)<<2)
+}
+
+// spreadBits3 separa os 21 bits de v com dois zeros entre cada um.
+func spreadBits3(v uint64) uint64 {
+	v = (v | v<<32) & 0x1f00000000ffff
+	v = (v | v<<16) & 0x1f0000ff0000ff
+	v = (v | v<<8) & 0x100f00f00f00f00f
+	v = (v | v<<4) & 0x10c30c30c30c30c3
+	v = (v | v<<2) & 0x1249249249249249
+	return v
+}
+
+// inRegion indica se a origem está dentro da caixa [min, max] (limites inclusivos).
+func inRegion(origin, min, max util.DFCoord) bool {
+	return origin.X >= min.X && origin.X <= max.X &&
+		origin.Y >= min.Y && origin.Y <= max.Y &&
+		origin.Z >= min.Z && origin.Z <= max.Z
+}
+
+// openRepository abre (ou cria) o repositório de um mundo no backend escolhido.
+func openRepository(backend StorageBackend, worldName string) (ChunkRepository, error) {
+	switch backend {
+	case BackendMemory:
+		return newMemoryRepository(), nil
+	case BackendLog:
+		return openLogRepository(LogWorldPath(worldName), false)
+	default:
+		return openSQLiteRepository(worldName)
+	}
+}
+
+// openReadOnlyRepository abre um mundo arquivado, detectando o formato pelo arquivo em SavesDir.
+func openReadOnlyRepository(worldName string) (ChunkRepository, error) {
+	if fileExists(WorldPath(worldName)) {
+		return openSQLiteReadOnly(worldName)
+	}
+	if fileExists(LogWorldPath(worldName)) {
+		return openLogRepository(LogWorldPath(worldName), true)
+	}
+	return nil, fmt.Errorf("mundo %q não encontrado em %s", worldName, SavesDir)
+}
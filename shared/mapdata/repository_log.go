@@ -0,0 +1,645 @@
+package mapdata
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+
+	"FortressVision/shared/util"
+)
+
+// logMagic abre todo arquivo .fvlog (o último byte é a versão do layout do log).
+var logMagic = []byte("FVLOG\x01")
+
+// maxLogRecord limita o tamanho de um campo lido do log (protege contra lixo no fim do arquivo).
+const maxLogRecord = 1 << 30
+
+// logCompactMinGarbage é o mínimo de bytes em registros substituídos para que a
+// abertura reescreva o arquivo (ver openLogRepository).
+const logCompactMinGarbage = 64 << 20
+
+// Tipos de registro do log.
+const (
+	logRecordChunk      byte = 'C'
+	logRecordMetadata   byte = 'M'
+	logRecordDictionary byte = 'D'
+)
+
+// logEntry aponta para os dados do registro mais recente de um chunk no arquivo.
+type logEntry struct {
+	offset  int64
+	size    int
+	mtime   int64
+	isEmpty bool
+}
+
+// logRepository grava o mundo num arquivo simples só de acréscimos (.fvlog).
+// Cada gravação vira um registro novo no fim do arquivo; na abertura o arquivo
+// é lido uma vez e o último registro de cada chave vence. Não depende de SQLite,
+// o que o torna adequado para arquivos de mundos distribuídos e abertos só para leitura.
+//
+// Os registros substituídos continuam ocupando o arquivo até uma compactação
+// (Vacuum), que o reescreve só com o registro mais recente de cada chave. A
+// abertura para escrita compacta sozinha quando os registros substituídos passam
+// de logCompactMinGarbage e da metade do arquivo; numa sessão longa o arquivo
+// cresce a cada gravação de chunk até a próxima abertura ou Vacuum.
+//
+// Layout: logMagic seguido de registros
+//
+//	'C' x y z mtime(varint) isEmpty(byte) len(uvarint) data
+//	'M' len(uvarint) chave len(uvarint) valor
+//	'D' len(uvarint) chave len(uvarint) dados
+type logRepository struct {
+	mu       sync.RWMutex
+	path     string
+	file     *os.File
+	size     int64
+	garbage  int64 // Bytes (aproximados) de registros já substituídos
+	readOnly bool
+
+	chunks map[util.DFCoord]logEntry
+	meta   map[string]string
+	dicts  map[string][]byte
+}
+
+// LogWorldPath retorna o caminho do arquivo .fvlog de um mundo.
+func LogWorldPath(worldName string) string {
+	return filepath.Join(SavesDir, fmt.Sprintf("%s.fvlog", worldName))
+}
+
+// openLogRepository abre (ou cria, se não for readOnly) um arquivo .fvlog e indexa seus registros.
+// Um registro incompleto no fim (queda durante a escrita) é descartado.
+func openLogRepository(path string, readOnly bool) (*logRepository, error) {
+	flag := os.O_RDONLY
+	if !readOnly {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, err
+		}
+		flag = os.O_RDWR | os.O_CREATE
+	}
+	f, err := os.OpenFile(path, flag, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &logRepository{
+		path:     path,
+		file:     f,
+		readOnly: readOnly,
+		chunks:   make(map[util.DFCoord]logEntry),
+		meta:     make(map[string]string),
+		dicts:    make(map[string][]byte),
+	}
+	if err := r.load(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("arquivo %s inválido: %w", path, err)
+	}
+
+	if version, err := strconv.Atoi(r.meta[formatVersionKey]); err == nil && version > CurrentFormatVersion {
+		f.Close()
+		return nil, fmt.Errorf("%w: %s está na versão %d, este binário suporta até a %d",
+			ErrFormatTooNew, path, version, CurrentFormatVersion)
+	}
+	if !readOnly && r.meta[formatVersionKey] == "" {
+		if err := r.SaveMetadata(formatVersionKey, fmt.Sprint(CurrentFormatVersion)); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+
+	if !readOnly && r.garbage >= logCompactMinGarbage && r.garbage*2 >= r.size {
+		before := r.size
+		if err := r.rewrite(nil); err != nil {
+			log.Printf("[Persistence] Aviso: falha ao compactar %s: %v", path, err)
+		} else {
+			log.Printf("[Persistence] Log %s compactado: %d -> %d bytes", path, before, r.size)
+		}
+	}
+
+	log.Printf("[Persistence] Log de mundo aberto: %s (%d chunks)", path, len(r.chunks))
+	return r, nil
+}
+
+// load lê o arquivo inteiro montando os índices em memória.
+func (r *logRepository) load() error {
+	stat, err := r.file.Stat()
+	if err != nil {
+		return err
+	}
+	if stat.Size() == 0 {
+		if r.readOnly {
+			return errors.New("arquivo vazio")
+		}
+		if _, err := r.file.Write(logMagic); err != nil {
+			return err
+		}
+		r.size = int64(len(logMagic))
+		return nil
+	}
+
+	br := &countingReader{r: bufio.NewReader(io.NewSectionReader(r.file, 0, stat.Size()))}
+	magic := make([]byte, len(logMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || !bytes.Equal(magic, logMagic) {
+		return errors.New("cabeçalho FVLOG ausente")
+	}
+
+	valid := br.n
+	for {
+		if err := r.readRecord(br); err != nil {
+			if err != io.EOF {
+				log.Printf("[Persistence] Log com registro incompleto em %d (%v). Ignorando o restante.", valid, err)
+			}
+			break
+		}
+		valid = br.n
+	}
+
+	r.size = valid
+	if valid < stat.Size() && !r.readOnly {
+		return r.file.Truncate(valid)
+	}
+	return nil
+}
+
+// readRecord lê um registro e atualiza o índice. Retorna io.EOF no fim limpo do arquivo.
+func (r *logRepository) readRecord(br *countingReader) error {
+	kind, err := br.ReadByte()
+	if err != nil {
+		return err
+	}
+
+	switch kind {
+	case logRecordChunk:
+		model, size, err := readChunkRecordHeader(br, maxLogRecord)
+		if err != nil {
+			return err
+		}
+		offset := br.n
+		if _, err := io.CopyN(io.Discard, br, int64(size)); err != nil {
+			return unexpected(err)
+		}
+		origin := util.NewDFCoord(model.X, model.Y, model.Z)
+		r.setChunk(origin, logEntry{offset: offset, size: int(size), mtime: model.MTime, isEmpty: model.IsEmpty})
+
+	case logRecordMetadata, logRecordDictionary:
+		key, err := readLogBytes(br)
+		if err != nil {
+			return unexpected(err)
+		}
+		value, err := readLogBytes(br)
+		if err != nil {
+			return unexpected(err)
+		}
+		r.setKeyValue(kind, string(key), value)
+
+	default:
+		return fmt.Errorf("tipo de registro desconhecido %q", kind)
+	}
+	return nil
+}
+
+// readChunkRecordHeader lê o cabeçalho de um registro 'C' (depois do tipo) e
+// retorna o modelo sem os dados e o tamanho dos dados que vêm em seguida,
+// recusando registros com mais de limit bytes de dados.
+func readChunkRecordHeader(br io.ByteReader, limit uint64) (*ChunkModel, uint64, error) {
+	var vals [4]int64
+	var err error
+	for i := range vals {
+		if vals[i], err = binary.ReadVarint(br); err != nil {
+			return nil, 0, unexpected(err)
+		}
+	}
+	empty, err := br.ReadByte()
+	if err != nil {
+		return nil, 0, unexpected(err)
+	}
+	size, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, 0, unexpected(err)
+	}
+	if size > limit {
+		return nil, 0, fmt.Errorf("registro de %d bytes", size)
+	}
+	model := &ChunkModel{X: int32(vals[0]), Y: int32(vals[1]), Z: int32(vals[2]), MTime: vals[3], IsEmpty: empty != 0}
+	return model, size, nil
+}
+
+// setChunk atualiza o índice de um chunk, contando o registro anterior como lixo. Requer mu.
+func (r *logRepository) setChunk(origin util.DFCoord, entry logEntry) {
+	if old, ok := r.chunks[origin]; ok {
+		r.garbage += int64(old.size)
+	}
+	r.chunks[origin] = entry
+}
+
+// setKeyValue atualiza o índice de um registro 'M' ou 'D', contando o anterior como lixo. Requer mu.
+func (r *logRepository) setKeyValue(kind byte, key string, value []byte) {
+	if kind == logRecordMetadata {
+		if old, ok := r.meta[key]; ok {
+			r.garbage += int64(len(key) + len(old))
+		}
+		r.meta[key] = string(value)
+		return
+	}
+	if old, ok := r.dicts[key]; ok {
+		r.garbage += int64(len(key) + len(old))
+	}
+	r.dicts[key] = append([]byte(nil), value...)
+}
+
+// append grava registros no fim do arquivo. O tamanho só avança depois de uma
+// escrita completa; se ela falhar, o arquivo volta ao tamanho anterior. Requer mu.
+func (r *logRepository) append(record []byte) (int64, error) {
+	if r.readOnly {
+		return 0, ErrReadOnly
+	}
+	start := r.size
+	if _, err := r.file.WriteAt(record, start); err != nil {
+		r.truncate(start)
+		return 0, err
+	}
+	r.size += int64(len(record))
+	return start, nil
+}
+
+// truncate descarta o que foi escrito depois de size (escrita que falhou no meio).
+// Se nem isso funcionar, a próxima escrita sobrescreve os bytes a partir de size
+// e a abertura descarta o registro incompleto. Requer mu.
+func (r *logRepository) truncate(size int64) {
+	r.size = size
+	if err := r.file.Truncate(size); err != nil {
+		log.Printf("[Persistence] Aviso: falha ao truncar %s em %d: %v", r.path, size, err)
+	}
+}
+
+// encodeChunkRecord serializa um registro 'C'; dataOffset é a posição dos dados dentro do registro.
+func encodeChunkRecord(buf *bytes.Buffer, model *ChunkModel) (dataOffset int) {
+	var tmp [binary.MaxVarintLen64]byte
+	buf.WriteByte(logRecordChunk)
+	for _, v := range []int64{int64(model.X), int64(model.Y), int64(model.Z), model.MTime} {
+		buf.Write(tmp[:binary.PutVarint(tmp[:], v)])
+	}
+	if model.IsEmpty {
+		buf.WriteByte(1)
+	} else {
+		buf.WriteByte(0)
+	}
+	buf.Write(tmp[:binary.PutUvarint(tmp[:], uint64(len(model.Data)))])
+	dataOffset = buf.Len()
+	buf.Write(model.Data)
+	return dataOffset
+}
+
+func (r *logRepository) LoadChunk(origin util.DFCoord) (*ChunkModel, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	entry, ok := r.chunks[origin]
+	if !ok {
+		return nil, ErrChunkNotFound
+	}
+	return r.readChunk(origin, entry)
+}
+
+// LoadRegion filtra o índice em RAM e lê os blobs da caixa em ordem de offset,
+// para que a leitura do arquivo ande sempre para a frente. As leituras seguram mu
+// para que uma compactação não troque o arquivo no meio.
+func (r *logRepository) LoadRegion(min, max util.DFCoord) ([]*ChunkModel, error) {
+	type hit struct {
+		origin util.DFCoord
+		entry  logEntry
+	}
+	var hits []hit
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for origin, entry := range r.chunks {
+		if inRegion(origin, min, max) {
+			hits = append(hits, hit{origin, entry})
+		}
+	}
+	slices.SortFunc(hits, func(a, b hit) int { return cmp.Compare(a.entry.offset, b.entry.offset) })
+
+	models := make([]*ChunkModel, 0, len(hits))
+	for _, h := range hits {
+		model, err := r.readChunk(h.origin, h.entry)
+		if err != nil {
+			return nil, err
+		}
+		models = append(models, model)
+	}
+	return models, nil
+}
+
+// readChunk lê do arquivo o blob apontado por uma entrada do índice. Requer mu (leitura).
+func (r *logRepository) readChunk(origin util.DFCoord, entry logEntry) (*ChunkModel, error) {
+	data := make([]byte, entry.size)
+	if _, err := r.file.ReadAt(data, entry.offset); err != nil {
+		return nil, err
+	}
+	return &ChunkModel{
+		Key:     chunkKey(origin),
+		X:       origin.X,
+		Y:       origin.Y,
+		Z:       origin.Z,
+		Data:    data,
+		MTime:   entry.mtime,
+		IsEmpty: entry.isEmpty,
+	}, nil
+}
+
+func (r *logRepository) SaveChunk(model *ChunkModel) error {
+	_, err := r.SaveChunks([]*ChunkModel{model})
+	return err
+}
+
+// SaveChunks grava o lote numa única escrita seguida de fsync.
+func (r *logRepository) SaveChunks(models []*ChunkModel) (int, error) {
+	var buf bytes.Buffer
+	offsets := make([]int, len(models))
+	for i, model := range models {
+		offsets[i] = encodeChunkRecord(&buf, model)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	start, err := r.append(buf.Bytes())
+	if err != nil {
+		return 0, err
+	}
+	if err := r.file.Sync(); err != nil {
+		r.truncate(start)
+		return 0, err
+	}
+	for i, model := range models {
+		origin := util.NewDFCoord(model.X, model.Y, model.Z)
+		r.setChunk(origin, logEntry{
+			offset:  start + int64(offsets[i]),
+			size:    len(model.Data),
+			mtime:   model.MTime,
+			isEmpty: model.IsEmpty,
+		})
+	}
+	return len(models), nil
+}
+
+func (r *logRepository) ChunkCount() (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return int64(len(r.chunks)), nil
+}
+
+func (r *logRepository) ChunkHeaders() ([]ChunkHeader, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	headers := make([]ChunkHeader, 0, len(r.chunks))
+	for origin, e := range r.chunks {
+		headers = append(headers, ChunkHeader{Origin: origin, MTime: e.mtime, IsEmpty: e.isEmpty})
+	}
+	return headers, nil
+}
+
+func (r *logRepository) ChunkBounds() (util.DFCoord, util.DFCoord, bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var lo, hi util.DFCoord
+	found := false
+	for origin, e := range r.chunks {
+		if e.isEmpty {
+			continue
+		}
+		if !found {
+			lo, hi, found = origin, origin, true
+			continue
+		}
+		lo = util.NewDFCoord(min(lo.X, origin.X), min(lo.Y, origin.Y), min(lo.Z, origin.Z))
+		hi = util.NewDFCoord(max(hi.X, origin.X), max(hi.Y, origin.Y), max(hi.Z, origin.Z))
+	}
+	return lo, hi, found, nil
+}
+
+// encodeKeyValueRecord serializa um registro 'M' ou 'D'.
+func encodeKeyValueRecord(buf *bytes.Buffer, kind byte, key string, value []byte) {
+	var tmp [binary.MaxVarintLen64]byte
+	buf.WriteByte(kind)
+	buf.Write(tmp[:binary.PutUvarint(tmp[:], uint64(len(key)))])
+	buf.WriteString(key)
+	buf.Write(tmp[:binary.PutUvarint(tmp[:], uint64(len(value)))])
+	buf.Write(value)
+}
+
+// saveKeyValue grava um registro 'M' ou 'D'.
+func (r *logRepository) saveKeyValue(kind byte, key string, value []byte) error {
+	var buf bytes.Buffer
+	encodeKeyValueRecord(&buf, kind, key, value)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.append(buf.Bytes()); err != nil {
+		return err
+	}
+	r.setKeyValue(kind, key, value)
+	return nil
+}
+
+func (r *logRepository) SaveDictionary(key string, data []byte) error {
+	return r.saveKeyValue(logRecordDictionary, key, data)
+}
+
+func (r *logRepository) GetDictionary(key string) ([]byte, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	data, ok := r.dicts[key]
+	if !ok {
+		return nil, fmt.Errorf("dicionário %q não encontrado", key)
+	}
+	return data, nil
+}
+
+func (r *logRepository) SaveMetadata(key, value string) error {
+	return r.saveKeyValue(logRecordMetadata, key, []byte(value))
+}
+
+func (r *logRepository) GetMetadata(key string) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	value, ok := r.meta[key]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrMetadataNotFound, key)
+	}
+	return value, nil
+}
+
+// Vacuum reescreve o arquivo só com o registro mais recente de cada chave,
+// devolvendo ao disco o espaço dos registros substituídos.
+func (r *logRepository) Vacuum() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rewrite(nil)
+}
+
+// PurgeEmpty reescreve o arquivo sem os chunks marcados como vazios (o log não
+// tem registro de remoção).
+func (r *logRepository) PurgeEmpty() (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var n int64
+	for _, e := range r.chunks {
+		if e.isEmpty {
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	if err := r.rewrite(func(e logEntry) bool { return !e.isEmpty }); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// rewrite grava num arquivo ao lado os metadados, os dicionários e os chunks
+// atuais (os aceitos por keep, ou todos com keep nil) e o troca pelo original.
+// Se algo falhar antes da troca, o original fica intacto. Requer mu.
+func (r *logRepository) rewrite(keep func(logEntry) bool) error {
+	if r.readOnly {
+		return ErrReadOnly
+	}
+	tmpPath := r.path + ".compact"
+	f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	chunks, size, err := r.writeCompacted(f, keep)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	// No Windows não dá para renomear por cima de um arquivo aberto
+	r.file.Close()
+	if err := os.Rename(tmpPath, r.path); err != nil {
+		os.Remove(tmpPath)
+		if r.file, err = os.OpenFile(r.path, os.O_RDWR, 0644); err != nil {
+			return fmt.Errorf("reabrir %s: %w", r.path, err)
+		}
+		return err
+	}
+	if r.file, err = os.OpenFile(r.path, os.O_RDWR, 0644); err != nil {
+		return fmt.Errorf("reabrir %s: %w", r.path, err)
+	}
+	r.chunks, r.size, r.garbage = chunks, size, 0
+	return nil
+}
+
+// writeCompacted escreve em w o conteúdo atual do log e devolve o índice dos
+// chunks no arquivo novo e o tamanho dele. Requer mu.
+func (r *logRepository) writeCompacted(w io.Writer, keep func(logEntry) bool) (map[util.DFCoord]logEntry, int64, error) {
+	bw := bufio.NewWriter(w)
+	var buf bytes.Buffer
+	var size int64
+	flush := func() error {
+		n, err := bw.Write(buf.Bytes())
+		size += int64(n)
+		buf.Reset()
+		return err
+	}
+
+	buf.Write(logMagic)
+	for _, key := range slices.Sorted(maps.Keys(r.meta)) {
+		encodeKeyValueRecord(&buf, logRecordMetadata, key, []byte(r.meta[key]))
+	}
+	for _, key := range slices.Sorted(maps.Keys(r.dicts)) {
+		encodeKeyValueRecord(&buf, logRecordDictionary, key, r.dicts[key])
+	}
+	if err := flush(); err != nil {
+		return nil, 0, err
+	}
+
+	// Na ordem do arquivo antigo, para ler sempre para a frente
+	origins := make([]util.DFCoord, 0, len(r.chunks))
+	for origin, e := range r.chunks {
+		if keep == nil || keep(e) {
+			origins = append(origins, origin)
+		}
+	}
+	slices.SortFunc(origins, func(a, b util.DFCoord) int { return cmp.Compare(r.chunks[a].offset, r.chunks[b].offset) })
+
+	chunks := make(map[util.DFCoord]logEntry, len(origins))
+	for _, origin := range origins {
+		model, err := r.readChunk(origin, r.chunks[origin])
+		if err != nil {
+			return nil, 0, err
+		}
+		dataOffset := encodeChunkRecord(&buf, model)
+		chunks[origin] = logEntry{offset: size + int64(dataOffset), size: len(model.Data), mtime: model.MTime, isEmpty: model.IsEmpty}
+		if err := flush(); err != nil {
+			return nil, 0, err
+		}
+	}
+	return chunks, size, bw.Flush()
+}
+
+func (r *logRepository) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file.Close()
+}
+
+// countingReader conta os bytes lidos para saber o offset de cada registro.
+type countingReader struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.n++
+	}
+	return b, err
+}
+
+func readLogBytes(br *countingReader) ([]byte, error) {
+	size, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, err
+	}
+	if size > maxLogRecord {
+		return nil, fmt.Errorf("registro de %d bytes", size)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(br, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// unexpected converte um EOF no meio de um registro em ErrUnexpectedEOF.
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
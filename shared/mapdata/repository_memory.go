@@ -0,0 +1,226 @@
+package mapdata
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"FortressVision/shared/util"
+)
+
+// memoryRepository mantém tudo em RAM; some quando o processo termina.
+// Serve para testes e para sessões que não devem tocar o disco.
+type memoryRepository struct {
+	mu     sync.RWMutex
+	chunks map[util.DFCoord]ChunkModel
+	dicts  map[string][]byte
+	meta   map[string]string
+	// snaps guarda as versões de cada chunk em ordem de gravação
+	snaps map[util.DFCoord][]ChunkSnapshotModel
+}
+
+func newMemoryRepository() *memoryRepository {
+	return &memoryRepository{
+		chunks: make(map[util.DFCoord]ChunkModel),
+		dicts:  make(map[string][]byte),
+		meta:   map[string]string{formatVersionKey: fmt.Sprint(CurrentFormatVersion)},
+		snaps:  make(map[util.DFCoord][]ChunkSnapshotModel),
+	}
+}
+
+func (r *memoryRepository) LoadChunk(origin util.DFCoord) (*ChunkModel, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	model, ok := r.chunks[origin]
+	if !ok {
+		return nil, ErrChunkNotFound
+	}
+	return &model, nil
+}
+
+func (r *memoryRepository) LoadRegion(min, max util.DFCoord) ([]*ChunkModel, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var models []*ChunkModel
+	for origin, model := range r.chunks {
+		if inRegion(origin, min, max) {
+			m := model
+			models = append(models, &m)
+		}
+	}
+	return models, nil
+}
+
+func (r *memoryRepository) SaveChunk(model *ChunkModel) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.store(model)
+	return nil
+}
+
+func (r *memoryRepository) SaveChunks(models []*ChunkModel) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, model := range models {
+		r.store(model)
+	}
+	return len(models), nil
+}
+
+// store grava uma cópia do modelo (o chamador pode reutilizar o buffer). Requer mu.
+func (r *memoryRepository) store(model *ChunkModel) {
+	m := *model
+	m.Data = append([]byte(nil), model.Data...)
+	r.chunks[util.NewDFCoord(m.X, m.Y, m.Z)] = m
+}
+
+func (r *memoryRepository) ChunkCount() (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return int64(len(r.chunks)), nil
+}
+
+func (r *memoryRepository) ChunkHeaders() ([]ChunkHeader, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	headers := make([]ChunkHeader, 0, len(r.chunks))
+	for origin, m := range r.chunks {
+		headers = append(headers, ChunkHeader{Origin: origin, MTime: m.MTime, IsEmpty: m.IsEmpty})
+	}
+	return headers, nil
+}
+
+func (r *memoryRepository) ChunkBounds() (util.DFCoord, util.DFCoord, bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var lo, hi util.DFCoord
+	found := false
+	for origin, m := range r.chunks {
+		if m.IsEmpty {
+			continue
+		}
+		if !found {
+			lo, hi, found = origin, origin, true
+			continue
+		}
+		lo = util.NewDFCoord(min(lo.X, origin.X), min(lo.Y, origin.Y), min(lo.Z, origin.Z))
+		hi = util.NewDFCoord(max(hi.X, origin.X), max(hi.Y, origin.Y), max(hi.Z, origin.Z))
+	}
+	return lo, hi, found, nil
+}
+
+func (r *memoryRepository) SaveSnapshots(snaps []*ChunkSnapshotModel) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, snap := range snaps {
+		origin := util.NewDFCoord(snap.X, snap.Y, snap.Z)
+		m := *snap
+		m.Data = append([]byte(nil), snap.Data...)
+		m.Replaces = 0
+		versions := r.snaps[origin]
+		if snap.Replaces != 0 {
+			versions = slices.DeleteFunc(versions, func(v ChunkSnapshotModel) bool { return v.Time == snap.Replaces })
+		}
+		r.snaps[origin] = append(versions, m)
+	}
+	return nil
+}
+
+func (r *memoryRepository) LoadRegionAt(min, max util.DFCoord, at HistoryPoint) ([]*ChunkSnapshotModel, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var snaps []*ChunkSnapshotModel
+	for origin, versions := range r.snaps {
+		if !inRegion(origin, min, max) {
+			continue
+		}
+		for i := len(versions) - 1; i >= 0; i-- {
+			v := versions[i]
+			if (at.Tick > 0 && v.Tick > 0 && v.Tick <= at.Tick) || (at.Tick == 0 && v.Time <= at.Time.UnixNano()) {
+				snaps = append(snaps, &v)
+				break
+			}
+		}
+	}
+	return snaps, nil
+}
+
+func (r *memoryRepository) HistoryRange() (HistoryRange, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var hr HistoryRange
+	var first, last int64
+	for _, versions := range r.snaps {
+		for _, v := range versions {
+			if hr.Snapshots == 0 || v.Time < first {
+				first = v.Time
+			}
+			last = max(last, v.Time)
+			if v.Tick > 0 && (hr.First.Tick == 0 || v.Tick < hr.First.Tick) {
+				hr.First.Tick = v.Tick
+			}
+			hr.Last.Tick = max(hr.Last.Tick, v.Tick)
+			hr.Snapshots++
+		}
+	}
+	if hr.Snapshots > 0 {
+		hr.First.Time, hr.Last.Time = time.Unix(0, first), time.Unix(0, last)
+	}
+	return hr, nil
+}
+
+func (r *memoryRepository) PruneHistory(before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cut := before.UnixNano()
+	var n int64
+	for origin, versions := range r.snaps {
+		// Mantém a última versão anterior ao corte e todas as posteriores
+		keep := 0
+		for keep+1 < len(versions) && versions[keep+1].Time < cut {
+			keep++
+		}
+		if keep > 0 {
+			r.snaps[origin] = versions[keep:]
+			n += int64(keep)
+		}
+	}
+	return n, nil
+}
+
+func (r *memoryRepository) SaveDictionary(key string, data []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dicts[key] = append([]byte(nil), data...)
+	return nil
+}
+
+func (r *memoryRepository) GetDictionary(key string) ([]byte, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	data, ok := r.dicts[key]
+	if !ok {
+		return nil, fmt.Errorf("dicionário %q não encontrado", key)
+	}
+	return data, nil
+}
+
+func (r *memoryRepository) SaveMetadata(key, value string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.meta[key] = value
+	return nil
+}
+
+func (r *memoryRepository) GetMetadata(key string) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	value, ok := r.meta[key]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrMetadataNotFound, key)
+	}
+	return value, nil
+}
+
+func (r *memoryRepository) Close() error { return nil }
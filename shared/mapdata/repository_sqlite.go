@@ -0,0 +1,438 @@
+package mapdata
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"FortressVision/shared/util"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// sqliteRepository guarda o mundo num banco SQLite (.fv) via GORM.
+type sqliteRepository struct {
+	db *gorm.DB
+	// tempPath é a cópia migrada aberta por openSQLiteReadOnly, apagada no Close
+	tempPath string
+}
+
+// openSQLiteRepository abre (ou cria) o banco do mundo e roda as migrações de formato.
+// Bancos corrompidos são trocados pela cópia íntegra mais recente em BackupsDir ou,
+// sem cópia, renomeados e recriados; bancos mais novos que o binário são recusados.
+func openSQLiteRepository(worldName string) (*sqliteRepository, error) {
+	// O nome pode ter subpastas (ex.: o cache do cliente, uma pasta por servidor)
+	dbPath := WorldPath(worldName)
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+		return nil, err
+	}
+
+	restored := false
+	db, err := openCheckedDB(dbPath)
+	if err != nil {
+		log.Printf("[Persistence] Banco CORROMPIDO Detectado: %v. Procurando cópia de segurança...", err)
+		if restoreFromBackup(dbPath, worldName) {
+			db, err = openCheckedDB(dbPath)
+			restored = err == nil
+		}
+		if err != nil {
+			log.Printf("[Persistence] Sem cópia íntegra de %s. Iniciando auto-reset...", worldName)
+			return resetCorruptDatabase(dbPath)
+		}
+	}
+
+	// Migrações de formato: falhas mantêm o banco intacto (nada de reset) para
+	// que o cache do mundo não se perca; o passo interrompido retoma na próxima abertura.
+	if err := runMigrations(db, worldName); err != nil {
+		closeDB(db)
+		return nil, fmt.Errorf("banco %s não pôde ser migrado: %w", dbPath, err)
+	}
+
+	// Auto-Migrate do Esquema
+	if err := migrateSchema(db); err != nil {
+		log.Printf("[Persistence] Aviso: Falha ao migrar esquema principal: %v", err)
+	}
+
+	// Salva metadados iniciais
+	db.Save(&WorldMetadata{Key: formatVersionKey, Value: fmt.Sprint(CurrentFormatVersion)})
+	if restored {
+		// A cópia volta com MTimes mais antigos que os já vistos pelos clientes
+		db.Save(&WorldMetadata{Key: generationKey, Value: newGeneration()})
+	}
+
+	log.Printf("[Persistence] Banco de dados SQLite aberto e íntegro: %s", dbPath)
+	return &sqliteRepository{db: db}, nil
+}
+
+// openSQLiteReadOnly abre o banco de um mundo arquivado apenas para leitura,
+// sem nunca alterar o arquivo. Não recria bancos corrompidos e recusa bancos
+// mais novos que o binário (ErrFormatTooNew); bancos em formato antigo são
+// migrados numa cópia temporária, apagada no Close.
+func openSQLiteReadOnly(worldName string) (*sqliteRepository, error) {
+	path := WorldPath(worldName)
+	db, err := openReadOnlyDB(path)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := storedFormatVersion(db)
+	if err != nil {
+		closeDB(db)
+		return nil, err
+	}
+	if version > CurrentFormatVersion {
+		closeDB(db)
+		return nil, fmt.Errorf("%w: mundo %s está na versão %d, este binário suporta até a %d",
+			ErrFormatTooNew, worldName, version, CurrentFormatVersion)
+	}
+	if version == CurrentFormatVersion {
+		return &sqliteRepository{db: db}, nil
+	}
+	closeDB(db)
+
+	log.Printf("[Persistence] %s está no formato v%d: migrando uma cópia temporária para leitura", worldName, version)
+	tmpPath, err := migratedCopy(path, worldName)
+	if err != nil {
+		return nil, err
+	}
+	db, err = openReadOnlyDB(tmpPath)
+	if err != nil {
+		removeDatabaseFiles(tmpPath)
+		return nil, err
+	}
+	return &sqliteRepository{db: db, tempPath: tmpPath}, nil
+}
+
+// migratedCopy copia o banco em path (com o -wal, se houver) para a pasta
+// temporária e aplica as migrações pendentes na cópia. Retorna o caminho da cópia.
+func migratedCopy(path, worldName string) (string, error) {
+	tmp, err := os.CreateTemp("", "fv-readonly-*.fv")
+	if err != nil {
+		return "", err
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+
+	if err := copyFile(path, tmpPath); err != nil {
+		removeDatabaseFiles(tmpPath)
+		return "", err
+	}
+	if err := copyFile(path+"-wal", tmpPath+"-wal"); err != nil && !os.IsNotExist(err) {
+		removeDatabaseFiles(tmpPath)
+		return "", err
+	}
+	if err := migrateWorldFile(tmpPath, worldName); err != nil {
+		removeDatabaseFiles(tmpPath)
+		return "", fmt.Errorf("banco %s não pôde ser migrado: %w", path, err)
+	}
+	return tmpPath, nil
+}
+
+// removeDatabaseFiles apaga um banco e seus -wal/-shm.
+func removeDatabaseFiles(path string) {
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		os.Remove(path + suffix)
+	}
+}
+
+// openDB conecta no SQLite com parâmetros otimizados:
+// _journal_mode=WAL: Permite leituras concorrentes durante escritas
+// _busy_timeout=10000: Espera até 10s em vez de retornar "database is locked"
+// _synchronous=NORMAL: Equilíbrio entre performance e segurança
+func openDB(path string) (*gorm.DB, error) {
+	dsn := fmt.Sprintf("%s?_journal_mode=WAL&_busy_timeout=10000&_synchronous=NORMAL", path)
+	return gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+}
+
+// openCheckedDB conecta no banco e roda a verificação de integridade.
+func openCheckedDB(path string) (*gorm.DB, error) {
+	db, err := openDB(path)
+	if err != nil {
+		return nil, err
+	}
+	var integrity string
+	if err := db.Raw("PRAGMA integrity_check").Scan(&integrity).Error; err != nil || integrity != "ok" {
+		closeDB(db)
+		if err == nil {
+			err = errors.New(integrity)
+		}
+		return nil, err
+	}
+	return db, nil
+}
+
+func openReadOnlyDB(path string) (*gorm.DB, error) {
+	dsn := fmt.Sprintf("file:%s?mode=ro&_busy_timeout=5000", path)
+	return gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+}
+
+func closeDB(db *gorm.DB) {
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+}
+
+// migrateSchema cria ou completa as tabelas do formato atual.
+func migrateSchema(db *gorm.DB) error {
+	return db.AutoMigrate(&ChunkModel{}, &ChunkSnapshotModel{}, &WorldMetadata{}, &MaterialModel{}, &DictionaryModel{})
+}
+
+// migrateWorldFile aplica as migrações pendentes ao banco de um mundo fora de um MapDataStore.
+func migrateWorldFile(path, worldName string) error {
+	db, err := openDB(path)
+	if err != nil {
+		return err
+	}
+	defer closeDB(db)
+	if err := runMigrations(db, worldName); err != nil {
+		return err
+	}
+	if err := migrateSchema(db); err != nil {
+		return err
+	}
+	return db.Save(&WorldMetadata{Key: formatVersionKey, Value: fmt.Sprint(CurrentFormatVersion)}).Error
+}
+
+// moveCorruptDatabase renomeia o banco corrompido (e seus -wal/-shm, que não
+// podem ser aplicados a outro banco) para <banco>.corrupt_<data>.
+func moveCorruptDatabase(dbPath string) error {
+	backupPath := dbPath + ".corrupt_" + time.Now().Format("20060102_150405")
+	log.Printf("[Persistence] Renomeando banco corrompido para: %s", backupPath)
+
+	// Tenta renomear. Se falhar porque o arquivo está em uso, retornamos erro fatal.
+	if err := os.Rename(dbPath, backupPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("não foi possível mover banco corrompido (arquivo em uso?): %w", err)
+	}
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Rename(dbPath+suffix, backupPath+suffix); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("não foi possível mover %s do banco corrompido: %w", suffix, err)
+		}
+	}
+	return nil
+}
+
+// resetCorruptDatabase renomeia o arquivo corrompido e tenta criar um novo
+func resetCorruptDatabase(dbPath string) (*sqliteRepository, error) {
+	if err := moveCorruptDatabase(dbPath); err != nil {
+		return nil, err
+	}
+
+	// Tenta reconectar em modo limpo (isso criará um novo arquivo)
+	db, err := openDB(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("falha crítica: não foi possível criar novo banco após reset: %w", err)
+	}
+
+	if err := migrateSchema(db); err != nil {
+		return nil, fmt.Errorf("falha na migração do banco novo: %w", err)
+	}
+	db.Save(&WorldMetadata{Key: formatVersionKey, Value: fmt.Sprint(CurrentFormatVersion)})
+
+	log.Printf("[Persistence] Novo banco de dados criado com sucesso: %s", dbPath)
+	return &sqliteRepository{db: db}, nil
+}
+
+// Backup grava uma cópia compacta e consistente do banco em dest (VACUUM INTO),
+// sem bloquear as escritas do scanner (WAL).
+func (r *sqliteRepository) Backup(dest string) error {
+	return r.db.Exec("VACUUM INTO ?", dest).Error
+}
+
+// Vacuum reconstrói o arquivo do banco (VACUUM) e zera o -wal.
+func (r *sqliteRepository) Vacuum() error {
+	if err := r.db.Exec("VACUUM").Error; err != nil {
+		return err
+	}
+	return r.db.Exec("PRAGMA wal_checkpoint(TRUNCATE)").Error
+}
+
+// PurgeEmpty apaga os chunks marcados como vazios.
+func (r *sqliteRepository) PurgeEmpty() (int64, error) {
+	res := r.db.Where("is_empty = ?", true).Delete(&ChunkModel{})
+	return res.RowsAffected, res.Error
+}
+
+func (r *sqliteRepository) LoadChunk(origin util.DFCoord) (*ChunkModel, error) {
+	var model ChunkModel
+	err := r.db.First(&model, "key = ?", chunkKey(origin)).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrChunkNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+// LoadRegion percorre o intervalo de chaves Morton da caixa pela chave primária;
+// o filtro em x/y/z descarta as origens do intervalo que caem fora da caixa.
+func (r *sqliteRepository) LoadRegion(min, max util.DFCoord) ([]*ChunkModel, error) {
+	var models []*ChunkModel
+	err := r.db.Where("key BETWEEN ? AND ? AND x BETWEEN ? AND ? AND y BETWEEN ? AND ? AND z BETWEEN ? AND ?",
+		chunkKey(min), chunkKey(max), min.X, max.X, min.Y, max.Y, min.Z, max.Z).Find(&models).Error
+	return models, err
+}
+
+func (r *sqliteRepository) SaveChunk(model *ChunkModel) error {
+	// Upsert (Cria ou Atualiza)
+	return r.db.Save(model).Error
+}
+
+// SaveChunks usa uma transaction para agrupar todas as escritas em uma operação atômica.
+// Isso é MUITO mais rápido e elimina "database is locked" entre goroutines.
+func (r *sqliteRepository) SaveChunks(models []*ChunkModel) (int, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		for _, model := range models {
+			if err := tx.Save(model).Error; err != nil {
+				return fmt.Errorf("chunk %d_%d_%d: %w", model.X, model.Y, model.Z, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(models), nil
+}
+
+func (r *sqliteRepository) ChunkCount() (int64, error) {
+	var count int64
+	err := r.db.Model(&ChunkModel{}).Count(&count).Error
+	return count, err
+}
+
+func (r *sqliteRepository) ChunkHeaders() ([]ChunkHeader, error) {
+	// Retira apenas os metadados para não estourar a RAM
+	var models []ChunkModel
+	if err := r.db.Select("x", "y", "z", "m_time", "is_empty").Find(&models).Error; err != nil {
+		return nil, err
+	}
+	headers := make([]ChunkHeader, len(models))
+	for i, m := range models {
+		headers[i] = ChunkHeader{Origin: util.NewDFCoord(m.X, m.Y, m.Z), MTime: m.MTime, IsEmpty: m.IsEmpty}
+	}
+	return headers, nil
+}
+
+func (r *sqliteRepository) ChunkBounds() (util.DFCoord, util.DFCoord, bool, error) {
+	var res struct {
+		MinX, MinY, MinZ int32
+		MaxX, MaxY, MaxZ int32
+		N                int64
+	}
+	err := r.db.Model(&ChunkModel{}).Where("is_empty = ?", false).
+		Select("MIN(x) as min_x, MIN(y) as min_y, MIN(z) as min_z, " +
+			"MAX(x) as max_x, MAX(y) as max_y, MAX(z) as max_z, COUNT(*) as n").Scan(&res).Error
+	if err != nil || res.N == 0 {
+		return util.DFCoord{}, util.DFCoord{}, false, err
+	}
+	return util.NewDFCoord(res.MinX, res.MinY, res.MinZ), util.NewDFCoord(res.MaxX, res.MaxY, res.MaxZ), true, nil
+}
+
+func (r *sqliteRepository) SaveSnapshots(snaps []*ChunkSnapshotModel) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, snap := range snaps {
+			if snap.Replaces == 0 {
+				continue
+			}
+			if err := tx.Where("key = ? AND time = ?", snap.Key, snap.Replaces).Delete(&ChunkSnapshotModel{}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.CreateInBatches(snaps, 200).Error
+	})
+}
+
+// LoadRegionAt escolhe, por chunk da caixa, a versão mais recente até at numa
+// subconsulta agrupada pela chave, e busca os blobs dessas versões num único JOIN.
+func (r *sqliteRepository) LoadRegionAt(min, max util.DFCoord, at HistoryPoint) ([]*ChunkSnapshotModel, error) {
+	latest := r.db.Model(&ChunkSnapshotModel{}).Select("key, MAX(time) AS time").
+		Where("key BETWEEN ? AND ? AND x BETWEEN ? AND ? AND y BETWEEN ? AND ? AND z BETWEEN ? AND ?",
+			chunkKey(min), chunkKey(max), min.X, max.X, min.Y, max.Y, min.Z, max.Z)
+	if at.Tick > 0 {
+		latest = latest.Where("tick > 0 AND tick <= ?", at.Tick)
+	} else {
+		latest = latest.Where("time <= ?", at.Time.UnixNano())
+	}
+
+	var snaps []*ChunkSnapshotModel
+	err := r.db.Joins("JOIN (?) AS latest ON latest.key = chunk_snapshot_models.key AND latest.time = chunk_snapshot_models.time",
+		latest.Group("key")).Find(&snaps).Error
+	return snaps, err
+}
+
+func (r *sqliteRepository) HistoryRange() (HistoryRange, error) {
+	var res struct {
+		FirstTime, LastTime int64
+		FirstTick, LastTick int64
+		N                   int64
+	}
+	err := r.db.Model(&ChunkSnapshotModel{}).
+		Select("MIN(time) AS first_time, MAX(time) AS last_time, COALESCE(MIN(NULLIF(tick, 0)), 0) AS first_tick, MAX(tick) AS last_tick, COUNT(*) AS n").
+		Scan(&res).Error
+	if err != nil || res.N == 0 {
+		return HistoryRange{}, err
+	}
+	return HistoryRange{
+		First:     HistoryPoint{Tick: res.FirstTick, Time: time.Unix(0, res.FirstTime)},
+		Last:      HistoryPoint{Tick: res.LastTick, Time: time.Unix(0, res.LastTime)},
+		Snapshots: res.N,
+	}, nil
+}
+
+func (r *sqliteRepository) PruneHistory(before time.Time) (int64, error) {
+	cut := before.UnixNano()
+	res := r.db.Exec(`DELETE FROM chunk_snapshot_models WHERE time < ? AND EXISTS (
+		SELECT 1 FROM chunk_snapshot_models newer
+		WHERE newer.key = chunk_snapshot_models.key AND newer.time > chunk_snapshot_models.time AND newer.time < ?)`, cut, cut)
+	return res.RowsAffected, res.Error
+}
+
+func (r *sqliteRepository) SaveDictionary(key string, data []byte) error {
+	// Salva ou atualiza
+	return r.db.Save(&DictionaryModel{Key: key, Data: data}).Error
+}
+
+func (r *sqliteRepository) GetDictionary(key string) ([]byte, error) {
+	var model DictionaryModel
+	if err := r.db.First(&model, "key = ?", key).Error; err != nil {
+		return nil, err
+	}
+	return model.Data, nil
+}
+
+func (r *sqliteRepository) SaveMetadata(key, value string) error {
+	return r.db.Save(&WorldMetadata{Key: key, Value: value}).Error
+}
+
+func (r *sqliteRepository) GetMetadata(key string) (string, error) {
+	var meta WorldMetadata
+	if err := r.db.First(&meta, "key = ?", key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", fmt.Errorf("%w: %q", ErrMetadataNotFound, key)
+		}
+		return "", err
+	}
+	return meta.Value, nil
+}
+
+func (r *sqliteRepository) Close() error {
+	sqlDB, err := r.db.DB()
+	if err != nil {
+		return err
+	}
+	err = sqlDB.Close()
+	if r.tempPath != "" {
+		removeDatabaseFiles(r.tempPath)
+	}
+	return err
+}
@@ -0,0 +1,246 @@
+package mapdata
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"FortressVision/shared/util"
+)
+
+func chunkModelAt(x, y, z int32, data string) *ChunkModel {
+	origin := util.NewDFCoord(x, y, z)
+	return &ChunkModel{Key: chunkKey(origin), X: x, Y: y, Z: z, Data: []byte(data), MTime: int64(x + y + z), IsEmpty: data == ""}
+}
+
+// testRepositoryContract valida o comportamento comum a todos os backends.
+func testRepositoryContract(t *testing.T, repo ChunkRepository) {
+	t.Helper()
+
+	if _, err := repo.LoadChunk(util.NewDFCoord(0, 0, 0)); !errors.Is(err, ErrChunkNotFound) {
+		t.Fatalf("LoadChunk inexistente: err = %v, want ErrChunkNotFound", err)
+	}
+
+	if err := repo.SaveChunk(chunkModelAt(0, 0, 1, "a")); err != nil {
+		t.Fatal(err)
+	}
+	batch := []*ChunkModel{chunkModelAt(16, 0, 1, "b"), chunkModelAt(32, 48, 2, "c"), chunkModelAt(64, 64, 9, "")}
+	if n, err := repo.SaveChunks(batch); err != nil || n != len(batch) {
+		t.Fatalf("SaveChunks = %d, %v", n, err)
+	}
+	// Regravar substitui a versão anterior
+	if err := repo.SaveChunk(chunkModelAt(0, 0, 1, "a2")); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := repo.LoadChunk(util.NewDFCoord(0, 0, 1))
+	if err != nil || string(got.Data) != "a2" {
+		t.Fatalf("LoadChunk = %+v, %v", got, err)
+	}
+	got, err = repo.LoadChunk(util.NewDFCoord(64, 64, 9))
+	if err != nil || !got.IsEmpty || got.MTime != 137 {
+		t.Fatalf("LoadChunk vazio = %+v, %v", got, err)
+	}
+
+	// A caixa pega os dois chunks em z=1 e o de z=2, mas não o de (64,64,9)
+	region, err := repo.LoadRegion(util.NewDFCoord(0, 0, 1), util.NewDFCoord(48, 48, 2))
+	if err != nil || len(region) != 3 {
+		t.Fatalf("LoadRegion = %d chunks, %v; want 3", len(region), err)
+	}
+	for _, m := range region {
+		if m.Z == 1 && m.X == 0 && string(m.Data) != "a2" {
+			t.Fatalf("LoadRegion devolveu versão antiga: %+v", m)
+		}
+	}
+	if region, _ := repo.LoadRegion(util.NewDFCoord(16, 16, 1), util.NewDFCoord(16, 32, 9)); len(region) != 0 {
+		t.Fatalf("LoadRegion fora dos chunks gravados = %d, want 0", len(region))
+	}
+
+	if count, err := repo.ChunkCount(); err != nil || count != 4 {
+		t.Fatalf("ChunkCount = %d, %v", count, err)
+	}
+	headers, err := repo.ChunkHeaders()
+	if err != nil || len(headers) != 4 {
+		t.Fatalf("ChunkHeaders = %v, %v", headers, err)
+	}
+
+	// Chunks vazios não entram nos limites
+	lo, hi, ok, err := repo.ChunkBounds()
+	if err != nil || !ok || lo != util.NewDFCoord(0, 0, 1) || hi != util.NewDFCoord(32, 48, 2) {
+		t.Fatalf("ChunkBounds = %v, %v, %v, %v", lo, hi, ok, err)
+	}
+
+	if err := repo.SaveMetadata("MapSizeX", "12"); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := repo.GetMetadata("MapSizeX"); err != nil || v != "12" {
+		t.Fatalf("GetMetadata = %q, %v", v, err)
+	}
+	if _, err := repo.GetMetadata("Ausente"); !errors.Is(err, ErrMetadataNotFound) {
+		t.Fatalf("GetMetadata de chave ausente: err = %v, want ErrMetadataNotFound", err)
+	}
+
+	if err := repo.SaveDictionary("TiletypeList", []byte{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	if d, err := repo.GetDictionary("TiletypeList"); err != nil || len(d) != 3 {
+		t.Fatalf("GetDictionary = %v, %v", d, err)
+	}
+}
+
+func TestMemoryRepository(t *testing.T) {
+	testRepositoryContract(t, newMemoryRepository())
+}
+
+func TestSQLiteRepository(t *testing.T) {
+	chdirTemp(t)
+	repo, err := openSQLiteRepository("Contrato")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	testRepositoryContract(t, repo)
+}
+
+func TestSQLiteRepositoryInSubdir(t *testing.T) {
+	chdirTemp(t)
+	name := filepath.Join("cache", "localhost_8080", "Contrato")
+	repo, err := openSQLiteRepository(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	testRepositoryContract(t, repo)
+	if _, err := os.Stat(WorldPath(name)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Subpastas de SavesDir não aparecem como mundos salvos
+	if worlds, err := ListWorlds(); err != nil || len(worlds) != 0 {
+		t.Fatalf("ListWorlds = %v, %v", worlds, err)
+	}
+}
+
+func TestLogRepository(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Contrato.fvlog")
+	repo, err := openLogRepository(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testRepositoryContract(t, repo)
+	repo.Close()
+
+	// Simula uma queda no meio de uma escrita: o registro incompleto é descartado
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{logRecordChunk, 2, 4})
+	f.Close()
+
+	ro, err := openLogRepository(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ro.Close()
+	if count, _ := ro.ChunkCount(); count != 4 {
+		t.Fatalf("ChunkCount após reabrir = %d, want 4", count)
+	}
+	got, err := ro.LoadChunk(util.NewDFCoord(0, 0, 1))
+	if err != nil || string(got.Data) != "a2" {
+		t.Fatalf("LoadChunk após reabrir = %+v, %v", got, err)
+	}
+	if err := ro.SaveChunk(chunkModelAt(0, 0, 2, "x")); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("SaveChunk read-only: err = %v, want ErrReadOnly", err)
+	}
+}
+
+func TestLogRepositoryFailedAppendKeepsSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Falha.fvlog")
+	repo, err := openLogRepository(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	if err := repo.SaveChunk(chunkModelAt(0, 0, 1, "a")); err != nil {
+		t.Fatal(err)
+	}
+	size := repo.size
+
+	// Um descritor só de leitura faz a escrita falhar
+	writable := repo.file
+	if repo.file, err = os.Open(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SaveChunk(chunkModelAt(16, 0, 1, "b")); err == nil {
+		t.Fatal("SaveChunk com arquivo só de leitura não falhou")
+	}
+	if repo.size != size {
+		t.Fatalf("size após escrita falha = %d, want %d", repo.size, size)
+	}
+	repo.file.Close()
+	repo.file = writable
+
+	if err := repo.SaveChunk(chunkModelAt(32, 0, 1, "c")); err != nil {
+		t.Fatal(err)
+	}
+	repo.Close()
+	ro, err := openLogRepository(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ro.Close()
+	if count, _ := ro.ChunkCount(); count != 2 {
+		t.Fatalf("ChunkCount após reabrir = %d, want 2", count)
+	}
+}
+
+func TestLogRepositoryCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Compacta.fvlog")
+	repo, err := openLogRepository(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { repo.Close() }()
+	for i := 0; i < 50; i++ {
+		repo.SaveChunk(chunkModelAt(0, 0, 1, fmt.Sprintf("versão %d", i)))
+		repo.SaveMetadata("Chave", fmt.Sprint(i))
+	}
+	repo.SaveChunks([]*ChunkModel{chunkModelAt(16, 0, 1, "b"), chunkModelAt(32, 0, 1, "")})
+	repo.SaveDictionary("Dicionario", []byte("dados"))
+
+	before := repo.size
+	if err := repo.Vacuum(); err != nil {
+		t.Fatal(err)
+	}
+	if repo.size >= before || repo.garbage != 0 {
+		t.Fatalf("Vacuum: %d -> %d bytes, lixo %d", before, repo.size, repo.garbage)
+	}
+	if stat, err := os.Stat(path); err != nil || stat.Size() != repo.size {
+		t.Fatalf("arquivo com %v bytes (err %v), want %d", stat.Size(), err, repo.size)
+	}
+
+	if n, err := repo.PurgeEmpty(); err != nil || n != 1 {
+		t.Fatalf("PurgeEmpty = %d, %v", n, err)
+	}
+
+	// O arquivo compactado reabre com o mesmo conteúdo
+	repo.Close()
+	if repo, err = openLogRepository(path, false); err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := repo.ChunkCount(); count != 2 {
+		t.Fatalf("ChunkCount = %d, want 2", count)
+	}
+	if got, err := repo.LoadChunk(util.NewDFCoord(0, 0, 1)); err != nil || string(got.Data) != "versão 49" {
+		t.Fatalf("LoadChunk = %+v, %v", got, err)
+	}
+	if v, _ := repo.GetMetadata("Chave"); v != "49" {
+		t.Fatalf("metadado = %q, want 49", v)
+	}
+	if d, _ := repo.GetDictionary("Dicionario"); string(d) != "dados" {
+		t.Fatalf("dicionário = %q", d)
+	}
+}
@@ -0,0 +1,152 @@
+package mapdata
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"FortressVision/shared/pkg/dfproto"
+	"FortressVision/shared/util"
+)
+
+// scanStateKey é a chave em WorldMetadata onde o progresso da varredura total é persistido.
+const scanStateKey = "FullScanState"
+
+// ErrInvalidScanState indica um progresso de varredura gravado que não pôde ser lido.
+var ErrInvalidScanState = errors.New("estado de varredura inválido")
+
+// ScanState guarda o progresso persistente da varredura total (Full Scan) de um mundo.
+// Permite retomar o download após reiniciar o servidor ou reconectar ao DFHack.
+type ScanState struct {
+	// Geometria do mapa no momento da varredura (BlockPos e BlockSize do MapInfo).
+	// Se o mapa mudar, o progresso salvo deixa de valer.
+	MapOrigin util.DFCoord
+	MapSize   util.DFCoord
+
+	// Cursor do próximo lote a ser buscado. X e Y são índices de bloco relativos
+	// ao MapOrigin, Z é absoluto. Só vale quando CursorValid for true.
+	CursorZ     int32
+	CursorX     int32
+	CursorY     int32
+	CursorValid bool
+
+	// DoneLayers marca os níveis Z (absolutos) já varridos por completo.
+	DoneLayers map[int32]bool
+
+	UpdatedAt time.Time
+}
+
+// NewScanState cria um estado vazio (nenhuma camada varrida) para o mapa informado.
+func NewScanState(info *dfproto.MapInfo) *ScanState {
+	return &ScanState{
+		MapOrigin:  util.NewDFCoord(info.BlockPosX, info.BlockPosY, info.BlockPosZ),
+		MapSize:    util.NewDFCoord(info.BlockSizeX, info.BlockSizeY, info.BlockSizeZ),
+		DoneLayers: make(map[int32]bool),
+	}
+}
+
+// Matches indica se o estado foi gerado para a mesma geometria de mapa.
+func (st *ScanState) Matches(info *dfproto.MapInfo) bool {
+	return st.MapOrigin == util.NewDFCoord(info.BlockPosX, info.BlockPosY, info.BlockPosZ) &&
+		st.MapSize == util.NewDFCoord(info.BlockSizeX, info.BlockSizeY, info.BlockSizeZ)
+}
+
+// MinZ e MaxZ retornam o intervalo absoluto de níveis Z do mapa (inclusivo).
+func (st *ScanState) MinZ() int32 { return st.MapOrigin.Z }
+func (st *ScanState) MaxZ() int32 { return st.MapOrigin.Z + st.MapSize.Z - 1 }
+
+// IsLayerDone indica se o nível Z já foi varrido por completo.
+func (st *ScanState) IsLayerDone(z int32) bool {
+	return st.DoneLayers[z]
+}
+
+// MarkLayerDone marca o nível Z como concluído e invalida o cursor se ele apontava para esse nível.
+func (st *ScanState) MarkLayerDone(z int32) {
+	st.DoneLayers[z] = true
+	if st.CursorValid && st.CursorZ == z {
+		st.CursorValid = false
+	}
+}
+
+// SetCursor registra o próximo lote a ser buscado dentro do nível Z.
+func (st *ScanState) SetCursor(z, x, y int32) {
+	st.CursorZ, st.CursorX, st.CursorY = z, x, y
+	st.CursorValid = true
+}
+
+// ResetLayers marca os níveis do intervalo como pendentes (pedido explícito de nova varredura).
+func (st *ScanState) ResetLayers(minZ, maxZ int32) {
+	minZ = util.Max(minZ, st.MinZ())
+	maxZ = util.Min(maxZ, st.MaxZ())
+	for z := minZ; z <= maxZ; z++ {
+		delete(st.DoneLayers, z)
+	}
+	if st.CursorValid && st.CursorZ >= minZ && st.CursorZ <= maxZ {
+		st.CursorValid = false
+	}
+}
+
+// DoneCount retorna quantos níveis do mapa já foram concluídos.
+func (st *ScanState) DoneCount() int32 {
+	count := int32(0)
+	for z := st.MinZ(); z <= st.MaxZ(); z++ {
+		if st.DoneLayers[z] {
+			count++
+		}
+	}
+	return count
+}
+
+// Complete indica se todos os níveis do mapa já foram varridos.
+func (st *ScanState) Complete() bool {
+	return st.DoneCount() >= st.MapSize.Z
+}
+
+// NextLayer retorna o próximo nível Z a varrer: o do cursor, se houver,
+// senão o nível pendente mais alto (a varredura é Top-Down). Os níveis de skip
+// (ex.: os que ficaram com lotes faltando nesta execução) são pulados.
+func (st *ScanState) NextLayer(skip map[int32]bool) (int32, bool) {
+	if st.CursorValid && !st.DoneLayers[st.CursorZ] && !skip[st.CursorZ] {
+		return st.CursorZ, true
+	}
+	for z := st.MaxZ(); z >= st.MinZ(); z-- {
+		if !st.DoneLayers[z] && !skip[z] {
+			return z, true
+		}
+	}
+	return 0, false
+}
+
+// LoadScanState carrega o progresso da varredura total salvo no banco.
+// Retorna (nil, nil) se o mundo nunca teve uma varredura registrada e
+// ErrInvalidScanState se o progresso gravado não pôde ser lido; falhas do
+// banco são repassadas, para que o progresso salvo não seja sobrescrito.
+func (s *MapDataStore) LoadScanState() (*ScanState, error) {
+	value, err := s.GetMetadata(scanStateKey)
+	if errors.Is(err, ErrMetadataNotFound) || (err == nil && value == "") {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("lendo progresso da varredura: %w", err)
+	}
+
+	var st ScanState
+	if err := json.Unmarshal([]byte(value), &st); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidScanState, err)
+	}
+	if st.DoneLayers == nil {
+		st.DoneLayers = make(map[int32]bool)
+	}
+	return &st, nil
+}
+
+// SaveScanState persiste o progresso da varredura total no banco.
+func (s *MapDataStore) SaveScanState(st *ScanState) error {
+	st.UpdatedAt = time.Now()
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	return s.SaveMetadata(scanStateKey, string(data))
+}
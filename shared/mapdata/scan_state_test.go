@@ -0,0 +1,60 @@
+package mapdata
+
+import (
+	"errors"
+	"testing"
+
+	"FortressVision/shared/pkg/dfproto"
+)
+
+func TestLoadScanStateErrors(t *testing.T) {
+	s := NewMapDataStore()
+	// Sem banco a leitura falha: o chamador não pode tratar isso como "nunca varrido"
+	if st, err := s.LoadScanState(); err == nil || st != nil {
+		t.Fatalf("LoadScanState sem banco = %v, %v; want erro", st, err)
+	}
+
+	s.Repo = newMemoryRepository()
+	if st, err := s.LoadScanState(); err != nil || st != nil {
+		t.Fatalf("LoadScanState sem progresso = %v, %v; want nil, nil", st, err)
+	}
+
+	s.SaveMetadata(scanStateKey, "{quebrado")
+	if _, err := s.LoadScanState(); !errors.Is(err, ErrInvalidScanState) {
+		t.Fatalf("LoadScanState com JSON inválido: err = %v, want ErrInvalidScanState", err)
+	}
+
+	info := &dfproto.MapInfo{BlockSizeX: 2, BlockSizeY: 2, BlockSizeZ: 3, BlockPosZ: 10}
+	st := NewScanState(info)
+	st.MarkLayerDone(12)
+	if err := s.SaveScanState(st); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := s.LoadScanState()
+	if err != nil || !loaded.Matches(info) || !loaded.IsLayerDone(12) || loaded.DoneCount() != 1 {
+		t.Fatalf("LoadScanState = %+v, %v", loaded, err)
+	}
+}
+
+func TestNextLayerSkipsIncompleteLayers(t *testing.T) {
+	st := NewScanState(&dfproto.MapInfo{BlockSizeX: 2, BlockSizeY: 2, BlockSizeZ: 3, BlockPosZ: 10})
+	st.SetCursor(11, 1, 0)
+
+	if z, ok := st.NextLayer(nil); !ok || z != 11 {
+		t.Fatalf("NextLayer = %d, %v; want o nível do cursor (11)", z, ok)
+	}
+	// Um nível com lotes faltando volta a pendente, sem cursor, e é pulado nesta execução
+	st.ResetLayers(11, 11)
+	skip := map[int32]bool{11: true}
+	if z, ok := st.NextLayer(skip); !ok || z != 12 {
+		t.Fatalf("NextLayer = %d, %v; want 12", z, ok)
+	}
+	st.MarkLayerDone(12)
+	st.MarkLayerDone(10)
+	if z, ok := st.NextLayer(skip); ok {
+		t.Fatalf("NextLayer = %d com só o nível pulado pendente", z)
+	}
+	if st.Complete() {
+		t.Fatal("varredura completa com o nível 11 pendente")
+	}
+}
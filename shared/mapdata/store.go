@@ -4,23 +4,38 @@ import (
 	"log"
 	"math"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"FortressVision/shared/pkg/dfproto"
 	"FortressVision/shared/util"
-
-	"gorm.io/gorm"
 )
 
 // MapDataStore gerencia o armazenamento de tiles do mapa.
 // Pode representar o mapa inteiro ou uma "fatia" (slice) local.
 type MapDataStore struct {
+	// Mu protege entidades, dicionários e o estado do mundo aberto.
+	// Os chunks não passam por ele: ficam em shards com locks próprios (ver chunkmap.go).
 	Mu sync.RWMutex
 
 	// dbMu serializa escritas no banco SQLite (impede "database is locked")
 	dbMu sync.Mutex
 
-	// Chunks armazena os blocos do mapa (16x16x1)
-	Chunks map[util.DFCoord]*Chunk
+	// chunks armazena os blocos do mapa (16x16x1) carregados em RAM
+	chunks chunkMap
+
+	// MemoryBudget limita (em bytes) a RAM dos chunks; acima dele os menos usados
+	// são despejados (ver cache.go). 0 = sem limite.
+	MemoryBudget int64
+	cache        chunkCache
+
+	// History liga o histórico de versões dos chunks (ver history.go)
+	History  HistoryPolicy
+	history  chunkHistory
+	gameTick atomic.Int64 // Tick do DF informado por SetGameTick
+
+	// Backup define as cópias de segurança feitas por BackupWorld (ver backup.go)
+	Backup BackupPolicy
 
 	// Tiletypes é um cache para consulta de propriedades (shape, material, etc)
 	Tiletypes map[int32]*dfproto.Tiletype
@@ -29,12 +44,32 @@ type MapDataStore struct {
 	Buildings      map[int32]*BuildingInstance
 	Units          map[int32]*UnitInstance
 	BuildingLookup map[util.DFCoord]int32 // Mapa de Coordenada -> ID da Construção
+	BuildingDefs   []dfproto.BuildingDefinition
+	entities       entityState // O que falta gravar (ver entity_persistence.go)
 
 	// MapSize é o tamanho total detectado do mapa (opcional)
 	MapSize util.DFCoord
 
-	// DB é a conexão com o banco SQLite (GORM)
-	DB *gorm.DB
+	// Repo é o armazenamento persistente do mundo aberto (nil = só RAM)
+	Repo ChunkRepository
+
+	// Backend escolhe onde OpenInitialize grava o mundo (vazio = SQLite)
+	Backend StorageBackend
+
+	// WorldName é o mundo cujo banco está aberto em DB
+	WorldName string
+
+	// ReadOnly indica um mundo arquivado aberto com OpenReadOnly (nada é gravado)
+	ReadOnly bool
+
+	// worldGen muda a cada troca de mundo (ResetMemory, sob Mu); o write-back do
+	// cache o compara para descartar chunks coletados no mundo anterior
+	worldGen uint64
+
+	// countedAt é quando ChunkCount foi gravado nos metadados pela última vez e
+	// countStale indica gravações de chunks depois disso (ver saveWorldStats)
+	countedAt  time.Time
+	countStale bool
 
 	// FirstPerson indica se o sistema está em modo primeira pessoa (afeta desenho)
 	FirstPerson bool
@@ -70,9 +105,11 @@ const (
 )
 
 // Chunk representa um bloco 16x16x1 de tiles.
+// Um chunk publicado no store é imutável: alterações geram uma nova versão
+// (ver chunkMap.update), então quem recebe um *Chunk pode lê-lo sem lock.
 type Chunk struct {
 	Origin            util.DFCoord
-	Tiles             [16][16]*Tile
+	Tiles             *TileBlock                 // Tiles em layout compacto (nil = sem terreno carregado)
 	Plants            []dfproto.PlantDetail      // Cache de plantas (shrubs/saplings)
 	Buildings         []dfproto.BuildingInstance // Construções no bloco
 	Items             []dfproto.Item             // Itens soltos no bloco
@@ -82,12 +119,17 @@ type Chunk struct {
 	MTime             int64                      // Contador de modificações / versão
 	IsDirty           bool                       // Indica que o chunk foi alterado e precisa salvar
 	IsEmpty           bool                       // Indica que o bloco é ar/vazio
+
+	store *MapDataStore // Store dono do chunk, repassado às visões de Tile
+
+	// Campos do cache LRU, preenchidos ao publicar (ver chunkMap.update)
+	lastUse *atomic.Int64 // Último acesso (compartilhado entre as versões do chunk)
+	size    int64         // Estimativa de RAM desta versão
 }
 
 // NewMapDataStore cria um novo repositório de dados do mapa.
 func NewMapDataStore() *MapDataStore {
 	return &MapDataStore{
-		Chunks:         make(map[util.DFCoord]*Chunk),
 		Tiletypes:      make(map[int32]*dfproto.Tiletype),
 		Buildings:      make(map[int32]*BuildingInstance),
 		Units:          make(map[int32]*UnitInstance),
@@ -98,69 +140,198 @@ func NewMapDataStore() *MapDataStore {
 // MarkAsEmpty marca um chunk como conhecido e vazio (Ar).
 // Isso evita que o servidor fique requisitando o mesmo céu repetidamente.
 func (s *MapDataStore) MarkAsEmpty(origin util.DFCoord) {
-	s.Mu.Lock()
-	defer s.Mu.Unlock()
-	if _, exists := s.Chunks[origin]; !exists {
-		s.Chunks[origin] = &Chunk{
+	s.chunks.update(origin, func(cur *Chunk) *Chunk {
+		if cur != nil {
+			return cur
+		}
+		return &Chunk{
 			Origin:  origin,
 			IsEmpty: true,
 			MTime:   1,    // Versão mínima
-			IsDirty: true, // DEVE ser salvo no banco para não perder a informação do vazio no Purge
+			IsDirty: true, // DEVE ser salvo no banco para não perder a informação do vazio no despejo do cache
+			store:   s,
 		}
-	}
+	})
+	s.maybeEvict()
 }
 
 // GetTile retorna um tile em coordenadas globais. Retorna nil se não existir.
 func (s *MapDataStore) GetTile(pos util.DFCoord) *Tile {
-	s.Mu.RLock()
-	defer s.Mu.RUnlock()
-
-	blockPos := pos.BlockCoord()
-	chunk, ok := s.Chunks[blockPos]
+	chunk, ok := s.GetChunk(pos.BlockCoord())
 	if !ok {
 		return nil
 	}
 
 	local := pos.LocalCoord()
-	return chunk.Tiles[local.X][local.Y]
+	return s.tileView(chunk, local.X, local.Y)
 }
 
-// GetChunk retorna um chunk de forma segura (thread-safe).
-func (s *MapDataStore) GetChunk(origin util.DFCoord) (*Chunk, bool) {
-	s.Mu.RLock()
-	defer s.Mu.RUnlock()
-	c, ok := s.Chunks[origin]
-	return c, ok
+// tileView monta a visão de um tile ligada a este store.
+func (s *MapDataStore) tileView(chunk *Chunk, x, y int32) *Tile {
+	if !chunk.HasTile(x, y) {
+		return nil
+	}
+	t := NewTile(s, util.NewDFCoord(chunk.Origin.X+x, chunk.Origin.Y+y, chunk.Origin.Z))
+	chunk.Tiles.load(tileIndex(x, y), t)
+	return t
 }
 
-// GetOrCreateTile retorna um tile existente ou cria um novo se necessário.
-func (s *MapDataStore) GetOrCreateTile(pos util.DFCoord) *Tile {
-	s.Mu.Lock()
-	defer s.Mu.Unlock()
+// ReadTile preenche dst com o tile em pos sem alocar, ao contrário de GetTile,
+// que monta um Tile novo a cada chamada. É o acesso dos laços quentes (mesher),
+// que reaproveitam o mesmo Tile. Retorna false, sem mexer em dst, se não há tile em pos.
+func (s *MapDataStore) ReadTile(pos util.DFCoord, dst *Tile) bool {
+	chunk, i, ok := s.tileAt(pos)
+	if !ok {
+		return false
+	}
+	dst.container, dst.Position = s, pos
+	chunk.Tiles.load(i, dst)
+	return true
+}
 
-	blockPos := pos.BlockCoord()
-	chunk, ok := s.Chunks[blockPos]
+// TileShape devolve o formato do tile em pos sem montar um Tile (ver ReadTile);
+// ok = false se não há tile em pos.
+func (s *MapDataStore) TileShape(pos util.DFCoord) (shape dfproto.TiletypeShape, ok bool) {
+	chunk, i, ok := s.tileAt(pos)
 	if !ok {
-		// Tenta carregamento do SQLite (Streaming)
-		var err error
-		chunk, err = s.LoadChunk(blockPos)
-		if err != nil || chunk == nil {
-			// Se não existe no banco, cria novo e marca como sujo (Write-Back)
-			chunk = &Chunk{Origin: blockPos, IsDirty: true}
-		}
-		s.Chunks[blockPos] = chunk
+		return dfproto.ShapeNoShape, false
 	}
+	return s.shapeOf(int32(chunk.Tiles.TileType[i])), true
+}
 
+// TileHidden indica se o tile em pos está oculto, sem montar um Tile;
+// ok = false se não há tile em pos.
+func (s *MapDataStore) TileHidden(pos util.DFCoord) (hidden, ok bool) {
+	chunk, i, ok := s.tileAt(pos)
+	if !ok {
+		return false, false
+	}
+	return chunk.Tiles.flag(flagHidden, i), true
+}
+
+// TileLiquid devolve os níveis de água e magma do tile em pos sem montar um Tile;
+// ok = false se não há tile em pos.
+func (s *MapDataStore) TileLiquid(pos util.DFCoord) (water, magma int32, ok bool) {
+	chunk, i, ok := s.tileAt(pos)
+	if !ok {
+		return 0, 0, false
+	}
+	liquid := chunk.Tiles.Liquid[i]
+	return int32(liquid & 0x0f), int32(liquid >> 4), true
+}
+
+// tileAt localiza o chunk e o índice do tile em pos, se ele existir.
+func (s *MapDataStore) tileAt(pos util.DFCoord) (*Chunk, int, bool) {
+	chunk, ok := s.GetChunk(pos.BlockCoord())
+	if !ok {
+		return nil, 0, false
+	}
 	local := pos.LocalCoord()
-	tile := chunk.Tiles[local.X][local.Y]
-	if tile == nil {
-		tile = NewTile(s, pos)
-		chunk.Tiles[local.X][local.Y] = tile
+	if !chunk.HasTile(local.X, local.Y) {
+		return nil, 0, false
+	}
+	return chunk, tileIndex(local.X, local.Y), true
+}
+
+// shapeOf resolve o formato de um tiletype (ShapeNoShape se desconhecido).
+func (s *MapDataStore) shapeOf(tileType int32) dfproto.TiletypeShape {
+	if s.Tiletypes == nil {
+		return dfproto.ShapeNoShape
+	}
+	tt, ok := s.Tiletypes[tileType]
+	if !ok {
+		return dfproto.ShapeNoShape
+	}
+	return tt.Shape
+}
+
+// GetChunk retorna um chunk de forma segura (thread-safe).
+// O chunk devolvido é uma versão imutável e pode ser lido sem lock.
+// Chunks despejados pelo cache são relidos do banco de forma transparente.
+func (s *MapDataStore) GetChunk(origin util.DFCoord) (*Chunk, bool) {
+	c, evicted := s.chunks.lookup(origin)
+	if c != nil {
+		return c, true
+	}
+	if evicted {
+		return s.reload(origin)
 	}
+	return nil, false
+}
+
+// PutChunk publica um chunk vindo de fora do store (banco, rede), substituindo a versão em RAM.
+// O chunk passa a pertencer ao store e não deve mais ser alterado por quem o passou.
+func (s *MapDataStore) PutChunk(chunk *Chunk) {
+	chunk.store = s
+	s.chunks.put(chunk)
+	s.maybeEvict()
+}
+
+// DeleteChunk descarta o chunk de origin da RAM (sem tocar no banco).
+func (s *MapDataStore) DeleteChunk(origin util.DFCoord) {
+	s.chunks.delete(origin)
+}
+
+// DeleteChunksWhere descarta da RAM os chunks para os quais pred retorna true
+// e retorna quantos saíram. pred roda com o shard travado e não deve chamar o store.
+func (s *MapDataStore) DeleteChunksWhere(pred func(c *Chunk) bool) int {
+	return len(s.chunks.deleteWhere(pred))
+}
 
+// LoadedChunkCount retorna quantos chunks estão em RAM.
+func (s *MapDataStore) LoadedChunkCount() int {
+	return s.chunks.len()
+}
+
+// GetOrCreateTile retorna um tile existente ou cria um novo se necessário.
+// Como em GetTile, o retorno é uma visão: alterações são gravadas com SetTile.
+func (s *MapDataStore) GetOrCreateTile(pos util.DFCoord) *Tile {
+	blockPos := pos.BlockCoord()
+	local := pos.LocalCoord()
+
+	var tile *Tile
+	s.chunks.update(blockPos, func(cur *Chunk) *Chunk {
+		chunk := cur
+		if chunk == nil {
+			// Tenta carregamento do SQLite (Streaming)
+			var err error
+			chunk, err = s.LoadChunk(blockPos)
+			if err != nil || chunk == nil {
+				// Se não existe no banco, cria novo e marca como sujo (Write-Back)
+				chunk = &Chunk{Origin: blockPos, IsDirty: true, store: s}
+			}
+		}
+		if tile = s.tileView(chunk, local.X, local.Y); tile != nil {
+			return chunk
+		}
+		if chunk == cur {
+			chunk = cur.clone()
+			chunk.ownTiles()
+		}
+		tile = NewTile(s, pos)
+		chunk.SetTile(local.X, local.Y, tile)
+		return chunk
+	})
+	s.maybeEvict()
 	return tile
 }
 
+// SetTile grava as alterações de uma visão de tile (ver GetTile) no chunk que a contém.
+func (s *MapDataStore) SetTile(t *Tile) {
+	local := t.Position.LocalCoord()
+	s.chunks.update(t.Position.BlockCoord(), func(cur *Chunk) *Chunk {
+		if cur == nil {
+			return nil
+		}
+		chunk := cur.clone()
+		chunk.ownTiles()
+		chunk.SetTile(local.X, local.Y, t)
+		chunk.MTime++
+		chunk.IsDirty = true
+		return chunk
+	})
+}
+
 // StoreBlocks processa uma lista de blocos recebida do DFHack.
 func (s *MapDataStore) StoreBlocks(list *dfproto.BlockList) {
 	for _, block := range list.MapBlocks {
@@ -170,23 +341,39 @@ func (s *MapDataStore) StoreBlocks(list *dfproto.BlockList) {
 
 // StoreSingleBlock converte um bloco do Raw Proto e armazena/atualiza no store.
 // Retorna o tipo de mudança detectada (NoChange, TerrainChange ou VegetationChange).
+// O bloco é aplicado numa cópia do chunk, publicada só no final: leitores do
+// mesmo chunk continuam vendo a versão anterior enquanto os 256 tiles são processados.
 func (s *MapDataStore) StoreSingleBlock(block *dfproto.MapBlock) ChangeType {
-	s.Mu.Lock()
-	defer s.Mu.Unlock()
-
 	origin := util.NewDFCoord(block.MapX, block.MapY, block.MapZ).BlockCoord()
-	chunk, ok := s.Chunks[origin]
-	if !ok {
-		chunk = &Chunk{Origin: origin}
-		for y := 0; y < 16; y++ {
-			for x := 0; x < 16; x++ {
-				tilePos := util.NewDFCoord(block.MapX+int32(x), block.MapY+int32(y), block.MapZ)
-				chunk.Tiles[x][y] = NewTile(s, tilePos)
+
+	change := NoChange
+	s.chunks.update(origin, func(cur *Chunk) *Chunk {
+		var next *Chunk
+		change, next = s.mergeBlock(cur, origin, block)
+		return next
+	})
+	s.maybeEvict()
+	return change
+}
+
+// mergeBlock aplica um bloco do DFHack sobre a versão atual do chunk (nil = chunk novo).
+// Retorna o tipo de mudança e a versão a publicar (a própria cur, se nada mudou).
+func (s *MapDataStore) mergeBlock(cur *Chunk, origin util.DFCoord, block *dfproto.MapBlock) (ChangeType, *Chunk) {
+	var chunk *Chunk
+	// ownsTiles indica se chunk.Tiles já é uma cópia própria (e pode ser alterado)
+	ownsTiles := cur == nil
+	if cur == nil {
+		chunk = &Chunk{Origin: origin, store: s}
+		for y := int32(0); y < 16; y++ {
+			for x := int32(0); x < 16; x++ {
+				tilePos := util.NewDFCoord(block.MapX+x, block.MapY+y, block.MapZ)
+				chunk.SetTile(x, y, NewTile(s, tilePos))
 			}
 		}
-		s.Chunks[origin] = chunk
 		chunk.IsDirty = true // Primeiro carregamento sempre marca como dirty
 		log.Printf("[Store] Chunk criado em RAM: %v (Origem DFHack: %d,%d,%d)", origin, block.MapX, block.MapY, block.MapZ)
+	} else {
+		chunk = cur.clone()
 	}
 
 	// Flag para indicar se houve mudança real nos dados deste chunk
@@ -230,55 +417,42 @@ func (s *MapDataStore) StoreSingleBlock(block *dfproto.MapBlock) ChangeType {
 	for yy := int32(0); yy < 16; yy++ {
 		for xx := int32(0); xx < 16; xx++ {
 			idx := xx + (yy * 16)
-			// Nota: Chamamos GetOrCreateTileINTERNO sem lock, pois já temos o lock
+			// Todos os tiles do bloco caem no mesmo chunk (MapX/MapY são múltiplos de 16)
 			worldCoord := util.NewDFCoord(baseTileX+xx, baseTileY+yy, block.MapZ)
 
-			// Lógica inline de GetOrCreateTile para evitar deadlock (recursão de mutex)
-			blockPos := worldCoord.BlockCoord()
-			chunk, ok := s.Chunks[blockPos]
-			if !ok {
-				// Tenta carregamento do SQLite (Streaming)
-				var err error
-				chunk, err = s.LoadChunk(blockPos)
-				if err != nil || chunk == nil {
-					// Se não existe no banco, cria novo e marca como sujo
-					chunk = &Chunk{Origin: blockPos, IsDirty: true}
-					chunkChanged = true
-				}
-				s.Chunks[blockPos] = chunk
-			}
-
+			// Trabalha numa visão do tile e grava de volta no layout compacto ao final
 			local := worldCoord.LocalCoord()
-			tile := chunk.Tiles[local.X][local.Y]
+			tile := s.tileView(chunk, local.X, local.Y)
+			tileChanged := false
 			if tile == nil {
 				// NewTile precisa de 's' apenas para referência, não usa lock
 				tile = NewTile(s, worldCoord)
-				chunk.Tiles[local.X][local.Y] = tile
+				tileChanged = true
 			}
 
 			// Helper para verificar mudança
 			checkChange := func(name string, current *int32, newVal int32) {
 				if *current != newVal {
 					*current = newVal
-					chunkChanged = true
+					tileChanged = true
 				}
 			}
 			checkChangeBool := func(name string, current *bool, newVal bool) {
 				if *current != newVal {
 					*current = newVal
-					chunkChanged = true
+					tileChanged = true
 				}
 			}
 			checkChangeMatPair := func(name string, current *dfproto.MatPair, newVal dfproto.MatPair) {
 				if *current != newVal {
 					*current = newVal
-					chunkChanged = true
+					tileChanged = true
 				}
 			}
 			checkChangeCoord := func(name string, current *util.DFCoord, newVal util.DFCoord) {
 				if current.X != newVal.X || current.Y != newVal.Y || current.Z != newVal.Z {
 					*current = newVal
-					chunkChanged = true
+					tileChanged = true
 				}
 			}
 
@@ -332,7 +506,7 @@ func (s *MapDataStore) StoreSingleBlock(block *dfproto.MapBlock) ChangeType {
 				newDig := block.TileDigDesignation[idx]
 				if tile.DigDesignation != newDig {
 					tile.DigDesignation = newDig
-					chunkChanged = true
+					tileChanged = true
 				}
 			}
 			if len(block.DigDesignationMarker) > int(idx) {
@@ -350,7 +524,7 @@ func (s *MapDataStore) StoreSingleBlock(block *dfproto.MapBlock) ChangeType {
 				newVal := uint8(block.TreePercent[idx])
 				if tile.TrunkPercent != newVal {
 					tile.TrunkPercent = newVal
-					chunkChanged = true
+					tileChanged = true
 				}
 			}
 			if len(block.TreeX) > int(idx) && len(block.TreeY) > int(idx) && len(block.TreeZ) > int(idx) {
@@ -366,6 +540,15 @@ func (s *MapDataStore) StoreSingleBlock(block *dfproto.MapBlock) ChangeType {
 				// Reseta se não houver mais fluxo
 				checkChangeCoord("FlowVector", &tile.FlowVector, util.NewDFCoord(0, 0, 0))
 			}
+
+			if tileChanged {
+				if !ownsTiles {
+					chunk.ownTiles()
+					ownsTiles = true
+				}
+				chunk.SetTile(local.X, local.Y, tile)
+				chunkChanged = true
+			}
 		}
 	}
 
@@ -399,7 +582,7 @@ func (s *MapDataStore) StoreSingleBlock(block *dfproto.MapBlock) ChangeType {
 		// Fallback para detecção por shape (compatibilidade ou blocos incompletos)
 		for yy := int32(0); yy < 16; yy++ {
 			for xx := int32(0); xx < 16; xx++ {
-				tile := chunk.Tiles[xx][yy]
+				tile := s.tileView(chunk, xx, yy)
 				if tile != nil {
 					shape := tile.Shape()
 					if shape == dfproto.ShapeSapling || shape == dfproto.ShapeShrub {
@@ -437,38 +620,42 @@ func (s *MapDataStore) StoreSingleBlock(block *dfproto.MapBlock) ChangeType {
 	}
 
 	if chunkChanged {
-		return TerrainChange
+		return TerrainChange, chunk
 	}
 	if vegChanged {
-		return VegetationChange
+		return VegetationChange, chunk
+	}
+	if cur == nil {
+		return NoChange, chunk
 	}
-	return NoChange
+	return NoChange, cur
 }
 
 // StorePlants processa atualizações específicas de vegetação enviadas via rede.
 func (s *MapDataStore) StorePlants(chunkX, chunkY, chunkZ int32, plants []dfproto.PlantDetail) {
-	s.Mu.Lock()
-	defer s.Mu.Unlock()
-
 	origin := util.NewDFCoord(chunkX, chunkY, chunkZ)
-	chunk, ok := s.Chunks[origin]
-	if !ok {
-		return
-	}
-
-	chunk.Plants = plants
-	chunk.MTime++
-	chunk.IsDirty = true
+	s.chunks.update(origin, func(cur *Chunk) *Chunk {
+		if cur == nil {
+			return nil
+		}
+		chunk := cur.clone()
+		chunk.ownTiles()
+		chunk.Plants = plants
+		chunk.MTime++
+		chunk.IsDirty = true
 
-	// Atualiza os materiais nos tiles para refletir o crescimento/mudança
-	for _, p := range plants {
-		if p.Pos.X >= 0 && p.Pos.X < 16 && p.Pos.Y >= 0 && p.Pos.Y < 16 {
-			tile := chunk.Tiles[p.Pos.X][p.Pos.Y]
-			if tile != nil {
-				tile.Material = p.Material
+		// Atualiza os materiais nos tiles para refletir o crescimento/mudança
+		for _, p := range plants {
+			if p.Pos.X >= 0 && p.Pos.X < 16 && p.Pos.Y >= 0 && p.Pos.Y < 16 {
+				tile := s.tileView(chunk, p.Pos.X, p.Pos.Y)
+				if tile != nil {
+					tile.Material = p.Material
+					chunk.SetTile(p.Pos.X, p.Pos.Y, tile)
+				}
 			}
 		}
-	}
+		return chunk
+	})
 }
 
 // UpdateTiletypes atualiza o cache de definições de tiles.
@@ -481,87 +668,70 @@ func (s *MapDataStore) UpdateTiletypes(list *dfproto.TiletypeList) {
 	}
 }
 
-// Close fecha a conexão com o banco de dados SQLite.
+// Close fecha o repositório do mundo aberto, regravando ChunkCount se houve
+// gravações desde a última contagem. Não deve correr junto com Save.
 func (s *MapDataStore) Close() {
-	if s.DB != nil {
-		sqlDB, _ := s.DB.DB()
-		if sqlDB != nil {
-			log.Println("[Persistence] Fechando banco de dados SQLite...")
-			sqlDB.Close()
+	if s.Repo != nil {
+		// A contagem do mundo que fecha fica exata para a lista de mundos
+		if s.countStale {
+			s.saveChunkCount()
+		}
+		s.countedAt, s.countStale = time.Time{}, false
+		log.Println("[Persistence] Fechando banco de dados...")
+		if err := s.Repo.Close(); err != nil {
+			log.Printf("[Persistence] Aviso: erro ao fechar banco: %v", err)
 		}
 	}
 }
 
-// Purge descarrega chunks distantes da RAM (streaming).
-func (s *MapDataStore) Purge(center util.DFCoord, radius float32) {
-	s.Mu.Lock()
-	defer s.Mu.Unlock()
-
-	radiusSq := radius * radius
-	for origin, chunk := range s.Chunks {
-		dx := float32(origin.X - center.X)
-		dy := float32(origin.Y - center.Y)
-		dz := float32(origin.Z - center.Z)
-		distSq := dx*dx + dy*dy + dz*dz
-
-		if distSq > radiusSq {
-			// WRITE-BACK: Antes de descarregar, salva se estiver sujo de forma ASSÍNCRONA
-			if chunk.IsDirty {
-				// Salva em background com dbMu para serializar escritas no banco
-				chunkCopy := chunk
-				go func() {
-					s.dbMu.Lock()
-					s.SaveChunk(chunkCopy)
-					s.dbMu.Unlock()
-				}()
-			}
-			delete(s.Chunks, origin)
-		}
-	}
+// CurrentWorld retorna o mundo aberto (WorldName) lido sob Mu, para quem roda
+// em paralelo a uma troca de mundo (SwitchWorld).
+func (s *MapDataStore) CurrentWorld() string {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	return s.WorldName
 }
 
 // HasData verifica se o banco de dados já possui algum chunk salvo para o mundo atual.
 func (s *MapDataStore) HasData() bool {
 	s.Mu.RLock()
 	defer s.Mu.RUnlock()
-	if s.DB == nil {
+	if s.Repo == nil {
 		return false
 	}
-	var count int64
-	s.DB.Model(&ChunkModel{}).Count(&count)
+	count, _ := s.Repo.ChunkCount()
 	return count > 0
 }
 
 // QueueAllStoredChunks carrega todos os blocos do SQLite na tela inicializando o mapa 3D sem depender do socket.
 func (s *MapDataStore) QueueAllStoredChunks(enqueueFunc func(origin util.DFCoord, mtime int64)) int {
-	if s.DB == nil {
+	if s.Repo == nil {
 		return 0
 	}
 
 	// Retira apenas os metadados para não estourar a RAM
-	var chunks []ChunkModel
-	s.DB.Select("x", "y", "z", "m_time").Find(&chunks)
+	chunks, err := s.Repo.ChunkHeaders()
+	if err != nil {
+		log.Printf("[Persistence] Erro ao listar chunks gravados: %v", err)
+		return 0
+	}
 
-	s.Mu.Lock()
-	for _, model := range chunks {
-		origin := util.NewDFCoord(model.X, model.Y, model.Z)
+	for _, header := range chunks {
 		// Registra o chunk na memória como uma "casca" (shell)
 		// Isso informa ao Scanner que o dado existe no SQL, evitando re-download.
-		if _, exists := s.Chunks[origin]; !exists {
-			s.Chunks[origin] = &Chunk{
-				Origin: origin,
-				MTime:  model.MTime,
+		s.chunks.update(header.Origin, func(cur *Chunk) *Chunk {
+			if cur != nil {
+				return cur
 			}
-		}
+			return &Chunk{Origin: header.Origin, MTime: header.MTime, store: s}
+		})
 	}
-	s.Mu.Unlock()
 
 	count := len(chunks)
 	log.Printf("[Persistence] Enfileirando %d blocos carregados do SQLite local.", count)
 
-	for _, model := range chunks {
-		origin := util.NewDFCoord(model.X, model.Y, model.Z)
-		enqueueFunc(origin, model.MTime)
+	for _, header := range chunks {
+		enqueueFunc(header.Origin, header.MTime)
 	}
 
 	return count
@@ -846,8 +1016,12 @@ func (s *MapDataStore) AddBuilding(b *BuildingInstance) {
 	defer s.Mu.Unlock()
 
 	s.Buildings[b.Index] = b
+	s.indexBuilding(b)
+	s.entities.buildingsDirty = true
+}
 
-	// Indexar todos os tiles ocupados pela construção
+// indexBuilding registra em BuildingLookup todos os tiles ocupados pela construção. Exige Mu travado.
+func (s *MapDataStore) indexBuilding(b *BuildingInstance) {
 	for zz := b.MinPos.Z; zz <= b.MaxPos.Z; zz++ {
 		for yy := b.MinPos.Y; yy <= b.MaxPos.Y; yy++ {
 			for xx := b.MinPos.X; xx <= b.MaxPos.X; xx++ {
@@ -874,14 +1048,35 @@ func (s *MapDataStore) GetBuildingAt(pos util.DFCoord) *BuildingInstance {
 func (s *MapDataStore) UpdateUnit(u *UnitInstance) {
 	s.Mu.Lock()
 	defer s.Mu.Unlock()
+	if cur, ok := s.Units[u.ID]; !ok || *cur != *u {
+		s.entities.unitsDirty = true
+	}
 	s.Units[u.ID] = u
 }
 
+// ResetMemory descarta chunks, entidades e dimensões mantidos em RAM (útil ao mudar de mapa).
+// Os dicionários (Tiletypes) são preservados até chegarem os do novo mundo.
+func (s *MapDataStore) ResetMemory() {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	s.chunks.reset()
+	s.history.reset()
+	s.Buildings = make(map[int32]*BuildingInstance)
+	s.Units = make(map[int32]*UnitInstance)
+	s.BuildingLookup = make(map[util.DFCoord]int32)
+	s.BuildingDefs = nil
+	s.entities = entityState{}
+	s.MapSize = util.DFCoord{}
+	s.worldGen++
+}
+
 // ClearEntities remove todas as entidades (útil ao mudar de mapa).
+// Só a RAM é limpa: o que já foi gravado no banco continua lá.
 func (s *MapDataStore) ClearEntities() {
 	s.Mu.Lock()
 	defer s.Mu.Unlock()
 	s.Buildings = make(map[int32]*BuildingInstance)
 	s.Units = make(map[int32]*UnitInstance)
 	s.BuildingLookup = make(map[util.DFCoord]int32)
+	s.entities = entityState{}
 }
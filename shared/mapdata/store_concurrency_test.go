@@ -0,0 +1,277 @@
+package mapdata
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"FortressVision/shared/pkg/dfproto"
+	"FortressVision/shared/util"
+)
+
+// Área usada pelos testes de concorrência: 4x4 chunks em 3 níveis.
+const (
+	concChunksXY = 4
+	concLevels   = 3
+)
+
+// scanBlock monta um bloco do DFHack para origin em que todo tile tem TileType = gen.
+func scanBlock(origin util.DFCoord, gen int32) *dfproto.MapBlock {
+	b := &dfproto.MapBlock{MapX: origin.X, MapY: origin.Y, MapZ: origin.Z}
+	b.Tiles = make([]int32, 256)
+	b.Water = make([]int32, 256)
+	b.Materials = make([]dfproto.MatPair, 256)
+	for i := range b.Tiles {
+		b.Tiles[i] = gen
+		b.Water[i] = int32(i) % 8
+		b.Materials[i] = dfproto.MatPair{MatType: 0, MatIndex: gen % 16}
+	}
+	b.Items = []dfproto.Item{{ID: gen}}
+	return b
+}
+
+func concOrigins() []util.DFCoord {
+	var origins []util.DFCoord
+	for z := int32(0); z < concLevels; z++ {
+		for x := int32(0); x < concChunksXY; x++ {
+			for y := int32(0); y < concChunksXY; y++ {
+				origins = append(origins, util.NewDFCoord(x*16, y*16, z))
+			}
+		}
+	}
+	return origins
+}
+
+// TestConcurrentScanMeshStream roda scanner, mesher, streaming e gravação ao mesmo
+// tempo sobre os mesmos chunks. Deve ser rodado com -race.
+func TestConcurrentScanMeshStream(t *testing.T) {
+	s := NewMapDataStore()
+	s.Repo = newMemoryRepository()
+	origins := concOrigins()
+
+	const rounds = 30
+	var wg sync.WaitGroup
+	var done atomic.Bool
+
+	// Scanner: regrava todos os chunks a cada rodada
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer done.Store(true)
+		for gen := int32(1); gen <= rounds; gen++ {
+			for _, origin := range origins {
+				s.StoreSingleBlock(scanBlock(origin, gen))
+			}
+		}
+	}()
+
+	// Mesher: lê tiles e vizinhos; todos os tiles de um chunk devem ser da mesma rodada
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for !done.Load() {
+				for _, origin := range origins {
+					chunk, ok := s.GetChunk(origin)
+					if !ok {
+						continue
+					}
+					// A edição pontual abaixo (GetOrCreateTile) pode criar o chunk (0,0,0)
+					// antes da primeira varredura, só com o tile (5,5): compara apenas os
+					// tiles que existem
+					var first *Tile
+					for x := int32(0); x < 16; x++ {
+						for y := int32(0); y < 16; y++ {
+							tile := chunk.Tile(x, y)
+							if tile == nil {
+								continue
+							}
+							if first == nil {
+								first = tile
+							} else if tile.TileType != first.TileType {
+								t.Errorf("chunk %v com tiles de rodadas diferentes: %d e %d", origin, first.TileType, tile.TileType)
+								return
+							}
+						}
+					}
+					if tile := s.GetTile(origin); tile != nil {
+						tile.North()
+						tile.Shape()
+					}
+				}
+			}
+		}()
+	}
+
+	// Streaming: serializa os chunks como o servidor faz para os clientes
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for !done.Load() {
+			for _, origin := range origins {
+				if chunk, ok := s.GetChunk(origin); ok {
+					if _, err := EncodeChunk(chunk, LayersAll); err != nil {
+						t.Error(err)
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	// Gravação periódica e edições pontuais
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for !done.Load() {
+			if _, err := s.Save("Concorrencia"); err != nil {
+				t.Error(err)
+				return
+			}
+			if tile := s.GetOrCreateTile(util.NewDFCoord(5, 5, 0)); tile != nil {
+				tile.Hidden = !tile.Hidden
+				s.SetTile(tile)
+			}
+		}
+	}()
+
+	wg.Wait()
+
+	for _, origin := range origins {
+		tile := s.GetTile(origin.Add(util.NewDFCoord(15, 15, 0)))
+		if tile == nil || tile.TileType != rounds {
+			t.Fatalf("tile final de %v = %+v, want TileType %d", origin, tile, rounds)
+		}
+	}
+}
+
+// TestReadersDoNotWaitForWriters garante que um escritor lento não bloqueia a leitura
+// do próprio chunk que está alterando (a versão anterior continua visível).
+func TestReadersDoNotWaitForWriters(t *testing.T) {
+	s := NewMapDataStore()
+	origin := util.NewDFCoord(0, 0, 0)
+	s.StoreSingleBlock(scanBlock(origin, 1))
+
+	inWriter := make(chan struct{})
+	release := make(chan struct{})
+	go s.chunks.update(origin, func(cur *Chunk) *Chunk {
+		close(inWriter)
+		<-release
+		return cur
+	})
+	<-inWriter
+	defer close(release)
+
+	read := make(chan *Tile)
+	go func() { read <- s.GetTile(origin) }()
+	select {
+	case tile := <-read:
+		if tile == nil || tile.TileType != 1 {
+			t.Fatalf("GetTile durante escrita = %+v", tile)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("GetTile bloqueou esperando o escritor do mesmo chunk")
+	}
+}
+
+func TestSaveKeepsNewerVersionsDirty(t *testing.T) {
+	s := NewMapDataStore()
+	s.Repo = newMemoryRepository()
+	origin := util.NewDFCoord(0, 0, 0)
+	s.StoreSingleBlock(scanBlock(origin, 1))
+
+	saved, _ := s.GetChunk(origin)
+	s.StoreSingleBlock(scanBlock(origin, 2)) // chega uma versão nova antes do markClean
+	s.markClean(saved)
+
+	if chunk, _ := s.GetChunk(origin); !chunk.IsDirty {
+		t.Fatal("versão mais nova que a gravada perdeu a marca de suja")
+	}
+	if n, err := s.Save("Concorrencia"); err != nil || n != 1 {
+		t.Fatalf("Save = %d, %v", n, err)
+	}
+	if chunk, _ := s.GetChunk(origin); chunk.IsDirty {
+		t.Fatal("chunk continua sujo depois do Save")
+	}
+}
+
+// TestSaveAfterFailedSwitch simula uma troca de mundo que falhou ao reabrir o
+// banco enquanto o Save esperava dbMu: Save deve falhar em vez de usar Repo nil.
+func TestSaveAfterFailedSwitch(t *testing.T) {
+	chdirTemp(t)
+	if err := os.WriteFile(SavesDir, nil, 0644); err != nil {
+		t.Fatal(err) // Sem a pasta saves/, nenhum banco novo abre
+	}
+	s := NewMapDataStore()
+	s.Backend = BackendLog
+	s.Repo = newMemoryRepository()
+	s.StoreSingleBlock(scanBlock(util.NewDFCoord(0, 0, 0), 1))
+
+	s.dbMu.Lock()
+	saved := make(chan error)
+	go func() {
+		_, err := s.Save("Concorrencia")
+		saved <- err
+	}()
+	time.Sleep(50 * time.Millisecond) // Save passa da checagem inicial e espera dbMu
+	s.Mu.Lock()
+	s.Repo = nil
+	s.Mu.Unlock()
+	s.dbMu.Unlock()
+
+	if err := <-saved; err == nil {
+		t.Fatal("Save sem banco não falhou")
+	}
+}
+
+// BenchmarkGetTileDuringScan mede GetTile do mesher (em paralelo) com o scanner
+// regravando os mesmos chunks sem parar.
+func BenchmarkGetTileDuringScan(b *testing.B) {
+	s := NewMapDataStore()
+	origins := concOrigins()
+	for _, origin := range origins {
+		s.StoreSingleBlock(scanBlock(origin, 1))
+	}
+
+	stop := make(chan struct{})
+	var scanned atomic.Int64
+	go func() {
+		for gen := int32(2); ; gen++ {
+			for _, origin := range origins {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				s.StoreSingleBlock(scanBlock(origin, gen))
+				scanned.Add(1)
+			}
+		}
+	}()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			origin := origins[i%len(origins)]
+			s.GetTile(origin.Add(util.NewDFCoord(int32(i&15), int32(i>>4&15), 0)))
+			i++
+		}
+	})
+	b.StopTimer()
+	close(stop)
+	b.ReportMetric(float64(scanned.Load())/b.Elapsed().Seconds(), "blocks/s")
+}
+
+func BenchmarkStoreSingleBlock(b *testing.B) {
+	s := NewMapDataStore()
+	origins := concOrigins()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		origin := origins[i%len(origins)]
+		s.StoreSingleBlock(scanBlock(origin, int32(i/len(origins))))
+	}
+}
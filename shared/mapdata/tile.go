@@ -87,14 +87,10 @@ func (t *Tile) CopyFrom(orig *Tile) {
 // Propriedades auxiliares baseadas nos tipos (necessita que o container tenha acesso aos raws)
 
 func (t *Tile) Shape() dfproto.TiletypeShape {
-	if t.container == nil || t.container.Tiletypes == nil {
-		return dfproto.ShapeNoShape
-	}
-	tt, ok := t.container.Tiletypes[t.TileType]
-	if !ok {
+	if t.container == nil {
 		return dfproto.ShapeNoShape
 	}
-	return tt.Shape
+	return t.container.shapeOf(t.TileType)
 }
 
 func (t *Tile) MaterialCategory() dfproto.TiletypeMaterial {
@@ -0,0 +1,216 @@
+package mapdata
+
+import (
+	"FortressVision/shared/pkg/dfproto"
+	"FortressVision/shared/util"
+)
+
+// Índices dos materiais de um tile em TileBlock.Mats.
+const (
+	matMaterial = iota
+	matBase
+	matLayer
+	matVein
+	matConstructionItem
+	tileMatSlots
+)
+
+// Bitplanes de flags booleanas em TileBlock.Flags.
+const (
+	flagPresent = iota // O tile existe (equivale ao antigo ponteiro não-nil)
+	flagHidden
+	flagLight
+	flagSubterranean
+	flagOutside
+	flagAquifer
+	flagWaterStagnant
+	flagWaterSalt
+	flagDigMarker
+	flagDigAuto
+	numTileFlags
+)
+
+// TileBlock guarda os 256 tiles de um chunk em layout struct-of-arrays.
+// Substitui o antigo [16][16]*Tile (256 ponteiros para structs de ~200 bytes,
+// cada uma com sua própria Position e referência ao store) por ~6 KB contíguos.
+// O índice de um tile é y*16 + x (mesma ordem dos arrays do DFHack).
+//
+// Os campos são exportados apenas para o GOB (ver codec.go); o acesso normal é
+// por Chunk.Tile / Chunk.SetTile ou MapDataStore.GetTile.
+type TileBlock struct {
+	TileType [256]int16
+	// Mats guarda índices em Palette; 0 é sempre o MatPair zero.
+	Mats    [tileMatSlots][256]uint16
+	Palette []dfproto.MatPair
+	// Liquid guarda a água nos 4 bits baixos e o magma nos 4 altos (níveis 0-7).
+	Liquid [256]uint8
+	// Flow guarda o FlowVector com 2 bits por eixo (valor+1: 0=-1, 1=0, 2=+1).
+	Flow     [256]uint8
+	RampType [256]uint8
+	Dig      [256]uint8
+	Trunk    [256]uint8
+	Grass    [256]uint8
+	TreePos  [256][3]int16
+	Flags    [numTileFlags][4]uint64
+}
+
+func tileIndex(x, y int32) int {
+	return int(y)*16 + int(x)
+}
+
+func (b *TileBlock) flag(f, i int) bool {
+	return b.Flags[f][i>>6]&(1<<(uint(i)&63)) != 0
+}
+
+func (b *TileBlock) setFlag(f, i int, v bool) {
+	if v {
+		b.Flags[f][i>>6] |= 1 << (uint(i) & 63)
+	} else {
+		b.Flags[f][i>>6] &^= 1 << (uint(i) & 63)
+	}
+}
+
+// has indica se o tile i existe.
+func (b *TileBlock) has(i int) bool {
+	return b.flag(flagPresent, i)
+}
+
+// material resolve um índice da paleta.
+func (b *TileBlock) material(slot, i int) dfproto.MatPair {
+	idx := b.Mats[slot][i]
+	if idx == 0 || int(idx) > len(b.Palette) {
+		return dfproto.MatPair{}
+	}
+	return b.Palette[idx-1]
+}
+
+// paletteIndex retorna (criando se preciso) o índice de um material na paleta do chunk.
+// A paleta raramente passa de algumas dezenas de entradas, então a busca é linear.
+func (b *TileBlock) paletteIndex(m dfproto.MatPair) uint16 {
+	if m == (dfproto.MatPair{}) {
+		return 0
+	}
+	for i, p := range b.Palette {
+		if p == m {
+			return uint16(i + 1)
+		}
+	}
+	b.Palette = append(b.Palette, m)
+	return uint16(len(b.Palette))
+}
+
+func packFlow(v util.DFCoord) uint8 {
+	return uint8(clampUnit(v.X)+1) | uint8(clampUnit(v.Y)+1)<<2 | uint8(clampUnit(v.Z)+1)<<4
+}
+
+func unpackFlow(f uint8) util.DFCoord {
+	return util.NewDFCoord(int32(f&3)-1, int32(f>>2&3)-1, int32(f>>4&3)-1)
+}
+
+func clampUnit(v int32) int32 {
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	}
+	return 0
+}
+
+// load preenche t com os dados do tile i (sem mexer em Position nem no store).
+func (b *TileBlock) load(i int, t *Tile) {
+	t.TileType = int32(b.TileType[i])
+	t.Material = b.material(matMaterial, i)
+	t.BaseMaterial = b.material(matBase, i)
+	t.LayerMaterial = b.material(matLayer, i)
+	t.VeinMaterial = b.material(matVein, i)
+	t.ConstructionItem = b.material(matConstructionItem, i)
+	t.WaterLevel = int32(b.Liquid[i] & 0x0f)
+	t.MagmaLevel = int32(b.Liquid[i] >> 4)
+	t.FlowVector = unpackFlow(b.Flow[i])
+	t.RampType = int32(b.RampType[i])
+	t.Hidden = b.flag(flagHidden, i)
+	t.Light = b.flag(flagLight, i)
+	t.Subterranean = b.flag(flagSubterranean, i)
+	t.Outside = b.flag(flagOutside, i)
+	t.Aquifer = b.flag(flagAquifer, i)
+	t.WaterStagnant = b.flag(flagWaterStagnant, i)
+	t.WaterSalt = b.flag(flagWaterSalt, i)
+	t.TrunkPercent = b.Trunk[i]
+	t.PositionOnTree = util.NewDFCoord(int32(b.TreePos[i][0]), int32(b.TreePos[i][1]), int32(b.TreePos[i][2]))
+	t.DigDesignation = dfproto.TileDigDesignation(b.Dig[i])
+	t.DigMarker = b.flag(flagDigMarker, i)
+	t.DigAuto = b.flag(flagDigAuto, i)
+	t.GrassPercent = int32(b.Grass[i])
+}
+
+// store grava t no tile i. Valores fora da faixa de cada campo compacto são saturados.
+func (b *TileBlock) store(i int, t *Tile) {
+	b.setFlag(flagPresent, i, true)
+	b.TileType[i] = int16(t.TileType)
+	b.Mats[matMaterial][i] = b.paletteIndex(t.Material)
+	b.Mats[matBase][i] = b.paletteIndex(t.BaseMaterial)
+	b.Mats[matLayer][i] = b.paletteIndex(t.LayerMaterial)
+	b.Mats[matVein][i] = b.paletteIndex(t.VeinMaterial)
+	b.Mats[matConstructionItem][i] = b.paletteIndex(t.ConstructionItem)
+	b.Liquid[i] = uint8(min(max(t.WaterLevel, 0), 15)) | uint8(min(max(t.MagmaLevel, 0), 15))<<4
+	b.Flow[i] = packFlow(t.FlowVector)
+	b.RampType[i] = uint8(t.RampType)
+	b.setFlag(flagHidden, i, t.Hidden)
+	b.setFlag(flagLight, i, t.Light)
+	b.setFlag(flagSubterranean, i, t.Subterranean)
+	b.setFlag(flagOutside, i, t.Outside)
+	b.setFlag(flagAquifer, i, t.Aquifer)
+	b.setFlag(flagWaterStagnant, i, t.WaterStagnant)
+	b.setFlag(flagWaterSalt, i, t.WaterSalt)
+	b.Trunk[i] = t.TrunkPercent
+	b.TreePos[i] = [3]int16{int16(t.PositionOnTree.X), int16(t.PositionOnTree.Y), int16(t.PositionOnTree.Z)}
+	b.Dig[i] = uint8(t.DigDesignation)
+	b.setFlag(flagDigMarker, i, t.DigMarker)
+	b.setFlag(flagDigAuto, i, t.DigAuto)
+	b.Grass[i] = uint8(min(max(t.GrassPercent, 0), 255))
+}
+
+// tileBlockFromLegacy converte o layout antigo ([16][16]*Tile) para TileBlock.
+func tileBlockFromLegacy(tiles *[16][16]*Tile) *TileBlock {
+	b := &TileBlock{}
+	for x := int32(0); x < 16; x++ {
+		for y := int32(0); y < 16; y++ {
+			if t := tiles[x][y]; t != nil {
+				b.store(tileIndex(x, y), t)
+			}
+		}
+	}
+	return b
+}
+
+// Tile monta uma visão do tile local (x, y) do chunk, ou nil se ele não existir.
+// A visão é uma cópia: alterações só valem depois de SetTile.
+func (c *Chunk) Tile(x, y int32) *Tile {
+	if c.Tiles == nil {
+		return nil
+	}
+	i := tileIndex(x, y)
+	if !c.Tiles.has(i) {
+		return nil
+	}
+	t := &Tile{
+		container: c.store,
+		Position:  util.NewDFCoord(c.Origin.X+x, c.Origin.Y+y, c.Origin.Z),
+	}
+	c.Tiles.load(i, t)
+	return t
+}
+
+// HasTile indica se o tile local (x, y) existe, sem montar a visão.
+func (c *Chunk) HasTile(x, y int32) bool {
+	return c.Tiles != nil && c.Tiles.has(tileIndex(x, y))
+}
+
+// SetTile grava o tile local (x, y) no layout compacto do chunk.
+func (c *Chunk) SetTile(x, y int32, t *Tile) {
+	if c.Tiles == nil {
+		c.Tiles = &TileBlock{}
+	}
+	c.Tiles.store(tileIndex(x, y), t)
+}
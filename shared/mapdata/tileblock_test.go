@@ -0,0 +1,239 @@
+package mapdata
+
+import (
+	"bytes"
+	"encoding/gob"
+	"runtime"
+	"testing"
+
+	"FortressVision/shared/pkg/dfproto"
+	"FortressVision/shared/util"
+)
+
+// fullTile preenche todos os campos de Tile com valores dentro da faixa do layout compacto.
+func fullTile(pos util.DFCoord, i int32) *Tile {
+	return &Tile{
+		Position:         pos,
+		TileType:         300 + i,
+		Material:         dfproto.MatPair{MatType: 0, MatIndex: i % 7},
+		BaseMaterial:     dfproto.MatPair{MatType: 0, MatIndex: 3},
+		LayerMaterial:    dfproto.MatPair{MatType: 0, MatIndex: 4},
+		VeinMaterial:     dfproto.MatPair{MatType: 0, MatIndex: 5 + i%3},
+		ConstructionItem: dfproto.MatPair{MatType: 1, MatIndex: 9},
+		WaterLevel:       i % 8,
+		MagmaLevel:       (i + 3) % 8,
+		FlowVector:       util.NewDFCoord(i%3-1, 1, 0),
+		RampType:         i % 4,
+		Hidden:           i%2 == 0,
+		Light:            true,
+		Subterranean:     i%3 == 0,
+		Outside:          true,
+		Aquifer:          i%5 == 0,
+		WaterStagnant:    true,
+		WaterSalt:        i%7 == 0,
+		TrunkPercent:     uint8(i),
+		PositionOnTree:   util.NewDFCoord(-2, 1, 3),
+		DigDesignation:   dfproto.TileDigDesignation(i % 6),
+		DigMarker:        true,
+		DigAuto:          i%4 == 0,
+		GrassPercent:     i % 101,
+	}
+}
+
+func TestTileBlockRoundTrip(t *testing.T) {
+	s := NewMapDataStore()
+	c := &Chunk{Origin: util.NewDFCoord(16, 32, 5), store: s}
+	for x := int32(0); x < 16; x++ {
+		for y := int32(0); y < 16; y++ {
+			if (x+y)%5 == 0 {
+				continue // deixa buracos para testar tiles ausentes
+			}
+			c.SetTile(x, y, fullTile(util.DFCoord{}, x*16+y))
+		}
+	}
+
+	for x := int32(0); x < 16; x++ {
+		for y := int32(0); y < 16; y++ {
+			got := c.Tile(x, y)
+			if (x+y)%5 == 0 {
+				if got != nil || c.HasTile(x, y) {
+					t.Fatalf("tile (%d,%d) deveria estar ausente", x, y)
+				}
+				continue
+			}
+			want := fullTile(util.NewDFCoord(c.Origin.X+x, c.Origin.Y+y, c.Origin.Z), x*16+y)
+			want.container = s
+			if *got != *want {
+				t.Fatalf("tile (%d,%d) = %+v, want %+v", x, y, got, want)
+			}
+		}
+	}
+}
+
+// TestReadTileMatchesGetTile confere os acessos sem alocação do mesher contra GetTile.
+func TestReadTileMatchesGetTile(t *testing.T) {
+	s := NewMapDataStore()
+	s.UpdateTiletypes(&dfproto.TiletypeList{TiletypeList: []dfproto.Tiletype{{ID: 306, Shape: dfproto.ShapeWall}}})
+	origin := util.NewDFCoord(16, 32, 5)
+	c := &Chunk{Origin: origin, store: s}
+	for x := int32(0); x < 16; x++ {
+		for y := int32(0); y < 16; y++ {
+			if (x+y)%5 != 0 {
+				c.SetTile(x, y, fullTile(util.DFCoord{}, x*16+y))
+			}
+		}
+	}
+	s.PutChunk(c)
+
+	var tile Tile
+	for x := int32(0); x < 16; x++ {
+		for y := int32(0); y < 16; y++ {
+			pos := origin.Add(util.NewDFCoord(x, y, 0))
+			want := s.GetTile(pos)
+			ok := s.ReadTile(pos, &tile)
+			shape, shapeOK := s.TileShape(pos)
+			hidden, hiddenOK := s.TileHidden(pos)
+			water, magma, liquidOK := s.TileLiquid(pos)
+			if want == nil {
+				if ok || shapeOK || hiddenOK || liquidOK {
+					t.Fatalf("tile ausente %v lido como presente", pos)
+				}
+				continue
+			}
+			if !ok || tile != *want {
+				t.Fatalf("ReadTile %v = %+v, want %+v", pos, tile, want)
+			}
+			if !shapeOK || shape != want.Shape() || !hiddenOK || hidden != want.Hidden ||
+				!liquidOK || water != want.WaterLevel || magma != want.MagmaLevel {
+				t.Fatalf("campos de %v = %v %v %d %d, want %v %v %d %d", pos,
+					shape, hidden, water, magma, want.Shape(), want.Hidden, want.WaterLevel, want.MagmaLevel)
+			}
+		}
+	}
+
+	pos := origin.Add(util.NewDFCoord(1, 2, 0))
+	if allocs := testing.AllocsPerRun(100, func() {
+		s.ReadTile(pos, &tile)
+		s.TileShape(pos)
+		s.TileLiquid(pos)
+	}); allocs != 0 {
+		t.Fatalf("acessos do mesher alocam %.0f vezes por chamada", allocs)
+	}
+}
+
+// legacyTiles monta o layout antigo com todos os tiles presentes
+// (o GOB não grava arrays com ponteiros nil).
+func legacyTiles(origin util.DFCoord) *[16][16]*Tile {
+	var tiles [16][16]*Tile
+	for x := int32(0); x < 16; x++ {
+		for y := int32(0); y < 16; y++ {
+			tiles[x][y] = fullTile(util.NewDFCoord(origin.X+x, origin.Y+y, origin.Z), x*16+y)
+		}
+	}
+	return &tiles
+}
+
+func TestUpgradeCompactTiles(t *testing.T) {
+	origin := util.NewDFCoord(0, 0, 3)
+	tiles := legacyTiles(origin)
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(chunkData{Tiles: tiles, Items: []dfproto.Item{{ID: 1}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	out, changed, err := upgradeCompactTiles(buf.Bytes())
+	if err != nil || !changed {
+		t.Fatalf("upgradeCompactTiles = %v, %v", changed, err)
+	}
+	if _, changed, _ := upgradeCompactTiles(out); changed {
+		t.Fatal("blob já compacto não deveria ser recodificado")
+	}
+
+	got, err := DecodeChunk(origin, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Items) != 1 {
+		t.Errorf("Items perdidos na migração: %v", got.Items)
+	}
+	for x := int32(0); x < 16; x++ {
+		for y := int32(0); y < 16; y++ {
+			if tile := got.Tile(x, y); tile == nil || *tile != *tiles[x][y] {
+				t.Fatalf("tile (%d,%d) = %+v, want %+v", x, y, tile, tiles[x][y])
+			}
+		}
+	}
+}
+
+// Um embark 4x4 tem 192x192 tiles: 12x12 chunks por nível. Com ~150 níveis
+// (céu e cavernas incluídos) o full-scan chega perto de 20 mil chunks.
+const (
+	embarkChunksXY = 12
+	embarkLevels   = 150
+)
+
+// benchmarkEmbarkMemory mede o heap retido por chunk depois de montar um embark inteiro.
+func benchmarkEmbarkMemory(b *testing.B, build func(origin util.DFCoord) any) {
+	n := embarkChunksXY * embarkChunksXY * embarkLevels
+	for i := 0; i < b.N; i++ {
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+
+		chunks := make([]any, 0, n)
+		for z := int32(0); z < embarkLevels; z++ {
+			for x := int32(0); x < embarkChunksXY; x++ {
+				for y := int32(0); y < embarkChunksXY; y++ {
+					chunks = append(chunks, build(util.NewDFCoord(x*16, y*16, z)))
+				}
+			}
+		}
+
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc)/float64(n), "B/chunk")
+		runtime.KeepAlive(chunks)
+	}
+}
+
+// BenchmarkEmbarkMemory compara o layout antigo ([16][16]*Tile) com o TileBlock.
+// Rodar com: go test -run ^$ -bench EmbarkMemory -benchtime 1x ./shared/mapdata
+func BenchmarkEmbarkMemory(b *testing.B) {
+	b.Run("Legacy", func(b *testing.B) {
+		benchmarkEmbarkMemory(b, func(origin util.DFCoord) any {
+			return legacyTiles(origin)
+		})
+	})
+	b.Run("TileBlock", func(b *testing.B) {
+		benchmarkEmbarkMemory(b, func(origin util.DFCoord) any {
+			return &Chunk{Origin: origin, Tiles: tileBlockFromLegacy(legacyTiles(origin))}
+		})
+	})
+}
+
+func BenchmarkGetTile(b *testing.B) {
+	s := NewMapDataStore()
+	origin := util.NewDFCoord(0, 0, 0)
+	s.PutChunk(&Chunk{Origin: origin, Tiles: tileBlockFromLegacy(legacyTiles(origin))})
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if s.GetTile(util.NewDFCoord(int32(i&15), int32(i>>4&15), 0)) == nil {
+			b.Fatal("tile ausente")
+		}
+	}
+}
+
+func BenchmarkReadTile(b *testing.B) {
+	s := NewMapDataStore()
+	origin := util.NewDFCoord(0, 0, 0)
+	s.PutChunk(&Chunk{Origin: origin, Tiles: tileBlockFromLegacy(legacyTiles(origin))})
+
+	var tile Tile
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if !s.ReadTile(util.NewDFCoord(int32(i&15), int32(i>>4&15), 0), &tile) {
+			b.Fatal("tile ausente")
+		}
+	}
+}
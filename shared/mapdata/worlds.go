@@ -0,0 +1,211 @@
+package mapdata
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"FortressVision/shared/util"
+)
+
+// SavesDir é a pasta onde ficam os bancos (.fv) de cada mundo.
+const SavesDir = "saves"
+
+// WorldInfo resume um mundo salvo, a partir dos metadados do seu banco.
+type WorldInfo struct {
+	Name          string    `json:"name"`
+	SizeX         int32     `json:"size_x"`
+	SizeY         int32     `json:"size_y"`
+	SizeZ         int32     `json:"size_z"`
+	ChunkCount    int64     `json:"chunk_count"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	FormatVersion int       `json:"format_version"`
+	Generation    string    `json:"generation"` // Ver MapDataStore.Generation
+}
+
+// WorldPath retorna o caminho do banco de um mundo.
+func WorldPath(worldName string) string {
+	return filepath.Join(SavesDir, fmt.Sprintf("%s.fv", worldName))
+}
+
+// ListWorlds lista os mundos salvos em SavesDir (.fv e .fvlog), do mais recente para o mais antigo.
+// Bancos ilegíveis são ignorados.
+func ListWorlds() ([]WorldInfo, error) {
+	files, err := os.ReadDir(SavesDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var worlds []WorldInfo
+	for _, f := range files {
+		ext := filepath.Ext(f.Name())
+		if f.IsDir() || (ext != ".fv" && ext != ".fvlog") {
+			continue
+		}
+		name := strings.TrimSuffix(f.Name(), ext)
+		if seen[name] {
+			continue
+		}
+		info, err := ReadWorldInfo(name)
+		if err != nil {
+			continue
+		}
+		seen[name] = true
+		worlds = append(worlds, info)
+	}
+
+	sort.Slice(worlds, func(i, j int) bool {
+		return worlds[i].UpdatedAt.After(worlds[j].UpdatedAt)
+	})
+	return worlds, nil
+}
+
+// ReadWorldInfo abre o banco do mundo apenas para leitura e extrai seus metadados.
+// Se existirem os dois formatos, o banco SQLite (.fv) tem prioridade. A data da
+// última gravação e o número de chunks vêm dos metadados gravados por Save; só
+// bancos de antes deles caem na data do arquivo e numa contagem.
+func ReadWorldInfo(worldName string) (WorldInfo, error) {
+	path := WorldPath(worldName)
+	if !fileExists(path) {
+		path = LogWorldPath(worldName)
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return WorldInfo{}, err
+	}
+
+	info := WorldInfo{Name: worldName}
+	repo, err := openWorldForRead(path)
+	if err != nil {
+		return WorldInfo{}, err
+	}
+	defer repo.Close()
+
+	value := func(key string) string {
+		v, _ := repo.GetMetadata(key)
+		return v
+	}
+	info.FormatVersion, _ = strconv.Atoi(value(formatVersionKey))
+	info.Generation = value(generationKey)
+	info.SizeX = parseInt32(value("MapSizeX"))
+	info.SizeY = parseInt32(value("MapSizeY"))
+	info.SizeZ = parseInt32(value("MapSizeZ"))
+
+	if updated, err := time.Parse(time.RFC3339Nano, value(updatedAtKey)); err == nil {
+		info.UpdatedAt = updated
+	} else {
+		info.UpdatedAt = stat.ModTime()
+		// Com WAL, as escritas recentes ficam no -wal até o checkpoint
+		if wal, err := os.Stat(path + "-wal"); err == nil && wal.ModTime().After(info.UpdatedAt) {
+			info.UpdatedAt = wal.ModTime()
+		}
+	}
+	if count, err := strconv.ParseInt(value(chunkCountKey), 10, 64); err == nil {
+		info.ChunkCount = count
+	} else {
+		info.ChunkCount, _ = repo.ChunkCount()
+	}
+	return info, nil
+}
+
+// openWorldForRead abre o banco em path (.fv ou .fvlog) só para leitura.
+// Leitura direta, sem migrar: listar mundos nunca altera os arquivos.
+func openWorldForRead(path string) (ChunkRepository, error) {
+	if filepath.Ext(path) != ".fv" {
+		return openLogRepository(path, true)
+	}
+	db, err := openReadOnlyDB(path)
+	if err != nil {
+		return nil, err
+	}
+	if !db.Migrator().HasTable(&WorldMetadata{}) {
+		closeDB(db)
+		return nil, fmt.Errorf("banco %s sem metadados", path)
+	}
+	return &sqliteRepository{db: db}, nil
+}
+
+// EmbarkWorldName devolve o nome do banco do embarque com origem origin e tamanho
+// size (em blocos) no mundo world. O primeiro embarque fica em saves/<mundo>.fv;
+// outro embarque do mesmo mundo ganha um banco próprio, <mundo>@x_y_z, para os
+// dois mapas não se misturarem. Bancos antigos, sem a origem gravada, ficam com
+// o embarque de mesmo tamanho.
+func EmbarkWorldName(world string, origin, size util.DFCoord) string {
+	if world == "" || !WorldExists(world) {
+		return world
+	}
+	path := WorldPath(world)
+	if !fileExists(path) {
+		path = LogWorldPath(world)
+	}
+	repo, err := openWorldForRead(path)
+	if err != nil {
+		return world // Ilegível: OpenInitialize decide o que fazer com ele
+	}
+	value := func(key string) string {
+		v, _ := repo.GetMetadata(key)
+		return v
+	}
+	storedSize := util.NewDFCoord(parseInt32(value("MapSizeX")), parseInt32(value("MapSizeY")), parseInt32(value("MapSizeZ")))
+	posX, errPos := repo.GetMetadata("MapPosX")
+	storedPos := util.NewDFCoord(parseInt32(posX), parseInt32(value("MapPosY")), parseInt32(value("MapPosZ")))
+	repo.Close()
+
+	legacy := errPos != nil // Gravado antes de a origem entrar nos metadados
+	sameSize := storedSize == (util.DFCoord{}) || storedSize == size
+	if sameSize && (legacy || storedPos == origin) {
+		return world
+	}
+	return fmt.Sprintf("%s@%d_%d_%d", world, origin.X, origin.Y, origin.Z)
+}
+
+// WorldExists indica se existe um banco salvo com esse nome em SavesDir.
+// Nomes com separadores de caminho são recusados.
+func WorldExists(worldName string) bool {
+	if worldName == "" || filepath.Base(worldName) != worldName {
+		return false
+	}
+	return fileExists(WorldPath(worldName)) || fileExists(LogWorldPath(worldName))
+}
+
+// OpenReadOnly abre um mundo arquivado apenas para leitura (.fv ou .fvlog).
+// Diferente de OpenInitialize, não recria bancos corrompidos.
+func (s *MapDataStore) OpenReadOnly(worldName string) error {
+	if !WorldExists(worldName) {
+		return fmt.Errorf("mundo %q não encontrado em %s", worldName, SavesDir)
+	}
+	repo, err := openReadOnlyRepository(worldName)
+	if err != nil {
+		return err
+	}
+
+	s.Mu.Lock()
+	s.Repo = repo
+	s.WorldName = worldName
+	s.ReadOnly = true
+	s.Mu.Unlock()
+
+	if err := s.LoadEntities(); err != nil {
+		log.Printf("[Persistence] Aviso: entidades de %s ignoradas: %v", worldName, err)
+	}
+	return nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func parseInt32(s string) int32 {
+	v, _ := strconv.ParseInt(s, 10, 32)
+	return int32(v)
+}
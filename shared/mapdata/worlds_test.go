@@ -0,0 +1,116 @@
+package mapdata
+
+import (
+	"testing"
+	"time"
+
+	"FortressVision/shared/pkg/dfproto"
+	"FortressVision/shared/util"
+)
+
+func TestEmbarkWorldNameSeparatesEmbarks(t *testing.T) {
+	chdirTemp(t)
+	embarkA := &dfproto.MapInfo{BlockPosX: 10, BlockPosY: 20, BlockPosZ: 100, BlockSizeX: 4, BlockSizeY: 4, BlockSizeZ: 50}
+	embarkB := &dfproto.MapInfo{BlockPosX: 40, BlockPosY: 8, BlockPosZ: 90, BlockSizeX: 4, BlockSizeY: 4, BlockSizeZ: 50}
+	nameOf := func(info *dfproto.MapInfo) string {
+		return EmbarkWorldName("Mundo",
+			util.NewDFCoord(info.BlockPosX, info.BlockPosY, info.BlockPosZ),
+			util.NewDFCoord(info.BlockSizeX, info.BlockSizeY, info.BlockSizeZ))
+	}
+
+	// Primeiro embarque: banco novo com o nome do mundo
+	s := NewMapDataStore()
+	nameA := nameOf(embarkA)
+	if nameA != "Mundo" {
+		t.Fatalf("primeiro embarque em %q, want Mundo", nameA)
+	}
+	if err := s.OpenInitialize(nameA); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.SaveMapInfo(embarkA); err != nil {
+		t.Fatal(err)
+	}
+	oldOrigin := util.NewDFCoord(32, 16, 5)
+	s.StoreSingleBlock(scanBlock(oldOrigin, 7))
+	if _, err := s.Save(nameA); err != nil {
+		t.Fatal(err)
+	}
+
+	// Outro embarque do mesmo mundo: outro banco, sem os chunks do primeiro
+	nameB := nameOf(embarkB)
+	if nameB == nameA {
+		t.Fatalf("embarques diferentes no mesmo banco %q", nameB)
+	}
+	if err := s.SwitchWorld(nameB); err != nil {
+		t.Fatal(err)
+	}
+	s.SaveMapInfo(embarkB)
+	if c, err := s.LoadChunk(oldOrigin); err == nil && c != nil {
+		t.Errorf("chunk do embarque anterior continua visível em %s", nameB)
+	}
+	if n, err := s.Repo.ChunkCount(); err != nil || n != 0 {
+		t.Errorf("banco de %s com %d chunks (err %v), want 0", nameB, n, err)
+	}
+
+	// Voltar ao primeiro embarque reabre o banco dele
+	if got := nameOf(embarkA); got != nameA {
+		t.Fatalf("primeiro embarque agora em %q, want %q", got, nameA)
+	}
+	if got := nameOf(embarkB); got != nameB {
+		t.Fatalf("segundo embarque agora em %q, want %q", got, nameB)
+	}
+	if err := s.SwitchWorld(nameA); err != nil {
+		t.Fatal(err)
+	}
+	if c, err := s.LoadChunk(oldOrigin); err != nil || c == nil {
+		t.Errorf("chunk do primeiro embarque sumiu do seu banco: %v", err)
+	}
+}
+
+func TestReadWorldInfoUsesStoredStats(t *testing.T) {
+	chdirTemp(t)
+	s := NewMapDataStore()
+	if err := s.OpenInitialize("Estatisticas"); err != nil {
+		t.Fatal(err)
+	}
+	s.StoreSingleBlock(scanBlock(util.NewDFCoord(0, 0, 1), 3))
+	s.StoreSingleBlock(scanBlock(util.NewDFCoord(16, 0, 1), 3))
+	if _, err := s.Save("Estatisticas"); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := s.Repo.GetMetadata(updatedAtKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Os números vêm dos metadados, não de uma contagem no banco
+	s.Repo.SaveMetadata(chunkCountKey, "42")
+	s.Close()
+	info, err := ReadWorldInfo("Estatisticas")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ChunkCount != 42 {
+		t.Errorf("ChunkCount = %d, want 42 dos metadados", info.ChunkCount)
+	}
+	if got := info.UpdatedAt.Format(time.RFC3339Nano); got != saved {
+		t.Errorf("UpdatedAt = %s, want %s", got, saved)
+	}
+
+	// Bancos sem os metadados (de antes deles) ainda são contados
+	s = NewMapDataStore()
+	if err := s.OpenInitialize("Estatisticas"); err != nil {
+		t.Fatal(err)
+	}
+	s.Repo.SaveMetadata(chunkCountKey, "")
+	s.Repo.SaveMetadata(updatedAtKey, "")
+	s.Close()
+	info, err = ReadWorldInfo("Estatisticas")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ChunkCount != 2 || info.UpdatedAt.IsZero() {
+		t.Errorf("banco antigo: ChunkCount %d, UpdatedAt %v", info.ChunkCount, info.UpdatedAt)
+	}
+}
@@ -46,6 +46,8 @@ var signatures = map[string][2]string{
 	"GetBuildingDefList": {"dfproto.EmptyMessage", "RemoteFortressReader.BuildingList"},
 	"GetBuildingList":    {"dfproto.EmptyMessage", "RemoteFortressReader.BuildingInstanceList"},
 	"GetLanguage":        {"dfproto.EmptyMessage", "RemoteFortressReader.Language"},
+	"GetCreatureRaws":    {"dfproto.EmptyMessage", "RemoteFortressReader.CreatureRawList"},
+	"GetPlantRaws":       {"dfproto.EmptyMessage", "RemoteFortressReader.PlantRawList"},
 }
 
 func (s *RemoteFortressService) call(method string, reqMarshaler interface{ Marshal() ([]byte, error) }, respUnmarshaler interface{ Unmarshal([]byte) error }) error {
@@ -140,3 +142,25 @@ func (s *RemoteFortressService) GetLanguage() (*dfproto.Language, error) {
 	err := s.call("GetLanguage", &dfproto.EmptyMessage{}, resp)
 	return resp, err
 }
+
+// rawMessage guarda a resposta sem decodificar (dados que só são persistidos e repassados).
+type rawMessage []byte
+
+func (m *rawMessage) Unmarshal(data []byte) error {
+	*m = append((*m)[:0], data...)
+	return nil
+}
+
+// GetCreatureRaws retorna a CreatureRawList serializada, como veio do DFHack.
+func (s *RemoteFortressService) GetCreatureRaws() ([]byte, error) {
+	var resp rawMessage
+	err := s.call("GetCreatureRaws", &dfproto.EmptyMessage{}, &resp)
+	return resp, err
+}
+
+// GetPlantRaws retorna a PlantRawList serializada, como veio do DFHack.
+func (s *RemoteFortressService) GetPlantRaws() ([]byte, error) {
+	var resp rawMessage
+	err := s.call("GetPlantRaws", &dfproto.EmptyMessage{}, &resp)
+	return resp, err
+}
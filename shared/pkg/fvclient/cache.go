@@ -0,0 +1,428 @@
+package fvclient
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"FortressVision/shared/mapdata"
+	"FortressVision/shared/pkg/dfproto"
+	"FortressVision/shared/proto/fvnet"
+	"FortressVision/shared/util"
+)
+
+// CacheDir é a subpasta de mapdata.SavesDir com o cache local do cliente: uma
+// pasta por servidor e um banco por mundo. Subpastas não aparecem na lista de
+// mundos do servidor, então cliente e servidor podem rodar na mesma pasta.
+const CacheDir = "cache"
+
+// Chaves do cache no dicionário do banco. Os dicionários de tiletypes e
+// materiais usam as mesmas chaves do servidor (o payload é o mesmo).
+const (
+	versionsKey = "ClientChunkVersions"
+	viewKey     = "ClientView"
+	// generationKey (metadado) é a geração do banco do servidor a que as versões
+	// gravadas se referem (ver checkGenerationLocked)
+	generationKey = "ServerGeneration"
+)
+
+var cachedDictionaries = []string{"TiletypeList", "MaterialList"}
+
+// cacheName é o nome (para mapdata.WorldPath) do banco do cache de um mundo servido por serverURL.
+func cacheName(serverURL, world string) string {
+	host := serverURL
+	if u, err := url.Parse(serverURL); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	return filepath.Join(CacheDir, safeName(host), safeName(world))
+}
+
+// safeName troca os caracteres que não podem aparecer num nome de arquivo.
+func safeName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < ' ' || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// LastCachedWorld retorna o mundo do cache de serverURL usado por último, ou ""
+// se não há cache desse servidor.
+func LastCachedWorld(serverURL string) string {
+	dir := filepath.Dir(mapdata.WorldPath(cacheName(serverURL, "_")))
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	last, lastMod := "", int64(0)
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".fv" {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+		if mod := info.ModTime().UnixNano(); mod > lastMod {
+			last, lastMod = strings.TrimSuffix(f.Name(), ".fv"), mod
+		}
+	}
+	return last
+}
+
+// EnableCache liga o cache local em disco. Os chunks ao vivo recebidos ficam
+// gravados (ver SaveCache) num banco por servidor e mundo, com a versão (MTime
+// do servidor) de cada um. Todo pedido de região passa a informar essas versões,
+// e o servidor só envia os chunks que mudaram.
+//
+// Com world vazio, o cache abre quando o servidor anunciar o mundo. Senão, ele
+// abre na hora: os dicionários guardados são entregues aos callbacks OnTiletypes
+// e OnMaterials, e LoadCachedRegion já mostra o terreno antes da conexão. Se o
+// servidor servir outro mundo, o cliente troca de cache sozinho (OnWorldChanged).
+// Retorna quantos chunks o cache aberto conhece.
+func (c *NetworkClient) EnableCache(world string) (int, error) {
+	c.cacheOn.Store(true)
+	if world == "" {
+		return 0, nil
+	}
+
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+	if err := c.openCacheLocked(world); err != nil {
+		return 0, err
+	}
+
+	// Tiletypes e materiais da última sessão, para desenhar o terreno antes da conexão
+	if data, err := c.store.GetDictionary("TiletypeList"); err == nil && len(data) > 0 && c.OnTiletypes != nil {
+		var list dfproto.TiletypeList
+		if err := list.Unmarshal(data); err == nil {
+			c.OnTiletypes(&list)
+		}
+	}
+	if data, err := c.store.GetDictionary("MaterialList"); err == nil && len(data) > 0 && c.OnMaterials != nil {
+		var list dfproto.MaterialList
+		if err := list.Unmarshal(data); err == nil {
+			c.OnMaterials(&list)
+		}
+	}
+	return c.versions.len(), nil
+}
+
+// CacheWorld retorna o mundo cujo cache está aberto ("" sem cache).
+func (c *NetworkClient) CacheWorld() string {
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+	if !c.cacheOpen.Load() {
+		return ""
+	}
+	return c.cacheWorld
+}
+
+// CachedView retorna a posição da câmera gravada com o cache por SaveCache.
+func (c *NetworkClient) CachedView() (util.DFCoord, bool) {
+	if !c.cacheOpen.Load() {
+		return util.DFCoord{}, false
+	}
+	value, err := c.store.GetMetadata(viewKey)
+	if err != nil {
+		return util.DFCoord{}, false
+	}
+	var view util.DFCoord
+	if _, err := fmt.Sscanf(value, "%d,%d,%d", &view.X, &view.Y, &view.Z); err != nil {
+		return util.DFCoord{}, false
+	}
+	return view, true
+}
+
+// SaveCache grava no cache os chunks ao vivo recebidos desde a última gravação,
+// as versões conhecidas e a posição view da câmera. Retorna quantos chunks gravou.
+// Durante a linha do tempo não grava nada: o que está em RAM é histórico.
+func (c *NetworkClient) SaveCache(view util.DFCoord) (int, error) {
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+	if !c.cacheOpen.Load() {
+		return 0, nil
+	}
+	c.store.SaveMetadata(viewKey, fmt.Sprintf("%d,%d,%d", view.X, view.Y, view.Z))
+	return c.saveCacheLocked()
+}
+
+func (c *NetworkClient) saveCacheLocked() (int, error) {
+	if !c.cacheConfirmed.Load() || c.timeline.Load() {
+		return 0, nil
+	}
+	// As versões são copiadas antes dos chunks: um chunk gravado nunca é mais
+	// velho que a versão anotada para ele
+	versions, err := c.versions.encode()
+	if err != nil {
+		return 0, err
+	}
+	count, err := c.store.Save(cacheName(c.url, c.cacheWorld))
+	if err != nil {
+		return count, err
+	}
+	return count, c.store.SaveDictionary(versionsKey, versions)
+}
+
+// openCacheLocked grava o cache aberto e abre o de world no store. O que está
+// em RAM é descartado; as versões passam a ser as do novo cache.
+func (c *NetworkClient) openCacheLocked(world string) error {
+	if c.cacheOpen.Load() {
+		if c.cacheConfirmed.Load() {
+			if _, err := c.saveCacheLocked(); err != nil {
+				log.Printf("[Cache] Aviso: falha ao gravar o cache de %s: %v", c.cacheWorld, err)
+			}
+		} else {
+			// Nada em RAM pertence ao mundo do cache aberto: a troca não grava nada nele
+			c.store.ResetMemory()
+		}
+	}
+	c.cacheOpen.Store(false)
+	c.cacheConfirmed.Store(false)
+	c.versions.reset()
+
+	if err := c.store.SwitchWorld(cacheName(c.url, world)); err != nil {
+		return fmt.Errorf("abrindo cache de %s: %w", world, err)
+	}
+	c.cacheWorld = world
+	c.cacheOpen.Store(true)
+
+	c.loadVersionsLocked()
+	log.Printf("[Cache] Cache local de %s aberto: %d chunks conhecidos", world, c.versions.len())
+	return nil
+}
+
+// loadVersionsLocked troca as versões conhecidas pelas gravadas no cache aberto.
+func (c *NetworkClient) loadVersionsLocked() {
+	c.versions.reset()
+	data, err := c.store.GetDictionary(versionsKey)
+	if err != nil || len(data) == 0 {
+		return
+	}
+	if err := c.versions.load(data); err != nil {
+		log.Printf("[Cache] Versões do cache de %s ignoradas: %v", c.cacheWorld, err)
+	}
+}
+
+// saveTimelineCache grava o cache logo antes de a linha do tempo abrir.
+func (c *NetworkClient) saveTimelineCache() (int, error) {
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+	if !c.cacheOpen.Load() {
+		return 0, nil
+	}
+	return c.saveCacheLocked()
+}
+
+// confirmCache é chamado quando o servidor anuncia o mundo que serve. Abre (ou
+// troca para) o cache desse mundo e grava nele os dicionários recebidos nas
+// boas-vindas. As construções e unidades das boas-vindas, que chegam antes da
+// lista de mundos, são mantidas. generation é a geração do banco do servidor
+// (ver checkGenerationLocked). Retorna true se o cache de outro mundo estava
+// aberto ou se o do mundo foi descartado (o que estava na tela não vale mais).
+func (c *NetworkClient) confirmCache(world, generation string) bool {
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+	if c.cacheOpen.Load() && cacheName(c.url, c.cacheWorld) == cacheName(c.url, world) {
+		if !c.cacheConfirmed.Swap(true) {
+			c.writeDictionariesLocked()
+		}
+		return c.checkGenerationLocked(generation)
+	}
+
+	wasOpen := c.cacheOpen.Load()
+	if wasOpen {
+		log.Printf("[Cache] O servidor serve %s, não %s: trocando de cache", world, c.cacheWorld)
+	}
+	buildings, units := c.store.BuildingList(), c.store.UnitList()
+	if err := c.openCacheLocked(world); err != nil {
+		log.Printf("[Cache] %v", err)
+		return wasOpen
+	}
+	c.store.ReplaceBuildings(instancePointers(buildings))
+	c.store.ReplaceUnits(instancePointers(units))
+	c.cacheConfirmed.Store(true)
+	c.writeDictionariesLocked()
+	dropped := c.checkGenerationLocked(generation)
+	return wasOpen || dropped
+}
+
+// checkGenerationLocked compara a geração do banco do servidor com a anotada no
+// cache aberto. As versões são MTimes, que recomeçam quando o banco é substituído
+// (cópia restaurada, recriado, demonstração regerada): se a geração mudou (ou o
+// cache é de antes dela), os chunks e versões guardados saem e o cache passa a
+// anotar a nova. Retorna true se algo foi descartado.
+func (c *NetworkClient) checkGenerationLocked(generation string) bool {
+	stored, _ := c.store.GetMetadata(generationKey)
+	if stored == generation {
+		return false
+	}
+	if err := c.store.SaveMetadata(generationKey, generation); err != nil {
+		log.Printf("[Cache] Aviso: falha ao gravar a geração de %s: %v", c.cacheWorld, err)
+	}
+	known := c.versions.len()
+	if known == 0 {
+		return false
+	}
+	log.Printf("[Cache] O banco de %s mudou no servidor (geração %q → %q): %d chunks do cache descartados",
+		c.cacheWorld, stored, generation, known)
+	c.store.DeleteChunksWhere(func(*mapdata.Chunk) bool { return true })
+	c.versions.reset()
+	if err := c.store.SaveDictionary(versionsKey, nil); err != nil {
+		log.Printf("[Cache] Aviso: falha ao gravar as versões de %s: %v", c.cacheWorld, err)
+	}
+	return true
+}
+
+// unconfirmCache é chamado a cada nova conexão: até a lista de mundos chegar,
+// não se sabe se o servidor ainda serve o mundo do cache.
+func (c *NetworkClient) unconfirmCache() {
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+	c.cacheConfirmed.Store(false)
+	c.dictionaries = nil
+}
+
+// switchWorld descarta o mapa local depois de WORLD_CHANGED. Com o cache ligado,
+// passa a usar o cache do novo mundo; os dicionários dele vêm em seguida.
+func (c *NetworkClient) switchWorld(world string) {
+	if !c.cacheOn.Load() || world == "" {
+		c.store.ResetMemory()
+		c.versions.reset()
+		return
+	}
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+	c.dictionaries = nil
+	if err := c.openCacheLocked(world); err != nil {
+		log.Printf("[Cache] %v", err)
+		return
+	}
+	c.cacheConfirmed.Store(true)
+}
+
+// cacheDictionary guarda um dicionário recebido do servidor (TiletypeList,
+// MaterialList) para gravá-lo no cache quando o mundo for confirmado.
+func (c *NetworkClient) cacheDictionary(key string, data []byte) {
+	if !c.cacheOn.Load() {
+		return
+	}
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+	if c.dictionaries == nil {
+		c.dictionaries = make(map[string][]byte)
+	}
+	c.dictionaries[key] = data
+	if c.cacheConfirmed.Load() {
+		c.writeDictionariesLocked()
+	}
+}
+
+func (c *NetworkClient) writeDictionariesLocked() {
+	for _, key := range cachedDictionaries {
+		data, ok := c.dictionaries[key]
+		if !ok {
+			continue
+		}
+		if err := c.store.SaveDictionary(key, data); err != nil {
+			log.Printf("[Cache] Aviso: falha ao gravar %s: %v", key, err)
+		}
+		delete(c.dictionaries, key)
+	}
+}
+
+// LoadCachedRegion traz do cache para a RAM os chunks de v que ainda não
+// foram mostrados nesta sessão e chama OnMapChunk para cada um (inclusive os de
+// ar, que contam no progresso do loading). Retorna quantos chunks mostrou.
+// RequestView faz isso sozinho; o App o chama direto para mostrar o cache
+// antes da conexão ou enquanto ela está caída.
+func (c *NetworkClient) LoadCachedRegion(v View) int {
+	return c.surfaceCached(v.request())
+}
+
+func (c *NetworkClient) surfaceCached(req *fvnet.ClientRequestRegion) int {
+	if !c.cacheOpen.Load() || c.timeline.Load() {
+		return 0
+	}
+	// Com o cache ocupado (gravando ou trocando de mundo) fica para o próximo pedido
+	if !c.cacheMu.TryLock() {
+		return 0
+	}
+	defer c.cacheMu.Unlock()
+
+	origins, data := c.versions.unshown(req)
+	if data > 0 {
+		regionMin, regionMax := regionBounds(req)
+		if _, err := c.store.LoadRegion(regionMin, regionMax); err != nil {
+			log.Printf("[Cache] ERRO ao ler a região do cache: %v", err)
+		}
+	}
+	if c.OnMapChunk != nil {
+		for _, origin := range origins {
+			c.OnMapChunk(origin)
+		}
+	}
+	return len(origins)
+}
+
+// withKnown preenche req.Known com as versões dos chunks da região que o
+// cliente já tem. Com o cache aberto, antes traz para a RAM os guardados em disco.
+func (c *NetworkClient) withKnown(req *fvnet.ClientRequestRegion) {
+	c.surfaceCached(req)
+	req.Known = c.versions.inRegion(req, func(origin util.DFCoord) bool {
+		_, ok := c.store.GetChunk(origin)
+		return ok
+	})
+}
+
+// instancePointers converte a cópia de BuildingList/UnitList para ReplaceBuildings/ReplaceUnits.
+func instancePointers[T any](list []T) []*T {
+	out := make([]*T, len(list))
+	for i := range list {
+		out[i] = &list[i]
+	}
+	return out
+}
+
+// cachedVersion é o formato gravado de uma entrada de chunkVersions.
+type cachedVersion struct {
+	X, Y, Z int32
+	MTime   int64
+	Empty   bool
+}
+
+func (v *chunkVersions) encode() ([]byte, error) {
+	v.mu.Lock()
+	list := make([]cachedVersion, 0, len(v.m))
+	for origin, cv := range v.m {
+		list = append(list, cachedVersion{origin.X, origin.Y, origin.Z, cv.mtime, cv.empty})
+	}
+	v.mu.Unlock()
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(list); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// load substitui as versões pelas gravadas em data, todas ainda não mostradas.
+func (v *chunkVersions) load(data []byte) error {
+	var list []cachedVersion
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&list); err != nil {
+		return err
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.m = make(map[util.DFCoord]chunkVersion, len(list))
+	for _, e := range list {
+		v.m[util.NewDFCoord(e.X, e.Y, e.Z)] = chunkVersion{mtime: e.MTime, empty: e.Empty}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package fvclient
+
+import (
+	"FortressVision/shared/mapdata"
+	"FortressVision/shared/pkg/dfproto"
+	"FortressVision/shared/proto/fvnet"
+	"FortressVision/shared/util"
+)
+
+// buildingInstances converte a BuildingList do servidor para o formato do store.
+func buildingInstances(list *fvnet.BuildingList) []*mapdata.BuildingInstance {
+	out := make([]*mapdata.BuildingInstance, 0, len(list.Buildings))
+	for _, b := range list.Buildings {
+		instance := &mapdata.BuildingInstance{
+			Index:     b.Index,
+			Material:  dfproto.MatPair{MatType: b.MatType, MatIndex: b.MatIndex},
+			MinPos:    util.DFCoord{X: b.MinX, Y: b.MinY, Z: b.MinZ},
+			MaxPos:    util.DFCoord{X: b.MaxX, Y: b.MaxY, Z: b.MaxZ},
+			Center:    util.DFCoord{X: (b.MinX + b.MaxX) / 2, Y: (b.MinY + b.MaxY) / 2, Z: b.MinZ},
+			Direction: dfproto.BuildingDirection(b.Direction),
+		}
+		if b.TypeId != "" {
+			instance.BuildingType = &dfproto.BuildingDefinition{
+				BuildingType: dfproto.BuildingType{BuildingType: b.Type, BuildingSubtype: b.Subtype, BuildingCustom: b.Custom},
+				ID:           b.TypeId,
+				Name:         b.Name,
+			}
+		}
+		out = append(out, instance)
+	}
+	return out
+}
+
+// unitInstances converte a CreatureList do servidor para o formato do store.
+func unitInstances(list *fvnet.CreatureList) []*mapdata.UnitInstance {
+	out := make([]*mapdata.UnitInstance, 0, len(list.Creatures))
+	for _, c := range list.Creatures {
+		out = append(out, &mapdata.UnitInstance{
+			ID:     c.Id,
+			Name:   c.Name,
+			Race:   dfproto.MatPair{MatType: c.RaceType, MatIndex: c.RaceIndex},
+			Pos:    util.DFCoord{X: c.X, Y: c.Y, Z: c.Z},
+			Flags1: c.Flags1,
+			Flags2: c.Flags2,
+			Flags3: c.Flags3,
+			IsDead: c.Dead,
+		})
+	}
+	return out
+}
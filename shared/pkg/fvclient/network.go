@@ -0,0 +1,516 @@
+// Package fvclient é o cliente do protocolo websocket do Servidor FortressVision.
+// Os chunks recebidos são decodificados num MapDataStore; quem usa o pacote
+// (o visualizador, o fvbot, ferramentas de terceiros) reage pelos callbacks.
+// Não depende do raylib.
+package fvclient
+
+import (
+	"FortressVision/shared/mapdata"
+	"FortressVision/shared/pkg/dfproto"
+	"FortressVision/shared/proto/fvnet"
+	"FortressVision/shared/util"
+	"log"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/protobuf/proto"
+)
+
+// NetworkClient lida com a comunicação com o Servidor FortressVision
+type NetworkClient struct {
+	conn  *websocket.Conn
+	url   string
+	store *mapdata.MapDataStore
+	state atomic.Int32 // ConnState
+	mu    sync.RWMutex
+
+	// Reconexão automática (ver reconnect.go)
+	quit          chan struct{} // Fechado por Close: nada de reconectar depois disso
+	closeOnce     sync.Once
+	reconnecting  atomic.Bool
+	attempt       atomic.Int32 // Tentativa atual da reconexão em andamento
+	retryAt       atomic.Int64 // Próxima tentativa (UnixNano)
+	resyncPending atomic.Bool  // Reconectou: ressincronizar quando as boas-vindas terminarem
+	lastRegion    *fvnet.ClientRequestRegion
+	activeWorld   string
+	generation    string // Geração do banco do mundo ativo (WorldInfo.generation)
+	versions      chunkVersions
+
+	// Cache local em disco (ver cache.go). cacheMu serializa abrir, trocar e gravar o cache
+	cacheMu        sync.Mutex
+	cacheOn        atomic.Bool // EnableCache foi chamado
+	cacheOpen      atomic.Bool // O store está com o banco do cache de cacheWorld aberto
+	cacheConfirmed atomic.Bool // O servidor confirmou nesta conexão que serve cacheWorld
+	cacheWorld     string
+	dictionaries   map[string][]byte // Dicionários recebidos, à espera da confirmação do mundo
+
+	// timeline indica que o cliente está vendo a linha do tempo: as atualizações
+	// ao vivo são ignoradas e só os chunks históricos entram no store
+	timeline atomic.Bool
+
+	// Tráfego recebido do servidor e qualidade da conexão (ver ping.go)
+	messagesIn   atomic.Int64
+	bytesIn      atomic.Int64
+	dropped      atomic.Int64
+	decodeErrors atomic.Int64
+	ping         pingStats
+
+	// Callbacks para o App
+	OnMapChunk     func(origin util.DFCoord)
+	OnChunkError   func(origin util.DFCoord, err error)
+	OnStatus       func(status *fvnet.ServerStatus)
+	OnScanProgress func(progress *fvnet.ScanProgress)
+	OnWorldStatus  func(status *fvnet.WorldStatus)
+	OnWorldChanged func(msg *fvnet.WorldChanged)
+	OnWorldList    func(list *fvnet.WorldList)
+	OnHistoryInfo  func(info *fvnet.HistoryInfo)
+	OnDiff         func(diff *fvnet.DiffResult)
+	OnTiletypes    func(list *dfproto.TiletypeList)
+	OnMaterials    func(list *dfproto.MaterialList)
+	OnBuildings    func(list *fvnet.BuildingList)
+	OnCreatures    func(list *fvnet.CreatureList)
+}
+
+// WorldURL acrescenta o parâmetro de handshake ?world= à URL do servidor.
+// Sem mundo, a sessão é ligada ao mundo ao vivo.
+func WorldURL(base, world string) string {
+	if world == "" {
+		return base
+	}
+	u, err := url.Parse(base)
+	if err != nil {
+		return base
+	}
+	q := u.Query()
+	q.Set("world", world)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+func NewNetworkClient(url string, store *mapdata.MapDataStore) *NetworkClient {
+	return &NetworkClient{
+		url:      url,
+		store:    store,
+		quit:     make(chan struct{}),
+		versions: chunkVersions{m: make(map[util.DFCoord]chunkVersion)},
+	}
+}
+
+// Connect faz a conexão inicial, com até 10 tentativas. Depois de conectado, uma
+// queda dispara a reconexão automática (Reconnect) até Close.
+func (c *NetworkClient) Connect() error {
+	c.state.Store(int32(StateConnecting))
+
+	var conn *websocket.Conn
+	var err error
+	maxRetries := 10
+	for i := 0; i < maxRetries; i++ {
+		log.Printf("[Network] Tentativa de conexão %d/%d em %s...", i+1, maxRetries, c.url)
+		conn, err = c.dial()
+		if err == nil {
+			break
+		}
+		log.Printf("[Network] Servidor ainda não está pronto: %v. Aguardando...", err)
+		time.Sleep(2 * time.Second)
+	}
+
+	if err != nil {
+		log.Printf("[Network] ERRO CRÍTICO após %d tentativas: %v", maxRetries, err)
+		c.state.Store(int32(StateOffline))
+		return err
+	}
+
+	c.attach(conn)
+	return nil
+}
+
+func (c *NetworkClient) dial() (*websocket.Conn, error) {
+	dialer := websocket.Dialer{
+		HandshakeTimeout: 5 * time.Second,
+	}
+	conn, _, err := dialer.Dial(c.url, nil)
+	return conn, err
+}
+
+// attach passa a usar a conexão recém-aberta e inicia a leitura.
+func (c *NetworkClient) attach(conn *websocket.Conn) {
+	c.mu.Lock()
+	c.conn = conn
+	c.mu.Unlock()
+	c.unconfirmCache()
+	c.state.Store(int32(StateConnected))
+	done := make(chan struct{})
+	go c.readLoop(conn, done)
+	go c.pingLoop(conn, done)
+}
+
+func (c *NetworkClient) IsConnected() bool {
+	return c.State() == StateConnected
+}
+
+// Close encerra a conexão e a reconexão automática; o readLoop termina em seguida.
+func (c *NetworkClient) Close() {
+	c.closeOnce.Do(func() { close(c.quit) })
+	c.state.Store(int32(StateOffline))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil {
+		c.conn.Close()
+	}
+}
+
+// RequestRegion pede ao servidor só o nível de center, no raio radius (ver RequestView).
+func (c *NetworkClient) RequestRegion(center util.DFCoord, radius int32) {
+	c.RequestView(View{Center: center, Radius: radius})
+}
+
+// RequestView pede ao servidor os chunks de v, do nível do centro para fora.
+// Com o cache confirmado, o pedido leva as versões que o cliente já tem (os
+// chunks guardados em disco são mostrados antes) e só chegam os que mudaram.
+func (c *NetworkClient) RequestView(v View) {
+	req := v.request()
+	if c.cacheConfirmed.Load() {
+		c.withKnown(req)
+	}
+	c.mu.Lock()
+	req.Generation = c.generation
+	c.lastRegion = req // Reenviado ao reconectar
+	c.mu.Unlock()
+	c.Send(fvnet.Envelope_CLIENT_REQUEST_REGION, req)
+}
+
+// SetTimeline liga ou desliga o modo linha do tempo (ver RequestHistory).
+func (c *NetworkClient) SetTimeline(on bool) {
+	if on {
+		// Os chunks históricos substituem os ao vivo no store: as versões anotadas
+		// deixam de valer para a ressincronização. O cache grava antes o mapa ao vivo
+		if _, err := c.saveTimelineCache(); err != nil {
+			log.Printf("[Cache] Aviso: falha ao gravar o cache antes da linha do tempo: %v", err)
+		}
+		c.versions.reset()
+		c.timeline.Store(true)
+		return
+	}
+	c.timeline.Store(false)
+	if c.cacheOpen.Load() {
+		// Os históricos saem da RAM e o mapa ao vivo volta do cache (LoadCachedRegion);
+		// o servidor só reenvia o que mudou enquanto a linha do tempo estava aberta
+		c.cacheMu.Lock()
+		c.store.DeleteChunksWhere(func(*mapdata.Chunk) bool { return true })
+		c.loadVersionsLocked()
+		c.cacheMu.Unlock()
+	}
+}
+
+// RequestHistoryInfo pede ao servidor o intervalo do histórico do mundo.
+func (c *NetworkClient) RequestHistoryInfo() {
+	c.Send(fvnet.Envelope_HISTORY_INFO, nil)
+}
+
+// RequestHistory pede a região como ela estava em at (tick do DF ou, se zero, horário).
+func (c *NetworkClient) RequestHistory(center util.DFCoord, radius int32, at mapdata.HistoryPoint) {
+	c.Send(fvnet.Envelope_CLIENT_REQUEST_HISTORY, historyRequest(center, radius, at))
+}
+
+// RequestDiff pede as mudanças da região entre at e o estado atual (resposta em OnDiff).
+func (c *NetworkClient) RequestDiff(center util.DFCoord, radius int32, at mapdata.HistoryPoint) {
+	c.Send(fvnet.Envelope_CLIENT_REQUEST_DIFF, historyRequest(center, radius, at))
+}
+
+func historyRequest(center util.DFCoord, radius int32, at mapdata.HistoryPoint) *fvnet.HistoryRequest {
+	req := &fvnet.HistoryRequest{
+		CenterX: center.X,
+		CenterY: center.Y,
+		CenterZ: center.Z,
+		Radius:  radius,
+		Tick:    at.Tick,
+	}
+	if at.Tick == 0 {
+		req.Time = at.Time.UnixMilli()
+	}
+	return req
+}
+
+// RequestWorldList pede ao servidor a lista de mundos salvos.
+func (c *NetworkClient) RequestWorldList() {
+	c.Send(fvnet.Envelope_WORLD_LIST, nil)
+}
+
+// SelectWorld pede ao servidor para servir outro mundo salvo (modo offline).
+func (c *NetworkClient) SelectWorld(name string) {
+	c.Send(fvnet.Envelope_SELECT_WORLD, &fvnet.SelectWorld{Name: name})
+}
+
+func (c *NetworkClient) Send(msgType fvnet.Envelope_Type, msg proto.Message) {
+	if !c.IsConnected() {
+		return
+	}
+
+	var payload []byte
+	var err error
+	if msg != nil {
+		payload, err = proto.Marshal(msg)
+		if err != nil {
+			log.Printf("[Network] Erro ao serializar payload: %v", err)
+			return
+		}
+	}
+
+	env := &fvnet.Envelope{
+		Type:    msgType,
+		Payload: payload,
+	}
+
+	data, err := proto.Marshal(env)
+	if err != nil {
+		log.Printf("[Network] Erro ao serializar envelope: %v", err)
+		return
+	}
+
+	c.mu.Lock()
+	conn := c.conn
+	err = conn.WriteMessage(websocket.BinaryMessage, data)
+	c.mu.Unlock()
+
+	if err != nil {
+		log.Printf("[Network] Erro ao enviar mensagem: %v", err)
+		conn.Close() // O readLoop percebe a queda e inicia a reconexão
+	}
+}
+
+func (c *NetworkClient) readLoop(conn *websocket.Conn, done chan struct{}) {
+	defer func() {
+		close(done)
+		conn.Close()
+		c.state.Store(int32(StateOffline))
+		c.Reconnect() // Não faz nada depois de Close
+	}()
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			if c.State() != StateOffline { // Offline aqui é Close, não queda
+				log.Printf("[Network] Conexão perdida: %v", err)
+			}
+			break
+		}
+		c.messagesIn.Add(1)
+		c.bytesIn.Add(int64(len(message)))
+
+		var env fvnet.Envelope
+		if err := proto.Unmarshal(message, &env); err != nil {
+			log.Printf("[Network] Erro ao desempacotar envelope: %v", err)
+			c.decodeErrors.Add(1)
+			continue
+		}
+
+		c.handleMessage(&env)
+	}
+}
+
+func (c *NetworkClient) handleMessage(env *fvnet.Envelope) {
+	switch env.Type {
+	case fvnet.Envelope_SERVER_STATUS:
+		var status fvnet.ServerStatus
+		if c.unmarshal(env, &status) {
+			if c.OnStatus != nil {
+				c.OnStatus(&status)
+			}
+		}
+	case fvnet.Envelope_SCAN_PROGRESS:
+		var progress fvnet.ScanProgress
+		if c.unmarshal(env, &progress) {
+			if c.OnScanProgress != nil {
+				c.OnScanProgress(&progress)
+			}
+		}
+	case fvnet.Envelope_MAP_CHUNK:
+		if c.timeline.Load() {
+			c.dropped.Add(1)
+			return // Na linha do tempo o mapa ao vivo fica congelado
+		}
+		var chunkMsg fvnet.MapChunkMessage
+		if c.unmarshal(env, &chunkMsg) {
+			log.Printf("[Network] Chunk recebido: Z=%d (%d, %d)", chunkMsg.ChunkZ, chunkMsg.ChunkX, chunkMsg.ChunkY)
+			if c.processChunk(&chunkMsg, true) {
+				origin := util.DFCoord{X: chunkMsg.ChunkX, Y: chunkMsg.ChunkY, Z: chunkMsg.ChunkZ}
+				c.versions.set(origin, chunkMsg.Mtime, chunkMsg.VoxelData == nil)
+			}
+		}
+	case fvnet.Envelope_HISTORY_CHUNK:
+		if !c.timeline.Load() {
+			c.dropped.Add(1)
+			return // Resposta atrasada de uma linha do tempo já fechada
+		}
+		var chunkMsg fvnet.MapChunkMessage
+		if c.unmarshal(env, &chunkMsg) {
+			c.processChunk(&chunkMsg, false)
+		}
+	case fvnet.Envelope_HISTORY_INFO:
+		var info fvnet.HistoryInfo
+		if c.unmarshal(env, &info) {
+			if c.OnHistoryInfo != nil {
+				c.OnHistoryInfo(&info)
+			}
+		}
+	case fvnet.Envelope_DIFF_RESULT:
+		var diff fvnet.DiffResult
+		if c.unmarshal(env, &diff) {
+			if c.OnDiff != nil {
+				c.OnDiff(&diff)
+			}
+		}
+	case fvnet.Envelope_WORLD_STATUS:
+		var worldStatus fvnet.WorldStatus
+		if c.unmarshal(env, &worldStatus) {
+			if c.OnWorldStatus != nil {
+				c.OnWorldStatus(&worldStatus)
+			}
+		}
+	case fvnet.Envelope_WORLD_CHANGED:
+		var changed fvnet.WorldChanged
+		if c.unmarshal(env, &changed) {
+			log.Printf("[Network] Servidor trocou de mundo: %s", changed.WorldName)
+			// Os chunks recebidos pertencem ao mapa anterior
+			c.switchWorld(changed.WorldName)
+			c.mu.Lock()
+			c.activeWorld = changed.WorldName
+			c.generation = "" // Só se sabe com a próxima lista de mundos
+			c.mu.Unlock()
+			if c.OnWorldChanged != nil {
+				c.OnWorldChanged(&changed)
+			}
+		}
+	case fvnet.Envelope_WORLD_LIST:
+		var list fvnet.WorldList
+		if c.unmarshal(env, &list) {
+			// A lista de mundos fecha as boas-vindas: hora de ressincronizar se reconectou
+			c.noteWorldList(&list)
+			if c.OnWorldList != nil {
+				c.OnWorldList(&list)
+			}
+		}
+	case fvnet.Envelope_TILETYPE_LIST:
+		var list dfproto.TiletypeList
+		if c.unmarshal(env, &list) {
+			log.Printf("[Network] Recebidos %d tiletypes do servidor", len(list.TiletypeList))
+			c.cacheDictionary("TiletypeList", env.Payload)
+			if c.OnTiletypes != nil {
+				c.OnTiletypes(&list)
+			}
+		}
+	case fvnet.Envelope_MATERIAL_LIST:
+		var list dfproto.MaterialList
+		if c.unmarshal(env, &list) {
+			log.Printf("[Network] Recebidos %d materiais do servidor", len(list.MaterialList))
+			c.cacheDictionary("MaterialList", env.Payload)
+			if c.OnMaterials != nil {
+				c.OnMaterials(&list)
+			}
+		}
+	case fvnet.Envelope_BUILDING_LIST:
+		var list fvnet.BuildingList
+		if c.unmarshal(env, &list) {
+			c.store.ReplaceBuildings(buildingInstances(&list))
+			if c.OnBuildings != nil {
+				c.OnBuildings(&list)
+			}
+		}
+	case fvnet.Envelope_CREATURE_UPDATE:
+		var list fvnet.CreatureList
+		if c.unmarshal(env, &list) {
+			c.store.ReplaceUnits(unitInstances(&list))
+			if c.OnCreatures != nil {
+				c.OnCreatures(&list)
+			}
+		}
+	case fvnet.Envelope_PONG:
+		c.handlePong(env)
+	case fvnet.Envelope_VEGETATION_UPDATE:
+		if c.timeline.Load() {
+			c.dropped.Add(1)
+			return
+		}
+		var vegMsg fvnet.VegetationUpdateMessage
+		if c.unmarshal(env, &vegMsg) {
+			c.processVegetation(&vegMsg)
+		}
+	default:
+		c.dropped.Add(1) // Tipo que este cliente não conhece (servidor mais novo)
+	}
+}
+
+// unmarshal decodifica o payload do envelope em msg (protobuf gerado ou Unmarshal
+// manual, como em dfproto) e conta as falhas.
+func (c *NetworkClient) unmarshal(env *fvnet.Envelope, msg interface{}) bool {
+	var err error
+	if m, ok := msg.(interface{ Unmarshal([]byte) error }); ok {
+		err = m.Unmarshal(env.Payload)
+	} else {
+		err = proto.Unmarshal(env.Payload, msg.(proto.Message))
+	}
+	if err != nil {
+		log.Printf("[Network] Erro ao decodificar %v: %v", env.Type, err)
+		c.decodeErrors.Add(1)
+		return false
+	}
+	return true
+}
+
+func (c *NetworkClient) processVegetation(msg *fvnet.VegetationUpdateMessage) {
+	origin := util.DFCoord{X: msg.ChunkX, Y: msg.ChunkY, Z: msg.ChunkZ}
+
+	var plants []dfproto.PlantDetail
+	for _, p := range msg.Plants {
+		plants = append(plants, dfproto.PlantDetail{
+			Pos:      dfproto.Coord{X: p.X, Y: p.Y, Z: 0},
+			Material: dfproto.MatPair{MatType: p.MatType, MatIndex: p.MatIndex},
+		})
+	}
+
+	c.store.StorePlants(msg.ChunkX, msg.ChunkY, msg.ChunkZ, plants)
+
+	// Invalida o mesh para re-renderizar o crescimento
+	if c.OnMapChunk != nil {
+		c.OnMapChunk(origin)
+	}
+}
+
+// processChunk grava o chunk recebido no store; retorna false se ele não decodificou.
+// Chunks ao vivo (live) ficam sujos para irem ao cache em disco, se confirmado.
+func (c *NetworkClient) processChunk(msg *fvnet.MapChunkMessage, live bool) bool {
+	origin := util.DFCoord{X: msg.ChunkX, Y: msg.ChunkY, Z: msg.ChunkZ}
+
+	// Se VoxelData for nil, é um chunk de "Ar" (vazio)
+	if msg.VoxelData == nil {
+		c.store.DeleteChunk(origin) // Garante que não há lixo
+		if c.OnMapChunk != nil {
+			c.OnMapChunk(origin)
+		}
+		return true
+	}
+
+	// Decodificar o chunk completo (tiles, plantas, construções, itens...)
+	chunk, err := mapdata.DecodeChunk(origin, msg.VoxelData)
+	if err != nil {
+		log.Printf("[Network] Erro ao decodificar chunk %v: %v", origin, err)
+		c.decodeErrors.Add(1)
+		if c.OnChunkError != nil {
+			c.OnChunkError(origin, err)
+		}
+		return false
+	}
+
+	// Inserir no MapStore local (PutChunk re-conecta o chunk ao store p/ consultas)
+	chunk.MTime = time.Now().UnixNano() // Nova versão local
+	chunk.IsDirty = live && c.cacheConfirmed.Load()
+	c.store.PutChunk(chunk)
+
+	if c.OnMapChunk != nil {
+		c.OnMapChunk(origin)
+	}
+	return true
+}
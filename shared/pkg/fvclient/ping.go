@@ -0,0 +1,165 @@
+package fvclient
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"FortressVision/shared/proto/fvnet"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/protobuf/proto"
+)
+
+// pingInterval é o intervalo entre PINGs; sem PONG por pongTimeout a conexão é
+// dada como morta e derrubada, o que dispara a reconexão.
+const (
+	pingInterval = 2 * time.Second
+	pongTimeout  = 10 * time.Second
+)
+
+// ConnStats resume a qualidade da conexão e o tráfego recebido, para o HUD e o fvbot.
+type ConnStats struct {
+	RTT    time.Duration // Último RTT medido
+	AvgRTT time.Duration // Média suavizada (como o SRTT do TCP, peso 1/8)
+	MinRTT time.Duration
+	Jitter time.Duration // Variação entre RTTs consecutivos (RFC 3550, peso 1/16)
+
+	PingsSent     int64
+	PongsReceived int64
+	SinceLastPong time.Duration // 0 se nenhum PONG chegou nesta conexão
+
+	Messages     int64 // Mensagens recebidas
+	Bytes        int64 // Bytes recebidos
+	Dropped      int64 // Mensagens descartadas (tipo desconhecido, fora do modo atual)
+	DecodeErrors int64 // Envelopes, payloads ou chunks que não decodificaram
+}
+
+// Unanswered é quantos PINGs ficaram sem resposta (perdidos em quedas ou ainda a caminho).
+func (s ConnStats) Unanswered() int64 {
+	return s.PingsSent - s.PongsReceived
+}
+
+// pingStats guarda as medições de RTT. As da conexão anterior continuam valendo
+// depois de uma reconexão; só lastPong recomeça.
+type pingStats struct {
+	mu       sync.Mutex
+	seq      uint32
+	sent     int64
+	received int64
+	rtt      time.Duration
+	avg      time.Duration
+	min      time.Duration
+	jitter   time.Duration
+	lastPong time.Time
+}
+
+// next reserva o número de sequência do próximo PING.
+func (p *pingStats) next() uint32 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.seq++
+	p.sent++
+	return p.seq
+}
+
+// pong registra a resposta, recebida em now, a um PING enviado em sentAt.
+func (p *pingStats) pong(sentAt, now time.Time) {
+	rtt := now.Sub(sentAt)
+	if rtt < 0 {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.received == 0 {
+		p.avg, p.min = rtt, rtt
+	} else {
+		d := rtt - p.rtt
+		if d < 0 {
+			d = -d
+		}
+		p.jitter += (d - p.jitter) / 16
+		p.avg += (rtt - p.avg) / 8
+		p.min = min(p.min, rtt)
+	}
+	p.rtt = rtt
+	p.received++
+	p.lastPong = now
+}
+
+// alive registra um PONG sem eco (servidor antigo): mantém a conexão viva, sem medir RTT.
+func (p *pingStats) alive() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lastPong = time.Now()
+}
+
+// silentFor diz há quanto tempo (até now) não chega PONG, contando desde since
+// se nenhum chegou depois dele.
+func (p *pingStats) silentFor(since, now time.Time) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.lastPong.After(since) {
+		since = p.lastPong
+	}
+	return now.Sub(since)
+}
+
+// Stats retorna a qualidade da conexão e os contadores de tráfego.
+func (c *NetworkClient) Stats() ConnStats {
+	c.ping.mu.Lock()
+	s := ConnStats{
+		RTT:           c.ping.rtt,
+		AvgRTT:        c.ping.avg,
+		MinRTT:        c.ping.min,
+		Jitter:        c.ping.jitter,
+		PingsSent:     c.ping.sent,
+		PongsReceived: c.ping.received,
+	}
+	if !c.ping.lastPong.IsZero() {
+		s.SinceLastPong = time.Since(c.ping.lastPong)
+	}
+	c.ping.mu.Unlock()
+
+	s.Messages = c.messagesIn.Load()
+	s.Bytes = c.bytesIn.Load()
+	s.Dropped = c.dropped.Load()
+	s.DecodeErrors = c.decodeErrors.Load()
+	return s
+}
+
+// pingLoop envia PINGs enquanto a conexão conn estiver aberta (até done fechar) e
+// a derruba se os PONGs pararem de chegar.
+func (c *NetworkClient) pingLoop(conn *websocket.Conn, done <-chan struct{}) {
+	start := time.Now()
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		if silent := c.ping.silentFor(start, time.Now()); silent > pongTimeout {
+			log.Printf("[Network] Nenhum PONG há %v: conexão dada como morta", silent.Round(time.Second))
+			conn.Close() // O readLoop sai e a reconexão começa
+			return
+		}
+		c.Send(fvnet.Envelope_PING, &fvnet.Ping{Seq: c.ping.next(), SentAt: time.Now().UnixNano()})
+	}
+}
+
+// handlePong mede o RTT a partir do PING ecoado pelo servidor.
+func (c *NetworkClient) handlePong(env *fvnet.Envelope) {
+	if len(env.Payload) == 0 {
+		c.ping.alive() // Servidor antigo: PONG sem eco
+		return
+	}
+	var ping fvnet.Ping
+	if err := proto.Unmarshal(env.Payload, &ping); err != nil {
+		c.decodeErrors.Add(1)
+		return
+	}
+	c.ping.pong(time.Unix(0, ping.SentAt), time.Now())
+}
@@ -0,0 +1,66 @@
+package fvclient
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPingStatsRTTAndJitter(t *testing.T) {
+	ms := func(v float64) time.Duration { return time.Duration(v * float64(time.Millisecond)) }
+	steps := []struct {
+		rtt                   time.Duration
+		wantAvg, wantMin, jit time.Duration
+	}{
+		{ms(100), ms(100), ms(100), 0},
+		{ms(140), ms(105), ms(100), ms(2.5)},                        // jitter += |140-100|/16, avg += 40/8
+		{ms(60), ms(99.375), ms(60), ms(7.34375)},                   // |60-140| = 80
+		{ms(100), ms(99.453125), ms(60), 9384765 * time.Nanosecond}, // 2.5 + 4.84375 + 2.041015 (truncado)
+		{-ms(5), ms(99.453125), ms(60), 9384765 * time.Nanosecond},  // Relógio para trás: ignorado
+	}
+
+	var p pingStats
+	base := time.Unix(1000, 0)
+	for i, step := range steps {
+		seq := p.next()
+		sentAt := base.Add(time.Duration(i) * time.Second)
+		p.pong(sentAt, sentAt.Add(step.rtt))
+		if seq != uint32(i+1) {
+			t.Fatalf("passo %d: seq = %d", i, seq)
+		}
+		if p.avg != step.wantAvg || p.min != step.wantMin || p.jitter != step.jit {
+			t.Fatalf("passo %d (RTT %v): média %v, mínimo %v, jitter %v; want %v, %v, %v",
+				i, step.rtt, p.avg, p.min, p.jitter, step.wantAvg, step.wantMin, step.jit)
+		}
+	}
+	if p.sent != 5 || p.received != 4 || p.rtt != ms(100) {
+		t.Fatalf("enviados %d, recebidos %d, último RTT %v", p.sent, p.received, p.rtt)
+	}
+}
+
+func TestPingStatsSilentFor(t *testing.T) {
+	start := time.Unix(1000, 0)
+	cases := []struct {
+		name     string
+		lastPong time.Duration // Desde start; negativo = PONG da conexão anterior
+		now      time.Duration
+		want     time.Duration
+		dead     bool
+	}{
+		{"sem PONG, dentro do prazo", 0, 9 * time.Second, 9 * time.Second, false},
+		{"sem PONG, prazo estourado", 0, 11 * time.Second, 11 * time.Second, true},
+		{"PONG recente", 8 * time.Second, 15 * time.Second, 7 * time.Second, false},
+		{"PONG antigo", 2 * time.Second, 13 * time.Second, 11 * time.Second, true},
+		{"PONG da conexão anterior", -30 * time.Second, 5 * time.Second, 5 * time.Second, false},
+	}
+	for _, c := range cases {
+		var p pingStats
+		if c.lastPong != 0 {
+			sentAt := start.Add(c.lastPong - 50*time.Millisecond)
+			p.pong(sentAt, start.Add(c.lastPong))
+		}
+		got := p.silentFor(start, start.Add(c.now))
+		if got != c.want || (got > pongTimeout) != c.dead {
+			t.Errorf("%s: silentFor = %v (morta %v), want %v (morta %v)", c.name, got, got > pongTimeout, c.want, c.dead)
+		}
+	}
+}
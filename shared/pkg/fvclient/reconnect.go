@@ -0,0 +1,247 @@
+package fvclient
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"FortressVision/shared/proto/fvnet"
+	"FortressVision/shared/util"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// ConnState é o estado da conexão com o servidor, para o HUD.
+type ConnState int32
+
+const (
+	StateOffline      ConnState = iota // Antes de Connect, depois de Close ou entre uma queda e a reconexão
+	StateConnecting                    // Conexão inicial (Connect)
+	StateConnected                     // Conectado
+	StateReconnecting                  // Caiu; tentando de novo com espera exponencial
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case StateConnecting:
+		return "Conectando"
+	case StateConnected:
+		return "Conectado"
+	case StateReconnecting:
+		return "Reconectando"
+	}
+	return "Offline"
+}
+
+// Espera entre tentativas de reconexão: dobra a cada falha, de reconnectMinDelay
+// até reconnectMaxDelay.
+const (
+	reconnectMinDelay = 1 * time.Second
+	reconnectMaxDelay = 30 * time.Second
+)
+
+// reconnectDelay é a espera antes da tentativa attempt (a partir de 1).
+func reconnectDelay(attempt int) time.Duration {
+	delay := reconnectMinDelay
+	for i := 1; i < attempt && delay < reconnectMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, reconnectMaxDelay)
+}
+
+// State retorna o estado atual da conexão.
+func (c *NetworkClient) State() ConnState {
+	return ConnState(c.state.Load())
+}
+
+// ReconnectStatus retorna a tentativa de reconexão em andamento e quanto falta
+// para ela. Fora da reconexão, attempt é 0.
+func (c *NetworkClient) ReconnectStatus() (attempt int, retryIn time.Duration) {
+	if c.State() != StateReconnecting {
+		return 0, 0
+	}
+	retryIn = time.Until(time.Unix(0, c.retryAt.Load()))
+	if retryIn < 0 {
+		retryIn = 0
+	}
+	return int(c.attempt.Load()), retryIn
+}
+
+// Reconnect tenta reconectar em segundo plano até conseguir ou até Close. É
+// chamado sozinho quando a conexão cai; o App também o usa quando a conexão
+// inicial falha. Ao reconectar, o servidor reenvia as boas-vindas (status,
+// dicionários de tiletypes e materiais, construções, unidades e lista de mundos),
+// aplicadas pelos callbacks de sempre, e o cliente ressincroniza a última região.
+func (c *NetworkClient) Reconnect() {
+	select {
+	case <-c.quit:
+		return
+	default:
+	}
+	if !c.reconnecting.CompareAndSwap(false, true) {
+		return
+	}
+	c.state.Store(int32(StateReconnecting))
+
+	go func() {
+		defer c.reconnecting.Store(false)
+		for attempt := 1; ; attempt++ {
+			delay := reconnectDelay(attempt)
+			c.attempt.Store(int32(attempt))
+			c.retryAt.Store(time.Now().Add(delay).UnixNano())
+			select {
+			case <-c.quit:
+				return
+			case <-time.After(delay):
+			}
+
+			conn, err := c.dial()
+			if err == nil {
+				select {
+				case <-c.quit:
+					conn.Close()
+					return
+				default:
+				}
+				log.Printf("[Network] Reconectado a %s na tentativa %d", c.url, attempt)
+				c.resyncPending.Store(true)
+				c.attach(conn)
+				return
+			}
+			log.Printf("[Network] Reconexão %d falhou: %v (próxima em %v)", attempt, err, reconnectDelay(attempt+1))
+		}
+	}()
+}
+
+// noteWorldList anota o mundo ativo da sessão e, logo depois de uma reconexão,
+// ressincroniza: se o servidor passou a servir outro mundo, o mapa local é
+// descartado como em WORLD_CHANGED; senão a última região é pedida de novo
+// informando as versões que o cliente já tem, e só chegam os chunks que mudaram
+// enquanto ele estava desconectado. Se o banco do mesmo mundo foi substituído
+// no servidor (outra geração), as versões conhecidas não valem mais e o mapa
+// local também é descartado. Com o cache ligado, é aqui que o cache do mundo
+// servido é aberto ou confirmado (ver confirmCache).
+func (c *NetworkClient) noteWorldList(list *fvnet.WorldList) {
+	active, generation := "", ""
+	for _, w := range list.Worlds {
+		if w.Active {
+			active, generation = w.Name, w.Generation
+		}
+	}
+
+	c.mu.Lock()
+	previous, previousGeneration := c.activeWorld, c.generation
+	if active != "" {
+		c.activeWorld = active
+		c.generation = generation
+	}
+	region := c.lastRegion
+	c.mu.Unlock()
+
+	resync := c.resyncPending.Swap(false)
+	changed := resync && previous != "" && active != "" && previous != active
+	if changed {
+		log.Printf("[Network] Após reconectar, o servidor serve outro mundo (%s → %s)", previous, active)
+	} else if active != "" && active == previous && previousGeneration != "" && previousGeneration != generation {
+		log.Printf("[Network] O banco de %s foi substituído no servidor: descartando o mapa local", active)
+		changed = true
+	}
+	if c.cacheOn.Load() && active != "" {
+		if c.confirmCache(active, generation) {
+			changed = true
+		}
+	} else if changed {
+		c.store.ResetMemory()
+		c.versions.reset()
+	}
+	if changed && c.OnWorldChanged != nil {
+		c.OnWorldChanged(&fvnet.WorldChanged{WorldName: active})
+	}
+	if !resync || region == nil || c.timeline.Load() {
+		return
+	}
+
+	req := proto.Clone(region).(*fvnet.ClientRequestRegion)
+	req.Generation = generation
+	c.withKnown(req)
+	minZ, maxZ := req.Levels()
+	log.Printf("[Network] Ressincronizando região (%d, %d, %d) R:%d Z:%d..%d com %d chunks conhecidos",
+		req.CenterX, req.CenterY, req.CenterZ, req.Radius, minZ, maxZ, len(req.Known))
+	c.Send(fvnet.Envelope_CLIENT_REQUEST_REGION, req)
+}
+
+// chunkVersion é a versão (MTime do servidor) de um chunk ao vivo recebido.
+// Chunks de ar não ficam no store, então empty os distingue de um despejado.
+// shown diz se o chunk já foi entregue ao App nesta sessão; os lidos do cache
+// em disco começam sem ele (ver LoadCachedRegion).
+type chunkVersion struct {
+	mtime int64
+	empty bool
+	shown bool
+}
+
+// chunkVersions guarda a versão de cada chunk ao vivo recebido.
+type chunkVersions struct {
+	mu sync.Mutex
+	m  map[util.DFCoord]chunkVersion
+}
+
+func (v *chunkVersions) set(origin util.DFCoord, mtime int64, empty bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.m[origin] = chunkVersion{mtime: mtime, empty: empty, shown: true}
+}
+
+func (v *chunkVersions) len() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return len(v.m)
+}
+
+func (v *chunkVersions) reset() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.m = make(map[util.DFCoord]chunkVersion)
+}
+
+// inRegion lista as versões conhecidas dos chunks que o servidor enviaria para
+// req. Chunks com dados só entram se has confirmar que ainda estão no store
+// (o cache pode tê-los despejado).
+func (v *chunkVersions) inRegion(req *fvnet.ClientRequestRegion, has func(util.DFCoord) bool) []*fvnet.ChunkVersion {
+	origins := regionChunks(req)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	var known []*fvnet.ChunkVersion
+	for _, origin := range origins {
+		cv, ok := v.m[origin]
+		if !ok || cv.mtime == 0 || (!cv.empty && !has(origin)) {
+			continue
+		}
+		known = append(known, &fvnet.ChunkVersion{X: origin.X, Y: origin.Y, Z: origin.Z, Mtime: cv.mtime})
+	}
+	return known
+}
+
+// unshown marca como mostrados os chunks conhecidos da região que ainda não
+// foram entregues ao App e os retorna na ordem de envio do servidor; data é
+// quantos deles têm dados (não são ar).
+func (v *chunkVersions) unshown(req *fvnet.ClientRequestRegion) (origins []util.DFCoord, data int) {
+	chunks := regionChunks(req)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for _, origin := range chunks {
+		cv, ok := v.m[origin]
+		if !ok || cv.shown {
+			continue
+		}
+		cv.shown = true
+		v.m[origin] = cv
+		origins = append(origins, origin)
+		if !cv.empty {
+			data++
+		}
+	}
+	return origins, data
+}
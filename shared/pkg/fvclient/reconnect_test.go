@@ -0,0 +1,93 @@
+package fvclient
+
+import (
+	"testing"
+	"time"
+
+	"FortressVision/shared/proto/fvnet"
+	"FortressVision/shared/util"
+)
+
+func TestReconnectDelayDoublesUpToCap(t *testing.T) {
+	want := []time.Duration{1, 2, 4, 8, 16, 30, 30, 30}
+	for i, w := range want {
+		if got := reconnectDelay(i + 1); got != w*time.Second {
+			t.Errorf("reconnectDelay(%d) = %v, want %v", i+1, got, w*time.Second)
+		}
+	}
+	if got := reconnectDelay(1000); got != reconnectMaxDelay {
+		t.Errorf("reconnectDelay(1000) = %v, want %v", got, reconnectMaxDelay)
+	}
+}
+
+func TestReconnectStatus(t *testing.T) {
+	c := &NetworkClient{}
+	c.attempt.Store(3)
+	c.retryAt.Store(time.Now().Add(5 * time.Second).UnixNano())
+	if attempt, retryIn := c.ReconnectStatus(); attempt != 0 || retryIn != 0 {
+		t.Fatalf("fora da reconexão: %d, %v", attempt, retryIn)
+	}
+
+	c.state.Store(int32(StateReconnecting))
+	if attempt, retryIn := c.ReconnectStatus(); attempt != 3 || retryIn <= 4*time.Second || retryIn > 5*time.Second {
+		t.Fatalf("reconectando: tentativa %d em %v, want 3 em ~5s", attempt, retryIn)
+	}
+	c.retryAt.Store(time.Now().Add(-time.Second).UnixNano())
+	if _, retryIn := c.ReconnectStatus(); retryIn != 0 {
+		t.Fatalf("tentativa atrasada: retryIn = %v, want 0", retryIn)
+	}
+}
+
+// resyncRegion cobre os chunks (0..32, 0..32) do nível 5.
+func resyncRegion() *fvnet.ClientRequestRegion {
+	return &fvnet.ClientRequestRegion{CenterX: 24, CenterY: 24, CenterZ: 5, Radius: 16}
+}
+
+func TestKnownVersionsSkipEvictedChunks(t *testing.T) {
+	kept, evicted := util.NewDFCoord(0, 0, 5), util.NewDFCoord(16, 0, 5)
+	air, unversioned := util.NewDFCoord(32, 0, 5), util.NewDFCoord(0, 16, 5)
+	outside := util.NewDFCoord(64, 64, 5)
+
+	v := chunkVersions{m: make(map[util.DFCoord]chunkVersion)}
+	v.set(kept, 10, false)
+	v.set(evicted, 11, false)
+	v.set(air, 12, true)
+	v.set(unversioned, 0, false)
+	v.set(outside, 13, false)
+
+	inStore := map[util.DFCoord]bool{kept: true, unversioned: true, outside: true}
+	known := v.inRegion(resyncRegion(), func(origin util.DFCoord) bool { return inStore[origin] })
+
+	got := make(map[util.DFCoord]int64)
+	for _, k := range known {
+		got[util.NewDFCoord(k.X, k.Y, k.Z)] = k.Mtime
+	}
+	want := map[util.DFCoord]int64{kept: 10, air: 12} // Ar não fica no store e ainda vale
+	if len(got) != len(want) {
+		t.Fatalf("versões conhecidas = %v, want %v", got, want)
+	}
+	for origin, mtime := range want {
+		if got[origin] != mtime {
+			t.Fatalf("versões conhecidas = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestUnshownMarksCachedChunksOnce(t *testing.T) {
+	v := chunkVersions{m: make(map[util.DFCoord]chunkVersion)}
+	live := util.NewDFCoord(0, 0, 5)
+	v.set(live, 1, false) // Recebido ao vivo: já mostrado
+	v.m[util.NewDFCoord(16, 16, 5)] = chunkVersion{mtime: 2}
+	v.m[util.NewDFCoord(32, 16, 5)] = chunkVersion{mtime: 3, empty: true}
+
+	origins, data := v.unshown(resyncRegion())
+	if len(origins) != 2 || data != 1 {
+		t.Fatalf("unshown = %v (%d com dados), want 2 chunks, 1 com dados", origins, data)
+	}
+	if origins[0] != util.NewDFCoord(16, 16, 5) {
+		t.Errorf("ordem = %v, want o chunk do centro primeiro", origins)
+	}
+	if again, _ := v.unshown(resyncRegion()); len(again) != 0 {
+		t.Fatalf("segunda chamada devolveu %v", again)
+	}
+}
@@ -0,0 +1,53 @@
+package fvclient
+
+import (
+	"FortressVision/shared/mapdata"
+	"FortressVision/shared/proto/fvnet"
+	"FortressVision/shared/util"
+)
+
+// View é a área que o App quer ver: o raio Radius em volta de Center, os níveis
+// de MinZ a MaxZ e, opcionalmente, o polígono do chão visto pela câmera (x, y em
+// tiles). O servidor envia primeiro o nível de Center e depois os mais próximos.
+// Com MinZ e MaxZ zerados, só o nível de Center.
+type View struct {
+	Center     util.DFCoord
+	Radius     int32
+	MinZ, MaxZ int32
+	Frustum    []util.DFCoord
+}
+
+// Chunks lista as origens dos chunks que o servidor envia para v, na ordem em que chegam.
+func (v View) Chunks() []util.DFCoord {
+	return regionChunks(v.request())
+}
+
+// request monta o pedido de região de v.
+func (v View) request() *fvnet.ClientRequestRegion {
+	req := &fvnet.ClientRequestRegion{
+		CenterX: v.Center.X,
+		CenterY: v.Center.Y,
+		CenterZ: v.Center.Z,
+		Radius:  v.Radius,
+		MinZ:    v.MinZ,
+		MaxZ:    v.MaxZ,
+	}
+	if len(v.Frustum) >= 3 {
+		for _, p := range v.Frustum {
+			req.Frustum = append(req.Frustum, &fvnet.TilePoint{X: p.X, Y: p.Y})
+		}
+	}
+	return req
+}
+
+// regionChunks lista, na ordem de envio do servidor, as origens dos chunks pedidos em req.
+func regionChunks(req *fvnet.ClientRequestRegion) []util.DFCoord {
+	regionMin, regionMax := req.Bounds()
+	return mapdata.ChunksByPriority(mapdata.Region{Min: regionMin, Max: regionMax}, req.Center(), req.FrustumPolygon())
+}
+
+// regionBounds retorna as origens do primeiro e do último chunk da caixa pedida em req.
+func regionBounds(req *fvnet.ClientRequestRegion) (util.DFCoord, util.DFCoord) {
+	regionMin, regionMax := req.Bounds()
+	return regionMin.BlockCoord(), regionMax.BlockCoord()
+}
@@ -25,43 +25,73 @@ const (
 type Envelope_Type int32
 
 const (
-	Envelope_PING                  Envelope_Type = 0
-	Envelope_PONG                  Envelope_Type = 1
-	Envelope_MAP_CHUNK             Envelope_Type = 2
-	Envelope_CREATURE_UPDATE       Envelope_Type = 3
-	Envelope_CLIENT_REQUEST_REGION Envelope_Type = 4
-	Envelope_SERVER_STATUS         Envelope_Type = 5
-	Envelope_WORLD_STATUS          Envelope_Type = 6
-	Envelope_VEGETATION_UPDATE     Envelope_Type = 7
-	Envelope_TILETYPE_LIST         Envelope_Type = 8
-	Envelope_MATERIAL_LIST         Envelope_Type = 9
+	Envelope_PING                   Envelope_Type = 0 // Cliente mede a latência (Ping); o servidor devolve o mesmo payload em PONG
+	Envelope_PONG                   Envelope_Type = 1
+	Envelope_MAP_CHUNK              Envelope_Type = 2
+	Envelope_CREATURE_UPDATE        Envelope_Type = 3 // Servidor envia as unidades conhecidas do mundo (CreatureList)
+	Envelope_CLIENT_REQUEST_REGION  Envelope_Type = 4
+	Envelope_SERVER_STATUS          Envelope_Type = 5
+	Envelope_WORLD_STATUS           Envelope_Type = 6
+	Envelope_VEGETATION_UPDATE      Envelope_Type = 7
+	Envelope_TILETYPE_LIST          Envelope_Type = 8
+	Envelope_MATERIAL_LIST          Envelope_Type = 9
+	Envelope_SCAN_PROGRESS          Envelope_Type = 10
+	Envelope_WORLD_CHANGED          Envelope_Type = 11
+	Envelope_WORLD_LIST             Envelope_Type = 12 // Cliente pede (payload vazio) e servidor responde com WorldList
+	Envelope_SELECT_WORLD           Envelope_Type = 13 // Cliente liga a sessão a outro mundo salvo
+	Envelope_HISTORY_INFO           Envelope_Type = 14 // Cliente pede (payload vazio) e servidor responde com HistoryInfo
+	Envelope_CLIENT_REQUEST_HISTORY Envelope_Type = 15 // Cliente pede uma região como ela estava num instante (HistoryRequest)
+	Envelope_HISTORY_CHUNK          Envelope_Type = 16 // Resposta de CLIENT_REQUEST_HISTORY (MapChunkMessage)
+	Envelope_CLIENT_REQUEST_DIFF    Envelope_Type = 17 // Cliente pede as mudanças da região desde um instante (HistoryRequest)
+	Envelope_DIFF_RESULT            Envelope_Type = 18 // Resposta de CLIENT_REQUEST_DIFF (DiffResult)
+	Envelope_BUILDING_LIST          Envelope_Type = 19 // Servidor envia as construções do mundo (BuildingList)
 )
 
 // Enum value maps for Envelope_Type.
 var (
 	Envelope_Type_name = map[int32]string{
-		0: "PING",
-		1: "PONG",
-		2: "MAP_CHUNK",
-		3: "CREATURE_UPDATE",
-		4: "CLIENT_REQUEST_REGION",
-		5: "SERVER_STATUS",
-		6: "WORLD_STATUS",
-		7: "VEGETATION_UPDATE",
-		8: "TILETYPE_LIST",
-		9: "MATERIAL_LIST",
+		0:  "PING",
+		1:  "PONG",
+		2:  "MAP_CHUNK",
+		3:  "CREATURE_UPDATE",
+		4:  "CLIENT_REQUEST_REGION",
+		5:  "SERVER_STATUS",
+		6:  "WORLD_STATUS",
+		7:  "VEGETATION_UPDATE",
+		8:  "TILETYPE_LIST",
+		9:  "MATERIAL_LIST",
+		10: "SCAN_PROGRESS",
+		11: "WORLD_CHANGED",
+		12: "WORLD_LIST",
+		13: "SELECT_WORLD",
+		14: "HISTORY_INFO",
+		15: "CLIENT_REQUEST_HISTORY",
+		16: "HISTORY_CHUNK",
+		17: "CLIENT_REQUEST_DIFF",
+		18: "DIFF_RESULT",
+		19: "BUILDING_LIST",
 	}
 	Envelope_Type_value = map[string]int32{
-		"PING":                  0,
-		"PONG":                  1,
-		"MAP_CHUNK":             2,
-		"CREATURE_UPDATE":       3,
-		"CLIENT_REQUEST_REGION": 4,
-		"SERVER_STATUS":         5,
-		"WORLD_STATUS":          6,
-		"VEGETATION_UPDATE":     7,
-		"TILETYPE_LIST":         8,
-		"MATERIAL_LIST":         9,
+		"PING":                   0,
+		"PONG":                   1,
+		"MAP_CHUNK":              2,
+		"CREATURE_UPDATE":        3,
+		"CLIENT_REQUEST_REGION":  4,
+		"SERVER_STATUS":          5,
+		"WORLD_STATUS":           6,
+		"VEGETATION_UPDATE":      7,
+		"TILETYPE_LIST":          8,
+		"MATERIAL_LIST":          9,
+		"SCAN_PROGRESS":          10,
+		"WORLD_CHANGED":          11,
+		"WORLD_LIST":             12,
+		"SELECT_WORLD":           13,
+		"HISTORY_INFO":           14,
+		"CLIENT_REQUEST_HISTORY": 15,
+		"HISTORY_CHUNK":          16,
+		"CLIENT_REQUEST_DIFF":    17,
+		"DIFF_RESULT":            18,
+		"BUILDING_LIST":          19,
 	}
 )
 
@@ -92,30 +122,1308 @@ func (Envelope_Type) EnumDescriptor() ([]byte, []int) {
 	return file_shared_proto_fvnet_fv_network_proto_rawDescGZIP(), []int{0, 0}
 }
 
+// Estado operacional do servidor (substitui a interpretação de strings em message)
+type ServerStatus_State int32
+
+const (
+	ServerStatus_ONLINE          ServerStatus_State = 0 // Conectado ao DFHack, sincronização em tempo real
+	ServerStatus_OFFLINE_CACHE   ServerStatus_State = 1 // Sem DFHack, servindo o cache SQLite
+	ServerStatus_SCANNING        ServerStatus_State = 2 // Varredura total em andamento
+	ServerStatus_DF_DISCONNECTED ServerStatus_State = 3 // DFHack caiu durante a sessão, aguardando reconexão
+)
+
+// Enum value maps for ServerStatus_State.
+var (
+	ServerStatus_State_name = map[int32]string{
+		0: "ONLINE",
+		1: "OFFLINE_CACHE",
+		2: "SCANNING",
+		3: "DF_DISCONNECTED",
+	}
+	ServerStatus_State_value = map[string]int32{
+		"ONLINE":          0,
+		"OFFLINE_CACHE":   1,
+		"SCANNING":        2,
+		"DF_DISCONNECTED": 3,
+	}
+)
+
+func (x ServerStatus_State) Enum() *ServerStatus_State {
+	p := new(ServerStatus_State)
+	*p = x
+	return p
+}
+
+func (x ServerStatus_State) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ServerStatus_State) Descriptor() protoreflect.EnumDescriptor {
+	return file_shared_proto_fvnet_fv_network_proto_enumTypes[1].Descriptor()
+}
+
+func (ServerStatus_State) Type() protoreflect.EnumType {
+	return &file_shared_proto_fvnet_fv_network_proto_enumTypes[1]
+}
+
+func (x ServerStatus_State) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ServerStatus_State.Descriptor instead.
+func (ServerStatus_State) EnumDescriptor() ([]byte, []int) {
+	return file_shared_proto_fvnet_fv_network_proto_rawDescGZIP(), []int{15, 0}
+}
+
+type ScanProgress_Phase int32
+
+const (
+	ScanProgress_IDLE     ScanProgress_Phase = 0
+	ScanProgress_SCANNING ScanProgress_Phase = 1
+	ScanProgress_PAUSED   ScanProgress_Phase = 2 // DFHack indisponível, retoma na reconexão
+	ScanProgress_DONE     ScanProgress_Phase = 3
+)
+
+// Enum value maps for ScanProgress_Phase.
+var (
+	ScanProgress_Phase_name = map[int32]string{
+		0: "IDLE",
+		1: "SCANNING",
+		2: "PAUSED",
+		3: "DONE",
+	}
+	ScanProgress_Phase_value = map[string]int32{
+		"IDLE":     0,
+		"SCANNING": 1,
+		"PAUSED":   2,
+		"DONE":     3,
+	}
+)
+
+func (x ScanProgress_Phase) Enum() *ScanProgress_Phase {
+	p := new(ScanProgress_Phase)
+	*p = x
+	return p
+}
+
+func (x ScanProgress_Phase) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ScanProgress_Phase) Descriptor() protoreflect.EnumDescriptor {
+	return file_shared_proto_fvnet_fv_network_proto_enumTypes[2].Descriptor()
+}
+
+func (ScanProgress_Phase) Type() protoreflect.EnumType {
+	return &file_shared_proto_fvnet_fv_network_proto_enumTypes[2]
+}
+
+func (x ScanProgress_Phase) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ScanProgress_Phase.Descriptor instead.
+func (ScanProgress_Phase) EnumDescriptor() ([]byte, []int) {
+	return file_shared_proto_fvnet_fv_network_proto_rawDescGZIP(), []int{16, 0}
+}
+
 // Envelope para qualquer mensagem via WebSocket
 type Envelope struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Type          Envelope_Type          `protobuf:"varint,1,opt,name=type,proto3,enum=fvnet.Envelope_Type" json:"type,omitempty"`
-	Payload       []byte                 `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"`
+	Type          Envelope_Type          `protobuf:"varint,1,opt,name=type,proto3,enum=fvnet.Envelope_Type" json:"type,omitempty"`
+	Payload       []byte                 `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Envelope) Reset() {
+	*x = Envelope{}
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Envelope) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Envelope) ProtoMessage() {}
+
+func (x *Envelope) ProtoReflect() protoreflect.Message {
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Envelope.ProtoReflect.Descriptor instead.
+func (*Envelope) Descriptor() ([]byte, []int) {
+	return file_shared_proto_fvnet_fv_network_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Envelope) GetType() Envelope_Type {
+	if x != nil {
+		return x.Type
+	}
+	return Envelope_PING
+}
+
+func (x *Envelope) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+// Payload de PING e PONG: o servidor ecoa sem interpretar
+type Ping struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Seq           uint32                 `protobuf:"varint,1,opt,name=seq,proto3" json:"seq,omitempty"`
+	SentAt        int64                  `protobuf:"varint,2,opt,name=sent_at,json=sentAt,proto3" json:"sent_at,omitempty"` // UnixNano no relógio do cliente
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Ping) Reset() {
+	*x = Ping{}
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Ping) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Ping) ProtoMessage() {}
+
+func (x *Ping) ProtoReflect() protoreflect.Message {
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Ping.ProtoReflect.Descriptor instead.
+func (*Ping) Descriptor() ([]byte, []int) {
+	return file_shared_proto_fvnet_fv_network_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Ping) GetSeq() uint32 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *Ping) GetSentAt() int64 {
+	if x != nil {
+		return x.SentAt
+	}
+	return 0
+}
+
+type MapChunkMessage struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ChunkX        int32                  `protobuf:"varint,1,opt,name=chunk_x,json=chunkX,proto3" json:"chunk_x,omitempty"`
+	ChunkY        int32                  `protobuf:"varint,2,opt,name=chunk_y,json=chunkY,proto3" json:"chunk_y,omitempty"`
+	ChunkZ        int32                  `protobuf:"varint,3,opt,name=chunk_z,json=chunkZ,proto3" json:"chunk_z,omitempty"`
+	VoxelData     []byte                 `protobuf:"bytes,4,opt,name=voxel_data,json=voxelData,proto3" json:"voxel_data,omitempty"` // Chunk serializado por mapdata.EncodeChunk (vazio = Ar)
+	Mtime         int64                  `protobuf:"varint,5,opt,name=mtime,proto3" json:"mtime,omitempty"`                         // Versão (MTime) do chunk no servidor; 0 se o servidor não o tem
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MapChunkMessage) Reset() {
+	*x = MapChunkMessage{}
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MapChunkMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MapChunkMessage) ProtoMessage() {}
+
+func (x *MapChunkMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MapChunkMessage.ProtoReflect.Descriptor instead.
+func (*MapChunkMessage) Descriptor() ([]byte, []int) {
+	return file_shared_proto_fvnet_fv_network_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *MapChunkMessage) GetChunkX() int32 {
+	if x != nil {
+		return x.ChunkX
+	}
+	return 0
+}
+
+func (x *MapChunkMessage) GetChunkY() int32 {
+	if x != nil {
+		return x.ChunkY
+	}
+	return 0
+}
+
+func (x *MapChunkMessage) GetChunkZ() int32 {
+	if x != nil {
+		return x.ChunkZ
+	}
+	return 0
+}
+
+func (x *MapChunkMessage) GetVoxelData() []byte {
+	if x != nil {
+		return x.VoxelData
+	}
+	return nil
+}
+
+func (x *MapChunkMessage) GetMtime() int64 {
+	if x != nil {
+		return x.Mtime
+	}
+	return 0
+}
+
+// Versão de um chunk que o cliente já tem em memória
+type ChunkVersion struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	X             int32                  `protobuf:"varint,1,opt,name=x,proto3" json:"x,omitempty"`
+	Y             int32                  `protobuf:"varint,2,opt,name=y,proto3" json:"y,omitempty"`
+	Z             int32                  `protobuf:"varint,3,opt,name=z,proto3" json:"z,omitempty"`
+	Mtime         int64                  `protobuf:"varint,4,opt,name=mtime,proto3" json:"mtime,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChunkVersion) Reset() {
+	*x = ChunkVersion{}
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChunkVersion) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChunkVersion) ProtoMessage() {}
+
+func (x *ChunkVersion) ProtoReflect() protoreflect.Message {
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChunkVersion.ProtoReflect.Descriptor instead.
+func (*ChunkVersion) Descriptor() ([]byte, []int) {
+	return file_shared_proto_fvnet_fv_network_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ChunkVersion) GetX() int32 {
+	if x != nil {
+		return x.X
+	}
+	return 0
+}
+
+func (x *ChunkVersion) GetY() int32 {
+	if x != nil {
+		return x.Y
+	}
+	return 0
+}
+
+func (x *ChunkVersion) GetZ() int32 {
+	if x != nil {
+		return x.Z
+	}
+	return 0
+}
+
+func (x *ChunkVersion) GetMtime() int64 {
+	if x != nil {
+		return x.Mtime
+	}
+	return 0
+}
+
+type ClientRequestRegion struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	CenterX int32                  `protobuf:"varint,1,opt,name=center_x,json=centerX,proto3" json:"center_x,omitempty"`
+	CenterY int32                  `protobuf:"varint,2,opt,name=center_y,json=centerY,proto3" json:"center_y,omitempty"`
+	CenterZ int32                  `protobuf:"varint,3,opt,name=center_z,json=centerZ,proto3" json:"center_z,omitempty"`
+	Radius  int32                  `protobuf:"varint,4,opt,name=radius,proto3" json:"radius,omitempty"`
+	// Chunks da região que o cliente já tem (ressincronização após reconectar ou
+	// cache local em disco): o servidor pula os que continuam na mesma versão
+	Known []*ChunkVersion `protobuf:"bytes,5,rep,name=known,proto3" json:"known,omitempty"`
+	// Faixa de níveis Z pedida (inclusive), enviada do nível center_z para os
+	// mais próximos. Com as duas zeradas (clientes antigos), só center_z
+	MinZ int32 `protobuf:"varint,6,opt,name=min_z,json=minZ,proto3" json:"min_z,omitempty"`
+	MaxZ int32 `protobuf:"varint,7,opt,name=max_z,json=maxZ,proto3" json:"max_z,omitempty"`
+	// Polígono opcional da área vista pela câmera (x, y em tiles): dentro do
+	// quadrado do raio, só vão os chunks que tocam o polígono
+	Frustum []*TilePoint `protobuf:"bytes,8,rep,name=frustum,proto3" json:"frustum,omitempty"`
+	// Geração do banco (WorldInfo.generation) a que as versões de known se
+	// referem: se não for a do mundo servido, o servidor ignora known
+	Generation    string `protobuf:"bytes,9,opt,name=generation,proto3" json:"generation,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClientRequestRegion) Reset() {
+	*x = ClientRequestRegion{}
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClientRequestRegion) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientRequestRegion) ProtoMessage() {}
+
+func (x *ClientRequestRegion) ProtoReflect() protoreflect.Message {
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientRequestRegion.ProtoReflect.Descriptor instead.
+func (*ClientRequestRegion) Descriptor() ([]byte, []int) {
+	return file_shared_proto_fvnet_fv_network_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ClientRequestRegion) GetCenterX() int32 {
+	if x != nil {
+		return x.CenterX
+	}
+	return 0
+}
+
+func (x *ClientRequestRegion) GetCenterY() int32 {
+	if x != nil {
+		return x.CenterY
+	}
+	return 0
+}
+
+func (x *ClientRequestRegion) GetCenterZ() int32 {
+	if x != nil {
+		return x.CenterZ
+	}
+	return 0
+}
+
+func (x *ClientRequestRegion) GetRadius() int32 {
+	if x != nil {
+		return x.Radius
+	}
+	return 0
+}
+
+func (x *ClientRequestRegion) GetKnown() []*ChunkVersion {
+	if x != nil {
+		return x.Known
+	}
+	return nil
+}
+
+func (x *ClientRequestRegion) GetMinZ() int32 {
+	if x != nil {
+		return x.MinZ
+	}
+	return 0
+}
+
+func (x *ClientRequestRegion) GetMaxZ() int32 {
+	if x != nil {
+		return x.MaxZ
+	}
+	return 0
+}
+
+func (x *ClientRequestRegion) GetFrustum() []*TilePoint {
+	if x != nil {
+		return x.Frustum
+	}
+	return nil
+}
+
+func (x *ClientRequestRegion) GetGeneration() string {
+	if x != nil {
+		return x.Generation
+	}
+	return ""
+}
+
+type TilePoint struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	X             int32                  `protobuf:"varint,1,opt,name=x,proto3" json:"x,omitempty"`
+	Y             int32                  `protobuf:"varint,2,opt,name=y,proto3" json:"y,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TilePoint) Reset() {
+	*x = TilePoint{}
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TilePoint) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TilePoint) ProtoMessage() {}
+
+func (x *TilePoint) ProtoReflect() protoreflect.Message {
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TilePoint.ProtoReflect.Descriptor instead.
+func (*TilePoint) Descriptor() ([]byte, []int) {
+	return file_shared_proto_fvnet_fv_network_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *TilePoint) GetX() int32 {
+	if x != nil {
+		return x.X
+	}
+	return 0
+}
+
+func (x *TilePoint) GetY() int32 {
+	if x != nil {
+		return x.Y
+	}
+	return 0
+}
+
+// Linha do tempo: pede a região de ClientRequestRegion como ela estava num instante
+type HistoryRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	CenterX       int32                  `protobuf:"varint,1,opt,name=center_x,json=centerX,proto3" json:"center_x,omitempty"`
+	CenterY       int32                  `protobuf:"varint,2,opt,name=center_y,json=centerY,proto3" json:"center_y,omitempty"`
+	CenterZ       int32                  `protobuf:"varint,3,opt,name=center_z,json=centerZ,proto3" json:"center_z,omitempty"`
+	Radius        int32                  `protobuf:"varint,4,opt,name=radius,proto3" json:"radius,omitempty"`
+	Time          int64                  `protobuf:"varint,5,opt,name=time,proto3" json:"time,omitempty"` // Unix (milissegundos), usado quando tick = 0
+	Tick          int64                  `protobuf:"varint,6,opt,name=tick,proto3" json:"tick,omitempty"` // Tick absoluto do DF (ver mapdata.GameTick)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HistoryRequest) Reset() {
+	*x = HistoryRequest{}
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HistoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HistoryRequest) ProtoMessage() {}
+
+func (x *HistoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HistoryRequest.ProtoReflect.Descriptor instead.
+func (*HistoryRequest) Descriptor() ([]byte, []int) {
+	return file_shared_proto_fvnet_fv_network_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *HistoryRequest) GetCenterX() int32 {
+	if x != nil {
+		return x.CenterX
+	}
+	return 0
+}
+
+func (x *HistoryRequest) GetCenterY() int32 {
+	if x != nil {
+		return x.CenterY
+	}
+	return 0
+}
+
+func (x *HistoryRequest) GetCenterZ() int32 {
+	if x != nil {
+		return x.CenterZ
+	}
+	return 0
+}
+
+func (x *HistoryRequest) GetRadius() int32 {
+	if x != nil {
+		return x.Radius
+	}
+	return 0
+}
+
+func (x *HistoryRequest) GetTime() int64 {
+	if x != nil {
+		return x.Time
+	}
+	return 0
+}
+
+func (x *HistoryRequest) GetTick() int64 {
+	if x != nil {
+		return x.Tick
+	}
+	return 0
+}
+
+// Intervalo do histórico de chunks gravado no mundo da sessão
+type HistoryInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Enabled       bool                   `protobuf:"varint,1,opt,name=enabled,proto3" json:"enabled,omitempty"`                      // false se o mundo não guarda histórico
+	FirstTime     int64                  `protobuf:"varint,2,opt,name=first_time,json=firstTime,proto3" json:"first_time,omitempty"` // Unix (milissegundos)
+	LastTime      int64                  `protobuf:"varint,3,opt,name=last_time,json=lastTime,proto3" json:"last_time,omitempty"`
+	FirstTick     int64                  `protobuf:"varint,4,opt,name=first_tick,json=firstTick,proto3" json:"first_tick,omitempty"`
+	LastTick      int64                  `protobuf:"varint,5,opt,name=last_tick,json=lastTick,proto3" json:"last_tick,omitempty"`
+	Snapshots     int64                  `protobuf:"varint,6,opt,name=snapshots,proto3" json:"snapshots,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HistoryInfo) Reset() {
+	*x = HistoryInfo{}
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HistoryInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HistoryInfo) ProtoMessage() {}
+
+func (x *HistoryInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HistoryInfo.ProtoReflect.Descriptor instead.
+func (*HistoryInfo) Descriptor() ([]byte, []int) {
+	return file_shared_proto_fvnet_fv_network_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *HistoryInfo) GetEnabled() bool {
+	if x != nil {
+		return x.Enabled
+	}
+	return false
+}
+
+func (x *HistoryInfo) GetFirstTime() int64 {
+	if x != nil {
+		return x.FirstTime
+	}
+	return 0
+}
+
+func (x *HistoryInfo) GetLastTime() int64 {
+	if x != nil {
+		return x.LastTime
+	}
+	return 0
+}
+
+func (x *HistoryInfo) GetFirstTick() int64 {
+	if x != nil {
+		return x.FirstTick
+	}
+	return 0
+}
+
+func (x *HistoryInfo) GetLastTick() int64 {
+	if x != nil {
+		return x.LastTick
+	}
+	return 0
+}
+
+func (x *HistoryInfo) GetSnapshots() int64 {
+	if x != nil {
+		return x.Snapshots
+	}
+	return 0
+}
+
+// Mudanças de uma região entre um instante do histórico e o estado atual (ver mapdata.DiffRegion)
+type DiffResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Levels        []*DiffLevel           `protobuf:"bytes,1,rep,name=levels,proto3" json:"levels,omitempty"`
+	Tiles         []*DiffTile            `protobuf:"bytes,2,rep,name=tiles,proto3" json:"tiles,omitempty"`
+	Time          int64                  `protobuf:"varint,3,opt,name=time,proto3" json:"time,omitempty"` // Instante pedido, para o cliente descartar respostas atrasadas
+	Tick          int64                  `protobuf:"varint,4,opt,name=tick,proto3" json:"tick,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DiffResult) Reset() {
+	*x = DiffResult{}
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DiffResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DiffResult) ProtoMessage() {}
+
+func (x *DiffResult) ProtoReflect() protoreflect.Message {
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DiffResult.ProtoReflect.Descriptor instead.
+func (*DiffResult) Descriptor() ([]byte, []int) {
+	return file_shared_proto_fvnet_fv_network_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *DiffResult) GetLevels() []*DiffLevel {
+	if x != nil {
+		return x.Levels
+	}
+	return nil
+}
+
+func (x *DiffResult) GetTiles() []*DiffTile {
+	if x != nil {
+		return x.Tiles
+	}
+	return nil
+}
+
+func (x *DiffResult) GetTime() int64 {
+	if x != nil {
+		return x.Time
+	}
+	return 0
+}
+
+func (x *DiffResult) GetTick() int64 {
+	if x != nil {
+		return x.Tick
+	}
+	return 0
+}
+
+type DiffLevel struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Z             int32                  `protobuf:"varint,1,opt,name=z,proto3" json:"z,omitempty"`
+	Dug           int32                  `protobuf:"varint,2,opt,name=dug,proto3" json:"dug,omitempty"`
+	Constructed   int32                  `protobuf:"varint,3,opt,name=constructed,proto3" json:"constructed,omitempty"`
+	Flooded       int32                  `protobuf:"varint,4,opt,name=flooded,proto3" json:"flooded,omitempty"`
+	Revealed      int32                  `protobuf:"varint,5,opt,name=revealed,proto3" json:"revealed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DiffLevel) Reset() {
+	*x = DiffLevel{}
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DiffLevel) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DiffLevel) ProtoMessage() {}
+
+func (x *DiffLevel) ProtoReflect() protoreflect.Message {
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DiffLevel.ProtoReflect.Descriptor instead.
+func (*DiffLevel) Descriptor() ([]byte, []int) {
+	return file_shared_proto_fvnet_fv_network_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *DiffLevel) GetZ() int32 {
+	if x != nil {
+		return x.Z
+	}
+	return 0
+}
+
+func (x *DiffLevel) GetDug() int32 {
+	if x != nil {
+		return x.Dug
+	}
+	return 0
+}
+
+func (x *DiffLevel) GetConstructed() int32 {
+	if x != nil {
+		return x.Constructed
+	}
+	return 0
+}
+
+func (x *DiffLevel) GetFlooded() int32 {
+	if x != nil {
+		return x.Flooded
+	}
+	return 0
+}
+
+func (x *DiffLevel) GetRevealed() int32 {
+	if x != nil {
+		return x.Revealed
+	}
+	return 0
+}
+
+type DiffTile struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	X             int32                  `protobuf:"varint,1,opt,name=x,proto3" json:"x,omitempty"`
+	Y             int32                  `protobuf:"varint,2,opt,name=y,proto3" json:"y,omitempty"`
+	Z             int32                  `protobuf:"varint,3,opt,name=z,proto3" json:"z,omitempty"`
+	Kind          uint32                 `protobuf:"varint,4,opt,name=kind,proto3" json:"kind,omitempty"` // Bits de mapdata.DiffKind
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DiffTile) Reset() {
+	*x = DiffTile{}
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DiffTile) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DiffTile) ProtoMessage() {}
+
+func (x *DiffTile) ProtoReflect() protoreflect.Message {
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DiffTile.ProtoReflect.Descriptor instead.
+func (*DiffTile) Descriptor() ([]byte, []int) {
+	return file_shared_proto_fvnet_fv_network_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *DiffTile) GetX() int32 {
+	if x != nil {
+		return x.X
+	}
+	return 0
+}
+
+func (x *DiffTile) GetY() int32 {
+	if x != nil {
+		return x.Y
+	}
+	return 0
+}
+
+func (x *DiffTile) GetZ() int32 {
+	if x != nil {
+		return x.Z
+	}
+	return 0
+}
+
+func (x *DiffTile) GetKind() uint32 {
+	if x != nil {
+		return x.Kind
+	}
+	return 0
+}
+
+// Unidade na última posição conhecida (mapdata.UnitInstance). Offline, vem do banco.
+type Creature struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int32                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	RaceType      int32                  `protobuf:"varint,3,opt,name=race_type,json=raceType,proto3" json:"race_type,omitempty"`    // Race.MatType (índice em CreatureRawList)
+	RaceIndex     int32                  `protobuf:"varint,4,opt,name=race_index,json=raceIndex,proto3" json:"race_index,omitempty"` // Race.MatIndex (casta)
+	X             int32                  `protobuf:"varint,5,opt,name=x,proto3" json:"x,omitempty"`
+	Y             int32                  `protobuf:"varint,6,opt,name=y,proto3" json:"y,omitempty"`
+	Z             int32                  `protobuf:"varint,7,opt,name=z,proto3" json:"z,omitempty"`
+	Flags1        uint32                 `protobuf:"varint,8,opt,name=flags1,proto3" json:"flags1,omitempty"`
+	Flags2        uint32                 `protobuf:"varint,9,opt,name=flags2,proto3" json:"flags2,omitempty"`
+	Flags3        uint32                 `protobuf:"varint,10,opt,name=flags3,proto3" json:"flags3,omitempty"`
+	Dead          bool                   `protobuf:"varint,11,opt,name=dead,proto3" json:"dead,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Creature) Reset() {
+	*x = Creature{}
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Creature) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Creature) ProtoMessage() {}
+
+func (x *Creature) ProtoReflect() protoreflect.Message {
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Creature.ProtoReflect.Descriptor instead.
+func (*Creature) Descriptor() ([]byte, []int) {
+	return file_shared_proto_fvnet_fv_network_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *Creature) GetId() int32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Creature) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Creature) GetRaceType() int32 {
+	if x != nil {
+		return x.RaceType
+	}
+	return 0
+}
+
+func (x *Creature) GetRaceIndex() int32 {
+	if x != nil {
+		return x.RaceIndex
+	}
+	return 0
+}
+
+func (x *Creature) GetX() int32 {
+	if x != nil {
+		return x.X
+	}
+	return 0
+}
+
+func (x *Creature) GetY() int32 {
+	if x != nil {
+		return x.Y
+	}
+	return 0
+}
+
+func (x *Creature) GetZ() int32 {
+	if x != nil {
+		return x.Z
+	}
+	return 0
+}
+
+func (x *Creature) GetFlags1() uint32 {
+	if x != nil {
+		return x.Flags1
+	}
+	return 0
+}
+
+func (x *Creature) GetFlags2() uint32 {
+	if x != nil {
+		return x.Flags2
+	}
+	return 0
+}
+
+func (x *Creature) GetFlags3() uint32 {
+	if x != nil {
+		return x.Flags3
+	}
+	return 0
+}
+
+func (x *Creature) GetDead() bool {
+	if x != nil {
+		return x.Dead
+	}
+	return false
+}
+
+type CreatureList struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Creatures     []*Creature            `protobuf:"bytes,1,rep,name=creatures,proto3" json:"creatures,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreatureList) Reset() {
+	*x = CreatureList{}
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreatureList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreatureList) ProtoMessage() {}
+
+func (x *CreatureList) ProtoReflect() protoreflect.Message {
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreatureList.ProtoReflect.Descriptor instead.
+func (*CreatureList) Descriptor() ([]byte, []int) {
+	return file_shared_proto_fvnet_fv_network_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *CreatureList) GetCreatures() []*Creature {
+	if x != nil {
+		return x.Creatures
+	}
+	return nil
+}
+
+// Construção do mapa (mapdata.BuildingInstance), com o tipo já resolvido em BuildingDefList
+type Building struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Index         int32                  `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Type          int32                  `protobuf:"varint,2,opt,name=type,proto3" json:"type,omitempty"` // BuildingType (tipo, subtipo e custom do DF)
+	Subtype       int32                  `protobuf:"varint,3,opt,name=subtype,proto3" json:"subtype,omitempty"`
+	Custom        int32                  `protobuf:"varint,4,opt,name=custom,proto3" json:"custom,omitempty"`
+	TypeId        string                 `protobuf:"bytes,5,opt,name=type_id,json=typeId,proto3" json:"type_id,omitempty"` // BuildingDefinition.id (ex.: "Chair"); vazio se desconhecido
+	Name          string                 `protobuf:"bytes,6,opt,name=name,proto3" json:"name,omitempty"`
+	MatType       int32                  `protobuf:"varint,7,opt,name=mat_type,json=matType,proto3" json:"mat_type,omitempty"`
+	MatIndex      int32                  `protobuf:"varint,8,opt,name=mat_index,json=matIndex,proto3" json:"mat_index,omitempty"`
+	MinX          int32                  `protobuf:"varint,9,opt,name=min_x,json=minX,proto3" json:"min_x,omitempty"`
+	MinY          int32                  `protobuf:"varint,10,opt,name=min_y,json=minY,proto3" json:"min_y,omitempty"`
+	MinZ          int32                  `protobuf:"varint,11,opt,name=min_z,json=minZ,proto3" json:"min_z,omitempty"`
+	MaxX          int32                  `protobuf:"varint,12,opt,name=max_x,json=maxX,proto3" json:"max_x,omitempty"`
+	MaxY          int32                  `protobuf:"varint,13,opt,name=max_y,json=maxY,proto3" json:"max_y,omitempty"`
+	MaxZ          int32                  `protobuf:"varint,14,opt,name=max_z,json=maxZ,proto3" json:"max_z,omitempty"`
+	Direction     int32                  `protobuf:"varint,15,opt,name=direction,proto3" json:"direction,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Building) Reset() {
+	*x = Building{}
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Building) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Building) ProtoMessage() {}
+
+func (x *Building) ProtoReflect() protoreflect.Message {
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Building.ProtoReflect.Descriptor instead.
+func (*Building) Descriptor() ([]byte, []int) {
+	return file_shared_proto_fvnet_fv_network_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *Building) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *Building) GetType() int32 {
+	if x != nil {
+		return x.Type
+	}
+	return 0
+}
+
+func (x *Building) GetSubtype() int32 {
+	if x != nil {
+		return x.Subtype
+	}
+	return 0
+}
+
+func (x *Building) GetCustom() int32 {
+	if x != nil {
+		return x.Custom
+	}
+	return 0
+}
+
+func (x *Building) GetTypeId() string {
+	if x != nil {
+		return x.TypeId
+	}
+	return ""
+}
+
+func (x *Building) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Building) GetMatType() int32 {
+	if x != nil {
+		return x.MatType
+	}
+	return 0
+}
+
+func (x *Building) GetMatIndex() int32 {
+	if x != nil {
+		return x.MatIndex
+	}
+	return 0
+}
+
+func (x *Building) GetMinX() int32 {
+	if x != nil {
+		return x.MinX
+	}
+	return 0
+}
+
+func (x *Building) GetMinY() int32 {
+	if x != nil {
+		return x.MinY
+	}
+	return 0
+}
+
+func (x *Building) GetMinZ() int32 {
+	if x != nil {
+		return x.MinZ
+	}
+	return 0
+}
+
+func (x *Building) GetMaxX() int32 {
+	if x != nil {
+		return x.MaxX
+	}
+	return 0
+}
+
+func (x *Building) GetMaxY() int32 {
+	if x != nil {
+		return x.MaxY
+	}
+	return 0
+}
+
+func (x *Building) GetMaxZ() int32 {
+	if x != nil {
+		return x.MaxZ
+	}
+	return 0
+}
+
+func (x *Building) GetDirection() int32 {
+	if x != nil {
+		return x.Direction
+	}
+	return 0
+}
+
+type BuildingList struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Buildings     []*Building            `protobuf:"bytes,1,rep,name=buildings,proto3" json:"buildings,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BuildingList) Reset() {
+	*x = BuildingList{}
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BuildingList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BuildingList) ProtoMessage() {}
+
+func (x *BuildingList) ProtoReflect() protoreflect.Message {
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BuildingList.ProtoReflect.Descriptor instead.
+func (*BuildingList) Descriptor() ([]byte, []int) {
+	return file_shared_proto_fvnet_fv_network_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *BuildingList) GetBuildings() []*Building {
+	if x != nil {
+		return x.Buildings
+	}
+	return nil
+}
+
+type ServerStatus struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Message       string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	DfConnected   bool                   `protobuf:"varint,2,opt,name=df_connected,json=dfConnected,proto3" json:"df_connected,omitempty"`
+	TrackedUnits  int32                  `protobuf:"varint,3,opt,name=tracked_units,json=trackedUnits,proto3" json:"tracked_units,omitempty"`
+	State         ServerStatus_State     `protobuf:"varint,4,opt,name=state,proto3,enum=fvnet.ServerStatus_State" json:"state,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *Envelope) Reset() {
-	*x = Envelope{}
-	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[0]
+func (x *ServerStatus) Reset() {
+	*x = ServerStatus{}
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[15]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *Envelope) String() string {
+func (x *ServerStatus) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Envelope) ProtoMessage() {}
+func (*ServerStatus) ProtoMessage() {}
 
-func (x *Envelope) ProtoReflect() protoreflect.Message {
-	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[0]
+func (x *ServerStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[15]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -126,50 +1434,67 @@ func (x *Envelope) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Envelope.ProtoReflect.Descriptor instead.
-func (*Envelope) Descriptor() ([]byte, []int) {
-	return file_shared_proto_fvnet_fv_network_proto_rawDescGZIP(), []int{0}
+// Deprecated: Use ServerStatus.ProtoReflect.Descriptor instead.
+func (*ServerStatus) Descriptor() ([]byte, []int) {
+	return file_shared_proto_fvnet_fv_network_proto_rawDescGZIP(), []int{15}
 }
 
-func (x *Envelope) GetType() Envelope_Type {
+func (x *ServerStatus) GetMessage() string {
 	if x != nil {
-		return x.Type
+		return x.Message
 	}
-	return Envelope_PING
+	return ""
 }
 
-func (x *Envelope) GetPayload() []byte {
+func (x *ServerStatus) GetDfConnected() bool {
 	if x != nil {
-		return x.Payload
+		return x.DfConnected
 	}
-	return nil
+	return false
 }
 
-type MapChunkMessage struct {
+func (x *ServerStatus) GetTrackedUnits() int32 {
+	if x != nil {
+		return x.TrackedUnits
+	}
+	return 0
+}
+
+func (x *ServerStatus) GetState() ServerStatus_State {
+	if x != nil {
+		return x.State
+	}
+	return ServerStatus_ONLINE
+}
+
+// Progresso estruturado da varredura total do mapa
+type ScanProgress struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	ChunkX        int32                  `protobuf:"varint,1,opt,name=chunk_x,json=chunkX,proto3" json:"chunk_x,omitempty"`
-	ChunkY        int32                  `protobuf:"varint,2,opt,name=chunk_y,json=chunkY,proto3" json:"chunk_y,omitempty"`
-	ChunkZ        int32                  `protobuf:"varint,3,opt,name=chunk_z,json=chunkZ,proto3" json:"chunk_z,omitempty"`
-	VoxelData     []byte                 `protobuf:"bytes,4,opt,name=voxel_data,json=voxelData,proto3" json:"voxel_data,omitempty"`
+	Phase         ScanProgress_Phase     `protobuf:"varint,1,opt,name=phase,proto3,enum=fvnet.ScanProgress_Phase" json:"phase,omitempty"`
+	Current       int32                  `protobuf:"varint,2,opt,name=current,proto3" json:"current,omitempty"`                                  // Níveis Z concluídos
+	Total         int32                  `protobuf:"varint,3,opt,name=total,proto3" json:"total,omitempty"`                                      // Total de níveis Z do mapa
+	CurrentZ      int32                  `protobuf:"varint,4,opt,name=current_z,json=currentZ,proto3" json:"current_z,omitempty"`                // Nível Z sendo varrido agora
+	BlocksPerSec  float32                `protobuf:"fixed32,5,opt,name=blocks_per_sec,json=blocksPerSec,proto3" json:"blocks_per_sec,omitempty"` // Throughput da varredura
+	EtaSeconds    float32                `protobuf:"fixed32,6,opt,name=eta_seconds,json=etaSeconds,proto3" json:"eta_seconds,omitempty"`         // Estimativa de tempo restante
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *MapChunkMessage) Reset() {
-	*x = MapChunkMessage{}
-	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[1]
+func (x *ScanProgress) Reset() {
+	*x = ScanProgress{}
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[16]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *MapChunkMessage) String() string {
+func (x *ScanProgress) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*MapChunkMessage) ProtoMessage() {}
+func (*ScanProgress) ProtoMessage() {}
 
-func (x *MapChunkMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[1]
+func (x *ScanProgress) ProtoReflect() protoreflect.Message {
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[16]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -180,64 +1505,76 @@ func (x *MapChunkMessage) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use MapChunkMessage.ProtoReflect.Descriptor instead.
-func (*MapChunkMessage) Descriptor() ([]byte, []int) {
-	return file_shared_proto_fvnet_fv_network_proto_rawDescGZIP(), []int{1}
+// Deprecated: Use ScanProgress.ProtoReflect.Descriptor instead.
+func (*ScanProgress) Descriptor() ([]byte, []int) {
+	return file_shared_proto_fvnet_fv_network_proto_rawDescGZIP(), []int{16}
 }
 
-func (x *MapChunkMessage) GetChunkX() int32 {
+func (x *ScanProgress) GetPhase() ScanProgress_Phase {
 	if x != nil {
-		return x.ChunkX
+		return x.Phase
+	}
+	return ScanProgress_IDLE
+}
+
+func (x *ScanProgress) GetCurrent() int32 {
+	if x != nil {
+		return x.Current
 	}
 	return 0
 }
 
-func (x *MapChunkMessage) GetChunkY() int32 {
+func (x *ScanProgress) GetTotal() int32 {
 	if x != nil {
-		return x.ChunkY
+		return x.Total
 	}
 	return 0
 }
 
-func (x *MapChunkMessage) GetChunkZ() int32 {
+func (x *ScanProgress) GetCurrentZ() int32 {
 	if x != nil {
-		return x.ChunkZ
+		return x.CurrentZ
 	}
 	return 0
 }
 
-func (x *MapChunkMessage) GetVoxelData() []byte {
+func (x *ScanProgress) GetBlocksPerSec() float32 {
 	if x != nil {
-		return x.VoxelData
+		return x.BlocksPerSec
 	}
-	return nil
+	return 0
 }
 
-type ClientRequestRegion struct {
+func (x *ScanProgress) GetEtaSeconds() float32 {
+	if x != nil {
+		return x.EtaSeconds
+	}
+	return 0
+}
+
+// Enviado quando o DF carrega outro save/fortaleza: o cliente deve descartar seus caches
+type WorldChanged struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	CenterX       int32                  `protobuf:"varint,1,opt,name=center_x,json=centerX,proto3" json:"center_x,omitempty"`
-	CenterY       int32                  `protobuf:"varint,2,opt,name=center_y,json=centerY,proto3" json:"center_y,omitempty"`
-	CenterZ       int32                  `protobuf:"varint,3,opt,name=center_z,json=centerZ,proto3" json:"center_z,omitempty"`
-	Radius        int32                  `protobuf:"varint,4,opt,name=radius,proto3" json:"radius,omitempty"`
+	WorldName     string                 `protobuf:"bytes,1,opt,name=world_name,json=worldName,proto3" json:"world_name,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ClientRequestRegion) Reset() {
-	*x = ClientRequestRegion{}
-	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[2]
+func (x *WorldChanged) Reset() {
+	*x = WorldChanged{}
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[17]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ClientRequestRegion) String() string {
+func (x *WorldChanged) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ClientRequestRegion) ProtoMessage() {}
+func (*WorldChanged) ProtoMessage() {}
 
-func (x *ClientRequestRegion) ProtoReflect() protoreflect.Message {
-	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[2]
+func (x *WorldChanged) ProtoReflect() protoreflect.Message {
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[17]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -248,63 +1585,160 @@ func (x *ClientRequestRegion) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ClientRequestRegion.ProtoReflect.Descriptor instead.
-func (*ClientRequestRegion) Descriptor() ([]byte, []int) {
-	return file_shared_proto_fvnet_fv_network_proto_rawDescGZIP(), []int{2}
+// Deprecated: Use WorldChanged.ProtoReflect.Descriptor instead.
+func (*WorldChanged) Descriptor() ([]byte, []int) {
+	return file_shared_proto_fvnet_fv_network_proto_rawDescGZIP(), []int{17}
 }
 
-func (x *ClientRequestRegion) GetCenterX() int32 {
+func (x *WorldChanged) GetWorldName() string {
 	if x != nil {
-		return x.CenterX
+		return x.WorldName
+	}
+	return ""
+}
+
+// Metadados de um mundo salvo em saves/ (lidos de WorldMetadata)
+type WorldInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	SizeX         int32                  `protobuf:"varint,2,opt,name=size_x,json=sizeX,proto3" json:"size_x,omitempty"`
+	SizeY         int32                  `protobuf:"varint,3,opt,name=size_y,json=sizeY,proto3" json:"size_y,omitempty"`
+	SizeZ         int32                  `protobuf:"varint,4,opt,name=size_z,json=sizeZ,proto3" json:"size_z,omitempty"`
+	ChunkCount    int64                  `protobuf:"varint,5,opt,name=chunk_count,json=chunkCount,proto3" json:"chunk_count,omitempty"`
+	UpdatedAt     int64                  `protobuf:"varint,6,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"` // Unix (segundos)
+	FormatVersion int32                  `protobuf:"varint,7,opt,name=format_version,json=formatVersion,proto3" json:"format_version,omitempty"`
+	Active        bool                   `protobuf:"varint,8,opt,name=active,proto3" json:"active,omitempty"` // Mundo servido à sessão atual
+	Live          bool                   `protobuf:"varint,9,opt,name=live,proto3" json:"live,omitempty"`     // Mundo ao vivo do servidor (DFHack ou banco padrão)
+	// Muda quando o banco é substituído (cópia restaurada, recriado, demonstração
+	// regerada): as versões de chunk (ChunkVersion.mtime) de outra geração não valem
+	Generation    string `protobuf:"bytes,10,opt,name=generation,proto3" json:"generation,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WorldInfo) Reset() {
+	*x = WorldInfo{}
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WorldInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WorldInfo) ProtoMessage() {}
+
+func (x *WorldInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WorldInfo.ProtoReflect.Descriptor instead.
+func (*WorldInfo) Descriptor() ([]byte, []int) {
+	return file_shared_proto_fvnet_fv_network_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *WorldInfo) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *WorldInfo) GetSizeX() int32 {
+	if x != nil {
+		return x.SizeX
 	}
 	return 0
 }
 
-func (x *ClientRequestRegion) GetCenterY() int32 {
+func (x *WorldInfo) GetSizeY() int32 {
 	if x != nil {
-		return x.CenterY
+		return x.SizeY
 	}
 	return 0
 }
 
-func (x *ClientRequestRegion) GetCenterZ() int32 {
+func (x *WorldInfo) GetSizeZ() int32 {
 	if x != nil {
-		return x.CenterZ
+		return x.SizeZ
 	}
 	return 0
 }
 
-func (x *ClientRequestRegion) GetRadius() int32 {
+func (x *WorldInfo) GetChunkCount() int64 {
 	if x != nil {
-		return x.Radius
+		return x.ChunkCount
 	}
 	return 0
 }
 
-type ServerStatus struct {
+func (x *WorldInfo) GetUpdatedAt() int64 {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return 0
+}
+
+func (x *WorldInfo) GetFormatVersion() int32 {
+	if x != nil {
+		return x.FormatVersion
+	}
+	return 0
+}
+
+func (x *WorldInfo) GetActive() bool {
+	if x != nil {
+		return x.Active
+	}
+	return false
+}
+
+func (x *WorldInfo) GetLive() bool {
+	if x != nil {
+		return x.Live
+	}
+	return false
+}
+
+func (x *WorldInfo) GetGeneration() string {
+	if x != nil {
+		return x.Generation
+	}
+	return ""
+}
+
+type WorldList struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Message       string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
-	DfConnected   bool                   `protobuf:"varint,2,opt,name=df_connected,json=dfConnected,proto3" json:"df_connected,omitempty"`
-	TrackedUnits  int32                  `protobuf:"varint,3,opt,name=tracked_units,json=trackedUnits,proto3" json:"tracked_units,omitempty"`
+	Worlds        []*WorldInfo           `protobuf:"bytes,1,rep,name=worlds,proto3" json:"worlds,omitempty"`
+	Selectable    bool                   `protobuf:"varint,2,opt,name=selectable,proto3" json:"selectable,omitempty"` // false se o servidor não permite trocar o mundo da sessão
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ServerStatus) Reset() {
-	*x = ServerStatus{}
-	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[3]
+func (x *WorldList) Reset() {
+	*x = WorldList{}
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[19]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ServerStatus) String() string {
+func (x *WorldList) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ServerStatus) ProtoMessage() {}
+func (*WorldList) ProtoMessage() {}
 
-func (x *ServerStatus) ProtoReflect() protoreflect.Message {
-	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[3]
+func (x *WorldList) ProtoReflect() protoreflect.Message {
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[19]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -315,30 +1749,67 @@ func (x *ServerStatus) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ServerStatus.ProtoReflect.Descriptor instead.
-func (*ServerStatus) Descriptor() ([]byte, []int) {
-	return file_shared_proto_fvnet_fv_network_proto_rawDescGZIP(), []int{3}
+// Deprecated: Use WorldList.ProtoReflect.Descriptor instead.
+func (*WorldList) Descriptor() ([]byte, []int) {
+	return file_shared_proto_fvnet_fv_network_proto_rawDescGZIP(), []int{19}
 }
 
-func (x *ServerStatus) GetMessage() string {
+func (x *WorldList) GetWorlds() []*WorldInfo {
 	if x != nil {
-		return x.Message
+		return x.Worlds
 	}
-	return ""
+	return nil
 }
 
-func (x *ServerStatus) GetDfConnected() bool {
+func (x *WorldList) GetSelectable() bool {
 	if x != nil {
-		return x.DfConnected
+		return x.Selectable
 	}
 	return false
 }
 
-func (x *ServerStatus) GetTrackedUnits() int32 {
+type SelectWorld struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SelectWorld) Reset() {
+	*x = SelectWorld{}
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SelectWorld) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SelectWorld) ProtoMessage() {}
+
+func (x *SelectWorld) ProtoReflect() protoreflect.Message {
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[20]
 	if x != nil {
-		return x.TrackedUnits
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	return 0
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SelectWorld.ProtoReflect.Descriptor instead.
+func (*SelectWorld) Descriptor() ([]byte, []int) {
+	return file_shared_proto_fvnet_fv_network_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *SelectWorld) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
 }
 
 type WorldStatus struct {
@@ -360,7 +1831,7 @@ type WorldStatus struct {
 
 func (x *WorldStatus) Reset() {
 	*x = WorldStatus{}
-	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[4]
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[21]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -372,7 +1843,7 @@ func (x *WorldStatus) String() string {
 func (*WorldStatus) ProtoMessage() {}
 
 func (x *WorldStatus) ProtoReflect() protoreflect.Message {
-	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[4]
+	mi := &file_shared_proto_fvnet_fv_network_proto_msgTypes[21]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -385,7 +1856,7 @@ func (x *WorldStatus) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use WorldStatus.ProtoReflect.Descriptor instead.
 func (*WorldStatus) Descriptor() ([]byte, []int) {
-	return file_shared_proto_fvnet_fv_network_proto_rawDescGZIP(), []int{4}
+	return file_shared_proto_fvnet_fv_network_proto_rawDescGZIP(), []int{21}
 }
 
 func (x *WorldStatus) GetWorldName() string {
@@ -462,10 +1933,10 @@ var File_shared_proto_fvnet_fv_network_proto protoreflect.FileDescriptor
 
 const file_shared_proto_fvnet_fv_network_proto_rawDesc = "" +
 	"\n" +
-	"#shared/proto/fvnet/fv_network.proto\x12\x05fvnet\"\xce\x01\n" +
+	"#shared/proto/fvnet/fv_network.proto\x12\x05fvnet\"\xd2\x03\n" +
 	"\bEnvelope\x12(\n" +
 	"\x04type\x18\x01 \x01(\x0e2\x14.fvnet.Envelope.TypeR\x04type\x12\x18\n" +
-	"\apayload\x18\x02 \x01(\fR\apayload\"~\n" +
+	"\apayload\x18\x02 \x01(\fR\apayload\"\x81\x03\n" +
 	"\x04Type\x12\b\n" +
 	"\x04PING\x10\x00\x12\b\n" +
 	"\x04PONG\x10\x01\x12\r\n" +
@@ -473,22 +1944,171 @@ const file_shared_proto_fvnet_fv_network_proto_rawDesc = "" +
 	"\x0fCREATURE_UPDATE\x10\x03\x12\x19\n" +
 	"\x15CLIENT_REQUEST_REGION\x10\x04\x12\x11\n" +
 	"\rSERVER_STATUS\x10\x05\x12\x10\n" +
-	"\fWORLD_STATUS\x10\x06\"{\n" +
+	"\fWORLD_STATUS\x10\x06\x12\x15\n" +
+	"\x11VEGETATION_UPDATE\x10\a\x12\x11\n" +
+	"\rTILETYPE_LIST\x10\b\x12\x11\n" +
+	"\rMATERIAL_LIST\x10\t\x12\x11\n" +
+	"\rSCAN_PROGRESS\x10\n" +
+	"\x12\x11\n" +
+	"\rWORLD_CHANGED\x10\v\x12\x0e\n" +
+	"\n" +
+	"WORLD_LIST\x10\f\x12\x10\n" +
+	"\fSELECT_WORLD\x10\r\x12\x10\n" +
+	"\fHISTORY_INFO\x10\x0e\x12\x1a\n" +
+	"\x16CLIENT_REQUEST_HISTORY\x10\x0f\x12\x11\n" +
+	"\rHISTORY_CHUNK\x10\x10\x12\x17\n" +
+	"\x13CLIENT_REQUEST_DIFF\x10\x11\x12\x0f\n" +
+	"\vDIFF_RESULT\x10\x12\x12\x11\n" +
+	"\rBUILDING_LIST\x10\x13\"1\n" +
+	"\x04Ping\x12\x10\n" +
+	"\x03seq\x18\x01 \x01(\rR\x03seq\x12\x17\n" +
+	"\asent_at\x18\x02 \x01(\x03R\x06sentAt\"\x91\x01\n" +
 	"\x0fMapChunkMessage\x12\x17\n" +
 	"\achunk_x\x18\x01 \x01(\x05R\x06chunkX\x12\x17\n" +
 	"\achunk_y\x18\x02 \x01(\x05R\x06chunkY\x12\x17\n" +
 	"\achunk_z\x18\x03 \x01(\x05R\x06chunkZ\x12\x1d\n" +
 	"\n" +
-	"voxel_data\x18\x04 \x01(\fR\tvoxelData\"~\n" +
+	"voxel_data\x18\x04 \x01(\fR\tvoxelData\x12\x14\n" +
+	"\x05mtime\x18\x05 \x01(\x03R\x05mtime\"N\n" +
+	"\fChunkVersion\x12\f\n" +
+	"\x01x\x18\x01 \x01(\x05R\x01x\x12\f\n" +
+	"\x01y\x18\x02 \x01(\x05R\x01y\x12\f\n" +
+	"\x01z\x18\x03 \x01(\x05R\x01z\x12\x14\n" +
+	"\x05mtime\x18\x04 \x01(\x03R\x05mtime\"\x9f\x02\n" +
 	"\x13ClientRequestRegion\x12\x19\n" +
 	"\bcenter_x\x18\x01 \x01(\x05R\acenterX\x12\x19\n" +
 	"\bcenter_y\x18\x02 \x01(\x05R\acenterY\x12\x19\n" +
 	"\bcenter_z\x18\x03 \x01(\x05R\acenterZ\x12\x16\n" +
-	"\x06radius\x18\x04 \x01(\x05R\x06radius\"p\n" +
+	"\x06radius\x18\x04 \x01(\x05R\x06radius\x12)\n" +
+	"\x05known\x18\x05 \x03(\v2\x13.fvnet.ChunkVersionR\x05known\x12\x13\n" +
+	"\x05min_z\x18\x06 \x01(\x05R\x04minZ\x12\x13\n" +
+	"\x05max_z\x18\a \x01(\x05R\x04maxZ\x12*\n" +
+	"\afrustum\x18\b \x03(\v2\x10.fvnet.TilePointR\afrustum\x12\x1e\n" +
+	"\n" +
+	"generation\x18\t \x01(\tR\n" +
+	"generation\"'\n" +
+	"\tTilePoint\x12\f\n" +
+	"\x01x\x18\x01 \x01(\x05R\x01x\x12\f\n" +
+	"\x01y\x18\x02 \x01(\x05R\x01y\"\xa1\x01\n" +
+	"\x0eHistoryRequest\x12\x19\n" +
+	"\bcenter_x\x18\x01 \x01(\x05R\acenterX\x12\x19\n" +
+	"\bcenter_y\x18\x02 \x01(\x05R\acenterY\x12\x19\n" +
+	"\bcenter_z\x18\x03 \x01(\x05R\acenterZ\x12\x16\n" +
+	"\x06radius\x18\x04 \x01(\x05R\x06radius\x12\x12\n" +
+	"\x04time\x18\x05 \x01(\x03R\x04time\x12\x12\n" +
+	"\x04tick\x18\x06 \x01(\x03R\x04tick\"\xbd\x01\n" +
+	"\vHistoryInfo\x12\x18\n" +
+	"\aenabled\x18\x01 \x01(\bR\aenabled\x12\x1d\n" +
+	"\n" +
+	"first_time\x18\x02 \x01(\x03R\tfirstTime\x12\x1b\n" +
+	"\tlast_time\x18\x03 \x01(\x03R\blastTime\x12\x1d\n" +
+	"\n" +
+	"first_tick\x18\x04 \x01(\x03R\tfirstTick\x12\x1b\n" +
+	"\tlast_tick\x18\x05 \x01(\x03R\blastTick\x12\x1c\n" +
+	"\tsnapshots\x18\x06 \x01(\x03R\tsnapshots\"\x85\x01\n" +
+	"\n" +
+	"DiffResult\x12(\n" +
+	"\x06levels\x18\x01 \x03(\v2\x10.fvnet.DiffLevelR\x06levels\x12%\n" +
+	"\x05tiles\x18\x02 \x03(\v2\x0f.fvnet.DiffTileR\x05tiles\x12\x12\n" +
+	"\x04time\x18\x03 \x01(\x03R\x04time\x12\x12\n" +
+	"\x04tick\x18\x04 \x01(\x03R\x04tick\"\x83\x01\n" +
+	"\tDiffLevel\x12\f\n" +
+	"\x01z\x18\x01 \x01(\x05R\x01z\x12\x10\n" +
+	"\x03dug\x18\x02 \x01(\x05R\x03dug\x12 \n" +
+	"\vconstructed\x18\x03 \x01(\x05R\vconstructed\x12\x18\n" +
+	"\aflooded\x18\x04 \x01(\x05R\aflooded\x12\x1a\n" +
+	"\brevealed\x18\x05 \x01(\x05R\brevealed\"H\n" +
+	"\bDiffTile\x12\f\n" +
+	"\x01x\x18\x01 \x01(\x05R\x01x\x12\f\n" +
+	"\x01y\x18\x02 \x01(\x05R\x01y\x12\f\n" +
+	"\x01z\x18\x03 \x01(\x05R\x01z\x12\x12\n" +
+	"\x04kind\x18\x04 \x01(\rR\x04kind\"\xf0\x01\n" +
+	"\bCreature\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x05R\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x1b\n" +
+	"\trace_type\x18\x03 \x01(\x05R\braceType\x12\x1d\n" +
+	"\n" +
+	"race_index\x18\x04 \x01(\x05R\traceIndex\x12\f\n" +
+	"\x01x\x18\x05 \x01(\x05R\x01x\x12\f\n" +
+	"\x01y\x18\x06 \x01(\x05R\x01y\x12\f\n" +
+	"\x01z\x18\a \x01(\x05R\x01z\x12\x16\n" +
+	"\x06flags1\x18\b \x01(\rR\x06flags1\x12\x16\n" +
+	"\x06flags2\x18\t \x01(\rR\x06flags2\x12\x16\n" +
+	"\x06flags3\x18\n" +
+	" \x01(\rR\x06flags3\x12\x12\n" +
+	"\x04dead\x18\v \x01(\bR\x04dead\"=\n" +
+	"\fCreatureList\x12-\n" +
+	"\tcreatures\x18\x01 \x03(\v2\x0f.fvnet.CreatureR\tcreatures\"\xe7\x02\n" +
+	"\bBuilding\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x05R\x05index\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\x05R\x04type\x12\x18\n" +
+	"\asubtype\x18\x03 \x01(\x05R\asubtype\x12\x16\n" +
+	"\x06custom\x18\x04 \x01(\x05R\x06custom\x12\x17\n" +
+	"\atype_id\x18\x05 \x01(\tR\x06typeId\x12\x12\n" +
+	"\x04name\x18\x06 \x01(\tR\x04name\x12\x19\n" +
+	"\bmat_type\x18\a \x01(\x05R\amatType\x12\x1b\n" +
+	"\tmat_index\x18\b \x01(\x05R\bmatIndex\x12\x13\n" +
+	"\x05min_x\x18\t \x01(\x05R\x04minX\x12\x13\n" +
+	"\x05min_y\x18\n" +
+	" \x01(\x05R\x04minY\x12\x13\n" +
+	"\x05min_z\x18\v \x01(\x05R\x04minZ\x12\x13\n" +
+	"\x05max_x\x18\f \x01(\x05R\x04maxX\x12\x13\n" +
+	"\x05max_y\x18\r \x01(\x05R\x04maxY\x12\x13\n" +
+	"\x05max_z\x18\x0e \x01(\x05R\x04maxZ\x12\x1c\n" +
+	"\tdirection\x18\x0f \x01(\x05R\tdirection\"=\n" +
+	"\fBuildingList\x12-\n" +
+	"\tbuildings\x18\x01 \x03(\v2\x0f.fvnet.BuildingR\tbuildings\"\xec\x01\n" +
 	"\fServerStatus\x12\x18\n" +
 	"\amessage\x18\x01 \x01(\tR\amessage\x12!\n" +
 	"\fdf_connected\x18\x02 \x01(\bR\vdfConnected\x12#\n" +
-	"\rtracked_units\x18\x03 \x01(\x05R\ftrackedUnits\"\xe5\x01\n" +
+	"\rtracked_units\x18\x03 \x01(\x05R\ftrackedUnits\x12/\n" +
+	"\x05state\x18\x04 \x01(\x0e2\x19.fvnet.ServerStatus.StateR\x05state\"I\n" +
+	"\x05State\x12\n" +
+	"\n" +
+	"\x06ONLINE\x10\x00\x12\x11\n" +
+	"\rOFFLINE_CACHE\x10\x01\x12\f\n" +
+	"\bSCANNING\x10\x02\x12\x13\n" +
+	"\x0fDF_DISCONNECTED\x10\x03\"\x8a\x02\n" +
+	"\fScanProgress\x12/\n" +
+	"\x05phase\x18\x01 \x01(\x0e2\x19.fvnet.ScanProgress.PhaseR\x05phase\x12\x18\n" +
+	"\acurrent\x18\x02 \x01(\x05R\acurrent\x12\x14\n" +
+	"\x05total\x18\x03 \x01(\x05R\x05total\x12\x1b\n" +
+	"\tcurrent_z\x18\x04 \x01(\x05R\bcurrentZ\x12$\n" +
+	"\x0eblocks_per_sec\x18\x05 \x01(\x02R\fblocksPerSec\x12\x1f\n" +
+	"\veta_seconds\x18\x06 \x01(\x02R\n" +
+	"etaSeconds\"5\n" +
+	"\x05Phase\x12\b\n" +
+	"\x04IDLE\x10\x00\x12\f\n" +
+	"\bSCANNING\x10\x01\x12\n" +
+	"\n" +
+	"\x06PAUSED\x10\x02\x12\b\n" +
+	"\x04DONE\x10\x03\"-\n" +
+	"\fWorldChanged\x12\x1d\n" +
+	"\n" +
+	"world_name\x18\x01 \x01(\tR\tworldName\"\x97\x02\n" +
+	"\tWorldInfo\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x15\n" +
+	"\x06size_x\x18\x02 \x01(\x05R\x05sizeX\x12\x15\n" +
+	"\x06size_y\x18\x03 \x01(\x05R\x05sizeY\x12\x15\n" +
+	"\x06size_z\x18\x04 \x01(\x05R\x05sizeZ\x12\x1f\n" +
+	"\vchunk_count\x18\x05 \x01(\x03R\n" +
+	"chunkCount\x12\x1d\n" +
+	"\n" +
+	"updated_at\x18\x06 \x01(\x03R\tupdatedAt\x12%\n" +
+	"\x0eformat_version\x18\a \x01(\x05R\rformatVersion\x12\x16\n" +
+	"\x06active\x18\b \x01(\bR\x06active\x12\x12\n" +
+	"\x04live\x18\t \x01(\bR\x04live\x12\x1e\n" +
+	"\n" +
+	"generation\x18\n" +
+	" \x01(\tR\n" +
+	"generation\"U\n" +
+	"\tWorldList\x12(\n" +
+	"\x06worlds\x18\x01 \x03(\v2\x10.fvnet.WorldInfoR\x06worlds\x12\x1e\n" +
+	"\n" +
+	"selectable\x18\x02 \x01(\bR\n" +
+	"selectable\"!\n" +
+	"\vSelectWorld\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"\x80\x02\n" +
 	"\vWorldStatus\x12\x1d\n" +
 	"\n" +
 	"world_name\x18\x01 \x01(\tR\tworldName\x12\x12\n" +
@@ -501,7 +2121,9 @@ const file_shared_proto_fvnet_fv_network_proto_rawDesc = "" +
 	"population\x12\x15\n" +
 	"\x06view_x\x18\a \x01(\x05R\x05viewX\x12\x15\n" +
 	"\x06view_y\x18\b \x01(\x05R\x05viewY\x12\x15\n" +
-	"\x06view_z\x18\t \x01(\x05R\x05viewZB#Z!FortressVision/shared/proto/fvnetb\x06proto3"
+	"\x06view_z\x18\t \x01(\x05R\x05viewZ\x12\x19\n" +
+	"\bz_offset\x18\n" +
+	" \x01(\x05R\azOffsetB#Z!FortressVision/shared/proto/fvnetb\x06proto3"
 
 var (
 	file_shared_proto_fvnet_fv_network_proto_rawDescOnce sync.Once
@@ -515,23 +2137,51 @@ func file_shared_proto_fvnet_fv_network_proto_rawDescGZIP() []byte {
 	return file_shared_proto_fvnet_fv_network_proto_rawDescData
 }
 
-var file_shared_proto_fvnet_fv_network_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_shared_proto_fvnet_fv_network_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_shared_proto_fvnet_fv_network_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
+var file_shared_proto_fvnet_fv_network_proto_msgTypes = make([]protoimpl.MessageInfo, 22)
 var file_shared_proto_fvnet_fv_network_proto_goTypes = []any{
 	(Envelope_Type)(0),          // 0: fvnet.Envelope.Type
-	(*Envelope)(nil),            // 1: fvnet.Envelope
-	(*MapChunkMessage)(nil),     // 2: fvnet.MapChunkMessage
-	(*ClientRequestRegion)(nil), // 3: fvnet.ClientRequestRegion
-	(*ServerStatus)(nil),        // 4: fvnet.ServerStatus
-	(*WorldStatus)(nil),         // 5: fvnet.WorldStatus
+	(ServerStatus_State)(0),     // 1: fvnet.ServerStatus.State
+	(ScanProgress_Phase)(0),     // 2: fvnet.ScanProgress.Phase
+	(*Envelope)(nil),            // 3: fvnet.Envelope
+	(*Ping)(nil),                // 4: fvnet.Ping
+	(*MapChunkMessage)(nil),     // 5: fvnet.MapChunkMessage
+	(*ChunkVersion)(nil),        // 6: fvnet.ChunkVersion
+	(*ClientRequestRegion)(nil), // 7: fvnet.ClientRequestRegion
+	(*TilePoint)(nil),           // 8: fvnet.TilePoint
+	(*HistoryRequest)(nil),      // 9: fvnet.HistoryRequest
+	(*HistoryInfo)(nil),         // 10: fvnet.HistoryInfo
+	(*DiffResult)(nil),          // 11: fvnet.DiffResult
+	(*DiffLevel)(nil),           // 12: fvnet.DiffLevel
+	(*DiffTile)(nil),            // 13: fvnet.DiffTile
+	(*Creature)(nil),            // 14: fvnet.Creature
+	(*CreatureList)(nil),        // 15: fvnet.CreatureList
+	(*Building)(nil),            // 16: fvnet.Building
+	(*BuildingList)(nil),        // 17: fvnet.BuildingList
+	(*ServerStatus)(nil),        // 18: fvnet.ServerStatus
+	(*ScanProgress)(nil),        // 19: fvnet.ScanProgress
+	(*WorldChanged)(nil),        // 20: fvnet.WorldChanged
+	(*WorldInfo)(nil),           // 21: fvnet.WorldInfo
+	(*WorldList)(nil),           // 22: fvnet.WorldList
+	(*SelectWorld)(nil),         // 23: fvnet.SelectWorld
+	(*WorldStatus)(nil),         // 24: fvnet.WorldStatus
 }
 var file_shared_proto_fvnet_fv_network_proto_depIdxs = []int32{
-	0, // 0: fvnet.Envelope.type:type_name -> fvnet.Envelope.Type
-	1, // [1:1] is the sub-list for method output_type
-	1, // [1:1] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	0,  // 0: fvnet.Envelope.type:type_name -> fvnet.Envelope.Type
+	6,  // 1: fvnet.ClientRequestRegion.known:type_name -> fvnet.ChunkVersion
+	8,  // 2: fvnet.ClientRequestRegion.frustum:type_name -> fvnet.TilePoint
+	12, // 3: fvnet.DiffResult.levels:type_name -> fvnet.DiffLevel
+	13, // 4: fvnet.DiffResult.tiles:type_name -> fvnet.DiffTile
+	14, // 5: fvnet.CreatureList.creatures:type_name -> fvnet.Creature
+	16, // 6: fvnet.BuildingList.buildings:type_name -> fvnet.Building
+	1,  // 7: fvnet.ServerStatus.state:type_name -> fvnet.ServerStatus.State
+	2,  // 8: fvnet.ScanProgress.phase:type_name -> fvnet.ScanProgress.Phase
+	21, // 9: fvnet.WorldList.worlds:type_name -> fvnet.WorldInfo
+	10, // [10:10] is the sub-list for method output_type
+	10, // [10:10] is the sub-list for method input_type
+	10, // [10:10] is the sub-list for extension type_name
+	10, // [10:10] is the sub-list for extension extendee
+	0,  // [0:10] is the sub-list for field type_name
 }
 
 func init() { file_shared_proto_fvnet_fv_network_proto_init() }
@@ -544,8 +2194,8 @@ func file_shared_proto_fvnet_fv_network_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_shared_proto_fvnet_fv_network_proto_rawDesc), len(file_shared_proto_fvnet_fv_network_proto_rawDesc)),
-			NumEnums:      1,
-			NumMessages:   5,
+			NumEnums:      3,
+			NumMessages:   22,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
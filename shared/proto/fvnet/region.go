@@ -0,0 +1,46 @@
+package fvnet
+
+import (
+	"FortressVision/shared/util"
+)
+
+// MaxRegionLevels limita quantos níveis Z um único pedido de região cobre.
+const MaxRegionLevels = 64
+
+// Levels devolve a faixa de níveis Z pedida, sempre incluindo center_z e cortada
+// em MaxRegionLevels em volta dele. Pedidos sem min_z/max_z (clientes antigos)
+// cobrem só center_z.
+func (r *ClientRequestRegion) Levels() (minZ, maxZ int32) {
+	if r.MinZ == 0 && r.MaxZ == 0 {
+		return r.CenterZ, r.CenterZ
+	}
+	minZ = max(min(r.MinZ, r.CenterZ), r.CenterZ-MaxRegionLevels/2)
+	maxZ = min(max(r.MaxZ, r.CenterZ), r.CenterZ+MaxRegionLevels/2)
+	return minZ, maxZ
+}
+
+// Bounds devolve a caixa de tiles (limites inclusivos) coberta pelo pedido.
+func (r *ClientRequestRegion) Bounds() (min, max util.DFCoord) {
+	minZ, maxZ := r.Levels()
+	min = util.NewDFCoord(r.CenterX-r.Radius, r.CenterY-r.Radius, minZ)
+	max = util.NewDFCoord(r.CenterX+r.Radius, r.CenterY+r.Radius, maxZ)
+	return min, max
+}
+
+// Center devolve o foco do pedido.
+func (r *ClientRequestRegion) Center() util.DFCoord {
+	return util.NewDFCoord(r.CenterX, r.CenterY, r.CenterZ)
+}
+
+// FrustumPolygon devolve o polígono da câmera em coordenadas de tile (Z = center_z),
+// ou nil se o pedido não trouxe um polígono válido.
+func (r *ClientRequestRegion) FrustumPolygon() []util.DFCoord {
+	if len(r.Frustum) < 3 {
+		return nil
+	}
+	polygon := make([]util.DFCoord, len(r.Frustum))
+	for i, p := range r.Frustum {
+		polygon[i] = util.NewDFCoord(p.X, p.Y, r.CenterZ)
+	}
+	return polygon
+}